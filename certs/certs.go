@@ -71,12 +71,17 @@ This mechanism of operation is essentially Trust On First Use (TOFU): if we
 have never seen a certificate for a particular CN, we trust it. If later we see
 a different certificate for that certificate, we return an ErrValidationFailed error.
 
+The first use can be restricted by the trust pinning configuration: if certificate
+IDs or a CA are pinned for this GUN, only the certificates in the root which match
+the pinned IDs or chain up to the pinned CA are used to validate it.  If TOFU is
+disabled and nothing is pinned for this GUN, validation fails.
+
 Note that since we only allow trust data to be downloaded over an HTTPS channel
 we are using the current public PKI to validate the first download of the certificate
 adding an extra layer of security over the normal (SSH style) trust model.
 We shall call this: TOFUS.
 */
-func ValidateRoot(certStore trustmanager.X509Store, root *data.Signed, gun string) error {
+func ValidateRoot(certStore trustmanager.X509Store, root *data.Signed, gun string, trustPinning TrustPinConfig) error {
 	logrus.Debugf("entered ValidateRoot with dns: %s", gun)
 	signedRoot, err := data.RootFromSigned(root)
 	if err != nil {
@@ -113,7 +118,11 @@ func ValidateRoot(certStore trustmanager.X509Store, root *data.Signed, gun strin
 			return &ErrValidationFail{Reason: "failed to validate data with current trusted certificates"}
 		}
 	} else {
-		logrus.Debugf("found no currently valid root certificates for %s", gun)
+		logrus.Debugf("found no currently valid root certificates for %s, using trust_pinning config to bootstrap trust", gun)
+		certsFromRoot, err = pinnedRootCerts(signedRoot, certsFromRoot, gun, trustPinning)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Validate the integrity of the new root (does it have valid signatures)
@@ -126,6 +135,39 @@ func ValidateRoot(certStore trustmanager.X509Store, root *data.Signed, gun strin
 	// Getting here means A) we had trusted certificates and both the
 	// old and new validated this root; or B) we had no trusted certificates but
 	// the new set of certificates has integrity (self-signed)
+	if err := rotateTrustedCerts(certStore, gun, trustedCerts, certsFromRoot); err != nil {
+		return err
+	}
+
+	logrus.Debugf("Root validation succeeded for %s", gun)
+	return nil
+}
+
+// TrustRotatedRoot makes the leaf certificates in root the trusted certificates
+// for the GUN, without checking that root is signed by the currently trusted
+// certificates.  It must only be used for a root which has already been
+// verified some other way, such as by the TUF client checking each version of
+// the root after one which ValidateRoot accepted.
+func TrustRotatedRoot(certStore trustmanager.X509Store, root *data.SignedRoot, gun string) error {
+	certsFromRoot, err := validRootLeafCerts(root, gun)
+	if err != nil {
+		logrus.Debugf("error retrieving valid leaf certificates for: %s, %v", gun, err)
+		return &ErrValidationFail{Reason: "unable to retrieve valid leaf certificates"}
+	}
+
+	trustedCerts, err := certStore.GetCertificatesByCN(gun)
+	if err != nil {
+		if _, ok := err.(*trustmanager.ErrNoCertificatesFound); !ok {
+			logrus.Debugf("error retrieving trusted certificates for: %s, %v", gun, err)
+			return &ErrValidationFail{Reason: "unable to retrieve trusted certificates"}
+		}
+	}
+	return rotateTrustedCerts(certStore, gun, trustedCerts, certsFromRoot)
+}
+
+// rotateTrustedCerts replaces the trusted certificates for the GUN with the
+// certificates from a new root
+func rotateTrustedCerts(certStore trustmanager.X509Store, gun string, trustedCerts, certsFromRoot []*x509.Certificate) error {
 	logrus.Debugf("entering root certificate rotation for: %s", gun)
 
 	// Do root certificate rotation: we trust only the certs present in the new root
@@ -156,11 +198,39 @@ func ValidateRoot(certStore trustmanager.X509Store, root *data.Signed, gun strin
 			return &ErrRootRotationFail{Reason: "failed to rotate root keys"}
 		}
 	}
-
-	logrus.Debugf("Root validation succeeded for %s", gun)
 	return nil
 }
 
+// pinnedRootCerts returns the subset of leafCerts from the root which satisfy
+// the trust pinning configuration for this GUN
+func pinnedRootCerts(root *data.SignedRoot, leafCerts []*x509.Certificate, gun string,
+	trustPinning TrustPinConfig) ([]*x509.Certificate, error) {
+
+	trustPinCheck, err := NewTrustPinChecker(trustPinning, gun)
+	if err != nil {
+		logrus.Debugf("unable to use trust pinning for %s: %v", gun, err)
+		return nil, &ErrValidationFail{Reason: err.Error()}
+	}
+
+	_, allIntCerts := parseAllCerts(root)
+	var pinnedCerts []*x509.Certificate
+	for _, cert := range leafCerts {
+		certID, err := trustmanager.FingerprintCert(cert)
+		if err != nil {
+			continue
+		}
+		if trustPinCheck(cert, allIntCerts[certID]) {
+			pinnedCerts = append(pinnedCerts, cert)
+		}
+	}
+
+	if len(pinnedCerts) == 0 {
+		logrus.Debugf("no root certificates for %s match the trust pinning configuration", gun)
+		return nil, &ErrValidationFail{Reason: "unable to match any certificates to trust_pinning config"}
+	}
+	return pinnedCerts, nil
+}
+
 // validRootLeafCerts returns a list of non-expired, non-sha1 certificates
 // found in root whose Common-Names match the provided GUN. Note that this
 // "validity" alone does not imply any measure of trust.
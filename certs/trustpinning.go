@@ -0,0 +1,132 @@
+package certs
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/notary/trustmanager"
+	"github.com/docker/notary/tuf/utils"
+)
+
+// TrustPinConfig represents the configuration under the trust_pinning section
+// of the client config file.  It determines how trust is bootstrapped the first
+// time the root for a GUN is seen:
+//   - Certs maps a GUN to a list of certificate IDs, one of which the root
+//     must use.
+//   - CA maps a GUN prefix to the path of a PEM bundle of CA certificates.  The
+//     most specific (longest) prefix matching a GUN is used, and the root must
+//     contain a leaf certificate that chains up to one of these CAs.
+//   - DisableTOFU, if set, causes validation to fail if neither Certs nor CA
+//     has an entry for a GUN, instead of trusting the first root seen.
+//
+// Certs takes priority over CA if both match a GUN.
+type TrustPinConfig struct {
+	CA          map[string]string
+	Certs       map[string][]string
+	DisableTOFU bool
+}
+
+// CertChecker is a function that determines whether a leaf certificate, along
+// with the intermediates bundled with it in the root, satisfies the trust pinning
+// configuration for a GUN
+type CertChecker func(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool
+
+type trustPinChecker struct {
+	gun           string
+	pinnedCAPool  *x509.CertPool
+	pinnedCertIDs []string
+}
+
+// NewTrustPinChecker returns a CertChecker for a particular GUN, based on the
+// provided TrustPinConfig.  It returns an error if the configuration does not
+// specify any way of trusting this GUN (i.e. TOFU is disabled and there is no
+// pinned certificate or CA for it), or if the pinned CA bundle is not usable.
+func NewTrustPinChecker(trustPinConfig TrustPinConfig, gun string) (CertChecker, error) {
+	t := trustPinChecker{gun: gun}
+
+	if pinnedCerts, ok := trustPinConfig.Certs[gun]; ok {
+		logrus.Debugf("trust pinning %s to certificate IDs: %s", gun, strings.Join(pinnedCerts, ", "))
+		t.pinnedCertIDs = pinnedCerts
+		return t.certsCheck, nil
+	}
+
+	if caFilepath, ok := pinnedCAFilepathByPrefix(trustPinConfig, gun); ok {
+		logrus.Debugf("trust pinning %s to the CA bundle in %s", gun, caFilepath)
+		caCerts, err := trustmanager.LoadCertBundleFromFile(caFilepath)
+		if err != nil {
+			return nil, fmt.Errorf("could not load pinned CA certificates for %s from %s: %v", gun, caFilepath, err)
+		}
+		caRootPool := x509.NewCertPool()
+		for _, caCert := range caCerts {
+			if err := trustmanager.ValidateCertificate(caCert); err != nil {
+				logrus.Debugf("ignoring invalid pinned CA certificate for %s: %v", gun, err)
+				continue
+			}
+			caRootPool.AddCert(caCert)
+		}
+		if len(caRootPool.Subjects()) == 0 {
+			return nil, fmt.Errorf("no valid pinned CA certificates for %s in %s", gun, caFilepath)
+		}
+		t.pinnedCAPool = caRootPool
+		return t.caCheck, nil
+	}
+
+	if trustPinConfig.DisableTOFU {
+		return nil, fmt.Errorf("trust on first use is disabled and no trust pinning is configured for %s", gun)
+	}
+	return t.tofusCheck, nil
+}
+
+// certsCheck succeeds only if the leaf certificate's ID is one of the pinned IDs
+func (t trustPinChecker) certsCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
+	certID, err := trustmanager.FingerprintCert(leafCert)
+	if err != nil {
+		logrus.Debugf("error while fingerprinting root certificate for %s: %v", t.gun, err)
+		return false
+	}
+	return utils.StrSliceContains(t.pinnedCertIDs, certID)
+}
+
+// caCheck succeeds only if there is a valid chain from the leaf certificate to
+// one of the pinned CAs, possibly through the intermediates bundled with it
+func (t trustPinChecker) caCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
+	caIntPool := x509.NewCertPool()
+	for _, intCert := range intCerts {
+		caIntPool.AddCert(intCert)
+	}
+	_, err := leafCert.Verify(x509.VerifyOptions{
+		Roots:         t.pinnedCAPool,
+		Intermediates: caIntPool,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		logrus.Debugf("unable to find a valid certificate chain from leaf certificate to pinned CA for %s: %v", t.gun, err)
+		return false
+	}
+	return true
+}
+
+// tofusCheck trusts any certificate
+func (t trustPinChecker) tofusCheck(leafCert *x509.Certificate, intCerts []*x509.Certificate) bool {
+	return true
+}
+
+// pinnedCAFilepathByPrefix returns the CA bundle path of the most specific
+// (longest) GUN prefix in the CA configuration that matches the GUN
+func pinnedCAFilepathByPrefix(trustPinConfig TrustPinConfig, gun string) (string, bool) {
+	var (
+		specificPrefix string
+		caFilepath     string
+		found          bool
+	)
+	for gunPrefix, path := range trustPinConfig.CA {
+		if strings.HasPrefix(gun, gunPrefix) && (!found || len(gunPrefix) > len(specificPrefix)) {
+			specificPrefix = gunPrefix
+			caFilepath = path
+			found = true
+		}
+	}
+	return caFilepath, found
+}
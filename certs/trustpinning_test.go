@@ -0,0 +1,263 @@
+package certs
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/notary/cryptoservice"
+	"github.com/docker/notary/trustmanager"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/stretchr/testify/require"
+)
+
+// generateTestCA returns a new self-signed CA certificate and its private key
+func generateTestCA(t *testing.T, commonName string) (*x509.Certificate, data.PrivateKey) {
+	caKey, err := trustmanager.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+
+	startTime := time.Now()
+	template, err := trustmanager.NewCertificate(commonName, startTime, startTime.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+
+	derBytes, err := x509.CreateCertificate(
+		rand.Reader, template, template, caKey.CryptoSigner().Public(), caKey.CryptoSigner())
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	return caCert, caKey
+}
+
+// generateTestLeaf returns a new certificate for the given GUN, signed by the
+// CA certificate and key, and the private key for the certificate
+func generateTestLeaf(t *testing.T, gun string, caCert *x509.Certificate, caKey data.PrivateKey) (
+	*x509.Certificate, data.PrivateKey) {
+
+	leafKey, err := trustmanager.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+
+	startTime := time.Now()
+	template, err := trustmanager.NewCertificate(gun, startTime, startTime.AddDate(1, 0, 0))
+	require.NoError(t, err)
+
+	derBytes, err := x509.CreateCertificate(
+		rand.Reader, template, caCert, leafKey.CryptoSigner().Public(), caKey.CryptoSigner())
+	require.NoError(t, err)
+	leafCert, err := x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	return leafCert, leafKey
+}
+
+// signedRootWithCert returns a root whose root role has a single key, which is
+// the PEM bundle passed in, signed with the provided private key
+func signedRootWithCert(t *testing.T, gun string, pemBundle []byte, privKey data.PrivateKey) *data.Signed {
+	cs := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+	require.NoError(t, cs.AddKey(data.CanonicalRootRole, gun, privKey))
+
+	rootKey := data.NewPublicKey(data.ECDSAx509Key, pemBundle)
+	rootRole, err := data.NewRole(data.CanonicalRootRole, 1, []string{rootKey.ID()}, nil)
+	require.NoError(t, err)
+
+	testRoot, err := data.NewRoot(
+		map[string]data.PublicKey{rootKey.ID(): rootKey},
+		map[string]*data.RootRole{
+			data.CanonicalRootRole:      &rootRole.RootRole,
+			data.CanonicalTimestampRole: &rootRole.RootRole,
+			data.CanonicalTargetsRole:   &rootRole.RootRole,
+			data.CanonicalSnapshotRole:  &rootRole.RootRole},
+		false,
+	)
+	require.NoError(t, err)
+
+	signedTestRoot, err := testRoot.ToSigned()
+	require.NoError(t, err)
+	require.NoError(t, signed.Sign(cs, signedTestRoot, rootKey))
+	return signedTestRoot
+}
+
+// writeCABundle writes the certificates as a PEM bundle to a file in dir
+func writeCABundle(t *testing.T, dir, name string, certs ...*x509.Certificate) string {
+	var bundle []byte
+	for _, cert := range certs {
+		bundle = append(bundle, trustmanager.CertToPEM(cert)...)
+	}
+	caPath := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(caPath, bundle, 0644))
+	return caPath
+}
+
+func newTestCertStore(t *testing.T) (string, trustmanager.X509Store) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	certStore, err := trustmanager.NewX509FileStore(tempBaseDir)
+	require.NoError(t, err)
+	return tempBaseDir, certStore
+}
+
+// If a set of certificate IDs are pinned for a GUN, a root is only trusted on
+// first use if it has one of those certificates.
+func TestValidateRootWithPinnedCert(t *testing.T) {
+	gun := "docker.com/notary"
+
+	privKey, err := trustmanager.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := cryptoservice.GenerateTestingCertificate(privKey.CryptoSigner(), gun)
+	require.NoError(t, err)
+	certID, err := trustmanager.FingerprintCert(cert)
+	require.NoError(t, err)
+	signedTestRoot := signedRootWithCert(t, gun, trustmanager.CertToPEM(cert), privKey)
+
+	// a pinned cert that does not match fails, even if TOFU is not disabled
+	tempBaseDir, certStore := newTestCertStore(t)
+	defer os.RemoveAll(tempBaseDir)
+	err = ValidateRoot(certStore, signedTestRoot, gun,
+		TrustPinConfig{Certs: map[string][]string{gun: {"abc"}}})
+	require.Error(t, err)
+	require.IsType(t, &ErrValidationFail{}, err)
+	require.Empty(t, certStore.GetCertificates())
+
+	// a pinned cert for a different GUN does not help, if TOFU is disabled
+	err = ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+		Certs:       map[string][]string{"docker.com/other": {certID}},
+		DisableTOFU: true,
+	})
+	require.Error(t, err)
+	require.IsType(t, &ErrValidationFail{}, err)
+	require.Empty(t, certStore.GetCertificates())
+
+	// a matching pinned cert succeeds, and the cert is now trusted
+	err = ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+		Certs:       map[string][]string{gun: {"abc", certID}},
+		DisableTOFU: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*x509.Certificate{cert}, certStore.GetCertificates())
+}
+
+// If a CA is pinned for a GUN prefix, a root is only trusted on first use if
+// it has a certificate that chains up to that CA.
+func TestValidateRootWithPinnedCA(t *testing.T) {
+	gun := "docker.com/notary"
+
+	tempBaseDir, certStore := newTestCertStore(t)
+	defer os.RemoveAll(tempBaseDir)
+
+	caCert, caKey := generateTestCA(t, "Notary Testing CA")
+	leafCert, leafKey := generateTestLeaf(t, gun, caCert, caKey)
+	signedTestRoot := signedRootWithCert(t, gun, trustmanager.CertToPEM(leafCert), leafKey)
+
+	otherCACert, _ := generateTestCA(t, "Some Other CA")
+	caPath := writeCABundle(t, tempBaseDir, "ca.crt", caCert)
+	otherCAPath := writeCABundle(t, tempBaseDir, "other-ca.crt", otherCACert)
+
+	// the most specific prefix is used, and it points to the wrong CA
+	err := ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+		CA: map[string]string{"docker.com/": caPath, "docker.com/not": otherCAPath},
+	})
+	require.Error(t, err)
+	require.IsType(t, &ErrValidationFail{}, err)
+	require.Empty(t, certStore.GetCertificates())
+
+	// pinned certs take priority over the CA
+	err = ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+		CA:    map[string]string{"docker.com/": caPath},
+		Certs: map[string][]string{gun: {"abc"}},
+	})
+	require.Error(t, err)
+	require.IsType(t, &ErrValidationFail{}, err)
+	require.Empty(t, certStore.GetCertificates())
+
+	// the most specific prefix is used, and it points to the right CA
+	err = ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+		CA:          map[string]string{"docker.com/notary": caPath, "docker.com/": otherCAPath},
+		DisableTOFU: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []*x509.Certificate{leafCert}, certStore.GetCertificates())
+}
+
+// A pinned CA check can make use of the intermediates bundled with a leaf
+// certificate to build a chain up to the pinned CA.
+func TestTrustPinCheckerCAWithIntermediates(t *testing.T) {
+	gun := "docker.com/notary"
+
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	caCert, caKey := generateTestCA(t, "Notary Testing CA")
+	intCert, intKey := generateTestCA(t, "Notary Testing Intermediate CA")
+	// re-sign the intermediate with the CA
+	derBytes, err := x509.CreateCertificate(
+		rand.Reader, intCert, caCert, intKey.CryptoSigner().Public(), caKey.CryptoSigner())
+	require.NoError(t, err)
+	intCert, err = x509.ParseCertificate(derBytes)
+	require.NoError(t, err)
+	leafCert, _ := generateTestLeaf(t, gun, intCert, intKey)
+
+	checker, err := NewTrustPinChecker(TrustPinConfig{
+		CA: map[string]string{"docker.com": writeCABundle(t, tempBaseDir, "ca.crt", caCert)},
+	}, gun)
+	require.NoError(t, err)
+	require.False(t, checker(leafCert, nil))
+	require.True(t, checker(leafCert, []*x509.Certificate{intCert}))
+}
+
+// If the pinned CA bundle cannot be read, or contains no valid CA certificates,
+// validation fails.
+func TestValidateRootWithInvalidPinnedCA(t *testing.T) {
+	gun := "docker.com/notary"
+
+	tempBaseDir, certStore := newTestCertStore(t)
+	defer os.RemoveAll(tempBaseDir)
+
+	caCert, caKey := generateTestCA(t, "Notary Testing CA")
+	leafCert, leafKey := generateTestLeaf(t, gun, caCert, caKey)
+	signedTestRoot := signedRootWithCert(t, gun, trustmanager.CertToPEM(leafCert), leafKey)
+
+	// the fixture CA certificates have expired
+	for _, caPath := range []string{
+		filepath.Join(tempBaseDir, "nonexistent.crt"),
+		"../fixtures/root-ca.crt",
+	} {
+		err := ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{
+			CA: map[string]string{gun: caPath},
+		})
+		require.Error(t, err)
+		require.IsType(t, &ErrValidationFail{}, err)
+		require.Empty(t, certStore.GetCertificates())
+	}
+}
+
+// If TOFU is disabled and nothing is pinned for a GUN, a root is not trusted on
+// first use.  Trust pinning does not affect roots for GUNs with trusted
+// certificates.
+func TestValidateRootDisableTOFU(t *testing.T) {
+	gun := "docker.com/notary"
+
+	privKey, err := trustmanager.GenerateECDSAKey(rand.Reader)
+	require.NoError(t, err)
+	cert, err := cryptoservice.GenerateTestingCertificate(privKey.CryptoSigner(), gun)
+	require.NoError(t, err)
+	signedTestRoot := signedRootWithCert(t, gun, trustmanager.CertToPEM(cert), privKey)
+
+	tempBaseDir, certStore := newTestCertStore(t)
+	defer os.RemoveAll(tempBaseDir)
+
+	err = ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{DisableTOFU: true})
+	require.Error(t, err)
+	require.IsType(t, &ErrValidationFail{}, err)
+	require.Empty(t, certStore.GetCertificates())
+
+	require.NoError(t, ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{}))
+	require.Len(t, certStore.GetCertificates(), 1)
+
+	require.NoError(t, ValidateRoot(certStore, signedTestRoot, gun, TrustPinConfig{DisableTOFU: true}))
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/notary/tuf"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/docker/notary/tuf/utils"
+)
+
+// Bundle is updated metadata for a repository which has been exported so that
+// it can be signed elsewhere, such as on an offline machine which holds the
+// signing keys, and then published with PublishBundle.
+type Bundle struct {
+	GUN   string       `json:"gun"`
+	Roles []BundleRole `json:"roles"`
+}
+
+// BundleRole is the metadata for one role in a Bundle, along with the sets of
+// keys which must sign it.  The metadata for a role is signed by the role
+// itself, except that a new root must also be signed by the previous root, so
+// it has a BundleSigner for each of them.
+type BundleRole struct {
+	Role    string         `json:"role"`
+	Signers []BundleSigner `json:"signers"`
+	Signed  data.Signed    `json:"signed"`
+}
+
+// BundleSigner is the keys, by TUF key ID, of a role which must sign metadata
+// in a Bundle, and Threshold is the number of them that must.
+type BundleSigner struct {
+	Threshold int       `json:"threshold"`
+	Keys      data.Keys `json:"keys"`
+}
+
+// ErrBundleGUN is returned when a bundle is used with a repository other than
+// the one it was exported from
+type ErrBundleGUN struct {
+	BundleGUN string
+	GUN       string
+}
+
+func (err ErrBundleGUN) Error() string {
+	return fmt.Sprintf("bundle contains metadata for %s, not %s", err.BundleGUN, err.GUN)
+}
+
+// ExportUnsigned applies the changes in the changelist to the latest metadata
+// for this repository, the same way that Publish does.  Rather than signing the
+// updated root and targets metadata, it returns the metadata unsigned in a
+// Bundle, along with the keys that can sign it.  The changelist is not cleared.
+func (r *NotaryRepository) ExportUnsigned() (*Bundle, error) {
+	cl, err := r.GetChangelist()
+	if err != nil {
+		return nil, err
+	}
+	initialPublish, err := r.updateForChangelist(cl)
+	if err != nil {
+		return nil, err
+	}
+	// a new root must also be signed by the current root keys
+	prevRootRole, err := r.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	if err != nil {
+		return nil, err
+	}
+	// the keys for the changed roles are expected to be elsewhere
+	r.tufRepo.AllowUnsignedChanges()
+	if err := applyChangelist(r.tufRepo, cl); err != nil {
+		logrus.Debug("Error applying changelist")
+		return nil, err
+	}
+
+	updated := make(map[string]*data.Signed)
+	// as when publishing, an initial root that has not changed is exported
+	// as it is, already signed
+	if r.rootNearExpiry() || r.tufRepo.Root.Dirty {
+		updated[data.CanonicalRootRole], err = unsignedRole(
+			r.tufRepo, data.CanonicalRootRole, r.expires(data.CanonicalRootRole))
+		if err != nil {
+			return nil, err
+		}
+	} else if initialPublish {
+		if updated[data.CanonicalRootRole], err = r.tufRepo.Root.ToSigned(); err != nil {
+			return nil, err
+		}
+	}
+	for roleName, roleObj := range r.tufRepo.Targets {
+		if roleObj.Dirty || (roleName == data.CanonicalTargetsRole && initialPublish) {
+			if updated[roleName], err = unsignedRole(r.tufRepo, roleName, r.expires(roleName)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	roleNames := make(utils.RoleList, 0, len(updated))
+	for role := range updated {
+		roleNames = append(roleNames, role)
+	}
+	sort.Sort(roleNames)
+
+	bundle := &Bundle{GUN: r.gun, Roles: make([]BundleRole, 0, len(updated))}
+	for _, role := range roleNames {
+		roles, err := r.signingRoles(role, prevRootRole)
+		if err != nil {
+			return nil, err
+		}
+		signers := make([]BundleSigner, 0, len(roles))
+		for _, baseRole := range roles {
+			signers = append(signers, BundleSigner{
+				Threshold: baseRole.Threshold,
+				Keys:      baseRole.Keys,
+			})
+		}
+		bundle.Roles = append(bundle.Roles, BundleRole{
+			Role:    role,
+			Signers: signers,
+			Signed:  *updated[role],
+		})
+	}
+	return bundle, nil
+}
+
+// SignBundle adds signatures to the metadata in a Bundle for this repository,
+// using whichever keys for each role are available locally, and returns the
+// roles that were signed.  Roles for which there are no keys available are
+// left as they are, so that a bundle can be passed between several key holders.
+// No network access is needed, but note that the metadata is signed as it is,
+// without checking it against the repository's trusted metadata.
+func (r *NotaryRepository) SignBundle(bundle *Bundle) ([]string, error) {
+	if bundle.GUN != r.gun {
+		return nil, ErrBundleGUN{BundleGUN: bundle.GUN, GUN: r.gun}
+	}
+
+	var (
+		signedRoles []string
+		allKeyIDs   []string
+	)
+	for i := range bundle.Roles {
+		bundleRole := &bundle.Roles[i]
+		if _, err := parseBundleRole(bundleRole); err != nil {
+			return nil, err
+		}
+		// the previous root may share keys with the new one
+		var keys data.KeyList
+		seen := make(map[string]bool)
+		for _, signer := range bundleRole.Signers {
+			for keyID, key := range signer.Keys {
+				if !seen[keyID] {
+					seen[keyID] = true
+					keys = append(keys, key)
+					allKeyIDs = append(allKeyIDs, keyID)
+				}
+			}
+		}
+		err := signed.Sign(r.CryptoService, &bundleRole.Signed, keys...)
+		if _, ok := err.(signed.ErrNoKeys); ok {
+			logrus.Debugf("no keys available to sign %s", bundleRole.Role)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signedRoles = append(signedRoles, bundleRole.Role)
+	}
+	if len(signedRoles) == 0 && len(bundle.Roles) > 0 {
+		return nil, signed.ErrNoKeys{KeyIDs: allKeyIDs}
+	}
+	return signedRoles, nil
+}
+
+// PublishBundle publishes the metadata in a Bundle for this repository, along
+// with a new snapshot, once every role in it has enough signatures.  Otherwise
+// it returns ErrPartiallySigned.  The changelist is not used.
+func (r *NotaryRepository) PublishBundle(bundle *Bundle) error {
+	if bundle.GUN != r.gun {
+		return ErrBundleGUN{BundleGUN: bundle.GUN, GUN: r.gun}
+	}
+
+	signedFiles := make(map[string][]byte)
+	for i := range bundle.Roles {
+		metaJSON, err := parseBundleRole(&bundle.Roles[i])
+		if err != nil {
+			return err
+		}
+		signedFiles[bundle.Roles[i].Role] = metaJSON
+	}
+	return r.publishSigned(signedFiles, "")
+}
+
+// parseBundleRole checks that the metadata in a BundleRole is valid metadata
+// for its role, and returns it serialized.  The metadata in the BundleRole is
+// replaced with its compacted form, which is what gets signed and published,
+// since a bundle may have been reformatted since it was exported.
+func parseBundleRole(bundleRole *BundleRole) ([]byte, error) {
+	metaJSON, err := json.Marshal(bundleRole.Signed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := parsePartial(bundleRole.Role, metaJSON); err != nil {
+		return nil, err
+	}
+	compacted := data.Signed{}
+	if err := json.Unmarshal(metaJSON, &compacted); err != nil {
+		return nil, err
+	}
+	bundleRole.Signed = compacted
+	return metaJSON, nil
+}
+
+// unsignedRole updates the version and expiry of the metadata for a root or
+// targets role, as signing it would, and returns the metadata without any
+// signatures
+func unsignedRole(tufRepo *tuf.Repo, role string, expires time.Time) (*data.Signed, error) {
+	var (
+		s   *data.Signed
+		err error
+	)
+	switch {
+	case role == data.CanonicalRootRole:
+		tufRepo.Root.Signed.Expires = expires
+		tufRepo.Root.Signed.Version++
+		s, err = tufRepo.Root.ToSigned()
+	case tufRepo.Targets[role] != nil:
+		tufRepo.Targets[role].Signed.Expires = expires
+		tufRepo.Targets[role].Signed.Version++
+		s, err = tufRepo.Targets[role].ToSigned()
+	default:
+		err = fmt.Errorf("%s not supported role to export", role)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Signatures = make([]data.Signature, 0)
+	return s, nil
+}
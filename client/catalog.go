@@ -0,0 +1,71 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// catalogPageSize is the number of GUNs requested from the catalog at a time
+const catalogPageSize = 100
+
+// catalogPage is a page of the catalog returned by the server
+type catalogPage struct {
+	Count        int      `json:"count"`
+	Repositories []string `json:"repositories"`
+	Last         string   `json:"last"`
+}
+
+// ListRepositories returns the GUNs of the trusted collections on the notary
+// server at baseURL that start with prefix, in sorted order.  If the server
+// requires authorization, and rt isn't authorized to list the whole catalog,
+// only the collections that rt is authorized to pull are listed.
+func ListRepositories(baseURL string, rt http.RoundTripper, prefix string) ([]string, error) {
+	catalogURL, err := url.Parse(baseURL + "/v2/_trust/catalog")
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: rt}
+
+	guns := []string{}
+	last := ""
+	for {
+		query := url.Values{}
+		query.Set("records", strconv.Itoa(catalogPageSize))
+		query.Set("last", last)
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		catalogURL.RawQuery = query.Encode()
+
+		page, err := getCatalogPage(client, catalogURL.String())
+		if err != nil {
+			return nil, err
+		}
+		guns = append(guns, page.Repositories...)
+		// pages may be short, or even empty, if the server only lists the
+		// collections rt may pull, so keep going until there are no more
+		if page.Last == "" {
+			return guns, nil
+		}
+		last = page.Last
+	}
+}
+
+func getCatalogPage(client *http.Client, catalogURL string) (*catalogPage, error) {
+	resp, err := client.Get(catalogURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to list repositories: server responded with %s", resp.Status)
+	}
+	var page catalogPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("unable to list repositories: %s", err.Error())
+	}
+	return &page, nil
+}
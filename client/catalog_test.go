@@ -0,0 +1,109 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/notary/cryptoservice"
+	"github.com/docker/notary/server"
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/trustmanager"
+	"github.com/docker/notary/tuf/data"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+// ListRepositories lists the repositories published to the server
+func TestListRepositories(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	for _, gun := range []string{"docker.com/notary", "docker.com/other", "example.com/notary"} {
+		repo, _ := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+		defer os.RemoveAll(repo.baseDir)
+		require.NoError(t, repo.Publish())
+	}
+
+	guns, err := ListRepositories(ts.URL, http.DefaultTransport, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.com/notary", "docker.com/other", "example.com/notary"}, guns)
+
+	guns, err = ListRepositories(ts.URL, http.DefaultTransport, "docker.com/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.com/notary", "docker.com/other"}, guns)
+
+	guns, err = ListRepositories(ts.URL, http.DefaultTransport, "nothing")
+	require.NoError(t, err)
+	require.Len(t, guns, 0)
+}
+
+// ListRepositories pages through the whole catalog
+func TestListRepositoriesPaging(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	expected := make([]string, 0, catalogPageSize*2+1)
+	for i := 0; i < catalogPageSize*2+1; i++ {
+		gun := fmt.Sprintf("gun%d", i)
+		require.NoError(t, metaStore.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalRootRole, Version: 1, Data: []byte(gun)},
+		}))
+		expected = append(expected, gun)
+	}
+	sort.Strings(expected)
+
+	ctx := context.WithValue(context.Background(), "metaStore", metaStore)
+	cryptoService := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+	ts := httptest.NewServer(server.RootHandler(nil, ctx, cryptoService, nil, nil))
+	defer ts.Close()
+
+	guns, err := ListRepositories(ts.URL, http.DefaultTransport, "")
+	require.NoError(t, err)
+	require.Equal(t, expected, guns)
+}
+
+// ListRepositories errors if the server responds with an error
+func TestListRepositoriesServerError(t *testing.T) {
+	ts := errorTestServer(t, http.StatusUnauthorized)
+	defer ts.Close()
+
+	_, err := ListRepositories(ts.URL, http.DefaultTransport, "")
+	require.Error(t, err)
+}
+
+// pullAccessController only authorizes pulls of the GUNs it was given
+type pullAccessController map[string]bool
+
+func (ac pullAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	for _, a := range access {
+		if a.Type != "repository" || a.Action != "pull" || !ac[a.Name] {
+			return nil, fmt.Errorf("not authorized to %s %s", a.Action, a.Name)
+		}
+	}
+	return ctx, nil
+}
+
+// ListRepositories keeps paging through the catalog when the server returns
+// pages without any collections that may be pulled
+func TestListRepositoriesFilteredByAccess(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	for i := 0; i < 2000; i++ {
+		gun := fmt.Sprintf("gun%04d", i)
+		require.NoError(t, metaStore.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalRootRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+	ac := pullAccessController{"gun0000": true, "gun1999": true}
+
+	ctx := context.WithValue(context.Background(), "metaStore", metaStore)
+	cryptoService := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
+	ts := httptest.NewServer(server.RootHandler(ac, ctx, cryptoService, nil, nil))
+	defer ts.Close()
+
+	guns, err := ListRepositories(ts.URL, http.DefaultTransport, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun0000", "gun1999"}, guns)
+}
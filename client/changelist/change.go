@@ -23,6 +23,7 @@ const (
 	TypeRootRole          = "role"
 	TypeTargetsTarget     = "target"
 	TypeTargetsDelegation = "delegation"
+	TypeWitness           = "witness"
 )
 
 // TufChange represents a change to a TUF repo
@@ -33,13 +34,24 @@ type TufChange struct {
 	ChangeType string `json:"type"`
 	ChangePath string `json:"path"`
 	Data       []byte `json:"data"`
+	// Staged against, named so as not to clash with Base
+	StagedOn *ChangeBase `json:"base,omitempty"`
+}
+
+// ChangeBase is what the entry affected by a change was when the change was
+// staged.  For targets, Data is the serialized FileMeta of the target, or nil
+// if the role did not have the target.
+type ChangeBase struct {
+	Data []byte `json:"data,omitempty"`
 }
 
 // TufRootData represents a modification of the keys associated
-// with a role that appears in the root.json
+// with a role that appears in the root.json, and optionally of
+// the role's threshold
 type TufRootData struct {
-	Keys     data.KeyList `json:"keys"`
-	RoleName string       `json:"role"`
+	Keys      data.KeyList `json:"keys"`
+	RoleName  string       `json:"role"`
+	Threshold int          `json:"threshold,omitempty"`
 }
 
 // NewTufChange initializes a tufChange object
@@ -78,6 +90,11 @@ func (c TufChange) Content() []byte {
 	return c.Data
 }
 
+// Base returns c.StagedOn
+func (c TufChange) Base() *ChangeBase {
+	return c.StagedOn
+}
+
 // TufDelegation represents a modification to a target delegation
 // this includes creating a delegations. This format is used to avoid
 // unexpected race conditions between humans modifying the same delegation
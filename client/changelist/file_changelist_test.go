@@ -42,6 +42,33 @@ func TestAdd(t *testing.T) {
 	require.Nil(t, err, "Clear should have left the tmpDir empty")
 
 }
+
+// What a change was staged against is kept, and an unknown base stays unknown
+func TestAddKeepsBase(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("/tmp", "test")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	cl, err := NewFileChangelist(tmpDir)
+	require.NoError(t, err)
+
+	unknown := NewTufChange(ActionCreate, "targets", "target", "unknown", []byte{1})
+	absent := NewTufChange(ActionCreate, "targets", "target", "absent", []byte{1})
+	absent.StagedOn = &ChangeBase{}
+	present := NewTufChange(ActionDelete, "targets", "target", "present", nil)
+	present.StagedOn = &ChangeBase{Data: []byte("{}")}
+	for _, c := range []*TufChange{unknown, absent, present} {
+		require.NoError(t, cl.Add(c))
+	}
+
+	cs := cl.List()
+	require.Len(t, cs, 3)
+	require.Nil(t, cs[0].Base())
+	require.NotNil(t, cs[1].Base())
+	require.Nil(t, cs[1].Base().Data)
+	require.Equal(t, []byte("{}"), cs[2].Base().Data)
+}
+
 func TestErrorConditions(t *testing.T) {
 	tmpDir, err := ioutil.TempDir("/tmp", "test")
 	if err != nil {
@@ -60,6 +60,11 @@ type Change interface {
 	// to be inserted or merged. In the case of a "delete"
 	// action, it will be nil.
 	Content() []byte
+
+	// Base is what the entry affected by the change was when
+	// the change was staged, or nil if that is not known.
+	// For TUF this is only recorded for targets.
+	Base() *ChangeBase
 }
 
 // ChangeIterator is the interface for iterating across collections of
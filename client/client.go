@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -71,8 +72,51 @@ func (err ErrRepositoryNotExist) Error() string {
 	return fmt.Sprintf("%s does not have trust data for %s", err.remote, err.gun)
 }
 
+// ErrPartiallySigned is returned when publishing metadata which does not yet
+// have enough signatures to meet the thresholds of the roles in Roles, using the
+// keys available locally.  The partially signed metadata has been saved in Dir,
+// and can be signed by other key holders with SignPartial.  Dir is empty if the
+// metadata came from a Bundle, which can be signed further with SignBundle.
+type ErrPartiallySigned struct {
+	Roles []string
+	Dir   string
+}
+
+func (err ErrPartiallySigned) Error() string {
+	if err.Dir == "" {
+		return fmt.Sprintf("more signatures are required to publish %s", strings.Join(err.Roles, ", "))
+	}
+	return fmt.Sprintf(
+		"more signatures are required to publish %s: partially signed metadata has been saved in %s",
+		strings.Join(err.Roles, ", "), err.Dir)
+}
+
+// ErrPublishConflict is returned when publishing if someone else published to
+// the repository in the meantime, and some of the changes in the changelist
+// conflict with what they published, for instance because a target that is
+// being updated has been removed.  Nothing is published, and the changelist is
+// kept.
+type ErrPublishConflict struct {
+	Changes []changelist.Change
+}
+
+func (err ErrPublishConflict) Error() string {
+	conflicts := make([]string, 0, len(err.Changes))
+	for _, c := range err.Changes {
+		conflicts = append(conflicts, fmt.Sprintf("%s %s in %s", c.Action(), c.Path(), c.Scope()))
+	}
+	return fmt.Sprintf(
+		"the repository has been changed by someone else, which conflicts with these changes: %s",
+		strings.Join(conflicts, ", "))
+}
+
 const (
-	tufDir = "tuf"
+	tufDir     = "tuf"
+	partialDir = "partial"
+
+	// defaultPublishRetries is the number of times Publish retries by default
+	// if someone else publishes to the repository at the same time
+	defaultPublishRetries = 3
 )
 
 // NotaryRepository stores all the information needed to operate on a notary
@@ -87,13 +131,44 @@ type NotaryRepository struct {
 	tufRepo       *tuf.Repo
 	roundTrip     http.RoundTripper
 	CertStore     trustmanager.X509Store
+	trustPinning  certs.TrustPinConfig
+
+	// PublishRetries is the number of times Publish re-applies the changelist
+	// and tries again if someone else publishes to the repository first
+	PublishRetries int
+
+	// Expiries are how long the metadata for each role is valid for once it
+	// is signed, for the roles that should not use the default expiry times.
+	// Delegation roles without an expiry of their own use the targets role's.
+	Expiries map[string]time.Duration
+}
+
+// expires returns when the metadata for a role should expire if it is signed
+// now
+func (r *NotaryRepository) expires(role string) time.Time {
+	if _, ok := r.Expiries[role]; !ok && data.IsDelegation(role) {
+		role = data.CanonicalTargetsRole
+	}
+	if expiry, ok := r.Expiries[role]; ok {
+		return time.Now().Add(expiry)
+	}
+	return data.DefaultExpires(role)
+}
+
+// rootNearExpiry returns whether less than half of the time the root would be
+// valid for if it were signed now is left before it expires, in which case it
+// is re-signed when publishing
+func (r *NotaryRepository) rootNearExpiry() bool {
+	now := time.Now()
+	halfway := now.Add(r.expires(data.CanonicalRootRole).Sub(now) / 2)
+	return r.tufRepo.Root.Signed.Expires.Before(halfway)
 }
 
 // repositoryFromKeystores is a helper function for NewNotaryRepository that
 // takes some basic NotaryRepository parameters as well as keystores (in order
 // of usage preference), and returns a NotaryRepository.
 func repositoryFromKeystores(baseDir, gun, baseURL string, rt http.RoundTripper,
-	keyStores []trustmanager.KeyStore) (*NotaryRepository, error) {
+	keyStores []trustmanager.KeyStore, trustPinning certs.TrustPinConfig) (*NotaryRepository, error) {
 
 	certPath := filepath.Join(baseDir, notary.TrustedCertsDir)
 	certStore, err := trustmanager.NewX509FilteredFileStore(
@@ -107,13 +182,15 @@ func repositoryFromKeystores(baseDir, gun, baseURL string, rt http.RoundTripper,
 	cryptoService := cryptoservice.NewCryptoService(keyStores...)
 
 	nRepo := &NotaryRepository{
-		gun:           gun,
-		baseDir:       baseDir,
-		baseURL:       baseURL,
-		tufRepoPath:   filepath.Join(baseDir, tufDir, filepath.FromSlash(gun)),
-		CryptoService: cryptoService,
-		roundTrip:     rt,
-		CertStore:     certStore,
+		gun:            gun,
+		baseDir:        baseDir,
+		baseURL:        baseURL,
+		tufRepoPath:    filepath.Join(baseDir, tufDir, filepath.FromSlash(gun)),
+		CryptoService:  cryptoService,
+		roundTrip:      rt,
+		CertStore:      certStore,
+		trustPinning:   trustPinning,
+		PublishRetries: defaultPublishRetries,
 	}
 
 	fileStore, err := store.NewFilesystemStore(
@@ -159,6 +236,33 @@ func NewTarget(targetName string, targetPath string) (*Target, error) {
 	return &Target{Name: targetName, Hashes: meta.Hashes, Length: meta.Length}, nil
 }
 
+// rootCertKey generates a new certificate for the given root private key,
+// and returns it along with the public key that gets stored in the TUF
+// metadata.  The root key is stored X509 encoded, linking the tuf root.json
+// to our X509 PKI.  If the key is RSA, we store it as type RSAx509, if it is
+// ECDSA we store it as ECDSAx509 to allow the gotuf verifiers to correctly
+// decode the key on verification of signatures.
+func rootCertKey(gun string, privKey data.PrivateKey) (*x509.Certificate, data.PublicKey, error) {
+	// Hard-coded policy: the generated certificate expires in 10 years.
+	startTime := time.Now()
+	cert, err := cryptoservice.GenerateCertificate(
+		privKey, gun, startTime, startTime.AddDate(10, 0, 0))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rootKey data.PublicKey
+	switch privKey.Algorithm() {
+	case data.RSAKey:
+		rootKey = data.NewRSAx509PublicKey(trustmanager.CertToPEM(cert))
+	case data.ECDSAKey:
+		rootKey = data.NewECDSAx509PublicKey(trustmanager.CertToPEM(cert))
+	default:
+		return nil, nil, fmt.Errorf("invalid format for root key: %s", privKey.Algorithm())
+	}
+	return cert, rootKey, nil
+}
+
 // Initialize creates a new repository by using rootKey as the root Key for the
 // TUF repository. The server must be reachable (and is asked to generate a
 // timestamp key and possibly other serverManagedRoles), but the created repository
@@ -197,31 +301,12 @@ func (r *NotaryRepository) Initialize(rootKeyID string, serverManagedRoles ...st
 		}
 	}
 
-	// Hard-coded policy: the generated certificate expires in 10 years.
-	startTime := time.Now()
-	rootCert, err := cryptoservice.GenerateCertificate(
-		privKey, r.gun, startTime, startTime.AddDate(10, 0, 0))
-
+	rootCert, rootKey, err := rootCertKey(r.gun, privKey)
 	if err != nil {
 		return err
 	}
 	r.CertStore.AddCert(rootCert)
 
-	// The root key gets stored in the TUF metadata X509 encoded, linking
-	// the tuf root.json to our X509 PKI.
-	// If the key is RSA, we store it as type RSAx509, if it is ECDSA we store it
-	// as ECDSAx509 to allow the gotuf verifiers to correctly decode the
-	// key on verification of signatures.
-	var rootKey data.PublicKey
-	switch privKey.Algorithm() {
-	case data.RSAKey:
-		rootKey = data.NewRSAx509PublicKey(trustmanager.CertToPEM(rootCert))
-	case data.ECDSAKey:
-		rootKey = data.NewECDSAx509PublicKey(trustmanager.CertToPEM(rootCert))
-	default:
-		return fmt.Errorf("invalid format for root key: %s", privKey.Algorithm())
-	}
-
 	var (
 		rootRole = data.NewBaseRole(
 			data.CanonicalRootRole,
@@ -309,17 +394,70 @@ func (r *NotaryRepository) Initialize(rootKeyID string, serverManagedRoles ...st
 
 // adds a TUF Change template to the given roles
 func addChange(cl *changelist.FileChangelist, c changelist.Change, roles ...string) error {
+	changes, err := roleChanges(c, roles...)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := cl.Add(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTargetChange adds a change to a target to the changelist for each of the
+// given roles like addChange, recording what the target was in each role when
+// the change was staged, so that it can be checked for conflicts when publishing
+func (r *NotaryRepository) addTargetChange(cl *changelist.FileChangelist, c changelist.Change, roles ...string) error {
+	changes, err := roleChanges(c, roles...)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		c.StagedOn = r.targetBase(c.Scope(), c.Path())
+		if err := cl.Add(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// targetBase returns what the target is in the local copy of the role's
+// metadata, or nil if there is no local copy
+func (r *NotaryRepository) targetBase(role, path string) *changelist.ChangeBase {
+	targetsJSON, err := r.fileStore.GetMeta(role, -1)
+	if err != nil {
+		return nil
+	}
+	targets := &data.SignedTargets{}
+	if err := json.Unmarshal(targetsJSON, targets); err != nil {
+		return nil
+	}
+	meta, ok := targets.Signed.Targets[path]
+	if !ok {
+		return &changelist.ChangeBase{}
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return nil
+	}
+	return &changelist.ChangeBase{Data: metaJSON}
+}
 
+// roleChanges returns a copy of the change for each of the given roles, which
+// must be targets roles
+func roleChanges(c changelist.Change, roles ...string) ([]*changelist.TufChange, error) {
 	if len(roles) == 0 {
 		roles = []string{data.CanonicalTargetsRole}
 	}
 
-	var changes []changelist.Change
+	var changes []*changelist.TufChange
 	for _, role := range roles {
 		// Ensure we can only add targets to the CanonicalTargetsRole,
 		// or a Delegation role (which is <CanonicalTargetsRole>/something else)
 		if role != data.CanonicalTargetsRole && !data.IsDelegation(role) {
-			return data.ErrInvalidRole{
+			return nil, data.ErrInvalidRole{
 				Role:   role,
 				Reason: "cannot add targets to this role",
 			}
@@ -333,13 +471,7 @@ func addChange(cl *changelist.FileChangelist, c changelist.Change, roles ...stri
 			c.Content(),
 		))
 	}
-
-	for _, c := range changes {
-		if err := cl.Add(c); err != nil {
-			return err
-		}
-	}
-	return nil
+	return changes, nil
 }
 
 // AddTarget creates new changelist entries to add a target to the given roles
@@ -363,6 +495,25 @@ func (r *NotaryRepository) AddTarget(target *Target, roles ...string) error {
 	template := changelist.NewTufChange(
 		changelist.ActionCreate, "", changelist.TypeTargetsTarget,
 		target.Name, metaJSON)
+	return r.addTargetChange(cl, template, roles...)
+}
+
+// Witness creates new changelist entries to re-sign the metadata for the given
+// roles, without changing any of their targets, when the changelist gets
+// applied at publish time.  This brings metadata that has been invalidated, for
+// instance by a key that signed it being removed from the role, or that is
+// about to expire, back up to date.  If roles are unspecified, the default
+// role is "targets".
+func (r *NotaryRepository) Witness(roles ...string) error {
+	cl, err := changelist.NewFileChangelist(filepath.Join(r.tufRepoPath, "changelist"))
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	logrus.Debugf("Witnessing %s", strings.Join(roles, ", "))
+
+	template := changelist.NewTufChange(
+		changelist.ActionUpdate, "", changelist.TypeWitness, "", nil)
 	return addChange(cl, template, roles...)
 }
 
@@ -378,7 +529,7 @@ func (r *NotaryRepository) RemoveTarget(targetName string, roles ...string) erro
 	logrus.Debugf("Removing target \"%s\"", targetName)
 	template := changelist.NewTufChange(changelist.ActionDelete, "",
 		changelist.TypeTargetsTarget, targetName, nil)
-	return addChange(cl, template, roles...)
+	return r.addTargetChange(cl, template, roles...)
 }
 
 // ListTargets lists all targets for the current repository. The list of
@@ -530,52 +681,98 @@ func (r *NotaryRepository) ListRoles() ([]RoleWithSignatures, error) {
 
 // Publish pushes the local changes in signed material to the remote notary-server
 // Conceptually it performs an operation similar to a `git rebase`
+//
+// If any of the metadata to be published does not have enough signatures to meet
+// its role's threshold, nothing is published.  Instead, the partially signed
+// metadata is saved, the changelist is cleared, and ErrPartiallySigned is
+// returned.  Once the other key holders have signed the metadata with
+// SignPartial, and it has been saved again with SavePartial, calling Publish
+// publishes the saved metadata rather than the changelist.
+//
+// If someone else publishes to the repository first, the changelist is applied
+// again on top of what they published and publishing is retried, up to
+// PublishRetries times.  If any of the changes conflict with what they
+// published, ErrPublishConflict is returned and the changelist is kept.
 func (r *NotaryRepository) Publish() error {
+	partial, err := r.loadPartial()
+	if err != nil {
+		return err
+	}
+	if len(partial) > 0 {
+		return r.publishPartial(partial)
+	}
+
 	cl, err := r.GetChangelist()
 	if err != nil {
 		return err
 	}
-	if err = r.publish(cl); err != nil {
+	err = r.publishRebasing(cl)
+	if _, ok := err.(ErrPartiallySigned); err != nil && !ok {
 		return err
 	}
-	if err = cl.Clear(""); err != nil {
+	// the changes in the changelist are now in the partially signed metadata,
+	// if there is any
+	if clearErr := cl.Clear(""); clearErr != nil {
 		// This is not a critical problem when only a single host is pushing
 		// but will cause weird behaviour if changelist cleanup is failing
 		// and there are multiple hosts writing to the repo.
 		logrus.Warn("Unable to clear changelist. You may want to manually delete the folder ", filepath.Join(r.tufRepoPath, "changelist"))
 	}
-	return nil
+	return err
+}
+
+// publishRebasing pushes the changes in the given changelist to the remote
+// notary-server like publish, as long as none of the changes conflict with what
+// someone else has published since they were staged.  If the server rejects
+// them because someone else has published in the meantime, the repository is
+// updated again and the changelist re-applied on top of the newly published
+// metadata, again as long as none of the changes conflict with it.
+func (r *NotaryRepository) publishRebasing(cl changelist.Changelist) error {
+	var previous map[string]data.Files
+	for attempt := 0; ; attempt++ {
+		initialPublish, err := r.updateForChangelist(cl)
+		if err != nil {
+			return err
+		}
+		current := publishedTargets(r.tufRepo)
+		conflicts, err := conflictingChanges(cl, previous, current)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return ErrPublishConflict{Changes: conflicts}
+		}
+
+		err = r.applyAndPublish(cl, initialPublish)
+		if _, ok := err.(store.ErrOldVersion); !ok || attempt >= r.PublishRetries {
+			return err
+		}
+		logrus.Infof("%s has been published to by someone else, applying the changes again (retry %d of %d)",
+			r.gun, attempt+1, r.PublishRetries)
+		previous = current
+	}
 }
 
 // publish pushes the changes in the given changelist to the remote notary-server
 // Conceptually it performs an operation similar to a `git rebase`
 func (r *NotaryRepository) publish(cl changelist.Changelist) error {
-	var initialPublish bool
-	// update first before publishing
-	if err := r.Update(true); err != nil {
-		// If the remote is not aware of the repo, then this is being published
-		// for the first time.  Try to load from disk instead for publishing.
-		if _, ok := err.(ErrRepositoryNotExist); ok {
-			err := r.bootstrapRepo()
-			if err != nil {
-				logrus.Debugf("Unable to load repository from local files: %s",
-					err.Error())
-				if _, ok := err.(store.ErrMetaNotFound); ok {
-					return ErrRepoNotInitialized{}
-				}
-				return err
-			}
-			// Ensure we will push the initial root and targets file.  Either or
-			// both of the root and targets may not be marked as Dirty, since
-			// there may not be any changes that update them, so use a
-			// different boolean.
-			initialPublish = true
-		} else {
-			// We could not update, so we cannot publish.
-			logrus.Error("Could not publish Repository since we could not update: ", err.Error())
-			return err
-		}
+	initialPublish, err := r.updateForChangelist(cl)
+	if err != nil {
+		return err
+	}
+	return r.applyAndPublish(cl, initialPublish)
+}
+
+// applyAndPublish applies the changes in the given changelist to the
+// repository, which has just been updated for publishing, then signs and
+// pushes the changed metadata to the remote notary-server
+func (r *NotaryRepository) applyAndPublish(cl changelist.Changelist, initialPublish bool) error {
+	// a new root must also be signed by the current root keys
+	prevRootRole, err := r.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	if err != nil {
+		return err
 	}
+
 	// apply the changelist to the repo
 	if err := applyChangelist(r.tufRepo, cl); err != nil {
 		logrus.Debug("Error applying changelist")
@@ -589,8 +786,9 @@ func (r *NotaryRepository) publish(cl changelist.Changelist) error {
 	// check if our root file is nearing expiry or dirty. Resign if it is.  If
 	// root is not dirty but we are publishing for the first time, then just
 	// publish the existing root we have.
-	if nearExpiry(r.tufRepo.Root) || r.tufRepo.Root.Dirty {
-		rootJSON, err := serializeCanonicalRole(r.tufRepo, data.CanonicalRootRole)
+	if r.rootNearExpiry() || r.tufRepo.Root.Dirty {
+		rootJSON, err := serializeCanonicalRole(
+			r.tufRepo, data.CanonicalRootRole, r.expires(data.CanonicalRootRole))
 		if err != nil {
 			return err
 		}
@@ -606,7 +804,7 @@ func (r *NotaryRepository) publish(cl changelist.Changelist) error {
 	// iterate through all the targets files - if they are dirty, sign and update
 	for roleName, roleObj := range r.tufRepo.Targets {
 		if roleObj.Dirty || (roleName == data.CanonicalTargetsRole && initialPublish) {
-			targetsJSON, err := serializeCanonicalRole(r.tufRepo, roleName)
+			targetsJSON, err := serializeCanonicalRole(r.tufRepo, roleName, r.expires(roleName))
 			if err != nil {
 				return err
 			}
@@ -614,6 +812,70 @@ func (r *NotaryRepository) publish(cl changelist.Changelist) error {
 		}
 	}
 
+	underSigned, err := r.underSignedRoles(updatedFiles, prevRootRole)
+	if err != nil {
+		return err
+	}
+	if len(underSigned) > 0 {
+		if err := r.savePartial(updatedFiles); err != nil {
+			return err
+		}
+		return ErrPartiallySigned{Roles: underSigned, Dir: filepath.Join(r.tufRepoPath, partialDir)}
+	}
+
+	return r.signSnapshotAndUpload(updatedFiles)
+}
+
+// updateForChangelist updates the repository from the server before the
+// changes in the changelist are applied to it, like updateForPublish.  The
+// metadata of any roles the changelist witnesses is loaded even if it is
+// invalid, so that it can be re-signed.
+func (r *NotaryRepository) updateForChangelist(cl changelist.Changelist) (initialPublish bool, err error) {
+	witnessed, err := witnessedRoles(cl)
+	if err != nil {
+		return false, err
+	}
+	return r.updateForPublish(witnessed...)
+}
+
+// updateForPublish updates the repository from the server before publishing.
+// If the server does not have the repository, it is loaded from the local files
+// instead, and initialPublish is true.  The metadata for any of the witnessed
+// delegation roles which is invalid is loaded as well, to be re-signed.
+func (r *NotaryRepository) updateForPublish(witnessed ...string) (initialPublish bool, err error) {
+	invalid, err := r.update(true, witnessed...)
+	if err != nil {
+		// If the remote is not aware of the repo, then this is being published
+		// for the first time.  Try to load from disk instead for publishing.
+		if _, ok := err.(ErrRepositoryNotExist); ok {
+			err := r.bootstrapRepo()
+			if err != nil {
+				logrus.Debugf("Unable to load repository from local files: %s",
+					err.Error())
+				if _, ok := err.(store.ErrMetaNotFound); ok {
+					return false, ErrRepoNotInitialized{}
+				}
+				return false, err
+			}
+			// Ensure we will push the initial root and targets file.  Either or
+			// both of the root and targets may not be marked as Dirty, since
+			// there may not be any changes that update them, so use a
+			// different boolean.
+			return true, nil
+		}
+		// We could not update, so we cannot publish.
+		logrus.Error("Could not publish Repository since we could not update: ", err.Error())
+		return false, err
+	}
+	for role, signedTargets := range invalid {
+		r.tufRepo.Targets[role] = signedTargets
+	}
+	return false, nil
+}
+
+// signSnapshotAndUpload signs the snapshot, if there is a local snapshot key,
+// and pushes it to the remote notary-server along with the given updated files
+func (r *NotaryRepository) signSnapshotAndUpload(updatedFiles map[string][]byte) error {
 	// if we initialized the repo while designating the server as the snapshot
 	// signer, then there won't be a snapshots file.  However, we might now
 	// have a local key (if there was a rotation), so initialize one.
@@ -624,7 +886,7 @@ func (r *NotaryRepository) publish(cl changelist.Changelist) error {
 	}
 
 	snapshotJSON, err := serializeCanonicalRole(
-		r.tufRepo, data.CanonicalSnapshotRole)
+		r.tufRepo, data.CanonicalSnapshotRole, r.expires(data.CanonicalSnapshotRole))
 
 	if err == nil {
 		// Only update the snapshot if we've successfully signed it.
@@ -645,7 +907,15 @@ func (r *NotaryRepository) publish(cl changelist.Changelist) error {
 		return err
 	}
 
-	return remote.SetMultiMeta(updatedFiles)
+	if err := remote.SetMultiMeta(updatedFiles); err != nil {
+		return err
+	}
+	// keep the local copy of the metadata up to date with what was published,
+	// since changes staged from now on are staged against it
+	if err := r.fileStore.SetMultiMeta(updatedFiles); err != nil {
+		logrus.Debugf("Unable to save the published metadata locally: %s", err.Error())
+	}
+	return nil
 }
 
 // bootstrapRepo loads the repository from the local file system (i.e.
@@ -704,7 +974,8 @@ func (r *NotaryRepository) bootstrapRepo() error {
 func (r *NotaryRepository) saveMetadata(ignoreSnapshot bool) error {
 	logrus.Debugf("Saving changes to Trusted Collection.")
 
-	rootJSON, err := serializeCanonicalRole(r.tufRepo, data.CanonicalRootRole)
+	rootJSON, err := serializeCanonicalRole(
+		r.tufRepo, data.CanonicalRootRole, r.expires(data.CanonicalRootRole))
 	if err != nil {
 		return err
 	}
@@ -715,7 +986,7 @@ func (r *NotaryRepository) saveMetadata(ignoreSnapshot bool) error {
 
 	targetsToSave := make(map[string][]byte)
 	for t := range r.tufRepo.Targets {
-		signedTargets, err := r.tufRepo.SignTargets(t, data.DefaultExpires(data.CanonicalTargetsRole))
+		signedTargets, err := r.tufRepo.SignTargets(t, r.expires(t))
 		if err != nil {
 			return err
 		}
@@ -736,7 +1007,8 @@ func (r *NotaryRepository) saveMetadata(ignoreSnapshot bool) error {
 		return nil
 	}
 
-	snapshotJSON, err := serializeCanonicalRole(r.tufRepo, data.CanonicalSnapshotRole)
+	snapshotJSON, err := serializeCanonicalRole(
+		r.tufRepo, data.CanonicalSnapshotRole, r.expires(data.CanonicalSnapshotRole))
 	if err != nil {
 		return err
 	}
@@ -758,23 +1030,42 @@ func (r *NotaryRepository) errRepositoryNotExist() error {
 // Update bootstraps a trust anchor (root.json) before updating all the
 // metadata from the repo.
 func (r *NotaryRepository) Update(forWrite bool) error {
+	_, err := r.update(forWrite)
+	return err
+}
+
+// update updates the repository like Update, except that the metadata for any
+// of the witnessed delegation roles which is invalid is left out rather than
+// failing the update.  The invalid metadata is returned, so that it can be
+// re-signed.
+func (r *NotaryRepository) update(forWrite bool, witnessed ...string) (map[string]*data.SignedTargets, error) {
 	c, err := r.bootstrapClient(forWrite)
 	if err != nil {
 		if _, ok := err.(store.ErrMetaNotFound); ok {
-			return r.errRepositoryNotExist()
+			return nil, r.errRepositoryNotExist()
 		}
-		return err
+		return nil, err
 	}
+	c.SkipInvalid(witnessed...)
+	bootstrappedVersion := r.tufRepo.Root.Signed.Version
 	if err := c.Update(); err != nil {
 		// notFound.Resource may include a checksum so when the role is root,
 		// it will be root.json or root.<checksum>.json. Therefore best we can
 		// do it match a "root." prefix
 		if notFound, ok := err.(store.ErrMetaNotFound); ok && strings.HasPrefix(notFound.Resource, data.CanonicalRootRole+".") {
-			return r.errRepositoryNotExist()
+			return nil, r.errRepositoryNotExist()
 		}
-		return err
+		return nil, err
 	}
-	return nil
+	// the TUF client only accepts a newer root if it can be verified, one
+	// version at a time, from the root we bootstrapped with, so its
+	// certificates can now be trusted in place of the old ones
+	if r.tufRepo.Root.Signed.Version != bootstrappedVersion {
+		if err := certs.TrustRotatedRoot(r.CertStore, r.tufRepo.Root, r.gun); err != nil {
+			return nil, err
+		}
+	}
+	return c.Invalid(), nil
 }
 
 // bootstrapClient attempts to bootstrap a root.json to be used as the trust
@@ -874,7 +1165,7 @@ func (r *NotaryRepository) validateRoot(rootJSON []byte) (*data.SignedRoot, erro
 		return nil, err
 	}
 
-	err = certs.ValidateRoot(r.CertStore, root, r.gun)
+	err = certs.ValidateRoot(r.CertStore, root, r.gun, r.trustPinning)
 	if err != nil {
 		return nil, err
 	}
@@ -882,11 +1173,64 @@ func (r *NotaryRepository) validateRoot(rootJSON []byte) (*data.SignedRoot, erro
 	return data.RootFromSigned(root)
 }
 
+// SetBaseRoleThreshold stages a change to the root or targets role in a
+// changelist, which adds the given public keys to the role and sets the number
+// of signatures that its metadata requires.  Root keys must be x509
+// certificates for this GUN.  If a threshold is greater than the number of the
+// role's keys available locally, Publish will produce partially signed metadata
+// which the holders of the other keys must sign.
+func (r *NotaryRepository) SetBaseRoleThreshold(role string, threshold int, keys ...data.PublicKey) error {
+	if role != data.CanonicalRootRole && role != data.CanonicalTargetsRole {
+		return data.ErrInvalidRole{Role: role, Reason: "notary only permits setting a threshold for the root and targets roles"}
+	}
+	if threshold < notary.MinThreshold {
+		return data.ErrInvalidRole{Role: role, Reason: fmt.Sprintf("threshold must be at least %d", notary.MinThreshold)}
+	}
+	if role == data.CanonicalRootRole {
+		for _, key := range keys {
+			if key.Algorithm() != data.ECDSAx509Key && key.Algorithm() != data.RSAx509Key {
+				return data.ErrInvalidRole{Role: role, Reason: fmt.Sprintf("root key %s is not an x509 certificate", key.ID())}
+			}
+		}
+	}
+
+	cl, err := changelist.NewFileChangelist(filepath.Join(r.tufRepoPath, "changelist"))
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	metaJSON, err := json.Marshal(changelist.TufRootData{
+		RoleName:  role,
+		Keys:      data.KeyList(keys),
+		Threshold: threshold,
+	})
+	if err != nil {
+		return err
+	}
+	return cl.Add(changelist.NewTufChange(
+		changelist.ActionUpdate,
+		changelist.ScopeRoot,
+		changelist.TypeRootRole,
+		role,
+		metaJSON,
+	))
+}
+
 // RotateKey removes all existing keys associated with the role, and either
 // creates and adds one new key or delegates managing the key to the server.
 // These changes are staged in a changelist until publish is called.
+// Rotating the root key generates a new root key and certificate, and the
+// new root is signed by both the old and new root keys so that clients which
+// trust the old root will accept the new one.
 func (r *NotaryRepository) RotateKey(role string, serverManagesKey bool) error {
 	switch {
+	// We only support locally managing root keys
+	case role == data.CanonicalRootRole && !serverManagesKey:
+		break
+	case role == data.CanonicalRootRole && serverManagesKey:
+		return ErrInvalidRemoteRole{Role: data.CanonicalRootRole}
+
 	// We currently support locally or remotely managing snapshot keys...
 	case role == data.CanonicalSnapshotRole:
 		break
@@ -912,10 +1256,13 @@ func (r *NotaryRepository) RotateKey(role string, serverManagesKey bool) error {
 		err       error
 		errFmtMsg string
 	)
-	switch serverManagesKey {
-	case true:
+	switch {
+	case serverManagesKey:
 		pubKey, err = getRemoteKey(r.baseURL, r.gun, role, r.roundTrip)
 		errFmtMsg = "unable to rotate remote key: %s"
+	case role == data.CanonicalRootRole:
+		pubKey, err = r.createRootCertKey()
+		errFmtMsg = "unable to generate root key: %s"
 	default:
 		pubKey, err = r.CryptoService.Create(role, r.gun, data.ECDSAKey)
 		errFmtMsg = "unable to generate key: %s"
@@ -932,6 +1279,22 @@ func (r *NotaryRepository) RotateKey(role string, serverManagesKey bool) error {
 	return r.publish(cl)
 }
 
+// createRootCertKey creates a new root key, and returns the public key
+// wrapped in a newly generated certificate for this GUN
+func (r *NotaryRepository) createRootCertKey() (data.PublicKey, error) {
+	// This is currently hardcoding the key to ECDSA.
+	pubKey, err := r.CryptoService.Create(data.CanonicalRootRole, "", data.ECDSAKey)
+	if err != nil {
+		return nil, err
+	}
+	privKey, _, err := r.CryptoService.GetPrivateKey(pubKey.ID())
+	if err != nil {
+		return nil, err
+	}
+	_, rootKey, err := rootCertKey(r.gun, privKey)
+	return rootKey, err
+}
+
 func (r *NotaryRepository) rootFileKeyChange(cl changelist.Changelist, role, action string, key data.PublicKey) error {
 	kl := make(data.KeyList, 0, 1)
 	kl = append(kl, key)
@@ -954,9 +1317,23 @@ func (r *NotaryRepository) rootFileKeyChange(cl changelist.Changelist, role, act
 	return cl.Add(c)
 }
 
-// DeleteTrustData removes the trust data stored for this repo in the TUF cache and certificate store on the client side
-func (r *NotaryRepository) DeleteTrustData() error {
-	// Clear TUF files and cache
+// DeleteTrustData removes the trust data stored for this repo in the TUF cache
+// and certificate store on the client side.  The TUF cache is the repo's whole
+// directory, so this includes the changelist and any partially signed metadata.
+// If deleteRemote is true, the repo is first deleted from the server too, which
+// requires push and pull access to it; if that fails, nothing is removed locally.
+func (r *NotaryRepository) DeleteTrustData(deleteRemote bool) error {
+	if deleteRemote {
+		remote, err := getRemoteStore(r.baseURL, r.gun, r.roundTrip)
+		if err != nil {
+			return err
+		}
+		if err := remote.RemoveAll(); err != nil {
+			return fmt.Errorf("error deleting remote trust data: %v", err)
+		}
+	}
+	// Clear TUF files and cache, along with the changelist and partially signed
+	// metadata stored alongside them
 	if err := r.fileStore.RemoveAll(); err != nil {
 		return fmt.Errorf("error clearing TUF repo data: %v", err)
 	}
@@ -25,6 +25,7 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/docker/notary"
+	"github.com/docker/notary/certs"
 	"github.com/docker/notary/client/changelist"
 	"github.com/docker/notary/cryptoservice"
 	"github.com/docker/notary/passphrase"
@@ -124,6 +125,10 @@ func simpleTestServer(t *testing.T, roles ...string) (
 }
 
 func fullTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(fullTestServerHandler(t))
+}
+
+func fullTestServerHandler(t *testing.T) http.Handler {
 	// Set up server
 	ctx := context.WithValue(
 		context.Background(), "metaStore", storage.NewMemStorage())
@@ -140,7 +145,24 @@ func fullTestServer(t *testing.T) *httptest.Server {
 	ctx = ctxu.WithLogger(ctx, logrus.NewEntry(l))
 
 	cryptoService := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
-	return httptest.NewServer(server.RootHandler(nil, ctx, cryptoService, nil, nil))
+	return server.RootHandler(nil, ctx, cryptoService, nil, nil)
+}
+
+// a full test server which, just before an update is posted to it, calls the
+// next function sent on races if there is one - to simulate someone else
+// publishing at the same time
+func racingTestServer(t *testing.T, races chan func()) *httptest.Server {
+	handler := fullTestServerHandler(t)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			select {
+			case race := <-races:
+				race()
+			default:
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
 }
 
 // server that returns some particular error code all the time
@@ -191,7 +213,7 @@ func createRepoAndKey(t *testing.T, rootType, tempBaseDir, gun, url string) (
 
 	rec := newRoleRecorder()
 	repo, err := NewNotaryRepository(
-		tempBaseDir, gun, url, http.DefaultTransport, rec.retriever)
+		tempBaseDir, gun, url, http.DefaultTransport, rec.retriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 
 	rootPubKey, err := repo.CryptoService.Create("root", repo.gun, rootType)
@@ -222,7 +244,7 @@ func newRepoToTestRepo(t *testing.T, existingRepo *NotaryRepository, newDir bool
 	rec := newRoleRecorder()
 	repo, err := NewNotaryRepository(
 		repoDir, existingRepo.gun, existingRepo.baseURL,
-		http.DefaultTransport, rec.retriever)
+		http.DefaultTransport, rec.retriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repository: %s", err)
 	if err != nil && newDir {
 		defer os.RemoveAll(repoDir)
@@ -574,7 +596,7 @@ func testInitRepoAttemptsExceeded(t *testing.T, rootType string) {
 	defer ts.Close()
 
 	retriever := passphrase.ConstantRetriever("password")
-	repo, err := NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever)
+	repo, err := NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 	rootPubKey, err := repo.CryptoService.Create("root", repo.gun, rootType)
 	require.NoError(t, err, "error generating root key: %s", err)
@@ -582,7 +604,7 @@ func testInitRepoAttemptsExceeded(t *testing.T, rootType string) {
 	retriever = passphrase.ConstantRetriever("incorrect password")
 	// repo.CryptoService’s FileKeyStore caches the unlocked private key, so to test
 	// private key unlocking we need a new repo instance.
-	repo, err = NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever)
+	repo, err = NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 	err = repo.Initialize(rootPubKey.ID())
 	require.EqualError(t, err, trustmanager.ErrAttemptsExceeded{}.Error())
@@ -612,14 +634,14 @@ func testInitRepoPasswordInvalid(t *testing.T, rootType string) {
 	defer ts.Close()
 
 	retriever := passphrase.ConstantRetriever("password")
-	repo, err := NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever)
+	repo, err := NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, retriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 	rootPubKey, err := repo.CryptoService.Create("root", repo.gun, rootType)
 	require.NoError(t, err, "error generating root key: %s", err)
 
 	// repo.CryptoService’s FileKeyStore caches the unlocked private key, so to test
 	// private key unlocking we need a new repo instance.
-	repo, err = NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, giveUpPassphraseRetriever)
+	repo, err = NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, giveUpPassphraseRetriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 	err = repo.Initialize(rootPubKey.ID())
 	require.EqualError(t, err, trustmanager.ErrPasswordInvalid{}.Error())
@@ -1641,7 +1663,7 @@ func TestPublishUninitializedRepo(t *testing.T) {
 	defer os.RemoveAll(tempBaseDir)
 
 	repo, err := NewNotaryRepository(tempBaseDir, gun, ts.URL,
-		http.DefaultTransport, passphraseRetriever)
+		http.DefaultTransport, passphraseRetriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repository: %s", err)
 	err = repo.Publish()
 	require.Error(t, err)
@@ -2005,7 +2027,7 @@ func TestPublishSnapshotLocalKeysCreatedFirst(t *testing.T) {
 	defer ts.Close()
 
 	repo, err := NewNotaryRepository(
-		tempBaseDir, gun, ts.URL, http.DefaultTransport, passphraseRetriever)
+		tempBaseDir, gun, ts.URL, http.DefaultTransport, passphraseRetriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 
 	cs := cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphraseRetriever))
@@ -2382,6 +2404,242 @@ func TestPublishTargetsDelegationFromTwoRepos(t *testing.T) {
 	}
 }
 
+// sets up a repository that two clients, which both have the targets key, can
+// publish to, and returns both of them
+func setUpTwoPublishers(t *testing.T, url string) (*NotaryRepository, *NotaryRepository) {
+	repo1, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", url, true)
+	repo2, _ := newRepoToTestRepo(t, repo1, true)
+
+	targetsKeys := repo1.CryptoService.ListKeys(data.CanonicalTargetsRole)
+	require.Len(t, targetsKeys, 1)
+	targetsKey, _, err := repo1.CryptoService.GetPrivateKey(targetsKeys[0])
+	require.NoError(t, err)
+	require.NoError(t, repo2.CryptoService.AddKey(data.CanonicalTargetsRole, repo2.gun, targetsKey))
+
+	addTarget(t, repo1, "current", "../fixtures/root-ca.crt")
+	require.NoError(t, repo1.Publish())
+	return repo1, repo2
+}
+
+// Witnessing a role re-signs and publishes its metadata with a new version,
+// without changing any of its targets
+func TestWitness(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	addTarget(t, repo, "current", "../fixtures/root-ca.crt")
+	require.NoError(t, repo.Publish())
+	version := repo.tufRepo.Targets[data.CanonicalTargetsRole].Signed.Version
+
+	// only targets and delegation roles can be witnessed
+	for _, role := range []string{data.CanonicalRootRole, data.CanonicalSnapshotRole, "invalidrole"} {
+		err := repo.Witness(role)
+		require.Error(t, err)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+	}
+	require.Len(t, getChanges(t, repo), 0)
+
+	require.NoError(t, repo.Witness(data.CanonicalTargetsRole))
+	changes := getChanges(t, repo)
+	require.Len(t, changes, 1)
+	require.Equal(t, changelist.TypeWitness, changes[0].Type())
+	require.Equal(t, data.CanonicalTargetsRole, changes[0].Scope())
+	require.NoError(t, repo.Publish())
+
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	require.NoError(t, fresh.Update(false))
+	targets := fresh.tufRepo.Targets[data.CanonicalTargetsRole].Signed
+	require.Equal(t, version+1, targets.Version)
+	require.Len(t, targets.Targets, 1)
+	_, ok := targets.Targets["current"]
+	require.True(t, ok)
+}
+
+// The metadata for each role is signed to expire after the repository's
+// expiry for the role, if it has one, and delegations without their own
+// expiry use the targets role's
+func TestPublishUsesExpiries(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	repo.Expiries = map[string]time.Duration{
+		data.CanonicalTargetsRole: 7 * notary.Day,
+		data.CanonicalRootRole:    5 * notary.Year,
+	}
+
+	before := time.Now()
+	addTarget(t, repo, "current", "../fixtures/root-ca.crt")
+	// rotating a key re-signs the root
+	require.NoError(t, repo.RotateKey(data.CanonicalTargetsRole, false))
+	require.NoError(t, repo.Publish())
+	after := time.Now()
+
+	requireExpires := func(role string, expires time.Time, expiry time.Duration) {
+		require.False(t, expires.Before(before.Add(expiry).Add(-time.Second)), "%s expires too soon", role)
+		require.False(t, expires.After(after.Add(expiry).Add(time.Second)), "%s expires too late", role)
+	}
+
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	require.NoError(t, fresh.Update(false))
+	requireExpires(data.CanonicalRootRole, fresh.tufRepo.Root.Signed.Expires, 5*notary.Year)
+	requireExpires(data.CanonicalTargetsRole,
+		fresh.tufRepo.Targets[data.CanonicalTargetsRole].Signed.Expires, 7*notary.Day)
+	requireExpires(data.CanonicalSnapshotRole, fresh.tufRepo.Snapshot.Signed.Expires,
+		notary.NotaryDefaultExpiries[data.CanonicalSnapshotRole])
+
+	require.Equal(t, 7*notary.Day, repo.expires("targets/releases").Sub(time.Now()).Round(time.Hour))
+}
+
+// The root is only re-signed when publishing once half the time it is valid
+// for has passed, so with a short root expiry a publish that doesn't change the
+// root doesn't need the root key
+func TestPublishShortRootExpiryWithoutRootKey(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, rootKeyID := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	repo.Expiries = map[string]time.Duration{data.CanonicalRootRole: 30 * notary.Day}
+
+	// rotating a key re-signs the root to expire in 30 days
+	require.NoError(t, repo.RotateKey(data.CanonicalTargetsRole, false))
+	require.NoError(t, repo.Publish())
+	rootVersion := repo.tufRepo.Root.Signed.Version
+
+	require.NoError(t, repo.CryptoService.RemoveKey(rootKeyID))
+	addTarget(t, repo, "current", "../fixtures/root-ca.crt")
+	require.NoError(t, repo.Publish())
+	require.Equal(t, rootVersion, repo.tufRepo.Root.Signed.Version)
+
+	// with a longer root expiry, the root is now near expiry, and can't be
+	// re-signed without the root key
+	repo.Expiries[data.CanonicalRootRole] = 90 * notary.Day
+	addTarget(t, repo, "next", "../fixtures/root-ca.crt")
+	require.Error(t, repo.Publish())
+}
+
+// If someone else publishes while a client is publishing, the client applies
+// its changelist again on top of what they published, and publishes that
+func TestPublishRetriesWhenSomeoneElsePublishes(t *testing.T) {
+	races := make(chan func(), 1)
+	ts := racingTestServer(t, races)
+	defer ts.Close()
+
+	repo1, repo2 := setUpTwoPublishers(t, ts.URL)
+	defer os.RemoveAll(repo1.baseDir)
+	defer os.RemoveAll(repo2.baseDir)
+
+	races <- func() {
+		addTarget(t, repo2, "second", "../fixtures/root-ca.crt")
+		require.NoError(t, repo2.Publish())
+	}
+	addTarget(t, repo1, "first", "../fixtures/root-ca.crt")
+	require.NoError(t, repo1.Publish())
+	require.Len(t, races, 0)
+	require.Len(t, getChanges(t, repo1), 0)
+
+	for _, repo := range []*NotaryRepository{repo1, repo2} {
+		targets, err := repo.ListTargets()
+		require.NoError(t, err)
+		var names []string
+		for _, target := range targets {
+			names = append(names, target.Name)
+		}
+		sort.Strings(names)
+		require.Equal(t, []string{"current", "first", "second"}, names)
+	}
+}
+
+// Once a client has retried publishing PublishRetries times, it gives up and
+// returns the error, keeping the changelist
+func TestPublishGivesUpAfterRetries(t *testing.T) {
+	races := make(chan func(), 1)
+	ts := racingTestServer(t, races)
+	defer ts.Close()
+
+	repo1, repo2 := setUpTwoPublishers(t, ts.URL)
+	defer os.RemoveAll(repo1.baseDir)
+	defer os.RemoveAll(repo2.baseDir)
+
+	races <- func() {
+		addTarget(t, repo2, "second", "../fixtures/root-ca.crt")
+		require.NoError(t, repo2.Publish())
+	}
+	repo1.PublishRetries = 0
+	addTarget(t, repo1, "first", "../fixtures/root-ca.crt")
+	err := repo1.Publish()
+	require.Error(t, err)
+	require.IsType(t, store.ErrOldVersion{}, err)
+	require.Len(t, getChanges(t, repo1), 1)
+}
+
+// If someone else publishes changes that conflict with the changelist while a
+// client is publishing, such as removing a target the client is updating,
+// nothing is published and the conflicting changes are returned
+func TestPublishConflictsWithSomeoneElse(t *testing.T) {
+	races := make(chan func(), 1)
+	ts := racingTestServer(t, races)
+	defer ts.Close()
+
+	repo1, repo2 := setUpTwoPublishers(t, ts.URL)
+	defer os.RemoveAll(repo1.baseDir)
+	defer os.RemoveAll(repo2.baseDir)
+
+	races <- func() {
+		require.NoError(t, repo2.RemoveTarget("current"))
+		require.NoError(t, repo2.Publish())
+	}
+	addTarget(t, repo1, "current", "../fixtures/intermediate-ca.crt")
+	addTarget(t, repo1, "first", "../fixtures/root-ca.crt")
+	err := repo1.Publish()
+	require.Error(t, err)
+	conflict, ok := err.(ErrPublishConflict)
+	require.True(t, ok, "expected a publish conflict but got %v", err)
+	require.Len(t, conflict.Changes, 1)
+	require.Equal(t, "current", conflict.Changes[0].Path())
+	require.Equal(t, changelist.ActionCreate, conflict.Changes[0].Action())
+	require.Len(t, getChanges(t, repo1), 2)
+
+	targets, err := repo2.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 0)
+}
+
+// Changes are checked for conflicts with what someone else published since
+// they were staged, even if that was before the client started publishing
+func TestPublishConflictsWithSomeoneElseBeforePublishing(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo1, repo2 := setUpTwoPublishers(t, ts.URL)
+	defer os.RemoveAll(repo1.baseDir)
+	defer os.RemoveAll(repo2.baseDir)
+
+	addTarget(t, repo1, "current", "../fixtures/intermediate-ca.crt")
+	addTarget(t, repo1, "first", "../fixtures/root-ca.crt")
+	require.NoError(t, repo2.RemoveTarget("current"))
+	require.NoError(t, repo2.Publish())
+
+	err := repo1.Publish()
+	require.Error(t, err)
+	conflict, ok := err.(ErrPublishConflict)
+	require.True(t, ok, "expected a publish conflict but got %v", err)
+	require.Len(t, conflict.Changes, 1)
+	require.Equal(t, "current", conflict.Changes[0].Path())
+	require.Equal(t, changelist.ActionCreate, conflict.Changes[0].Action())
+	require.Len(t, getChanges(t, repo1), 2)
+
+	targets, err := repo2.ListTargets()
+	require.NoError(t, err)
+	require.Len(t, targets, 0)
+}
+
 // A client who could publish before can no longer publish once the owner
 // removes their delegation key from the delegation role.
 func TestPublishRemoveDelegationKeyFromDelegationRole(t *testing.T) {
@@ -2517,18 +2775,17 @@ func TestRotateKeyInvalidRole(t *testing.T) {
 	defer os.RemoveAll(repo.baseDir)
 
 	// the equivalent of: remotely rotating the root key
-	// (RotateKey("root", true)), locally rotating the root key (RotateKey("root", false)),
-	// locally rotating the timestamp key (RotateKey("timestamp", false)),
-	// and remotely rotating the targets key (RotateKey(targets, true)), all of which should
-	// fail
+	// (RotateKey("root", true)), locally rotating the timestamp key
+	// (RotateKey("timestamp", false)), and remotely rotating the targets key
+	// (RotateKey(targets, true)), all of which should fail
 	for _, role := range data.BaseRoles {
 		if role == data.CanonicalSnapshotRole {
 			continue
 		}
 		for _, serverManagesKey := range []bool{true, false} {
-			// we support local rotation of the targets key and remote rotation of the
-			// timestamp key
-			if role == data.CanonicalTargetsRole && !serverManagesKey {
+			// we support local rotation of the root and targets keys and remote
+			// rotation of the timestamp key
+			if (role == data.CanonicalRootRole || role == data.CanonicalTargetsRole) && !serverManagesKey {
 				continue
 			}
 			if role == data.CanonicalTimestampRole && serverManagesKey {
@@ -2704,6 +2961,431 @@ func testRotateKeySuccess(t *testing.T, serverManagesSnapshotInit bool,
 	}
 }
 
+// Rotating the root key generates a new root key and certificate, and publishes
+// a root signed by both the old and the new root keys.  A client that has
+// only ever seen the old root, as well as a brand new client, can both
+// download and validate the rotated root.
+func TestRotateRootKey(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	gun := "docker.com/notary"
+	repo, oldRootKeyID := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	// this client has trusted the old root
+	oldClient, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(oldClient.baseDir)
+	require.NoError(t, oldClient.Update(false))
+
+	oldRootRole, err := repo.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false))
+
+	// the old canonical root key has not been removed from the cryptoservice
+	_, _, err = repo.CryptoService.GetPrivateKey(oldRootKeyID)
+	require.NoError(t, err)
+
+	// a new client that has never seen this repo before
+	newClient, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(newClient.baseDir)
+
+	for _, client := range []*NotaryRepository{repo, oldClient, newClient} {
+		require.NoError(t, client.Update(false))
+
+		newRootRole, err := client.tufRepo.GetBaseRole(data.CanonicalRootRole)
+		require.NoError(t, err)
+		require.Len(t, newRootRole.Keys, 1)
+		for keyID := range oldRootRole.Keys {
+			_, ok := newRootRole.Keys[keyID]
+			require.False(t, ok, "old root key is still in the root role")
+		}
+
+		// the new root is signed by both the old and new root keys
+		rootSigned, err := client.tufRepo.Root.ToSigned()
+		require.NoError(t, err)
+		require.NoError(t, signed.VerifySignatures(rootSigned, oldRootRole))
+		require.NoError(t, signed.VerifySignatures(rootSigned, newRootRole))
+
+		_, err = client.GetTargetByName("latest")
+		require.NoError(t, err)
+	}
+
+	// the next time the old client bootstraps from its cache, the new root
+	// certificate replaces the old one
+	oldClient, _ = newRepoToTestRepo(t, oldClient, false)
+	require.NoError(t, oldClient.Update(false))
+	trustedCerts, err := oldClient.CertStore.GetCertificatesByCN(gun)
+	require.NoError(t, err)
+	require.Len(t, trustedCerts, 1)
+	newRootRole, err := oldClient.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	_, ok := newRootRole.Keys[trustmanager.CertToKey(trustedCerts[0]).ID()]
+	require.True(t, ok, "trusted certificate is not the new root certificate")
+
+	// publishing further changes after the rotation still works
+	addTarget(t, repo, "current", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+	require.NoError(t, oldClient.Update(false))
+	_, err = oldClient.GetTargetByName("current")
+	require.NoError(t, err)
+}
+
+// A client that has only seen a root from several rotations ago can still update,
+// since it verifies each root in between.
+func TestRotateRootKeySeveralTimes(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	require.NoError(t, repo.Publish())
+
+	// this client has trusted the first root
+	oldClient, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(oldClient.baseDir)
+	require.NoError(t, oldClient.Update(false))
+	oldRootRole, err := oldClient.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false))
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false))
+	require.NoError(t, repo.Update(false))
+
+	// the latest root is not signed by the first root's keys
+	rootSigned, err := repo.tufRepo.Root.ToSigned()
+	require.NoError(t, err)
+	require.Error(t, signed.VerifySignatures(rootSigned, oldRootRole))
+
+	require.NoError(t, oldClient.Update(false))
+	require.Equal(t, repo.tufRepo.Root.Signed.Version, oldClient.tufRepo.Root.Signed.Version)
+	_, err = oldClient.GetTargetByName("latest")
+	require.NoError(t, err)
+}
+
+// Only the root and targets roles can have a threshold set, and the threshold
+// must be at least one.  Root keys must be certificates.
+func TestSetBaseRoleThresholdInvalid(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err, "failed to create a temporary directory")
+	defer os.RemoveAll(tempBaseDir)
+
+	repo, _, _ := createRepoAndKey(
+		t, data.ECDSAKey, tempBaseDir, "docker.com/notary", "http://localhost")
+
+	for _, role := range []string{data.CanonicalSnapshotRole, data.CanonicalTimestampRole, "targets/a"} {
+		err := repo.SetBaseRoleThreshold(role, 1)
+		require.Error(t, err)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+	}
+
+	err = repo.SetBaseRoleThreshold(data.CanonicalTargetsRole, 0)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	err = repo.SetBaseRoleThreshold(data.CanonicalRootRole, 2,
+		createKey(t, repo, data.CanonicalRootRole, false))
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	require.Len(t, getChanges(t, repo), 0)
+}
+
+// If the targets threshold is greater than the number of targets keys available
+// locally, publishing saves partially signed metadata instead.  Once another
+// key holder has signed it, the next publish uploads it.
+func TestPublishTargetsThreshold(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+
+	// another key holder, with their own targets key
+	cosigner, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(cosigner.baseDir)
+	cosignerKey := createKey(t, cosigner, data.CanonicalTargetsRole, false)
+
+	require.NoError(t, repo.SetBaseRoleThreshold(data.CanonicalTargetsRole, 2, cosignerKey))
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	err := repo.Publish()
+	require.Error(t, err)
+	require.IsType(t, ErrPartiallySigned{}, err)
+	require.Equal(t, []string{data.CanonicalTargetsRole}, err.(ErrPartiallySigned).Roles)
+	require.Len(t, getChanges(t, repo), 0)
+
+	partialDir := err.(ErrPartiallySigned).Dir
+	rootJSON, err := ioutil.ReadFile(filepath.Join(partialDir, "root.json"))
+	require.NoError(t, err)
+	targetsJSON, err := ioutil.ReadFile(filepath.Join(partialDir, "targets.json"))
+	require.NoError(t, err)
+
+	// publishing again without any more signatures still fails
+	err = repo.Publish()
+	require.Error(t, err)
+	require.IsType(t, ErrPartiallySigned{}, err)
+
+	// the co-signer needs the new root to know that their key is a targets key
+	_, err = cosigner.SignPartial(data.CanonicalTargetsRole, targetsJSON)
+	require.Error(t, err)
+	require.NoError(t, cosigner.SavePartial(data.CanonicalRootRole, rootJSON))
+	targetsJSON, err = cosigner.SignPartial(data.CanonicalTargetsRole, targetsJSON)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SavePartial(data.CanonicalTargetsRole, targetsJSON))
+	require.NoError(t, repo.Publish())
+	_, err = os.Stat(partialDir)
+	require.True(t, os.IsNotExist(err))
+
+	// a new client sees the new threshold and the target
+	newClient, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(newClient.baseDir)
+	_, err = newClient.GetTargetByName("latest")
+	require.NoError(t, err)
+	targetsRole, err := newClient.tufRepo.GetBaseRole(data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.Equal(t, 2, targetsRole.Threshold)
+	require.Len(t, targetsRole.Keys, 2)
+}
+
+// If a delegation's threshold is greater than the number of its keys available
+// locally, publishing saves partially signed metadata for the delegation.  Once
+// another delegation key holder has signed it, the next publish uploads it.
+func TestPublishDelegationThreshold(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, true)
+	defer os.RemoveAll(repo.baseDir)
+
+	// another delegation key holder
+	cosigner, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(cosigner.baseDir)
+
+	delgKey := createKey(t, repo, "targets/releases", false)
+	cosignerKey := createKey(t, cosigner, "targets/releases", false)
+	require.NoError(t, repo.AddDelegation(
+		"targets/releases", []data.PublicKey{delgKey, cosignerKey}, []string{""}))
+	require.NoError(t, repo.SetDelegationThreshold("targets/releases", 2))
+	require.NoError(t, repo.Publish())
+
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt", "targets/releases")
+	err := repo.Publish()
+	require.Error(t, err)
+	require.IsType(t, ErrPartiallySigned{}, err)
+	require.Equal(t, []string{"targets/releases"}, err.(ErrPartiallySigned).Roles)
+
+	delgJSON, err := ioutil.ReadFile(
+		filepath.Join(err.(ErrPartiallySigned).Dir, "targets", "releases.json"))
+	require.NoError(t, err)
+	delgJSON, err = cosigner.SignPartial("targets/releases", delgJSON)
+	require.NoError(t, err)
+
+	s := &data.Signed{}
+	require.NoError(t, json.Unmarshal(delgJSON, s))
+	require.Len(t, s.Signatures, 2)
+	delgRole, err := cosigner.tufRepo.GetDelegationRole("targets/releases")
+	require.NoError(t, err)
+	require.Equal(t, 2, delgRole.Threshold)
+	require.NoError(t, signed.VerifySignatures(s, delgRole.BaseRole))
+
+	require.NoError(t, repo.SavePartial("targets/releases", delgJSON))
+	require.NoError(t, repo.Publish())
+
+	newClient, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(newClient.baseDir)
+	_, err = newClient.GetTargetByName("latest", "targets/releases")
+	require.NoError(t, err)
+}
+
+// SetDelegationThreshold only accepts delegation roles and thresholds of at
+// least one, and the changelist it produces sets the delegation's threshold
+// without changing its keys or paths
+func TestSetDelegationThreshold(t *testing.T) {
+	gun := "docker.com/notary"
+	ts, _, _ := simpleTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+
+	err := repo.SetDelegationThreshold(data.CanonicalTargetsRole, 2)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	err = repo.SetDelegationThreshold("targets/a", 0)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	require.Empty(t, getChanges(t, repo))
+
+	delgKey := createKey(t, repo, "targets/a", false)
+	delgKey2 := createKey(t, repo, "targets/a", false)
+	require.NoError(t, repo.AddDelegation("targets/a", []data.PublicKey{delgKey, delgKey2}, []string{""}))
+	require.NoError(t, repo.SetDelegationThreshold("targets/a", 2))
+
+	changes := getChanges(t, repo)
+	require.Len(t, changes, 3)
+	require.Equal(t, changelist.ActionUpdate, changes[2].Action())
+	require.Equal(t, "targets/a", changes[2].Scope())
+	require.Equal(t, changelist.TypeTargetsDelegation, changes[2].Type())
+
+	cl, err := repo.GetChangelist()
+	require.NoError(t, err)
+	require.NoError(t, applyChangelist(repo.tufRepo, cl))
+
+	delgRole, err := repo.tufRepo.GetDelegationRole("targets/a")
+	require.NoError(t, err)
+	require.Equal(t, 2, delgRole.Threshold)
+	require.Len(t, delgRole.Keys, 2)
+	require.Equal(t, []string{""}, delgRole.Paths)
+}
+
+// A client without any signing keys can export its changes as an unsigned
+// bundle, which a client with the keys signs without talking to the server, and
+// the first client then publishes.
+func TestExportSignAndPublishBundle(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	offline, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, true)
+	defer os.RemoveAll(offline.baseDir)
+	require.NoError(t, offline.Publish())
+
+	online, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(online.baseDir)
+	require.Empty(t, online.CryptoService.ListAllKeys())
+
+	addTarget(t, online, "latest", "../fixtures/intermediate-ca.crt")
+	bundle, err := online.ExportUnsigned()
+	require.NoError(t, err)
+	require.Equal(t, "docker.com/notary", bundle.GUN)
+	require.Len(t, bundle.Roles, 1)
+	require.Equal(t, data.CanonicalTargetsRole, bundle.Roles[0].Role)
+	require.Len(t, bundle.Roles[0].Signers, 1)
+	require.Equal(t, 1, bundle.Roles[0].Signers[0].Threshold)
+	require.Empty(t, bundle.Roles[0].Signed.Signatures)
+	targetsKeyIDs := offline.CryptoService.ListKeys(data.CanonicalTargetsRole)
+	require.Len(t, targetsKeyIDs, 1)
+	_, ok := bundle.Roles[0].Signers[0].Keys[targetsKeyIDs[0]]
+	require.True(t, ok)
+
+	// the bundle is passed around as JSON, which may be reformatted
+	roundTrip := func(b *Bundle) *Bundle {
+		bundleJSON, err := json.MarshalIndent(b, "", "  ")
+		require.NoError(t, err)
+		parsed := &Bundle{}
+		require.NoError(t, json.Unmarshal(bundleJSON, parsed))
+		return parsed
+	}
+	bundle = roundTrip(bundle)
+
+	err = online.PublishBundle(bundle)
+	require.Error(t, err)
+	require.IsType(t, ErrPartiallySigned{}, err)
+	require.Equal(t, []string{data.CanonicalTargetsRole}, err.(ErrPartiallySigned).Roles)
+
+	_, err = online.SignBundle(bundle)
+	require.Error(t, err)
+	require.IsType(t, signed.ErrNoKeys{}, err)
+
+	otherRepo, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(otherRepo.baseDir)
+	otherRepo.gun = "docker.com/other"
+	_, err = otherRepo.SignBundle(bundle)
+	require.Error(t, err)
+	require.IsType(t, ErrBundleGUN{}, err)
+
+	signedRoles, err := offline.SignBundle(bundle)
+	require.NoError(t, err)
+	require.Equal(t, []string{data.CanonicalTargetsRole}, signedRoles)
+	bundle = roundTrip(bundle)
+
+	require.NoError(t, online.PublishBundle(bundle))
+
+	newClient, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(newClient.baseDir)
+	_, err = newClient.GetTargetByName("latest")
+	require.NoError(t, err)
+}
+
+// A bundle with a new root lists the keys and threshold of both the new root
+// and the previous root, each of which the root must meet before it can be
+// published.
+func TestExportBundleRootThreshold(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	offline, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL,true)
+	defer os.RemoveAll(offline.baseDir)
+	require.NoError(t, offline.Publish())
+	rootKeyIDs := offline.CryptoService.ListKeys(data.CanonicalRootRole)
+	require.Len(t, rootKeyIDs, 1)
+	prevRootRole, err := offline.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+
+	// another root key holder, whose key is added to the root along with
+	// raising its threshold to two
+	cosigner, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(cosigner.baseDir)
+	cosignerKey, err := cosigner.CryptoService.Create(data.CanonicalRootRole, cosigner.gun, data.ECDSAKey)
+	require.NoError(t, err)
+	privKey, _, err := cosigner.CryptoService.GetPrivateKey(cosignerKey.ID())
+	require.NoError(t, err)
+	start := time.Now().AddDate(0, 0, -1)
+	cert, err := cryptoservice.GenerateCertificate(privKey, cosigner.gun, start, start.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	cosignerCert := data.NewECDSAx509PublicKey(trustmanager.CertToPEM(cert))
+
+	online, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(online.baseDir)
+	require.NoError(t, online.SetBaseRoleThreshold(data.CanonicalRootRole, 2, cosignerCert))
+	bundle, err := online.ExportUnsigned()
+	require.NoError(t, err)
+	require.Len(t, bundle.Roles, 1)
+	require.Equal(t, data.CanonicalRootRole, bundle.Roles[0].Role)
+
+	signers := bundle.Roles[0].Signers
+	require.Len(t, signers, 2)
+	require.Equal(t, 2, signers[0].Threshold)
+	require.Len(t, signers[0].Keys, 2)
+	_, ok := signers[0].Keys[cosignerCert.ID()]
+	require.True(t, ok)
+	require.Equal(t, 1, signers[1].Threshold)
+	require.Len(t, signers[1].Keys, 1)
+	for keyID := range prevRootRole.Keys {
+		_, ok := signers[1].Keys[keyID]
+		require.True(t, ok)
+		_, ok = signers[0].Keys[keyID]
+		require.True(t, ok)
+	}
+
+	// the previous root key alone meets the previous root's threshold, but
+	// not the new root's
+	signedRoles, err := offline.SignBundle(bundle)
+	require.NoError(t, err)
+	require.Equal(t, []string{data.CanonicalRootRole}, signedRoles)
+	err = online.PublishBundle(bundle)
+	require.Error(t, err)
+	require.IsType(t, ErrPartiallySigned{}, err)
+
+	signedRoles, err = cosigner.SignBundle(bundle)
+	require.NoError(t, err)
+	require.Equal(t, []string{data.CanonicalRootRole}, signedRoles)
+	require.NoError(t, online.PublishBundle(bundle))
+
+	newClient, _ := newRepoToTestRepo(t, offline, true)
+	defer os.RemoveAll(newClient.baseDir)
+	require.NoError(t, newClient.Update(false))
+	rootRole, err := newClient.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Equal(t, 2, rootRole.Threshold)
+	require.Len(t, rootRole.Keys, 2)
+}
+
 // If there is no local cache, notary operations return the remote error code
 func TestRemoteServerUnavailableNoLocalCache(t *testing.T) {
 	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
@@ -2714,7 +3396,7 @@ func TestRemoteServerUnavailableNoLocalCache(t *testing.T) {
 	defer ts.Close()
 
 	repo, err := NewNotaryRepository(tempBaseDir, "docker.com/notary",
-		ts.URL, http.DefaultTransport, passphraseRetriever)
+		ts.URL, http.DefaultTransport, passphraseRetriever, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 
 	_, err = repo.ListTargets(data.CanonicalTargetsRole)
@@ -3024,6 +3706,7 @@ func TestBootstrapClientBadURL(t *testing.T) {
 		"http://localhost:9998",
 		http.DefaultTransport,
 		passphraseRetriever,
+		certs.TrustPinConfig{},
 	)
 	require.NoError(t, err, "error creating repo: %s", err)
 
@@ -3053,6 +3736,7 @@ func TestBootstrapClientInvalidURL(t *testing.T) {
 		"#!*)&!)#*^%!#)%^!#",
 		http.DefaultTransport,
 		passphraseRetriever,
+		certs.TrustPinConfig{},
 	)
 	require.NoError(t, err, "error creating repo: %s", err)
 
@@ -3145,7 +3829,7 @@ func TestDeleteRepo(t *testing.T) {
 	requireRepoHasExpectedMetadata(t, repo, data.CanonicalSnapshotRole, true)
 
 	// Delete all client trust data for repo
-	err := repo.DeleteTrustData()
+	err := repo.DeleteTrustData(false)
 	require.NoError(t, err)
 
 	// Assert no metadata for this repo exists locally
@@ -3193,7 +3877,7 @@ func TestDeleteRepoBadFilestore(t *testing.T) {
 	repo.fileStore = &brokenRemoveFilestore{repo.fileStore}
 
 	// Delete all client trust data for repo, require an error on the filestore removal
-	err := repo.DeleteTrustData()
+	err := repo.DeleteTrustData(false)
 	require.Error(t, err)
 }
 
@@ -3222,7 +3906,7 @@ func TestDeleteRepoNoCerts(t *testing.T) {
 	require.NotNil(t, err)
 
 	// Delete all client trust data for repo
-	err = repo.DeleteTrustData()
+	err = repo.DeleteTrustData(false)
 	require.NoError(t, err)
 
 	// Assert no metadata for this repo exists locally
@@ -3241,6 +3925,95 @@ func TestDeleteRepoNoCerts(t *testing.T) {
 	requireRepoHasExpectedKeys(t, repo, rootKeyID, true)
 }
 
+// writes a file into the directory for partially signed metadata
+func addPartial(t *testing.T, repo *NotaryRepository) string {
+	dir := filepath.Join(repo.tufRepoPath, partialDir)
+	require.NoError(t, os.MkdirAll(dir, 0700))
+	filename := filepath.Join(dir, data.CanonicalTargetsRole+".json")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("{}"), 0600))
+	return filename
+}
+
+// TestDeleteLocalRepoRemovesChanges tests that deleting only the local trust
+// data removes the repo's whole TUF directory, including any unpublished changes
+// and partially signed metadata, as `notary cert remove -g` always has
+func TestDeleteLocalRepoRemovesChanges(t *testing.T) {
+	gun := "docker.com/notary"
+
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	require.Len(t, getChanges(t, repo), 1)
+	partial := addPartial(t, repo)
+
+	require.NoError(t, repo.DeleteTrustData(false))
+
+	requireRepoHasExpectedMetadata(t, repo, data.CanonicalRootRole, false)
+	require.Len(t, getChanges(t, repo), 0)
+	_, err := os.Stat(filepath.Dir(partial))
+	require.True(t, os.IsNotExist(err))
+
+	// the repo still exists on the server
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	require.NoError(t, fresh.Update(false))
+}
+
+// TestDeleteRemoteRepo tests that the repo is deleted from the server as well
+// as locally, including any unpublished changes and partially signed metadata,
+// when asked to
+func TestDeleteRemoteRepo(t *testing.T) {
+	gun := "docker.com/notary"
+
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+	addTarget(t, repo, "latest", "../fixtures/intermediate-ca.crt")
+	require.Len(t, getChanges(t, repo), 1)
+	partial := addPartial(t, repo)
+
+	require.NoError(t, repo.DeleteTrustData(true))
+
+	requireRepoHasExpectedMetadata(t, repo, data.CanonicalRootRole, false)
+	requireRepoHasExpectedMetadata(t, repo, data.CanonicalTargetsRole, false)
+	require.Len(t, getChanges(t, repo), 0)
+	_, err := os.Stat(filepath.Dir(partial))
+	require.True(t, os.IsNotExist(err))
+
+	// the repo no longer exists on the server
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	err = fresh.Update(false)
+	require.Error(t, err)
+	require.IsType(t, ErrRepositoryNotExist{}, err)
+}
+
+// TestDeleteRemoteRepoFails tests that if the repo can't be deleted from the
+// server, nothing is deleted locally either
+func TestDeleteRemoteRepoFails(t *testing.T) {
+	gun := "docker.com/notary"
+
+	ts := fullTestServer(t)
+	repo, rootKeyID := initializeRepo(t, data.ECDSAKey, gun, ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+	ts.Close()
+
+	require.Error(t, repo.DeleteTrustData(true))
+
+	requireRepoHasExpectedKeys(t, repo, rootKeyID, true)
+	requireRepoHasExpectedCerts(t, repo)
+	requireRepoHasExpectedMetadata(t, repo, data.CanonicalRootRole, true)
+	requireRepoHasExpectedMetadata(t, repo, data.CanonicalTargetsRole, true)
+}
+
 // Test that we get a correct list of roles with keys and signatures
 func TestListRoles(t *testing.T) {
 	ts := fullTestServer(t)
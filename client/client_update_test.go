@@ -31,7 +31,7 @@ func newBlankRepo(t *testing.T, url string) *NotaryRepository {
 	require.NoError(t, err, "failed to create a temporary directory: %s", err)
 
 	repo, err := NewNotaryRepository(tempBaseDir, "docker.com/notary", url,
-		http.DefaultTransport, passphrase.ConstantRetriever("pass"))
+		http.DefaultTransport, passphrase.ConstantRetriever("pass"), certs.TrustPinConfig{})
 	require.NoError(t, err)
 	return repo
 }
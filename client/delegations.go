@@ -46,13 +46,13 @@ func (r *NotaryRepository) AddDelegationRoleAndKeys(name string, delegationKeys
 	}
 	defer cl.Close()
 
-	logrus.Debugf(`Adding delegation "%s" with threshold %d, and %d keys\n`,
-		name, notary.MinThreshold, len(delegationKeys))
+	logrus.Debugf(`Adding %d keys to delegation "%s"\n`, len(delegationKeys), name)
 
-	// Defaulting to threshold of 1, since we don't allow for larger thresholds at the moment.
+	// Leaving the threshold unset keeps the threshold of an existing delegation,
+	// and creates a new delegation with the minimum threshold.  Use
+	// SetDelegationThreshold to change it.
 	tdJSON, err := json.Marshal(&changelist.TufDelegation{
-		NewThreshold: notary.MinThreshold,
-		AddKeys:      data.KeyList(delegationKeys),
+		AddKeys: data.KeyList(delegationKeys),
 	})
 	if err != nil {
 		return err
@@ -89,6 +89,40 @@ func (r *NotaryRepository) AddDelegationPaths(name string, paths []string) error
 	return addChange(cl, template, name)
 }
 
+// SetDelegationThreshold creates a changelist entry to set the number of keys
+// which must sign an existing delegation's metadata.  The threshold cannot be
+// greater than the number of keys the delegation has once the changelist is
+// applied.  If the threshold cannot be met with the delegation keys available
+// locally, Publish will produce partially signed metadata which the holders of
+// the other keys must sign.
+func (r *NotaryRepository) SetDelegationThreshold(name string, threshold int) error {
+
+	if !data.IsDelegation(name) {
+		return data.ErrInvalidRole{Role: name, Reason: "invalid delegation role name"}
+	}
+	if threshold < notary.MinThreshold {
+		return data.ErrInvalidRole{Role: name, Reason: fmt.Sprintf("threshold must be at least %d", notary.MinThreshold)}
+	}
+
+	cl, err := changelist.NewFileChangelist(filepath.Join(r.tufRepoPath, "changelist"))
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	logrus.Debugf(`Setting threshold of delegation "%s" to %d\n`, name, threshold)
+
+	tdJSON, err := json.Marshal(&changelist.TufDelegation{
+		NewThreshold: threshold,
+	})
+	if err != nil {
+		return err
+	}
+
+	template := newUpdateDelegationChange(name, tdJSON)
+	return addChange(cl, template, name)
+}
+
 // RemoveDelegationKeysAndPaths creates changelist entries to remove provided delegation key IDs and paths.
 // This method composes RemoveDelegationPaths and RemoveDelegationKeys (each creates one changelist if called).
 func (r *NotaryRepository) RemoveDelegationKeysAndPaths(name string, keyIDs, paths []string) error {
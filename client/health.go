@@ -0,0 +1,318 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	canonicaljson "github.com/docker/go/canonical/json"
+	"github.com/docker/notary/trustmanager"
+	"github.com/docker/notary/tuf"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/docker/notary/tuf/store"
+)
+
+// RoleHealth describes the latest published metadata for a role
+type RoleHealth struct {
+	Role    string
+	Version int
+	Expires time.Time
+
+	// Signatures is the number of valid signatures the metadata has from the
+	// role's keys, and Threshold is the number it needs
+	Signatures int
+	Threshold  int
+
+	// RevokedKeys are the IDs of keys that signed the metadata which were keys
+	// of the role in the previous version of the root, or of the parent role
+	// for a delegation, but have since been removed from it.  UnknownKeys are
+	// the IDs of any other keys that signed it which are not keys of the role.
+	UnknownKeys []string
+	RevokedKeys []string
+}
+
+// CertHealth describes the certificate of a root or delegation key
+type CertHealth struct {
+	Role    string
+	KeyID   string
+	Expires time.Time
+}
+
+// HealthReport describes the metadata for every role of a trusted collection,
+// and the certificates of its root and delegation keys
+type HealthReport struct {
+	GUN          string
+	Roles        []RoleHealth
+	Certificates []CertHealth
+}
+
+// Problems returns a description of everything in the report that needs
+// attention within the given window: metadata and certificates which have
+// expired or will expire before then, and metadata which does not have
+// enough valid signatures
+func (h *HealthReport) Problems(window time.Duration) []string {
+	deadline := time.Now().Add(window)
+	var problems []string
+	for _, role := range h.Roles {
+		if role.Expires.Before(deadline) {
+			problems = append(problems, fmt.Sprintf("%s metadata %s",
+				role.Role, describeExpiry(role.Expires)))
+		}
+		if role.Signatures < role.Threshold {
+			problems = append(problems, fmt.Sprintf("%s metadata has %d of the %d valid signatures it needs",
+				role.Role, role.Signatures, role.Threshold))
+		}
+	}
+	for _, cert := range h.Certificates {
+		if cert.Expires.Before(deadline) {
+			problems = append(problems, fmt.Sprintf("certificate for %s key %s %s",
+				cert.Role, cert.KeyID, describeExpiry(cert.Expires)))
+		}
+	}
+	return problems
+}
+
+func describeExpiry(expires time.Time) string {
+	if signed.IsExpired(expires) {
+		return "expired on " + expires.Format(time.RFC3339)
+	}
+	return "expires on " + expires.Format(time.RFC3339)
+}
+
+// Health reports on the latest metadata for every role of the repository,
+// including delegations, and on the certificates of the root and delegation
+// keys.  If any of the metadata has expired, the report is made from the
+// metadata last downloaded, so that it can still be reported on.
+func (r *NotaryRepository) Health() (*HealthReport, error) {
+	if err := r.Update(false); err != nil {
+		switch err.(type) {
+		case signed.ErrExpired, tuf.ErrLocalRootExpired, tuf.ErrMetaExpired:
+			logrus.Warnf("reporting on the cached metadata for %s: %s", r.gun, err)
+		default:
+			return nil, err
+		}
+	}
+
+	s, err := r.cachedSigned(data.CanonicalRootRole)
+	if err != nil {
+		return nil, err
+	}
+	root, err := data.RootFromSigned(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// the previous versions of the root and of the parents of delegations say
+	// which keys have been removed from their roles
+	remote, err := getRemoteStore(r.baseURL, r.gun, r.roundTrip)
+	if err != nil {
+		logrus.Debugf("unable to reach the server for the previous metadata: %s", err)
+	}
+	var prevRoot *data.SignedRoot
+	if prev := previousSigned(remote, data.CanonicalRootRole, root.Signed.Version); prev != nil {
+		if prevRoot, err = data.RootFromSigned(prev); err != nil {
+			logrus.Debugf("unable to parse the previous root: %s", err)
+		}
+	}
+
+	var (
+		report  = &HealthReport{GUN: r.gun}
+		roles   []data.BaseRole
+		metas   []*data.Signed
+		revoked []map[string]bool
+		parents []*data.SignedTargets
+		names   []string
+	)
+	for _, name := range data.BaseRoles {
+		role, err := root.BuildBaseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		meta := s
+		if name == data.CanonicalRootRole {
+			report.Certificates = append(report.Certificates, certHealth(role)...)
+		} else if meta, err = r.cachedSigned(name); err != nil {
+			return nil, err
+		}
+		var prevKeys map[string]bool
+		if prevRoot != nil {
+			if prevRole, err := prevRoot.BuildBaseRole(name); err == nil {
+				prevKeys = keyIDs(prevRole)
+			}
+		}
+		roles = append(roles, role)
+		metas = append(metas, meta)
+		revoked = append(revoked, prevKeys)
+		if name == data.CanonicalTargetsRole {
+			targets, err := data.TargetsFromSigned(meta, name)
+			if err != nil {
+				return nil, err
+			}
+			parents = append(parents, targets)
+			names = append(names, name)
+		}
+	}
+
+	// walk down the delegations, breadth first
+	for len(parents) > 0 {
+		parent, parentName := parents[0], names[0]
+		parents, names = parents[1:], names[1:]
+
+		var prevParent *data.SignedTargets
+		if prev := previousSigned(remote, parentName, parent.Signed.Version); prev != nil {
+			if prevParent, err = data.TargetsFromSigned(prev, parentName); err != nil {
+				logrus.Debugf("unable to parse the previous %s: %s", parentName, err)
+			}
+		}
+
+		for _, delegation := range parent.Signed.Delegations.Roles {
+			role, err := parent.BuildDelegationRole(delegation.Name)
+			if err != nil {
+				return nil, err
+			}
+			report.Certificates = append(report.Certificates, certHealth(role.BaseRole)...)
+
+			s, err := r.cachedSigned(role.Name)
+			if err != nil {
+				// nothing has been published for this delegation yet
+				logrus.Debugf("no metadata for %s: %s", role.Name, err)
+				continue
+			}
+			targets, err := data.TargetsFromSigned(s, role.Name)
+			if err != nil {
+				return nil, err
+			}
+			var prevKeys map[string]bool
+			if prevParent != nil {
+				if prevRole, err := prevParent.BuildDelegationRole(role.Name); err == nil {
+					prevKeys = keyIDs(prevRole.BaseRole)
+				}
+			}
+			roles = append(roles, role.BaseRole)
+			metas = append(metas, s)
+			revoked = append(revoked, prevKeys)
+			parents = append(parents, targets)
+			names = append(names, role.Name)
+		}
+	}
+
+	for i, role := range roles {
+		health, err := roleHealth(role, metas[i], revoked[i])
+		if err != nil {
+			return nil, err
+		}
+		report.Roles = append(report.Roles, health)
+	}
+	return report, nil
+}
+
+// previousSigned downloads the version of a role's metadata before the given
+// one.  It returns nil if there is no previous version, or it can't be
+// downloaded, for instance because the server is unreachable.  It is not
+// verified, since it only decides whether the keys that signed the current
+// metadata are reported as revoked or unknown.
+func previousSigned(remote store.RemoteStore, role string, version int) *data.Signed {
+	if version <= 1 {
+		return nil
+	}
+	raw, err := remote.GetMeta(fmt.Sprintf("%s.%d", role, version-1), -1)
+	if err != nil {
+		logrus.Debugf("unable to download version %d of %s: %s", version-1, role, err)
+		return nil
+	}
+	s := &data.Signed{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		logrus.Debugf("unable to parse version %d of %s: %s", version-1, role, err)
+		return nil
+	}
+	return s
+}
+
+// keyIDs returns the set of the IDs of a role's keys
+func keyIDs(role data.BaseRole) map[string]bool {
+	ids := make(map[string]bool, len(role.Keys))
+	for keyID := range role.Keys {
+		ids[keyID] = true
+	}
+	return ids
+}
+
+// cachedSigned reads the metadata for a role last downloaded from the server
+func (r *NotaryRepository) cachedSigned(role string) (*data.Signed, error) {
+	raw, err := r.fileStore.GetMeta(role, -1)
+	if err != nil {
+		return nil, err
+	}
+	s := &data.Signed{}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// roleHealth describes a role's metadata.  Signatures from keys which aren't
+// the role's are sorted into revoked and unknown keys, by whether their IDs
+// are among the IDs of the keys the role used to have.
+func roleHealth(role data.BaseRole, s *data.Signed, revoked map[string]bool) (RoleHealth, error) {
+	common := &data.SignedCommon{}
+	if err := json.Unmarshal(*s.Signed, common); err != nil {
+		return RoleHealth{}, err
+	}
+	health := RoleHealth{
+		Role:      role.Name,
+		Version:   common.Version,
+		Expires:   common.Expires,
+		Threshold: role.Threshold,
+	}
+
+	// signatures are of the canonically marshalled signed object
+	var decoded map[string]interface{}
+	if err := canonicaljson.Unmarshal(*s.Signed, &decoded); err != nil {
+		return RoleHealth{}, err
+	}
+	msg, err := canonicaljson.MarshalCanonical(decoded)
+	if err != nil {
+		return RoleHealth{}, err
+	}
+
+	valid := make(map[string]bool)
+	for _, sig := range s.Signatures {
+		key, ok := role.Keys[sig.KeyID]
+		switch {
+		case ok:
+			if err := signed.VerifySignature(msg, sig, key); err != nil {
+				logrus.Debugf("invalid signature on %s from key %s: %s", role.Name, sig.KeyID, err)
+				continue
+			}
+			valid[sig.KeyID] = true
+		case revoked[sig.KeyID]:
+			health.RevokedKeys = append(health.RevokedKeys, sig.KeyID)
+		default:
+			health.UnknownKeys = append(health.UnknownKeys, sig.KeyID)
+		}
+	}
+	health.Signatures = len(valid)
+	return health, nil
+}
+
+// certHealth describes the certificates of a role's keys, for those of its
+// keys which are certificates
+func certHealth(role data.BaseRole) []CertHealth {
+	var certs []CertHealth
+	for keyID, key := range role.Keys {
+		switch key.Algorithm() {
+		case data.ECDSAx509Key, data.RSAx509Key:
+		default:
+			continue
+		}
+		cert, err := trustmanager.LoadCertFromPEM(key.Public())
+		if err != nil {
+			logrus.Debugf("unable to load the certificate for %s key %s: %s", role.Name, keyID, err)
+			continue
+		}
+		certs = append(certs, CertHealth{Role: role.Name, KeyID: keyID, Expires: cert.NotAfter})
+	}
+	return certs
+}
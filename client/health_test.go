@@ -0,0 +1,160 @@
+package client
+
+import (
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/docker/notary"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/docker/notary/tuf/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// The health report covers the metadata for every role, and the root
+// certificate, and only reports problems for what expires within the window
+func TestHealth(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	report, err := fresh.Health()
+	require.NoError(t, err)
+	require.Equal(t, "docker.com/notary", report.GUN)
+
+	versions := map[string]int{
+		data.CanonicalRootRole:      fresh.tufRepo.Root.Signed.Version,
+		data.CanonicalTargetsRole:   fresh.tufRepo.Targets[data.CanonicalTargetsRole].Signed.Version,
+		data.CanonicalSnapshotRole:  fresh.tufRepo.Snapshot.Signed.Version,
+		data.CanonicalTimestampRole: fresh.tufRepo.Timestamp.Signed.Version,
+	}
+	var roles []string
+	for _, role := range report.Roles {
+		roles = append(roles, role.Role)
+		require.Equal(t, versions[role.Role], role.Version, role.Role)
+		require.Equal(t, 1, role.Threshold, role.Role)
+		require.Equal(t, 1, role.Signatures, role.Role)
+		require.Empty(t, role.UnknownKeys, role.Role)
+		require.Empty(t, role.RevokedKeys, role.Role)
+		require.True(t, role.Expires.After(time.Now()), role.Role)
+	}
+	sort.Strings(roles)
+	require.Equal(t, []string{data.CanonicalRootRole, data.CanonicalSnapshotRole,
+		data.CanonicalTargetsRole, data.CanonicalTimestampRole}, roles)
+
+	require.Len(t, report.Certificates, 1)
+	require.Equal(t, data.CanonicalRootRole, report.Certificates[0].Role)
+	_, ok := repo.tufRepo.Root.Signed.Keys[report.Certificates[0].KeyID]
+	require.True(t, ok)
+	require.True(t, report.Certificates[0].Expires.After(time.Now().Add(notary.Year)))
+
+	require.Empty(t, report.Problems(time.Hour))
+	// everything expires within twenty years
+	require.Len(t, report.Problems(20*notary.Year), 5)
+}
+
+// Metadata which has expired is reported on, rather than failing the report
+func TestHealthExpiredMetadata(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	repo.Expiries = map[string]time.Duration{data.CanonicalTargetsRole: time.Second}
+	require.NoError(t, repo.Publish())
+
+	require.NoError(t, repo.Update(false))
+	time.Sleep(time.Second)
+	err := repo.Update(false)
+	require.Error(t, err)
+	require.IsType(t, signed.ErrExpired{}, err)
+
+	report, err := repo.Health()
+	require.NoError(t, err)
+	problems := report.Problems(time.Hour)
+	require.Len(t, problems, 1)
+	require.Contains(t, problems[0], "targets metadata expired on")
+}
+
+// A root key that has been rotated out still signs the new root, so that the
+// new root chains to the old one, and is reported as revoked rather than unknown
+func TestHealthRotatedRootKey(t *testing.T) {
+	ts := fullTestServer(t)
+	defer ts.Close()
+
+	repo, _ := initializeRepo(t, data.ECDSAKey, "docker.com/notary", ts.URL, false)
+	defer os.RemoveAll(repo.baseDir)
+	require.NoError(t, repo.Publish())
+	oldRootRole, err := repo.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.NoError(t, repo.RotateKey(data.CanonicalRootRole, false))
+
+	fresh, _ := newRepoToTestRepo(t, repo, true)
+	defer os.RemoveAll(fresh.baseDir)
+	report, err := fresh.Health()
+	require.NoError(t, err)
+
+	found := false
+	for _, role := range report.Roles {
+		if role.Role != data.CanonicalRootRole {
+			require.Empty(t, role.RevokedKeys, role.Role)
+			continue
+		}
+		found = true
+		require.Equal(t, 1, role.Signatures)
+		require.Equal(t, oldRootRole.ListKeyIDs(), role.RevokedKeys)
+		require.Empty(t, role.UnknownKeys)
+	}
+	require.True(t, found)
+	require.Empty(t, report.Problems(time.Hour))
+}
+
+// Signatures from keys other than the role's are reported as revoked if the
+// role used to have the key, and unknown otherwise, and don't count towards
+// the threshold
+func TestRoleHealthSignatures(t *testing.T) {
+	tufRepo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	targetsRole, err := tufRepo.GetBaseRole(data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	snapshotRole, err := tufRepo.GetBaseRole(data.CanonicalSnapshotRole)
+	require.NoError(t, err)
+	oldKey, err := cs.Create(data.CanonicalTargetsRole, "docker.com/notary", data.ECDSAKey)
+	require.NoError(t, err)
+
+	s, err := tufRepo.SignTargets(data.CanonicalTargetsRole, data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	keys := append(targetsRole.ListKeys(), snapshotRole.ListKeys()...)
+	require.NoError(t, signed.Sign(cs, s, append(keys, oldKey)...))
+	require.Len(t, s.Signatures, 3)
+
+	// the role used to have the old key as well as its current key
+	revoked := map[string]bool{oldKey.ID(): true}
+	for _, keyID := range targetsRole.ListKeyIDs() {
+		revoked[keyID] = true
+	}
+	health, err := roleHealth(targetsRole, s, revoked)
+	require.NoError(t, err)
+	require.Equal(t, data.CanonicalTargetsRole, health.Role)
+	require.Equal(t, 1, health.Signatures)
+	require.Equal(t, 1, health.Threshold)
+	require.Equal(t, []string{oldKey.ID()}, health.RevokedKeys)
+	require.Equal(t, snapshotRole.ListKeyIDs(), health.UnknownKeys)
+
+	// a signature which doesn't verify doesn't count
+	for i, sig := range s.Signatures {
+		if sig.KeyID == targetsRole.ListKeyIDs()[0] {
+			s.Signatures[i].Signature = []byte("invalid")
+		}
+	}
+	health, err = roleHealth(targetsRole, s, revoked)
+	require.NoError(t, err)
+	require.Equal(t, 0, health.Signatures)
+}
@@ -59,15 +59,125 @@ func applyChangelist(repo *tuf.Repo, cl changelist.Changelist) error {
 	return nil
 }
 
+// publishedTargets returns a copy of the targets in each of the repository's
+// targets roles
+func publishedTargets(repo *tuf.Repo) map[string]data.Files {
+	targets := make(map[string]data.Files, len(repo.Targets))
+	for role, signedTargets := range repo.Targets {
+		files := make(data.Files, len(signedTargets.Signed.Targets))
+		for path, meta := range signedTargets.Signed.Targets {
+			files[path] = meta
+		}
+		targets[role] = files
+	}
+	return targets
+}
+
+// conflictingChanges returns the target changes in the changelist which
+// conflict with what someone else published: a change conflicts if the target
+// it changes was changed remotely since the change was staged, and is not
+// already the way the change would leave it.  Changes that don't record what
+// the target was when they were staged are compared against before, the
+// targets when the changelist was last applied, if it is not nil.  Other
+// kinds of changes are assumed to be safe to apply again.
+func conflictingChanges(cl changelist.Changelist, before, after map[string]data.Files) ([]changelist.Change, error) {
+	it, err := cl.NewIterator()
+	if err != nil {
+		return nil, err
+	}
+	var conflicts []changelist.Change
+	for it.HasNext() {
+		c, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if c.Type() != changelist.TypeTargetsTarget {
+			continue
+		}
+		var (
+			previous    data.FileMeta
+			hadPrevious bool
+		)
+		switch base := c.Base(); {
+		case base != nil && base.Data != nil:
+			if err := json.Unmarshal(base.Data, &previous); err != nil {
+				return nil, err
+			}
+			hadPrevious = true
+		case base == nil && before == nil:
+			// nothing to compare against
+			continue
+		case base == nil:
+			previous, hadPrevious = before[c.Scope()][c.Path()]
+		}
+		current, hasCurrent := after[c.Scope()][c.Path()]
+		if hadPrevious == hasCurrent && (!hasCurrent || utils.FileMetaEqual(current, previous) == nil) {
+			// not changed remotely
+			continue
+		}
+		switch c.Action() {
+		case changelist.ActionCreate:
+			meta := data.FileMeta{}
+			if err := json.Unmarshal(c.Content(), &meta); err != nil {
+				return nil, err
+			}
+			if hasCurrent && utils.FileMetaEqual(current, meta) == nil {
+				continue
+			}
+		case changelist.ActionDelete:
+			if !hasCurrent {
+				continue
+			}
+		default:
+			continue
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, nil
+}
+
 func applyTargetsChange(repo *tuf.Repo, c changelist.Change) error {
 	switch c.Type() {
 	case changelist.TypeTargetsTarget:
 		return changeTargetMeta(repo, c)
 	case changelist.TypeTargetsDelegation:
 		return changeTargetsDelegation(repo, c)
+	case changelist.TypeWitness:
+		return witnessTargets(repo, c.Scope())
 	default:
-		return fmt.Errorf("only target meta and delegations changes supported")
+		return fmt.Errorf("only target meta, delegations and witness changes supported")
+	}
+}
+
+// witnessTargets marks the metadata for a targets role dirty, so that it is
+// re-signed with the role's current keys and published with a new version,
+// without changing any of its targets
+func witnessTargets(repo *tuf.Repo, role string) error {
+	signedTargets, ok := repo.Targets[role]
+	if !ok {
+		return data.ErrInvalidRole{Role: role, Reason: "there is no metadata for this role to witness"}
+	}
+	signedTargets.Dirty = true
+	return nil
+}
+
+// witnessedRoles returns the roles that the changelist witnesses
+func witnessedRoles(cl changelist.Changelist) ([]string, error) {
+	it, err := cl.NewIterator()
+	if err != nil {
+		return nil, err
+	}
+	var roles []string
+	for it.HasNext() {
+		c, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if c.Type() == changelist.TypeWitness {
+			roles = append(roles, c.Scope())
+		}
 	}
+	return roles, nil
 }
 
 func changeTargetsDelegation(repo *tuf.Repo, c changelist.Change) error {
@@ -184,17 +294,26 @@ func applyRootRoleChange(repo *tuf.Repo, c changelist.Change) error {
 		if err != nil {
 			return err
 		}
+	case changelist.ActionUpdate:
+		// adds keys to a role, and possibly changes its threshold
+		d := &changelist.TufRootData{}
+		err := json.Unmarshal(c.Content(), d)
+		if err != nil {
+			return err
+		}
+		err = repo.AddBaseKeys(d.RoleName, d.Keys...)
+		if err != nil {
+			return err
+		}
+		if d.Threshold > 0 {
+			return repo.SetBaseThreshold(d.RoleName, d.Threshold)
+		}
 	default:
 		logrus.Debug("action not yet supported for root: ", c.Action())
 	}
 	return nil
 }
 
-func nearExpiry(r *data.SignedRoot) bool {
-	plus6mo := time.Now().AddDate(0, 6, 0)
-	return r.Signed.Expires.Before(plus6mo)
-}
-
 // Fetches a public key from a remote store, given a gun and role
 func getRemoteKey(url, gun, role string, rt http.RoundTripper) (data.PublicKey, error) {
 	remote, err := getRemoteStore(url, gun, rt)
@@ -214,17 +333,17 @@ func getRemoteKey(url, gun, role string, rt http.RoundTripper) (data.PublicKey,
 	return pubKey, nil
 }
 
-// signs and serializes the metadata for a canonical role in a tuf repo to JSON
-func serializeCanonicalRole(tufRepo *tuf.Repo, role string) (out []byte, err error) {
+// signs and serializes the metadata for a canonical role in a tuf repo to JSON,
+// to expire at the given time
+func serializeCanonicalRole(tufRepo *tuf.Repo, role string, expires time.Time) (out []byte, err error) {
 	var s *data.Signed
 	switch {
 	case role == data.CanonicalRootRole:
-		s, err = tufRepo.SignRoot(data.DefaultExpires(role))
+		s, err = tufRepo.SignRoot(expires)
 	case role == data.CanonicalSnapshotRole:
-		s, err = tufRepo.SignSnapshot(data.DefaultExpires(role))
+		s, err = tufRepo.SignSnapshot(expires)
 	case tufRepo.Targets[role] != nil:
-		s, err = tufRepo.SignTargets(
-			role, data.DefaultExpires(data.CanonicalTargetsRole))
+		s, err = tufRepo.SignTargets(role, expires)
 	default:
 		err = fmt.Errorf("%s not supported role to sign on the client", role)
 	}
@@ -97,6 +97,40 @@ func TestApplyAddTargetTwice(t *testing.T) {
 	require.NotEmpty(t, repo.Targets["targets"].Signed.Targets["latest"])
 }
 
+// Witnessing a role marks its metadata dirty without changing its targets, and
+// a role without any metadata can't be witnessed
+func TestApplyWitnessChange(t *testing.T) {
+	repo, _, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	_, err = repo.InitTargets(data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	_, err = repo.AddTargets(data.CanonicalTargetsRole, data.Files{"latest": data.FileMeta{Length: 1}})
+	require.NoError(t, err)
+	repo.Targets[data.CanonicalTargetsRole].Dirty = false
+
+	cl := changelist.NewMemChangelist()
+	require.NoError(t, cl.Add(&changelist.TufChange{
+		Actn:       changelist.ActionUpdate,
+		Role:       changelist.ScopeTargets,
+		ChangeType: changelist.TypeWitness,
+	}))
+	witnessed, err := witnessedRoles(cl)
+	require.NoError(t, err)
+	require.Equal(t, []string{data.CanonicalTargetsRole}, witnessed)
+
+	require.NoError(t, applyChangelist(repo, cl))
+	require.True(t, repo.Targets[data.CanonicalTargetsRole].Dirty)
+	require.Len(t, repo.Targets[data.CanonicalTargetsRole].Signed.Targets, 1)
+
+	err = applyTargetsChange(repo, &changelist.TufChange{
+		Actn:       changelist.ActionUpdate,
+		Role:       "targets/level1",
+		ChangeType: changelist.TypeWitness,
+	})
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+}
+
 func TestApplyChangelist(t *testing.T) {
 	repo, _, err := testutils.EmptyRepo("docker.com/notary")
 	require.NoError(t, err)
@@ -0,0 +1,328 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/docker/notary/tuf/store"
+	"github.com/docker/notary/tuf/utils"
+)
+
+// SignPartial adds signatures to the partially signed metadata for a role,
+// using any of the role's keys that are available locally, and returns the
+// result.  The role's keys are determined from the latest trusted metadata for
+// this repository, overlaid with any partially signed metadata saved locally,
+// and for the root role also from the root being signed.  Only the root and
+// targets roles (including delegations) can be signed this way.
+func (r *NotaryRepository) SignPartial(role string, metaJSON []byte) ([]byte, error) {
+	s, err := parsePartial(role, metaJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []data.BaseRole
+	if role == data.CanonicalRootRole {
+		newRoot, err := data.RootFromSigned(s)
+		if err != nil {
+			return nil, err
+		}
+		newRootRole, err := newRoot.BuildBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, newRootRole)
+	}
+
+	if _, err := r.updateForPublish(); err != nil {
+		// a brand new root can be signed without any existing metadata
+		if _, ok := err.(ErrRepoNotInitialized); !ok || role != data.CanonicalRootRole {
+			return nil, err
+		}
+		logrus.Debugf("signing root for %s without any existing metadata", r.gun)
+	} else {
+		// if the root is being rotated, it must also be signed by the current root keys
+		if role == data.CanonicalRootRole {
+			prevRootRole, err := r.tufRepo.GetBaseRole(data.CanonicalRootRole)
+			if err != nil {
+				return nil, err
+			}
+			roles = append(roles, prevRootRole)
+		}
+		partial, err := r.loadPartial()
+		if err != nil {
+			return nil, err
+		}
+		if err := r.setPartial(partial); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case role == data.CanonicalTargetsRole:
+		targetsRole, err := r.tufRepo.GetBaseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, targetsRole)
+	case data.IsDelegation(role):
+		delgRole, err := r.tufRepo.GetDelegationRole(role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, delgRole.BaseRole)
+	}
+
+	keys := make(map[string]data.PublicKey)
+	for _, baseRole := range roles {
+		for keyID, key := range baseRole.Keys {
+			keys[keyID] = key
+		}
+	}
+	keyList := make(data.KeyList, 0, len(keys))
+	for _, key := range keys {
+		keyList = append(keyList, key)
+	}
+	if err := signed.Sign(r.CryptoService, s, keyList...); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// SavePartial saves partially signed metadata for a role, so that it will be
+// published by the next call to Publish once all the saved metadata has enough
+// signatures.  Any metadata previously saved for the role is replaced.
+func (r *NotaryRepository) SavePartial(role string, metaJSON []byte) error {
+	if _, err := parsePartial(role, metaJSON); err != nil {
+		return err
+	}
+	return r.savePartial(map[string][]byte{role: metaJSON})
+}
+
+// parsePartial checks that the metadata is valid, partially signed metadata
+// for the given role
+func parsePartial(role string, metaJSON []byte) (*data.Signed, error) {
+	s := &data.Signed{}
+	if err := json.Unmarshal(metaJSON, s); err != nil {
+		return nil, err
+	}
+	var err error
+	switch {
+	case role == data.CanonicalRootRole:
+		_, err = data.RootFromSigned(s)
+	case role == data.CanonicalTargetsRole || data.IsDelegation(role):
+		_, err = data.TargetsFromSigned(s, role)
+	default:
+		err = data.ErrInvalidRole{Role: role, Reason: "only root and targets metadata can be partially signed"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// publishPartial publishes the saved partially signed metadata, if every role
+// now has enough signatures, and then removes it
+func (r *NotaryRepository) publishPartial(partial map[string][]byte) error {
+	dir := filepath.Join(r.tufRepoPath, partialDir)
+	if err := r.publishSigned(partial, dir); err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// publishSigned publishes signed metadata which was produced outside of the
+// changelist, along with a new snapshot, if every role has enough signatures.
+// Otherwise it returns ErrPartiallySigned, naming dir as where the metadata is.
+func (r *NotaryRepository) publishSigned(signedFiles map[string][]byte, dir string) error {
+	if _, err := r.updateForPublish(); err != nil {
+		return err
+	}
+	// a new root must also be signed by the current root keys
+	prevRootRole, err := r.tufRepo.GetBaseRole(data.CanonicalRootRole)
+	if err != nil {
+		return err
+	}
+	if err := r.setPartial(signedFiles); err != nil {
+		return err
+	}
+
+	// serialize the metadata the same way the snapshot will when hashing it
+	updatedFiles := make(map[string][]byte)
+	for role := range signedFiles {
+		var (
+			metaJSON []byte
+			err      error
+		)
+		if role == data.CanonicalRootRole {
+			metaJSON, err = r.tufRepo.Root.MarshalJSON()
+		} else {
+			metaJSON, err = r.tufRepo.Targets[role].MarshalJSON()
+		}
+		if err != nil {
+			return err
+		}
+		updatedFiles[role] = metaJSON
+	}
+
+	underSigned, err := r.underSignedRoles(updatedFiles, prevRootRole)
+	if err != nil {
+		return err
+	}
+	if len(underSigned) > 0 {
+		return ErrPartiallySigned{Roles: underSigned, Dir: dir}
+	}
+
+	return r.signSnapshotAndUpload(updatedFiles)
+}
+
+// setPartial loads partially signed metadata into r.tufRepo, on top of the
+// metadata that has already been loaded
+func (r *NotaryRepository) setPartial(partial map[string][]byte) error {
+	if rootJSON, ok := partial[data.CanonicalRootRole]; ok {
+		s, err := parsePartial(data.CanonicalRootRole, rootJSON)
+		if err != nil {
+			return err
+		}
+		root, err := data.RootFromSigned(s)
+		if err != nil {
+			return err
+		}
+		if err := r.tufRepo.SetRoot(root); err != nil {
+			return err
+		}
+	}
+
+	targetsRoles := make(utils.RoleList, 0, len(partial))
+	for role := range partial {
+		if role != data.CanonicalRootRole {
+			targetsRoles = append(targetsRoles, role)
+		}
+	}
+	// load parents before their delegations
+	sort.Sort(targetsRoles)
+	for _, role := range targetsRoles {
+		s, err := parsePartial(role, partial[role])
+		if err != nil {
+			return err
+		}
+		targets, err := data.TargetsFromSigned(s, role)
+		if err != nil {
+			return err
+		}
+		if err := r.tufRepo.SetTargets(role, targets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// underSignedRoles returns, in sorted order, the roles in updatedFiles whose
+// metadata does not have enough valid signatures to meet the role's threshold.
+// The roles are looked up in r.tufRepo, which must already contain the updated
+// metadata, and a new root must additionally meet the threshold of prevRootRole.
+func (r *NotaryRepository) underSignedRoles(updatedFiles map[string][]byte, prevRootRole data.BaseRole) ([]string, error) {
+	var underSigned []string
+	for role, metaJSON := range updatedFiles {
+		if role != data.CanonicalRootRole && role != data.CanonicalTargetsRole && !data.IsDelegation(role) {
+			continue
+		}
+		roles, err := r.signingRoles(role, prevRootRole)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &data.Signed{}
+		if err := json.Unmarshal(metaJSON, s); err != nil {
+			return nil, err
+		}
+		for _, baseRole := range roles {
+			err := signed.VerifySignatures(s, baseRole)
+			if _, ok := err.(signed.ErrRoleThreshold); ok || err == signed.ErrNoSignatures {
+				logrus.Debugf("%s does not have enough signatures for %s", role, baseRole.Name)
+				underSigned = append(underSigned, role)
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(underSigned)
+	return underSigned, nil
+}
+
+// signingRoles returns the roles, as found in r.tufRepo, whose thresholds the
+// metadata for a root or targets role must meet: the role itself, and for the
+// root role also prevRootRole
+func (r *NotaryRepository) signingRoles(role string, prevRootRole data.BaseRole) ([]data.BaseRole, error) {
+	switch {
+	case role == data.CanonicalRootRole:
+		rootRole, err := r.tufRepo.GetBaseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		return []data.BaseRole{rootRole, prevRootRole}, nil
+	case role == data.CanonicalTargetsRole:
+		targetsRole, err := r.tufRepo.GetBaseRole(role)
+		if err != nil {
+			return nil, err
+		}
+		return []data.BaseRole{targetsRole}, nil
+	case data.IsDelegation(role):
+		delgRole, err := r.tufRepo.GetDelegationRole(role)
+		if err != nil {
+			return nil, err
+		}
+		return []data.BaseRole{delgRole.BaseRole}, nil
+	default:
+		return nil, data.ErrInvalidRole{Role: role, Reason: "only root and targets metadata can be partially signed"}
+	}
+}
+
+// loadPartial returns the partially signed metadata saved for this repository,
+// keyed by role
+func (r *NotaryRepository) loadPartial() (map[string][]byte, error) {
+	dir := filepath.Join(r.tufRepoPath, partialDir)
+	partial := make(map[string][]byte)
+	err := filepath.Walk(dir, func(fp string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.IsDir() || filepath.Ext(fp) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, fp)
+		if err != nil {
+			return err
+		}
+		metaJSON, err := ioutil.ReadFile(fp)
+		if err != nil {
+			return err
+		}
+		partial[filepath.ToSlash(strings.TrimSuffix(rel, ".json"))] = metaJSON
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return partial, nil
+}
+
+// savePartial saves partially signed metadata for this repository
+func (r *NotaryRepository) savePartial(partial map[string][]byte) error {
+	partialStore, err := store.NewFilesystemStore(r.tufRepoPath, partialDir, "json")
+	if err != nil {
+		return err
+	}
+	return partialStore.SetMultiMeta(partial)
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/docker/notary/certs"
 	"github.com/docker/notary/passphrase"
 	"github.com/docker/notary/trustmanager"
 	"github.com/docker/notary/trustmanager/yubikey"
@@ -13,9 +14,10 @@ import (
 
 // NewNotaryRepository is a helper method that returns a new notary repository.
 // It takes the base directory under where all the trust files will be stored
-// (usually ~/.docker/trust/).
+// (usually ~/.docker/trust/).  The trust pinning configuration determines how
+// trust in the root of this GUN is bootstrapped.
 func NewNotaryRepository(baseDir, gun, baseURL string, rt http.RoundTripper,
-	retriever passphrase.Retriever) (
+	retriever passphrase.Retriever, trustPinning certs.TrustPinConfig) (
 	*NotaryRepository, error) {
 
 	fileKeyStore, err := trustmanager.NewKeyFileStore(baseDir, retriever)
@@ -29,5 +31,5 @@ func NewNotaryRepository(baseDir, gun, baseURL string, rt http.RoundTripper,
 		keyStores = []trustmanager.KeyStore{yubiKeyStore, fileKeyStore}
 	}
 
-	return repositoryFromKeystores(baseDir, gun, baseURL, rt, keyStores)
+	return repositoryFromKeystores(baseDir, gun, baseURL, rt, keyStores, trustPinning)
 }
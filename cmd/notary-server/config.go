@@ -3,7 +3,10 @@ package main
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -11,13 +14,21 @@ import (
 	_ "github.com/docker/distribution/registry/auth/token"
 	"github.com/docker/go-connections/tlsconfig"
 	"github.com/docker/notary"
+	"github.com/docker/notary/cryptoservice"
 	"github.com/docker/notary/server"
+	"github.com/docker/notary/server/auth/mtls"
+	"github.com/docker/notary/server/gc"
+	"github.com/docker/notary/server/policy"
+	"github.com/docker/notary/server/resign"
 	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/server/webhooks"
 	"github.com/docker/notary/signer/client"
+	"github.com/docker/notary/trustmanager"
 	"github.com/docker/notary/tuf/data"
 	"github.com/docker/notary/tuf/signed"
 	"github.com/docker/notary/utils"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 )
@@ -78,12 +89,18 @@ func getStore(configuration *viper.Viper, allowedBackends []string, hRegister he
 	if err != nil {
 		return nil, fmt.Errorf("Error starting DB driver: %s", err.Error())
 	}
+	if storeConfig.Backend == utils.SqliteBackend {
+		// there are no migrations for SQLite, so create the tables here
+		if err := store.CreateTables(); err != nil {
+			return nil, fmt.Errorf("Error creating DB tables: %s", err.Error())
+		}
+	}
 	hRegister(
 		"DB operational", store.CheckHealth, time.Second*60)
 	return store, nil
 }
 
-type signerFactory func(hostname, port string, tlsConfig *tls.Config) *client.NotarySigner
+type signerFactory func(addresses []string, tlsConfig *tls.Config) *client.NotarySigner
 type healthRegister func(name string, checkFunc func() error, duration time.Duration)
 
 // parses the configuration and determines which trust service and key algorithm
@@ -93,6 +110,9 @@ func getTrustService(configuration *viper.Viper, sFactory signerFactory,
 
 	switch configuration.GetString("trust_service.type") {
 	case "local":
+		if configuration.GetString("trust_service.key_directory") != "" {
+			return getLocalKeyStoreTrustService(configuration)
+		}
 		logrus.Info("Using local signing service, which requires ED25519. " +
 			"Ignoring all other trust_service parameters, including keyAlgorithm")
 		return signed.NewEd25519(), data.ED25519Key, nil
@@ -112,13 +132,19 @@ func getTrustService(configuration *viper.Viper, sFactory signerFactory,
 		return nil, "", err
 	}
 
-	logrus.Info("Using remote signing service")
+	// several signers sharing the same key storage may be listed, in which
+	// case requests fail over between them
+	addresses := configuration.GetStringSlice("trust_service.addresses")
+	if len(addresses) == 0 {
+		addresses = []string{net.JoinHostPort(
+			configuration.GetString("trust_service.hostname"),
+			configuration.GetString("trust_service.port"),
+		)}
+	}
+
+	logrus.Infof("Using remote signing service at %s", strings.Join(addresses, ", "))
 
-	notarySigner := sFactory(
-		configuration.GetString("trust_service.hostname"),
-		configuration.GetString("trust_service.port"),
-		clientTLS,
-	)
+	notarySigner := sFactory(addresses, clientTLS)
 
 	minute := 1 * time.Minute
 	hRegister(
@@ -137,6 +163,42 @@ func getTrustService(configuration *viper.Viper, sFactory signerFactory,
 	return notarySigner, keyAlgo, nil
 }
 
+// getLocalKeyStoreTrustService returns a local signing service that keeps its
+// private keys encrypted on disk, in "trust_service.key_directory", so that
+// they survive a restart.  The keys are encrypted with the passphrase in the
+// environment variable named by "trust_service.passphrase_alias".
+func getLocalKeyStoreTrustService(configuration *viper.Viper) (signed.CryptoService, string, error) {
+	keyAlgo := configuration.GetString("trust_service.key_algorithm")
+	if keyAlgo == "" {
+		keyAlgo = data.ED25519Key
+	}
+	if keyAlgo != data.ED25519Key && keyAlgo != data.ECDSAKey && keyAlgo != data.RSAKey {
+		return nil, "", fmt.Errorf("invalid key algorithm configured: %s", keyAlgo)
+	}
+
+	alias := configuration.GetString("trust_service.passphrase_alias")
+	if alias == "" {
+		return nil, "", fmt.Errorf(
+			"must provide a passphrase alias for the local trust service key directory")
+	}
+	retriever := func(_, _ string, _ bool, _ int) (string, bool, error) {
+		pass := configuration.GetString(strings.ToUpper(alias))
+		if pass == "" {
+			return "", false, fmt.Errorf("expected env variable to not be empty: %s", alias)
+		}
+		return pass, false, nil
+	}
+
+	keyDir := utils.GetPathRelativeToConfig(configuration, "trust_service.key_directory")
+	keyStore, err := trustmanager.NewKeyFileStore(keyDir, retriever)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open the local trust service keys: %v", err)
+	}
+
+	logrus.Infof("Using local signing service with keys stored in %s", keyDir)
+	return cryptoservice.NewCryptoService(keyStore), keyAlgo, nil
+}
+
 // Parse the cache configurations for GET-ting current and checksummed metadata,
 // returning the configuration for current (non-content-addressed) metadata
 // first, then the configuration for consistent (content-addressed) metadata
@@ -171,6 +233,270 @@ func getCacheConfig(configuration *viper.Viper) (current, consistent utils.Cache
 	return
 }
 
+// Parse the webhooks configuration, returning a dispatcher for the configured
+// endpoints, or nil if there are none.  Each endpoint must have an http(s) `url`
+// and a `secret` used to sign the events sent to it.  `max_attempts`, `backoff`
+// and `timeout` optionally control how delivery of each event is retried.
+func getWebhooks(configuration *viper.Viper) (*webhooks.Dispatcher, error) {
+	rawEndpoints := configuration.Get("webhooks.endpoints")
+	if rawEndpoints == nil {
+		return nil, nil
+	}
+	endpointList, ok := rawEndpoints.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("webhooks.endpoints must be a list of endpoints")
+	}
+
+	conf := webhooks.Config{}
+	for _, rawEndpoint := range endpointList {
+		endpoint, ok := rawEndpoint.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("each webhook endpoint must have a url and a secret")
+		}
+		endpointURL, _ := endpoint["url"].(string)
+		secret, _ := endpoint["secret"].(string)
+		u, err := url.Parse(endpointURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid webhook endpoint url: %q", endpointURL)
+		}
+		if secret == "" {
+			return nil, fmt.Errorf("webhook endpoint %s must have a secret", endpointURL)
+		}
+		conf.Endpoints = append(conf.Endpoints, webhooks.Endpoint{URL: endpointURL, Secret: secret})
+	}
+	if len(conf.Endpoints) == 0 {
+		return nil, nil
+	}
+
+	if attempts := configuration.GetString("webhooks.max_attempts"); attempts != "" {
+		n, err := strconv.Atoi(attempts)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("webhooks.max_attempts must be a positive integer")
+		}
+		conf.MaxAttempts = n
+	}
+	for option, duration := range map[string]*time.Duration{
+		"webhooks.backoff": &conf.Backoff,
+		"webhooks.timeout": &conf.Timeout,
+	} {
+		if value := configuration.GetString(option); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%s must be a positive duration, such as \"10s\"", option)
+			}
+			*duration = d
+		}
+	}
+	return webhooks.NewDispatcher(conf), nil
+}
+
+// Parse the publish policies, each of which applies to the GUNs matching any
+// of its `guns` globs.  The rules of a policy are `min_thresholds` and
+// `max_expiry` (maps of role names to a threshold and a duration respectively),
+// `key_algorithms`, `required_delegations`, and `max_targets`.
+func getPolicies(configuration *viper.Viper) (policy.Policies, error) {
+	rawPolicies := configuration.Get("policies")
+	if rawPolicies == nil {
+		return nil, nil
+	}
+	policyList, ok := rawPolicies.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("policies must be a list of policies")
+	}
+
+	var policies policy.Policies
+	for i, rawPolicy := range policyList {
+		options, ok := rawPolicy.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("policy %d must be a map", i)
+		}
+		p, err := parsePolicy(options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy %d: %v", i, err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func parsePolicy(options map[string]interface{}) (policy.Policy, error) {
+	p := policy.Policy{}
+	var err error
+	if p.GUNs, err = stringList(options["guns"]); err != nil || len(p.GUNs) == 0 {
+		return p, fmt.Errorf("guns must be a list of GUN globs")
+	}
+
+	if rawThresholds, ok := options["min_thresholds"]; ok {
+		thresholds, ok := rawThresholds.(map[string]interface{})
+		if !ok {
+			return p, fmt.Errorf("min_thresholds must be a map of roles to thresholds")
+		}
+		p.MinThresholds = make(map[string]int)
+		for role, rawThreshold := range thresholds {
+			threshold, ok := rawThreshold.(float64)
+			if !ok || threshold < 1 || threshold != float64(int(threshold)) || !data.ValidRole(role) {
+				return p, fmt.Errorf("min_thresholds must be a map of roles to positive integers")
+			}
+			p.MinThresholds[role] = int(threshold)
+		}
+	}
+
+	if rawExpiries, ok := options["max_expiry"]; ok {
+		expiries, ok := rawExpiries.(map[string]interface{})
+		if !ok {
+			return p, fmt.Errorf("max_expiry must be a map of roles to durations")
+		}
+		p.MaxExpiry = make(map[string]time.Duration)
+		for role, rawExpiry := range expiries {
+			expiry, ok := rawExpiry.(string)
+			d, err := time.ParseDuration(expiry)
+			if !ok || err != nil || d <= 0 || !data.ValidRole(role) {
+				return p, fmt.Errorf("max_expiry must be a map of roles to positive durations, such as \"8760h\"")
+			}
+			p.MaxExpiry[role] = d
+		}
+	}
+
+	if rawAlgorithms, ok := options["key_algorithms"]; ok {
+		if p.KeyAlgorithms, err = stringList(rawAlgorithms); err != nil {
+			return p, fmt.Errorf("key_algorithms must be a list of key algorithms")
+		}
+		for _, algorithm := range p.KeyAlgorithms {
+			if algorithm != data.ECDSAKey && algorithm != data.RSAKey && algorithm != data.ED25519Key {
+				return p, fmt.Errorf("invalid key algorithm: %s", algorithm)
+			}
+		}
+	}
+
+	if rawDelegations, ok := options["required_delegations"]; ok {
+		if p.RequiredDelegations, err = stringList(rawDelegations); err != nil {
+			return p, fmt.Errorf("required_delegations must be a list of delegation roles")
+		}
+		for _, role := range p.RequiredDelegations {
+			if !data.IsDelegation(role) {
+				return p, fmt.Errorf("invalid delegation role: %s", role)
+			}
+		}
+	}
+
+	if rawMaxTargets, ok := options["max_targets"]; ok {
+		maxTargets, ok := rawMaxTargets.(float64)
+		if !ok || maxTargets < 1 || maxTargets != float64(int(maxTargets)) {
+			return p, fmt.Errorf("max_targets must be a positive integer")
+		}
+		p.MaxTargets = int(maxTargets)
+	}
+	return p, nil
+}
+
+func stringList(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list")
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("not a list of strings")
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// Parse the retention policy used when garbage collecting old versions of TUF
+// metadata.  `keep_versions` is the number of the newest versions of each role
+// to keep, and `keep_for` is a duration (such as "720h") for which every
+// version is kept.  At least one of them must be set.
+func getRetentionPolicy(configuration *viper.Viper) (gc.Policy, error) {
+	policy := gc.Policy{}
+	if keep := configuration.GetString("retention.keep_versions"); keep != "" {
+		n, err := strconv.Atoi(keep)
+		if err != nil || n < 0 {
+			return gc.Policy{}, fmt.Errorf("retention.keep_versions must be a non-negative integer")
+		}
+		policy.KeepVersions = n
+	}
+	if keepFor := configuration.GetString("retention.keep_for"); keepFor != "" {
+		d, err := time.ParseDuration(keepFor)
+		if err != nil || d < 0 {
+			return gc.Policy{}, fmt.Errorf("retention.keep_for must be a non-negative duration, such as \"720h\"")
+		}
+		policy.KeepFor = d
+	}
+	if err := policy.Valid(); err != nil {
+		return gc.Policy{}, err
+	}
+	return policy, nil
+}
+
+// Parse the configuration of the background worker that re-signs server-signed
+// timestamps and snapshots before they expire, returning nil if it is not
+// configured.  `window` is how long before expiry metadata is re-signed, and
+// is required to enable the worker.  `interval` (how often to scan every GUN)
+// and `concurrency` (how many GUNs to re-sign at once) are optional.
+func getResignConfig(configuration *viper.Viper) (*resign.Config, error) {
+	window := configuration.GetString("resign.window")
+	if window == "" {
+		return nil, nil
+	}
+	conf := resign.Config{Interval: time.Hour, Concurrency: 4}
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		return nil, fmt.Errorf("resign.window must be a positive duration, such as \"72h\"")
+	}
+	conf.Window = d
+	if interval := configuration.GetString("resign.interval"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("resign.interval must be a positive duration, such as \"1h\"")
+		}
+		conf.Interval = d
+	}
+	if concurrency := configuration.GetString("resign.concurrency"); concurrency != "" {
+		n, err := strconv.Atoi(concurrency)
+		if err != nil {
+			return nil, fmt.Errorf("resign.concurrency must be a positive integer")
+		}
+		conf.Concurrency = n
+	}
+	if err := conf.Valid(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// parseGCConfig parses just the parts of the configuration needed to garbage
+// collect old versions of TUF metadata: the storage backend and the
+// retention policy
+func parseGCConfig(configFilePath string, hRegister healthRegister) (storage.MetaStore, gc.Policy, error) {
+	config := viper.New()
+	utils.SetupViper(config, envPrefix)
+
+	if err := utils.ParseViper(config, configFilePath); err != nil {
+		return nil, gc.Policy{}, err
+	}
+
+	lvl, err := utils.ParseLogLevel(config, logrus.ErrorLevel)
+	if err != nil {
+		return nil, gc.Policy{}, err
+	}
+	logrus.SetLevel(lvl)
+
+	policy, err := getRetentionPolicy(config)
+	if err != nil {
+		return nil, gc.Policy{}, err
+	}
+
+	// garbage collecting an in-memory store from a separate process is pointless
+	store, err := getStore(config, []string{utils.MySQLBackend, utils.SqliteBackend}, hRegister)
+	if err != nil {
+		return nil, gc.Policy{}, err
+	}
+	return store, policy, nil
+}
+
 func parseServerConfig(configFilePath string, hRegister healthRegister) (context.Context, server.Config, error) {
 	config := viper.New()
 	utils.SetupViper(config, envPrefix)
@@ -196,35 +522,67 @@ func parseServerConfig(configFilePath string, hRegister healthRegister) (context
 	}
 	utils.SetUpBugsnag(bugsnagConf)
 
-	trust, keyAlgo, err := getTrustService(config, client.NewNotarySigner, hRegister)
+	trust, keyAlgo, err := getTrustService(config, client.NewFailoverNotarySigner, hRegister)
 	if err != nil {
 		return nil, server.Config{}, err
 	}
 	ctx = context.WithValue(ctx, "keyAlgorithm", keyAlgo)
 
-	store, err := getStore(config, []string{utils.MySQLBackend, utils.MemoryBackend}, hRegister)
+	store, err := getStore(config,
+		[]string{utils.MySQLBackend, utils.SqliteBackend, utils.MemoryBackend}, hRegister)
 	if err != nil {
 		return nil, server.Config{}, err
 	}
 	ctx = context.WithValue(ctx, "metaStore", store)
 
+	policies, err := getPolicies(config)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+	if len(policies) > 0 {
+		ctx = context.WithValue(ctx, "policies", policies)
+	}
+
+	dispatcher, err := getWebhooks(config)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+	if dispatcher != nil {
+		ctx = context.WithValue(ctx, "webhooks", dispatcher)
+	}
+
 	currentCache, consistentCache, err := getCacheConfig(config)
 	if err != nil {
 		return nil, server.Config{}, err
 	}
 
+	var resigner *resign.Worker
+	resignConf, err := getResignConfig(config)
+	if err != nil {
+		return nil, server.Config{}, err
+	}
+	if resignConf != nil {
+		resigner = resign.NewWorker(store, trust, *resignConf)
+	}
+
 	httpAddr, tlsConfig, err := getAddrAndTLSConfig(config)
 	if err != nil {
 		return nil, server.Config{}, err
 	}
+	authMethod := config.GetString("auth.type")
+	if authMethod == mtls.Name && (tlsConfig == nil || tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert) {
+		return nil, server.Config{}, fmt.Errorf(
+			"mtls auth requires server.client_ca_file, to verify client certificates")
+	}
 
 	return ctx, server.Config{
 		Addr:                         httpAddr,
 		TLSConfig:                    tlsConfig,
 		Trust:                        trust,
-		AuthMethod:                   config.GetString("auth.type"),
+		AuthMethod:                   authMethod,
 		AuthOpts:                     config.Get("auth.options"),
 		CurrentCacheControlConfig:    currentCache,
 		ConsistentCacheControlConfig: consistentCache,
+		Resigner:                     resigner,
 	}, nil
 }
@@ -7,10 +7,12 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/health"
 	"github.com/docker/notary/server"
+	"github.com/docker/notary/server/gc"
 	"github.com/docker/notary/version"
 )
 
@@ -50,6 +52,11 @@ func main() {
 	// when the server starts print the version for debugging and issue logs later
 	logrus.Infof("Version: %s, Git commit: %s", version.NotaryVersion, version.GitCommit)
 
+	if flag.Arg(0) == "gc" {
+		collectGarbage()
+		return
+	}
+
 	ctx, serverConfig, err := parseServerConfig(configFile, health.RegisterPeriodicFunc)
 	if err != nil {
 		logrus.Fatal(err.Error())
@@ -62,8 +69,25 @@ func main() {
 	return
 }
 
+// collectGarbage deletes the old versions of TUF metadata that the configured
+// retention policy does not keep, and exits.  It is intended to be run
+// periodically, for instance from cron.
+func collectGarbage() {
+	store, policy, err := parseGCConfig(configFile,
+		func(string, func() error, time.Duration) {})
+	if err != nil {
+		logrus.Fatal(err.Error())
+	}
+
+	deleted, err := gc.CollectAll(store, policy, time.Now())
+	fmt.Printf("Deleted %d old versions of TUF metadata\n", deleted)
+	if err != nil {
+		logrus.Fatal(err.Error())
+	}
+}
+
 func usage() {
-	fmt.Println("usage:", os.Args[0])
+	fmt.Println("usage:", os.Args[0], "[gc]")
 	flag.PrintDefaults()
 }
 
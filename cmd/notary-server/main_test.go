@@ -12,6 +12,10 @@ import (
 	"time"
 
 	"github.com/docker/notary"
+	"github.com/docker/notary/server/auth/mtls"
+	"github.com/docker/notary/server/gc"
+	"github.com/docker/notary/server/policy"
+	"github.com/docker/notary/server/resign"
 	"github.com/docker/notary/server/storage"
 	"github.com/docker/notary/signer/client"
 	"github.com/docker/notary/tuf/data"
@@ -109,7 +113,7 @@ func TestGetInvalidTrustService(t *testing.T) {
 
 	for _, config := range invalids {
 		_, _, err := getTrustService(configure(config),
-			client.NewNotarySigner, fakeRegister)
+			client.NewFailoverNotarySigner, fakeRegister)
 		require.Error(t, err)
 		require.Contains(t, err.Error(),
 			"must specify either a \"local\" or \"remote\" type for trust_service")
@@ -130,7 +134,7 @@ func TestGetLocalTrustService(t *testing.T) {
 	}
 
 	trust, algo, err := getTrustService(configure(localConfig),
-		client.NewNotarySigner, fakeRegister)
+		client.NewFailoverNotarySigner, fakeRegister)
 	require.NoError(t, err)
 	require.IsType(t, &signed.Ed25519{}, trust)
 	require.Equal(t, data.ED25519Key, algo)
@@ -139,6 +143,70 @@ func TestGetLocalTrustService(t *testing.T) {
 	require.Equal(t, 0, registerCalled)
 }
 
+// If a key directory is given for a local trust service, the keys it creates
+// are stored there, encrypted with the aliased passphrase, and are still
+// available to a new trust service using the same directory.
+func TestGetLocalKeyStoreTrustService(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "local-trust")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	localConfig := fmt.Sprintf(`{"trust_service": {
+		"type": "local",
+		"key_algorithm": "ecdsa",
+		"key_directory": %q,
+		"passphrase_alias": "timestamp_pass"
+	}}`, tempDir)
+	// the passphrase would normally come from the environment, as
+	// NOTARY_SERVER_TIMESTAMP_PASS
+	config := configure(localConfig)
+	config.Set("TIMESTAMP_PASS", "randompass")
+
+	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {}
+	trust, algo, err := getTrustService(config, client.NewFailoverNotarySigner, fakeRegister)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, algo)
+
+	pubKey, err := trust.Create(data.CanonicalTimestampRole, "gun", algo)
+	require.NoError(t, err)
+	require.Equal(t, data.ECDSAKey, pubKey.Algorithm())
+
+	// a restarted server still has the key
+	restarted, _, err := getTrustService(config, client.NewFailoverNotarySigner, fakeRegister)
+	require.NoError(t, err)
+	privKey, role, err := restarted.GetPrivateKey(pubKey.ID())
+	require.NoError(t, err)
+	require.Equal(t, data.CanonicalTimestampRole, role)
+	require.Equal(t, pubKey.ID(), privKey.ID())
+
+	// but not if it can't decrypt it
+	config.Set("TIMESTAMP_PASS", "wrongpass")
+	restarted, _, err = getTrustService(config, client.NewFailoverNotarySigner, fakeRegister)
+	require.NoError(t, err)
+	_, _, err = restarted.GetPrivateKey(pubKey.ID())
+	require.Error(t, err)
+}
+
+// A local trust service with a key directory needs a passphrase alias and a
+// valid key algorithm.
+func TestGetLocalKeyStoreTrustServiceInvalid(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "local-trust")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	invalids := map[string]string{
+		fmt.Sprintf(`{"trust_service": {"type": "local", "key_directory": %q}}`, tempDir): "must provide a passphrase alias",
+		fmt.Sprintf(`{"trust_service": {"type": "local", "key_directory": %q,
+			"passphrase_alias": "pass", "key_algorithm": "meh"}}`, tempDir): "invalid key algorithm configured",
+	}
+	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {}
+	for config, expected := range invalids {
+		_, _, err := getTrustService(configure(config), client.NewFailoverNotarySigner, fakeRegister)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), expected)
+	}
+}
+
 // Invalid key algorithms result in an error if a remote trust service was
 // specified.
 func TestGetTrustServiceInvalidKeyAlgorithm(t *testing.T) {
@@ -163,7 +231,7 @@ func TestGetTrustServiceInvalidKeyAlgorithm(t *testing.T) {
 
 	for _, config := range badKeyAlgos {
 		_, _, err := getTrustService(configure(config),
-			client.NewNotarySigner, fakeRegister)
+			client.NewFailoverNotarySigner, fakeRegister)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "invalid key algorithm")
 	}
@@ -198,7 +266,7 @@ func TestGetTrustServiceTLSMissingCertOrKey(t *testing.T) {
 	for _, clientTLSConfig := range configs {
 		jsonConfig := fmt.Sprintf(trustTLSConfigTemplate, clientTLSConfig)
 		config := configure(jsonConfig)
-		_, _, err := getTrustService(config, client.NewNotarySigner,
+		_, _, err := getTrustService(config, client.NewFailoverNotarySigner,
 			fakeRegister)
 		require.Error(t, err)
 		require.True(t,
@@ -225,7 +293,7 @@ func TestGetTrustServiceNoTLSConfig(t *testing.T) {
 	}
 
 	var tlsConfig *tls.Config
-	var fakeNewSigner = func(_, _ string, c *tls.Config) *client.NotarySigner {
+	var fakeNewSigner = func(_ []string, c *tls.Config) *client.NotarySigner {
 		tlsConfig = c
 		return &client.NotarySigner{}
 	}
@@ -241,6 +309,29 @@ func TestGetTrustServiceNoTLSConfig(t *testing.T) {
 	require.Equal(t, 1, registerCalled)
 }
 
+// The signer is at the configured hostname and port, unless a list of signer
+// addresses to fail over between is configured.
+func TestGetTrustServiceAddresses(t *testing.T) {
+	configs := map[string][]string{
+		`{"trust_service": {"type": "remote", "hostname": "notary-signer", "port": "7899",
+			"key_algorithm": "ecdsa"}}`: {"notary-signer:7899"},
+		`{"trust_service": {"type": "remote", "hostname": "ignored", "port": "7899",
+			"addresses": ["signer1:7899", "signer2:7899"], "key_algorithm": "ecdsa"}}`: {"signer1:7899", "signer2:7899"},
+	}
+	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {}
+
+	for config, expected := range configs {
+		var addresses []string
+		var fakeNewSigner = func(a []string, _ *tls.Config) *client.NotarySigner {
+			addresses = a
+			return &client.NotarySigner{}
+		}
+		_, _, err := getTrustService(configure(config), fakeNewSigner, fakeRegister)
+		require.NoError(t, err)
+		require.Equal(t, expected, addresses)
+	}
+}
+
 // The rest of the functionality of getTrustService depends upon
 // utils.ConfigureClientTLS, so this test just asserts that if successful,
 // the correct tls.Config is returned based on all the configuration parameters
@@ -257,7 +348,7 @@ func TestGetTrustServiceTLSSuccess(t *testing.T) {
 	}
 
 	var tlsConfig *tls.Config
-	var fakeNewSigner = func(_, _ string, c *tls.Config) *client.NotarySigner {
+	var fakeNewSigner = func(_ []string, c *tls.Config) *client.NotarySigner {
 		tlsConfig = c
 		return &client.NotarySigner{}
 	}
@@ -288,7 +379,7 @@ func TestGetTrustServiceTLSFailure(t *testing.T) {
 
 	_, _, err := getTrustService(
 		configure(fmt.Sprintf(trustTLSConfigTemplate, tlspart)),
-		client.NewNotarySigner, fakeRegister)
+		client.NewFailoverNotarySigner, fakeRegister)
 
 	require.Error(t, err)
 	require.True(t, strings.Contains(err.Error(),
@@ -337,6 +428,32 @@ func TestGetStoreDBStore(t *testing.T) {
 	require.Equal(t, 1, registerCalled)
 }
 
+// A SQLite database is set up with all the tables it needs
+func TestGetStoreSqliteCreatesTables(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	config := fmt.Sprintf(`{"storage": {"backend": "%s", "db_url": "%s/notary.db"}}`,
+		utils.SqliteBackend, tempBaseDir)
+
+	var healthChecks []func() error
+	var fakeRegister = func(_ string, check func() error, _ time.Duration) {
+		healthChecks = append(healthChecks, check)
+	}
+
+	store, err := getStore(configure(config),
+		[]string{utils.MySQLBackend, utils.SqliteBackend}, fakeRegister)
+	require.NoError(t, err)
+	require.Len(t, healthChecks, 1)
+	require.NoError(t, healthChecks[0]())
+
+	require.NoError(t, store.UpdateCurrent("gun", storage.MetaUpdate{Role: "root", Version: 1, Data: []byte("1")}))
+	_, d, err := store.GetCurrent("gun", "root")
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), d)
+}
+
 func TestGetMemoryStore(t *testing.T) {
 	var registerCalled = 0
 	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {
@@ -382,6 +499,210 @@ func TestGetCacheConfig(t *testing.T) {
 	}
 }
 
+func TestGetWebhooks(t *testing.T) {
+	for _, none := range []string{`{}`, `{"webhooks": {"endpoints": []}}`} {
+		dispatcher, err := getWebhooks(configure(none))
+		require.NoError(t, err)
+		require.Nil(t, dispatcher)
+	}
+
+	valid := `{"webhooks": {
+		"endpoints": [
+			{"url": "https://cd.example.com/notary", "secret": "secret1"},
+			{"url": "http://localhost:8000", "secret": "secret2"}
+		],
+		"max_attempts": 3, "backoff": "2s", "timeout": "5s"}}`
+	dispatcher, err := getWebhooks(configure(valid))
+	require.NoError(t, err)
+	require.NotNil(t, dispatcher)
+
+	invalids := []string{
+		`{"webhooks": {"endpoints": "https://cd.example.com"}}`,
+		`{"webhooks": {"endpoints": ["https://cd.example.com"]}}`,
+		`{"webhooks": {"endpoints": [{"url": "https://cd.example.com"}]}}`,
+		`{"webhooks": {"endpoints": [{"url": "ftp://cd.example.com", "secret": "secret"}]}}`,
+		`{"webhooks": {"endpoints": [{"secret": "secret"}]}}`,
+		`{"webhooks": {"endpoints": [{"url": "https://cd.example.com", "secret": "secret"}], "max_attempts": 0}}`,
+		`{"webhooks": {"endpoints": [{"url": "https://cd.example.com", "secret": "secret"}], "backoff": "soon"}}`,
+		`{"webhooks": {"endpoints": [{"url": "https://cd.example.com", "secret": "secret"}], "timeout": "-1s"}}`,
+	}
+	for _, invalid := range invalids {
+		_, err := getWebhooks(configure(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestGetPolicies(t *testing.T) {
+	policies, err := getPolicies(configure(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, policies)
+
+	valid := `{"policies": [
+		{
+			"guns": ["docker.com/*"],
+			"min_thresholds": {"root": 2, "targets/releases": 1},
+			"key_algorithms": ["ecdsa", "rsa"],
+			"max_expiry": {"timestamp": "336h"},
+			"required_delegations": ["targets/releases"],
+			"max_targets": 100
+		},
+		{"guns": ["*"], "max_targets": 1000}
+	]}`
+	policies, err = getPolicies(configure(valid))
+	require.NoError(t, err)
+	require.Equal(t, policy.Policies{
+		{
+			GUNs:                []string{"docker.com/*"},
+			MinThresholds:       map[string]int{"root": 2, "targets/releases": 1},
+			KeyAlgorithms:       []string{data.ECDSAKey, data.RSAKey},
+			MaxExpiry:           map[string]time.Duration{"timestamp": 336 * time.Hour},
+			RequiredDelegations: []string{"targets/releases"},
+			MaxTargets:          100,
+		},
+		{GUNs: []string{"*"}, MaxTargets: 1000},
+	}, policies)
+
+	invalids := []string{
+		`{"policies": {"guns": ["*"]}}`,
+		`{"policies": [{"max_targets": 1}]}`,
+		`{"policies": [{"guns": "*"}]}`,
+		`{"policies": [{"guns": ["*"], "min_thresholds": {"root": 0}}]}`,
+		`{"policies": [{"guns": ["*"], "min_thresholds": {"root": 1.5}}]}`,
+		`{"policies": [{"guns": ["*"], "min_thresholds": {"invalid": 1}}]}`,
+		`{"policies": [{"guns": ["*"], "key_algorithms": ["dsa"]}]}`,
+		`{"policies": [{"guns": ["*"], "max_expiry": {"root": "forever"}}]}`,
+		`{"policies": [{"guns": ["*"], "max_expiry": {"root": 10}}]}`,
+		`{"policies": [{"guns": ["*"], "required_delegations": ["releases"]}]}`,
+		`{"policies": [{"guns": ["*"], "max_targets": 0}]}`,
+	}
+	for _, invalid := range invalids {
+		_, err := getPolicies(configure(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestGetRetentionPolicy(t *testing.T) {
+	valids := map[string]gc.Policy{
+		`{"retention": {"keep_versions": 5}}`:                    {KeepVersions: 5},
+		`{"retention": {"keep_for": "720h"}}`:                    {KeepFor: 720 * time.Hour},
+		`{"retention": {"keep_versions": 2, "keep_for": "10m"}}`: {KeepVersions: 2, KeepFor: 10 * time.Minute},
+	}
+	invalids := []string{
+		`{}`,
+		`{"retention": {"keep_versions": 0}}`,
+		`{"retention": {"keep_versions": -1}}`,
+		`{"retention": {"keep_versions": "hello"}}`,
+		`{"retention": {"keep_for": "forever"}}`,
+		`{"retention": {"keep_for": "-1h"}}`,
+	}
+
+	for config, expected := range valids {
+		policy, err := getRetentionPolicy(configure(config))
+		require.NoError(t, err, config)
+		require.Equal(t, expected, policy)
+	}
+
+	for _, invalid := range invalids {
+		_, err := getRetentionPolicy(configure(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestGetResignConfig(t *testing.T) {
+	conf, err := getResignConfig(configure(`{}`))
+	require.NoError(t, err)
+	require.Nil(t, conf)
+
+	valids := map[string]resign.Config{
+		`{"resign": {"window": "72h"}}`: {Window: 72 * time.Hour, Interval: time.Hour, Concurrency: 4},
+		`{"resign": {"window": "1h", "interval": "10m", "concurrency": 10}}`: {
+			Window: time.Hour, Interval: 10 * time.Minute, Concurrency: 10},
+	}
+	invalids := []string{
+		`{"resign": {"window": "soon"}}`,
+		`{"resign": {"window": "-1h"}}`,
+		`{"resign": {"window": "1h", "interval": "often"}}`,
+		`{"resign": {"window": "1h", "interval": "0s"}}`,
+		`{"resign": {"window": "1h", "concurrency": "many"}}`,
+		`{"resign": {"window": "1h", "concurrency": 0}}`,
+	}
+
+	for config, expected := range valids {
+		conf, err := getResignConfig(configure(config))
+		require.NoError(t, err, config)
+		require.Equal(t, expected, *conf)
+	}
+
+	for _, invalid := range invalids {
+		_, err := getResignConfig(configure(invalid))
+		require.Error(t, err, invalid)
+	}
+}
+
+// Garbage collection only needs the storage and retention configuration, and
+// does not work against an in-memory store
+func TestParseGCConfig(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {}
+
+	configFile := tempBaseDir + "/config.json"
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(fmt.Sprintf(
+		`{"storage": {"backend": "%s", "db_url": "notary.db"}, "retention": {"keep_versions": 3}}`,
+		utils.SqliteBackend)), 0644))
+
+	store, policy, err := parseGCConfig(configFile, fakeRegister)
+	require.NoError(t, err)
+	require.NotNil(t, store)
+	require.Equal(t, gc.Policy{KeepVersions: 3}, policy)
+
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(fmt.Sprintf(
+		`{"storage": {"backend": "%s"}, "retention": {"keep_versions": 3}}`,
+		utils.MemoryBackend)), 0644))
+	_, _, err = parseGCConfig(configFile, fakeRegister)
+	require.Error(t, err)
+}
+
+// mtls auth can only be used if the server verifies client certificates
+func TestParseServerConfigMTLSRequiresClientCA(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	var fakeRegister = func(_ string, _ func() error, _ time.Duration) {}
+
+	configFile := tempBaseDir + "/config.json"
+	writeConfig := func(serverSection string) {
+		require.NoError(t, ioutil.WriteFile(configFile, []byte(fmt.Sprintf(`{
+			"server": %s,
+			"trust_service": {"type": "local"},
+			"storage": {"backend": "memory"},
+			"auth": {"type": "mtls", "options": {"rules": []}}
+		}`, serverSection)), 0644))
+	}
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	tlsOpts := fmt.Sprintf(`"tls_cert_file": "%s/%s", "tls_key_file": "%s/%s"`, cwd, Cert, cwd, Key)
+
+	for _, invalid := range []string{
+		`{"http_addr": ":4443"}`,
+		fmt.Sprintf(`{"http_addr": ":4443", %s}`, tlsOpts),
+	} {
+		writeConfig(invalid)
+		_, _, err = parseServerConfig(configFile, fakeRegister)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "client_ca_file")
+	}
+
+	writeConfig(fmt.Sprintf(`{"http_addr": ":4443", %s, "client_ca_file": "%s/%s"}`, tlsOpts, cwd, Root))
+	_, conf, err := parseServerConfig(configFile, fakeRegister)
+	require.NoError(t, err)
+	require.Equal(t, mtls.Name, conf.AuthMethod)
+}
+
 // For sanity, make sure we can always parse the sample config
 func TestSampleConfig(t *testing.T) {
 	var registerCalled = 0
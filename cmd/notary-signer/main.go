@@ -6,6 +6,7 @@ import (
 	_ "expvar"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -27,6 +28,7 @@ import (
 	"github.com/docker/notary/utils"
 	"github.com/docker/notary/version"
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/viper"
 
 	"github.com/Sirupsen/logrus"
@@ -85,21 +87,9 @@ func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string) (
 		keyStore = trustmanager.NewKeyMemoryStore(
 			passphrase.ConstantRetriever("memory-db-ignore"))
 	} else {
-		defaultAlias := configuration.GetString("storage.default_alias")
-		if defaultAlias == "" {
-			// backwards compatibility - support this environment variable
-			defaultAlias = configuration.GetString(defaultAliasEnv)
-		}
-
-		if defaultAlias == "" {
-			return nil, fmt.Errorf("must provide a default alias for the key DB")
-		}
-		logrus.Debug("Default Alias: ", defaultAlias)
-
-		dbStore, err := keydbstore.NewKeyDBStore(
-			passphraseRetriever, defaultAlias, storeConfig.Backend, storeConfig.Source)
+		dbStore, err := getKeyDBStore(configuration, storeConfig)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create a new keydbstore: %v", err)
+			return nil, err
 		}
 
 		health.RegisterPeriodicFunc(
@@ -114,6 +104,72 @@ func setUpCryptoservices(configuration *viper.Viper, allowedBackends []string) (
 	return cryptoServices, nil
 }
 
+// Opens the key database described by the storage configuration, using the
+// configured default alias to encrypt new keys
+func getKeyDBStore(configuration *viper.Viper, storeConfig *utils.Storage) (*keydbstore.KeyDBStore, error) {
+	defaultAlias := configuration.GetString("storage.default_alias")
+	if defaultAlias == "" {
+		// backwards compatibility - support this environment variable
+		defaultAlias = configuration.GetString(defaultAliasEnv)
+	}
+
+	if defaultAlias == "" {
+		return nil, fmt.Errorf("must provide a default alias for the key DB")
+	}
+	logrus.Debug("Default Alias: ", defaultAlias)
+
+	dbStore, err := keydbstore.NewKeyDBStore(
+		passphraseRetriever, defaultAlias, storeConfig.Backend, storeConfig.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a new keydbstore: %v", err)
+	}
+	if storeConfig.Backend == utils.SqliteBackend {
+		// there are no migrations for SQLite, so create the table here
+		if err := dbStore.CreateTable(); err != nil {
+			return nil, fmt.Errorf("failed to create the key table: %v", err)
+		}
+	}
+	return dbStore, nil
+}
+
+// Re-encrypts every key in the configured key database that is encrypted with
+// the passphrase of one alias with the passphrase of another, printing its
+// progress to out.  The passphrases of both aliases must be set in the
+// environment.
+func rotatePassphrase(configuration *viper.Viper, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("rotate-passphrase", flag.ContinueOnError)
+	flags.SetOutput(out)
+	from := flags.String("from", "", "The passphrase alias the keys are currently encrypted with")
+	to := flags.String("to", "", "The passphrase alias to re-encrypt the keys with")
+	batchSize := flags.Int("batch", 100, "The number of keys to re-encrypt in each transaction")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		return fmt.Errorf("both --from and --to passphrase aliases must be provided")
+	}
+
+	storeConfig, err := utils.ParseStorage(configuration,
+		[]string{utils.MySQLBackend, utils.SqliteBackend})
+	if err != nil {
+		return err
+	}
+	dbStore, err := getKeyDBStore(configuration, storeConfig)
+	if err != nil {
+		return err
+	}
+
+	rotated, err := dbStore.RotatePassphraseAlias(*from, *to, *batchSize, func(rotated, total int) {
+		fmt.Fprintf(out, "Re-encrypted %d of %d keys\n", rotated, total)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt keys after re-encrypting %d; "+
+			"run the rotation again to resume it: %v", rotated, err)
+	}
+	fmt.Fprintf(out, "Re-encrypted %d keys from passphrase alias %s to %s\n", rotated, *from, *to)
+	return nil
+}
+
 // set up the GRPC server
 func setupGRPCServer(grpcAddr string, tlsConfig *tls.Config,
 	cryptoServices signer.CryptoServiceIndex) (*grpc.Server, net.Listener, error) {
@@ -199,6 +255,13 @@ func main() {
 	}
 	utils.SetUpBugsnag(bugsnagConf)
 
+	if flag.Arg(0) == "rotate-passphrase" {
+		if err := rotatePassphrase(mainViper, flag.Args()[1:], os.Stdout); err != nil {
+			logrus.Fatal(err.Error())
+		}
+		return
+	}
+
 	// parse server config
 	httpAddr, grpcAddr, tlsConfig, err := getAddrAndTLSConfig(mainViper)
 	if err != nil {
@@ -207,7 +270,7 @@ func main() {
 
 	// setup the cryptoservices
 	cryptoServices, err := setUpCryptoservices(mainViper,
-		[]string{utils.MySQLBackend, utils.MemoryBackend})
+		[]string{utils.MySQLBackend, utils.SqliteBackend, utils.MemoryBackend})
 	if err != nil {
 		logrus.Fatal(err.Error())
 	}
@@ -232,7 +295,7 @@ func main() {
 }
 
 func usage() {
-	log.Println("usage:", os.Args[0], "<config>")
+	log.Println("usage:", os.Args[0], "[rotate-passphrase --from ALIAS --to ALIAS]")
 	flag.PrintDefaults()
 }
 
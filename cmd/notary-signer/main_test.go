@@ -8,6 +8,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/docker/notary/cryptoservice"
+	"github.com/docker/notary/passphrase"
 	"github.com/docker/notary/signer"
 	"github.com/docker/notary/signer/keydbstore"
 	"github.com/docker/notary/tuf/data"
@@ -160,6 +162,28 @@ func TestSetupCryptoServicesDBStoreSuccess(t *testing.T) {
 	require.Equal(t, 1, count)
 }
 
+// If a SQLite backend is specified, the key table is created if it does not
+// already exist.
+func TestSetupCryptoServicesSqliteCreatesTable(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+	dbFile := tempBaseDir + "/signer.db"
+
+	_, err = setUpCryptoservices(
+		configure(fmt.Sprintf(
+			`{"storage": {"backend": "%s", "db_url": "%s"},
+			"default_alias": "timestamp"}`,
+			utils.SqliteBackend, dbFile)),
+		[]string{utils.SqliteBackend})
+	require.NoError(t, err)
+
+	db, err := gorm.Open("sqlite3", dbFile)
+	require.NoError(t, err)
+	defer db.Close()
+	require.True(t, db.HasTable(&keydbstore.GormPrivateKey{}))
+}
+
 // If a memory backend is specified, then a default alias is not needed, and
 // a valid CryptoService is returned.
 func TestSetupCryptoServicesMemoryStore(t *testing.T) {
@@ -187,6 +211,68 @@ func TestSetupCryptoServicesMemoryStore(t *testing.T) {
 	require.NotNil(t, privKey)
 }
 
+// rotate-passphrase re-encrypts the keys using one passphrase alias with
+// another, reporting its progress
+func TestRotatePassphrase(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+	dbFile := tempBaseDir + "/signer.db"
+
+	os.Setenv("NOTARY_SIGNER_OLD", "oldpassword")
+	defer os.Unsetenv("NOTARY_SIGNER_OLD")
+	os.Setenv("NOTARY_SIGNER_NEW", "newpassword")
+	defer os.Unsetenv("NOTARY_SIGNER_NEW")
+
+	config := configure(fmt.Sprintf(
+		`{"storage": {"backend": "%s", "db_url": "%s", "default_alias": "old"}}`,
+		utils.SqliteBackend, dbFile))
+	storeConfig, err := utils.ParseStorage(config, []string{utils.SqliteBackend})
+	require.NoError(t, err)
+	dbStore, err := getKeyDBStore(config, storeConfig)
+	require.NoError(t, err)
+	cryptoService := cryptoservice.NewCryptoService(dbStore)
+	var keyIDs []string
+	for i := 0; i < 3; i++ {
+		pubKey, err := cryptoService.Create("timestamp", "", data.ECDSAKey)
+		require.NoError(t, err)
+		keyIDs = append(keyIDs, pubKey.ID())
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, rotatePassphrase(config,
+		[]string{"--from", "old", "--to", "new", "--batch", "2"}, &out))
+	require.Equal(t, "Re-encrypted 2 of 3 keys\nRe-encrypted 3 of 3 keys\n"+
+		"Re-encrypted 3 keys from passphrase alias old to new\n", out.String())
+
+	// the keys can be decrypted without the old passphrase
+	os.Unsetenv("NOTARY_SIGNER_OLD")
+	dbStore, err = keydbstore.NewKeyDBStore(passphrase.ConstantRetriever("newpassword"),
+		"new", utils.SqliteBackend, dbFile)
+	require.NoError(t, err)
+	for _, keyID := range keyIDs {
+		privKey, _, err := dbStore.GetKey(keyID)
+		require.NoError(t, err)
+		require.Equal(t, keyID, privKey.ID())
+	}
+}
+
+// rotate-passphrase needs both aliases and a database
+func TestRotatePassphraseInvalid(t *testing.T) {
+	sqlite := configure(fmt.Sprintf(
+		`{"storage": {"backend": "%s", "db_url": "/tmp/nope.db", "default_alias": "old"}}`,
+		utils.SqliteBackend))
+	memory := configure(fmt.Sprintf(`{"storage": {"backend": "%s"}}`, utils.MemoryBackend))
+
+	var out bytes.Buffer
+	err := rotatePassphrase(sqlite, []string{"--from", "old"}, &out)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "both --from and --to")
+
+	err = rotatePassphrase(memory, []string{"--from", "old", "--to", "new"}, &out)
+	require.Error(t, err)
+}
+
 func TestSetupHTTPServer(t *testing.T) {
 	httpServer := setupHTTPServer(":4443", nil, make(signer.CryptoServiceIndex))
 	require.Equal(t, ":4443", httpServer.Addr)
@@ -136,15 +136,20 @@ func (c *certCommander) certRemove(cmd *cobra.Command, args []string) error {
 	}
 
 	if removeTrustData {
+		trustPin, err := getTrustPinning(config)
+		if err != nil {
+			return err
+		}
+
 		// Remove all TUF data, so call RemoveTrustData on a NotaryRepository with the GUN
 		// no online operations are performed so the transport argument is nil
 		nRepo, err := notaryclient.NewNotaryRepository(
-			trustDir, c.certRemoveGUN, getRemoteTrustServer(config), nil, c.retriever)
+			trustDir, c.certRemoveGUN, getRemoteTrustServer(config), nil, c.retriever, trustPin)
 		if err != nil {
 			return fmt.Errorf("Could not establish trust data for GUN %s", c.certRemoveGUN)
 		}
 		// DeleteTrustData will pick up all of the same certificates by GUN (CN) and remove them
-		err = nRepo.DeleteTrustData()
+		err = nRepo.DeleteTrustData(false)
 		if err != nil {
 			return fmt.Errorf("Failed to delete trust data for %s", c.certRemoveGUN)
 		}
@@ -34,7 +34,7 @@ var cmdDelegationRemoveTemplate = usageTemplate{
 var cmdDelegationAddTemplate = usageTemplate{
 	Use:   "add [ GUN ] [ Role ] <X509 file path 1> ...",
 	Short: "Add a keys to delegation using the provided public key X509 certificates.",
-	Long:  "Add a keys to delegation using the provided public key PEM encoded X509 certificates in a specific Global Unique Name.",
+	Long:  "Add a keys to delegation using the provided public key PEM encoded X509 certificates in a specific Global Unique Name.  Use --threshold to require more than one of the delegation's keys to sign its metadata.",
 }
 
 type delegationCommander struct {
@@ -44,6 +44,7 @@ type delegationCommander struct {
 
 	paths                         []string
 	allPaths, removeAll, forceYes bool
+	threshold                     int
 }
 
 func (d *delegationCommander) GetCommand() *cobra.Command {
@@ -59,6 +60,7 @@ func (d *delegationCommander) GetCommand() *cobra.Command {
 	cmdAddDelg := cmdDelegationAddTemplate.ToCommand(d.delegationAdd)
 	cmdAddDelg.Flags().StringSliceVar(&d.paths, "paths", nil, "List of paths to add")
 	cmdAddDelg.Flags().BoolVar(&d.allPaths, "all-paths", false, "Add all paths to this delegation")
+	cmdAddDelg.Flags().IntVar(&d.threshold, "threshold", 0, "Number of keys required to sign this delegation's metadata")
 	cmd.AddCommand(cmdAddDelg)
 	return cmd
 }
@@ -78,14 +80,19 @@ func (d *delegationCommander) delegationsList(cmd *cobra.Command, args []string)
 
 	gun := args[0]
 
-	rt, err := getTransport(config, gun, true)
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	// initialize repo with transport to get latest state of the world before listing delegations
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, d.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, d.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -137,10 +144,15 @@ func (d *delegationCommander) delegationRemove(cmd *cobra.Command, args []string
 		d.paths = nil
 	}
 
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
 	// no online operations are performed by add so the transport argument
 	// should be nil
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, d.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, d.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -198,10 +210,11 @@ func (d *delegationCommander) delegationRemove(cmd *cobra.Command, args []string
 
 // delegationAdd creates a new delegation by adding a public key from a certificate to a specific role in a GUN
 func (d *delegationCommander) delegationAdd(cmd *cobra.Command, args []string) error {
-	// We must have at least the gun and role name, and at least one key or path (or the --all-paths flag) to add
-	if len(args) < 2 || len(args) < 3 && d.paths == nil && !d.allPaths {
+	// We must have at least the gun and role name, and at least one key or path (or the --all-paths flag) to add,
+	// or a new threshold
+	if len(args) < 2 || len(args) < 3 && d.paths == nil && !d.allPaths && d.threshold == 0 {
 		cmd.Usage()
-		return fmt.Errorf("must specify the Global Unique Name and the role of the delegation along with the public key certificate paths and/or a list of paths to add")
+		return fmt.Errorf("must specify the Global Unique Name and the role of the delegation along with the public key certificate paths, a list of paths and/or a threshold to add")
 	}
 
 	config, err := d.configGetter()
@@ -243,10 +256,15 @@ func (d *delegationCommander) delegationAdd(cmd *cobra.Command, args []string) e
 		d.paths = []string{""}
 	}
 
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
 	// no online operations are performed by add so the transport argument
 	// should be nil
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, d.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, d.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -257,6 +275,13 @@ func (d *delegationCommander) delegationAdd(cmd *cobra.Command, args []string) e
 		return fmt.Errorf("failed to create delegation: %v", err)
 	}
 
+	// Set the threshold once any new keys have been added
+	if d.threshold != 0 {
+		if err = nRepo.SetDelegationThreshold(role, d.threshold); err != nil {
+			return fmt.Errorf("failed to create delegation: %v", err)
+		}
+	}
+
 	// Make keyID slice for better CLI print
 	pubKeyIDs := []string{}
 	for _, pubKey := range pubKeys {
@@ -275,6 +300,9 @@ func (d *delegationCommander) delegationAdd(cmd *cobra.Command, args []string) e
 	if d.paths != nil || d.allPaths {
 		addingItems = addingItems + fmt.Sprintf("with paths [%s], ", prettyPrintPaths(d.paths))
 	}
+	if d.threshold != 0 {
+		addingItems = addingItems + fmt.Sprintf("with threshold %d, ", d.threshold)
+	}
 	cmd.Printf(
 		"Addition of delegation role %s %sto repository \"%s\" staged for next publish.\n",
 		role, addingItems, gun)
@@ -90,6 +90,35 @@ func TestAddInvalidShortPubkeyCert(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestAddInvalidDelegationThreshold(t *testing.T) {
+	// Cleanup after test
+	defer os.RemoveAll(testTrustDir)
+
+	// Setup commander
+	commander := setup()
+
+	// Should error due to a threshold of less than one
+	cmd := commander.GetCommand()
+	commander.threshold = -1
+	err := commander.delegationAdd(cmd, []string{"gun", "targets/delegation"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "threshold must be at least 1")
+}
+
+func TestAddDelegationThresholdOnly(t *testing.T) {
+	// Cleanup after test
+	defer os.RemoveAll(testTrustDir)
+
+	// Setup commander
+	commander := setup()
+
+	// A threshold can be staged without adding any keys or paths
+	cmd := commander.GetCommand()
+	commander.threshold = 2
+	err := commander.delegationAdd(cmd, []string{"gun", "targets/delegation"})
+	require.NoError(t, err)
+}
+
 func TestRemoveInvalidDelegationName(t *testing.T) {
 	// Cleanup after test
 	defer os.RemoveAll(testTrustDir)
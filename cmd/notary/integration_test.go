@@ -151,7 +151,174 @@ func TestClientTufInteraction(t *testing.T) {
 	require.False(t, strings.Contains(string(output), target))
 }
 
+// Publishes two repos and lists them with list-repos
+func TestClientListRepos(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	// -- tests --
+	output, err := runCommand(t, tempDir, "-s", server.URL, "list-repos")
+	require.NoError(t, err)
+	require.Contains(t, output, "No trusted collections found")
+
+	for _, gun := range []string{"gun", "other/gun"} {
+		_, err = runCommand(t, tempDir, "-s", server.URL, "init", gun)
+		require.NoError(t, err)
+		_, err = runCommand(t, tempDir, "-s", server.URL, "publish", gun)
+		require.NoError(t, err)
+	}
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list-repos")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun", "other/gun"}, strings.Fields(output))
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "list-repos", "--prefix", "other/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"other/gun"}, strings.Fields(output))
+}
+
+// Publishes a repo and checks its health, which fails once the warning window
+// covers when its metadata expires
+func TestClientCheck(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	// -- tests --
+	_, err := runCommand(t, tempDir, "-s", server.URL, "check", "gun")
+	require.Error(t, err)
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "check", "gun")
+	require.NoError(t, err)
+	for _, role := range data.BaseRoles {
+		require.Contains(t, output, role)
+	}
+	require.Contains(t, output, "1 of 1")
+	require.NotContains(t, output, "WARNING")
+
+	output, err = runCommand(t, tempDir, "-s", server.URL, "check", "gun", "--warn-within", "175200h")
+	require.Error(t, err)
+	require.Contains(t, output, "WARNING: targets metadata expires on")
+}
+
+// Publishes a repo and deletes it, locally and from the server
+func TestClientDelete(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	tempDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(tempDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	// -- tests --
+	_, err := runCommand(t, tempDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+
+	// nothing is deleted without confirmation
+	_, err = runCommand(t, tempDir, "-s", server.URL, "delete", "gun", "--remote")
+	require.Error(t, err)
+	_, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+
+	output, err := runCommand(t, tempDir, "-s", server.URL, "delete", "gun", "--remote", "-y")
+	require.NoError(t, err)
+	require.Contains(t, output, "Successfully deleted trust data for gun locally and on the remote trust server")
+	_, err = os.Stat(filepath.Join(tempDir, "tuf", "gun", "metadata", "root.json"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = runCommand(t, tempDir, "-s", server.URL, "list", "gun")
+	require.Error(t, err)
+}
+
 // Initialize repo and test delegations commands by adding, listing, and removing delegations
+// Changes staged on a machine without signing keys can be exported, signed
+// elsewhere, and published
+func TestClientTufBundleInteraction(t *testing.T) {
+	// -- setup --
+	setUp(t)
+
+	offlineDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(offlineDir)
+	onlineDir := tempDirWithConfig(t, "{}")
+	defer os.RemoveAll(onlineDir)
+
+	server := setupServer()
+	defer server.Close()
+
+	tempFile, err := ioutil.TempFile("", "targetfile")
+	require.NoError(t, err)
+	tempFile.Close()
+	defer os.Remove(tempFile.Name())
+
+	bundleFile := filepath.Join(onlineDir, "bundle.json")
+	signedBundleFile := filepath.Join(offlineDir, "signed.json")
+
+	var (
+		output string
+		target = "sdgkadga"
+	)
+	// -- tests --
+
+	// init repo, and have the server sign snapshots
+	_, err = runCommand(t, offlineDir, "-s", server.URL, "init", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, offlineDir, "-s", server.URL, "publish", "gun")
+	require.NoError(t, err)
+	_, err = runCommand(t, offlineDir, "-s", server.URL, "key", "rotate", "gun", "snapshot", "-r")
+	require.NoError(t, err)
+
+	// stage a target where there are no keys, and export it
+	_, err = runCommand(t, onlineDir, "add", "gun", target, tempFile.Name())
+	require.NoError(t, err)
+	_, err = runCommand(t, onlineDir, "-s", server.URL, "export-unsigned", "gun")
+	require.Error(t, err)
+	output, err = runCommand(t, onlineDir, "-s", server.URL, "export-unsigned", "gun", "--output", bundleFile)
+	require.NoError(t, err)
+	require.True(t, strings.Contains(output, "targets"))
+
+	// the changes are now in the bundle rather than the changelist
+	output, err = runCommand(t, onlineDir, "status", "gun")
+	require.NoError(t, err)
+	require.False(t, strings.Contains(output, target))
+
+	// an unsigned bundle can't be published
+	_, err = runCommand(t, onlineDir, "-s", server.URL, "publish", "gun", "--bundle", bundleFile)
+	require.Error(t, err)
+
+	// sign it where the keys are, and publish it
+	_, err = runCommand(t, onlineDir, "sign-bundle", bundleFile)
+	require.Error(t, err)
+	_, err = runCommand(t, offlineDir, "sign-bundle", bundleFile, "--output", signedBundleFile)
+	require.NoError(t, err)
+	_, err = runCommand(t, onlineDir, "-s", server.URL, "publish", "gun", "--bundle", signedBundleFile)
+	require.NoError(t, err)
+
+	// list repo - see target
+	output, err = runCommand(t, offlineDir, "-s", server.URL, "list", "gun")
+	require.NoError(t, err)
+	require.True(t, strings.Contains(output, target))
+}
+
 func TestClientDelegationsInteraction(t *testing.T) {
 	setUp(t)
 
@@ -36,8 +36,14 @@ var cmdKeyListTemplate = usageTemplate{
 
 var cmdRotateKeyTemplate = usageTemplate{
 	Use:   "rotate [ GUN ] [ key role ]",
-	Short: "Rotate a signing (non-root) key of the given type for the given Globally Unique Name and role.",
-	Long:  "Generates a new key for the given Globally Unique Name and role (one of \"snapshot\", \"targets\", or \"timestamp\").  If rotating to a server-managed key, a new key is requested from the server rather than generated.  If the generation or key request is successful, the key rotation is immediately published.  No other changes, even if they are staged, will be published.",
+	Short: "Rotate a signing key of the given type for the given Globally Unique Name and role.",
+	Long:  "Generates a new key for the given Globally Unique Name and role (one of \"root\", \"snapshot\", \"targets\", or \"timestamp\").  If rotating to a server-managed key, a new key is requested from the server rather than generated.  If rotating the root key, a new root key and certificate are generated, and the new root is signed with both the old and new root keys so that clients trusting the old root will accept it.  If the generation or key request is successful, the key rotation is immediately published.  No other changes, even if they are staged, will be published.",
+}
+
+var cmdKeyThresholdTemplate = usageTemplate{
+	Use:   "threshold [ GUN ] [ key role ] [ threshold ] <X509 file path 1> ...",
+	Short: "Sets the signing threshold of the root or targets role for the given Globally Unique Name.",
+	Long:  "Adds the public keys from the provided PEM encoded X509 certificates to the root or targets role for the given Globally Unique Name, and sets how many of the role's keys must sign its metadata.  Root key certificates must have the Globally Unique Name as their common name.  The change is staged for the next publish.  If the threshold cannot be met with the keys available locally, publishing saves partially signed metadata, which the other key holders can then sign with `notary sign`.",
 }
 
 var cmdKeyGenerateRootKeyTemplate = usageTemplate{
@@ -129,6 +135,8 @@ func (k *keyCommander) GetCommand() *cobra.Command {
 			"Required for timestamp role, optional for snapshot role")
 	cmd.AddCommand(cmdRotateKey)
 
+	cmd.AddCommand(cmdKeyThresholdTemplate.ToCommand(k.keysThreshold))
+
 	return cmd
 }
 
@@ -413,20 +421,83 @@ func (k *keyCommander) keysRotate(cmd *cobra.Command, args []string) error {
 	gun := args[0]
 	rotateKeyRole := args[1]
 
-	rt, err := getTransport(config, gun, false)
+	rt, err := getTransport(config, gun, readWrite)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
 		config.GetString("trust_dir"), gun, getRemoteTrustServer(config),
-		rt, k.getRetriever())
+		rt, k.getRetriever(), trustPin)
 	if err != nil {
 		return err
 	}
 	return nRepo.RotateKey(rotateKeyRole, k.rotateKeyServerManaged)
 }
 
+func (k *keyCommander) keysThreshold(cmd *cobra.Command, args []string) error {
+	if len(args) < 3 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN, a key role and a threshold")
+	}
+
+	config, err := k.configGetter()
+	if err != nil {
+		return err
+	}
+
+	gun := args[0]
+	role := args[1]
+	threshold, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid threshold %s: %v", args[2], err)
+	}
+
+	pubKeys := []data.PublicKey{}
+	for _, pubKeyPath := range args[3:] {
+		// Read public key bytes from PEM file
+		pubKeyBytes, err := ioutil.ReadFile(pubKeyPath)
+		if err != nil {
+			return fmt.Errorf("unable to read public key from file: %s", pubKeyPath)
+		}
+
+		// Parse PEM bytes into type PublicKey
+		pubKey, err := trustmanager.ParsePEMPublicKey(pubKeyBytes)
+		if err != nil {
+			return fmt.Errorf("unable to parse valid public key certificate from PEM file %s: %v", pubKeyPath, err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	// no online operations are performed by setting the threshold so the
+	// transport argument should be nil
+	nRepo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config),
+		nil, k.getRetriever(), trustPin)
+	if err != nil {
+		return err
+	}
+
+	if err := nRepo.SetBaseRoleThreshold(role, threshold, pubKeys...); err != nil {
+		return fmt.Errorf("failed to set threshold: %v", err)
+	}
+
+	cmd.Printf(
+		"Threshold of %d for role %s, with %d additional key(s), staged for next publish to repository \"%s\".\n",
+		threshold, role, len(pubKeys), gun)
+	return nil
+}
+
 func removeKeyInteractively(keyStores []trustmanager.KeyStore, keyID string,
 	in io.Reader, out io.Writer) error {
 
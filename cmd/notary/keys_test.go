@@ -16,6 +16,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	ctxu "github.com/docker/distribution/context"
 	"github.com/docker/notary"
+	"github.com/docker/notary/certs"
 	"github.com/docker/notary/client"
 	"github.com/docker/notary/cryptoservice"
 	"github.com/docker/notary/passphrase"
@@ -239,11 +240,10 @@ func TestRemoveMultikeysRemoveOnlyChosenKey(t *testing.T) {
 	}
 }
 
-// Non-roles, root, and delegation keys can't be rotated with the command line
+// Non-roles and delegation keys can't be rotated with the command line
 func TestRotateKeyInvalidRoles(t *testing.T) {
 	setUp(t)
 	invalids := []string{
-		data.CanonicalRootRole,
 		"notevenARole",
 		"targets/a",
 	}
@@ -281,6 +281,20 @@ func TestRotateKeyTargetCannotBeServerManaged(t *testing.T) {
 	require.IsType(t, client.ErrInvalidRemoteRole{}, err)
 }
 
+// Cannot rotate a root key and require that it is server managed
+func TestRotateKeyRootCannotBeServerManaged(t *testing.T) {
+	setUp(t)
+	k := &keyCommander{
+		configGetter:           func() (*viper.Viper, error) { return viper.New(), nil },
+		getRetriever:           func() passphrase.Retriever { return passphrase.ConstantRetriever("pass") },
+		rotateKeyRole:          data.CanonicalRootRole,
+		rotateKeyServerManaged: true,
+	}
+	err := k.keysRotate(&cobra.Command{}, []string{"gun", data.CanonicalRootRole})
+	require.Error(t, err)
+	require.IsType(t, client.ErrInvalidRemoteRole{}, err)
+}
+
 // Cannot rotate a timestamp key and require that it is locally managed
 func TestRotateKeyTimestampCannotBeLocallyManaged(t *testing.T) {
 	setUp(t)
@@ -330,7 +344,7 @@ func setUpRepo(t *testing.T, tempBaseDir, gun string, ret passphrase.Retriever)
 	ts := httptest.NewServer(server.RootHandler(nil, ctx, cryptoService, nil, nil))
 
 	repo, err := client.NewNotaryRepository(
-		tempBaseDir, gun, ts.URL, http.DefaultTransport, ret)
+		tempBaseDir, gun, ts.URL, http.DefaultTransport, ret, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 
 	rootPubKey, err := repo.CryptoService.Create("root", "", data.ECDSAKey)
@@ -372,7 +386,7 @@ func TestRotateKeyRemoteServerManagesKey(t *testing.T) {
 		}
 		require.NoError(t, k.keysRotate(&cobra.Command{}, []string{gun, role, "-r"}))
 
-		repo, err := client.NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, ret)
+		repo, err := client.NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, ret, certs.TrustPinConfig{})
 		require.NoError(t, err, "error creating repo: %s", err)
 
 		cl, err := repo.GetChangelist()
@@ -427,7 +441,7 @@ func TestRotateKeyBothKeys(t *testing.T) {
 	require.NoError(t, k.keysRotate(&cobra.Command{}, []string{gun, data.CanonicalTargetsRole}))
 	require.NoError(t, k.keysRotate(&cobra.Command{}, []string{gun, data.CanonicalSnapshotRole}))
 
-	repo, err := client.NewNotaryRepository(tempBaseDir, gun, ts.URL, nil, ret)
+	repo, err := client.NewNotaryRepository(tempBaseDir, gun, ts.URL, nil, ret, certs.TrustPinConfig{})
 	require.NoError(t, err, "error creating repo: %s", err)
 
 	cl, err := repo.GetChangelist()
@@ -460,6 +474,95 @@ func TestRotateKeyBothKeys(t *testing.T) {
 	require.True(t, found[data.CanonicalRootRole], "root key was removed somehow")
 }
 
+// The command line uses NotaryRepository's RotateKey - this is just testing
+// that rotating the root key creates a new root key without removing the old one
+func TestRotateKeyRoot(t *testing.T) {
+	setUp(t)
+	// Temporary directory where test files will be created
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	defer os.RemoveAll(tempBaseDir)
+	require.NoError(t, err, "failed to create a temporary directory: %s", err)
+	gun := "docker.com/notary"
+
+	ret := passphrase.ConstantRetriever("pass")
+
+	ts, initialKeys := setUpRepo(t, tempBaseDir, gun, ret)
+	defer ts.Close()
+
+	k := &keyCommander{
+		configGetter: func() (*viper.Viper, error) {
+			v := viper.New()
+			v.SetDefault("trust_dir", tempBaseDir)
+			v.SetDefault("remote_server.url", ts.URL)
+			return v, nil
+		},
+		getRetriever: func() passphrase.Retriever { return ret },
+	}
+	require.NoError(t, k.keysRotate(&cobra.Command{}, []string{gun, data.CanonicalRootRole}))
+
+	repo, err := client.NewNotaryRepository(tempBaseDir, gun, ts.URL, http.DefaultTransport, ret, certs.TrustPinConfig{})
+	require.NoError(t, err, "error creating repo: %s", err)
+
+	// there should be 4 keys - snapshot, targets, and the old and new root keys
+	newKeys := repo.CryptoService.ListAllKeys()
+	require.Len(t, newKeys, 4)
+	for keyID, role := range initialKeys {
+		r, ok := newKeys[keyID]
+		require.True(t, ok, "original key %s is gone", keyID)
+		require.Equal(t, role, r)
+	}
+
+	// the one new key is a root key
+	for keyID, role := range newKeys {
+		if _, ok := initialKeys[keyID]; !ok {
+			require.Equal(t, data.CanonicalRootRole, role)
+		}
+	}
+
+	// the changes were published, and the rotated root can be downloaded
+	cl, err := repo.GetChangelist()
+	require.NoError(t, err, "unable to get changelist: %v", err)
+	require.Len(t, cl.List(), 0)
+	require.NoError(t, repo.Update(false))
+}
+
+// Setting a threshold requires a GUN, a role and a numeric threshold, and only
+// stages a change for the root or targets roles
+func TestKeyThresholdInvalidArgs(t *testing.T) {
+	setUp(t)
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	defer os.RemoveAll(tempBaseDir)
+	require.NoError(t, err, "failed to create a temporary directory: %s", err)
+
+	k := &keyCommander{
+		configGetter: func() (*viper.Viper, error) {
+			v := viper.New()
+			v.SetDefault("trust_dir", tempBaseDir)
+			return v, nil
+		},
+		getRetriever: func() passphrase.Retriever { return ret },
+	}
+	err = k.keysThreshold(&cobra.Command{}, []string{"gun", data.CanonicalTargetsRole})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "Must specify a GUN, a key role and a threshold")
+
+	err = k.keysThreshold(&cobra.Command{}, []string{"gun", data.CanonicalTargetsRole, "two"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid threshold")
+
+	err = k.keysThreshold(&cobra.Command{}, []string{"gun", data.CanonicalTargetsRole, "2", "nonexistent.crt"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unable to read public key from file")
+
+	for _, role := range []string{data.CanonicalSnapshotRole, "targets/a"} {
+		err = k.keysThreshold(&cobra.Command{}, []string{"gun", role, "2"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to set threshold")
+	}
+
+	require.NoError(t, k.keysThreshold(&cobra.Command{}, []string{"gun", data.CanonicalTargetsRole, "1"}))
+}
+
 func TestChangeKeyPassphraseInvalidID(t *testing.T) {
 	setUp(t)
 	k := &keyCommander{
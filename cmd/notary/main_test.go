@@ -221,6 +221,13 @@ func (r *recordingMetaStore) GetChecksum(gun, role, checksum string) (*time.Time
 	return r.MemStorage.GetChecksum(gun, role, checksum)
 }
 
+// GetVersion gets the metadata from the underlying MetaStore, but also records
+// that the metadata was requested
+func (r *recordingMetaStore) GetVersion(gun, role string, version int) (*time.Time, []byte, error) {
+	r.gotten = append(r.gotten, fmt.Sprintf("%s.%s", gun, role))
+	return r.MemStorage.GetVersion(gun, role, version)
+}
+
 // the config can provide all the TLS information necessary - the root ca file,
 // the tls client files - they are all relative to the directory of the config
 // file, and not the cwd
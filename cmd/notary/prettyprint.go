@@ -28,6 +28,32 @@ func getTable(headers []string, writer io.Writer) *tablewriter.Table {
 	return table
 }
 
+// Pretty-prints the roles and certificates of a health report.  Roles are
+// listed in the order they are reported, which has the base roles first.
+func prettyPrintHealth(report *client.HealthReport, writer io.Writer) {
+	table := getTable([]string{"Role", "Version", "Expires", "Signatures", "Unknown Keys", "Revoked Keys"}, writer)
+	for _, r := range report.Roles {
+		table.Append([]string{
+			r.Role,
+			fmt.Sprintf("%d", r.Version),
+			r.Expires.Format(time.RFC3339),
+			fmt.Sprintf("%d of %d", r.Signatures, r.Threshold),
+			strings.Join(r.UnknownKeys, "\n"),
+			strings.Join(r.RevokedKeys, "\n"),
+		})
+	}
+	table.Render()
+
+	if len(report.Certificates) == 0 {
+		return
+	}
+	table = getTable([]string{"Role", "Certificate Key ID", "Expires"}, writer)
+	for _, c := range report.Certificates {
+		table.Append([]string{c.Role, c.KeyID, c.Expires.Format(time.RFC3339)})
+	}
+	table.Render()
+}
+
 // --- pretty printing certs ---
 
 func truncateWithEllipsis(str string, maxWidth int, leftTruncate bool) string {
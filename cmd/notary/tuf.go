@@ -2,12 +2,14 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/docker/distribution/registry/client/transport"
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/go-connections/tlsconfig"
+	"github.com/docker/notary/certs"
 	notaryclient "github.com/docker/notary/client"
 	"github.com/docker/notary/passphrase"
 	"github.com/docker/notary/tuf/data"
@@ -30,6 +33,12 @@ var cmdTufListTemplate = usageTemplate{
 	Long:  "Lists all targets for a remote trusted collection identified by the Globally Unique Name. This is an online operation.",
 }
 
+var cmdTufListReposTemplate = usageTemplate{
+	Use:   "list-repos",
+	Short: "Lists the trusted collections on the remote server.",
+	Long:  "Lists the Globally Unique Names of the trusted collections on the remote trust server that you are allowed to pull, optionally only those starting with --prefix. This is an online operation.",
+}
+
 var cmdTufAddTemplate = usageTemplate{
 	Use:   "add [ GUN ] <target> <file>",
 	Short: "Adds the file as a target to the trusted collection.",
@@ -42,6 +51,12 @@ var cmdTufRemoveTemplate = usageTemplate{
 	Long:  "Removes a target from the local trusted collection identified by the Globally Unique Name. This is an offline operation.  Please then use `publish` to push the changes to the remote trusted collection.",
 }
 
+var cmdTufWitnessTemplate = usageTemplate{
+	Use:   "witness [ GUN ] <role> ...",
+	Short: "Marks roles to be re-signed the next time they're published.",
+	Long:  "Marks the targets or delegation roles of the local trusted collection identified by the Globally Unique Name to be re-signed with their current keys, without changing any of their targets, the next time the collection is published.  This brings metadata that has been invalidated, for instance because a key that signed it was removed from the role, or that is about to expire, back up to date.  This is an offline operation.  Please then use `publish` to push the changes to the remote trusted collection.",
+}
+
 var cmdTufInitTemplate = usageTemplate{
 	Use:   "init [ GUN ]",
 	Short: "Initializes a local trusted collection.",
@@ -60,12 +75,42 @@ var cmdTufPublishTemplate = usageTemplate{
 	Long:  "Publishes the local trusted collection identified by the Globally Unique Name, sending the local changes to a remote trusted server.",
 }
 
+var cmdTufSignTemplate = usageTemplate{
+	Use:   "sign [ GUN ] [ role ]",
+	Short: "Adds signatures to partially signed metadata.",
+	Long:  "Adds signatures, using any keys for the role that are available locally, to the partially signed metadata for the root, targets or a delegation role of the Globally Unique Name, read from the file given by --input.  The result is written to the file given by --output, or if no output file is given, is saved so that it is published by the next `publish` once all the saved metadata has enough signatures.",
+}
+
+var cmdTufExportUnsignedTemplate = usageTemplate{
+	Use:   "export-unsigned [ GUN ]",
+	Short: "Exports the unsigned changes to a trusted collection as a bundle.",
+	Long:  "Applies the staged changes to the latest version of the trusted collection identified by the Globally Unique Name, and writes the updated metadata, unsigned, to a bundle file given by --output along with the keys needed to sign it.  The bundle can then be signed on another machine with `sign-bundle`, and published with `publish --bundle`.  The staged changes are cleared once the bundle has been written.  This is an online operation.",
+}
+
+var cmdTufSignBundleTemplate = usageTemplate{
+	Use:   "sign-bundle [ bundle ]",
+	Short: "Signs the metadata in a bundle.",
+	Long:  "Signs the metadata in a bundle produced by `export-unsigned`, using any of the keys available locally.  The signed bundle is written to the file given by --output, or if no output file is given, replaces the original bundle.  This is an offline operation.",
+}
+
 var cmdTufStatusTemplate = usageTemplate{
 	Use:   "status [ GUN ]",
 	Short: "Displays status of unpublished changes to the local trusted collection.",
 	Long:  "Displays status of unpublished changes to the local trusted collection identified by the Globally Unique Name.",
 }
 
+var cmdTufCheckTemplate = usageTemplate{
+	Use:   "check [ GUN ] ...",
+	Short: "Reports on the expiry and signatures of remote trusted collections.",
+	Long:  "Reports, for every role of the remote trusted collections identified by the Globally Unique Names, including delegations, when its metadata expires, its version, how many valid signatures it has of the number it needs, and any signatures from unknown or revoked keys, as well as when the root and delegation certificates expire.  Exits with an error if any metadata or certificate expires within the window given by --warn-within, or any metadata does not have enough valid signatures.  This is an online operation.",
+}
+
+var cmdTufDeleteTemplate = usageTemplate{
+	Use:   "delete [ GUN ]",
+	Short: "Deletes all trust data for a trusted collection.",
+	Long:  "Deletes all local trust data for the trusted collection identified by the Globally Unique Name, including its unpublished changes and trusted root certificates, but not its keys.  With --remote, the collection is first deleted from the remote trust server too, which is an online operation.",
+}
+
 var cmdTufVerifyTemplate = usageTemplate{
 	Use:   "verify [ GUN ] <target>",
 	Short: "Verifies if the content is included in the remote trusted collection",
@@ -78,28 +123,69 @@ type tufCommander struct {
 	retriever    passphrase.Retriever
 
 	// these are for command line parsing - no need to set
-	roles []string
+	roles      []string
+	input      string
+	output     string
+	bundle     string
+	prefix     string
+	expiries   []string
+	warnWithin time.Duration
+	remote     bool
+	yes        bool
 }
 
 func (t *tufCommander) AddToCommand(cmd *cobra.Command) {
 	cmd.AddCommand(cmdTufInitTemplate.ToCommand(t.tufInit))
 	cmd.AddCommand(cmdTufStatusTemplate.ToCommand(t.tufStatus))
-	cmd.AddCommand(cmdTufPublishTemplate.ToCommand(t.tufPublish))
+
+	cmdTufPublish := cmdTufPublishTemplate.ToCommand(t.tufPublish)
+	cmdTufPublish.Flags().StringVar(&t.bundle, "bundle", "", "Path to a signed bundle to publish instead of the staged changes")
+	cmdTufPublish.Flags().StringSliceVar(&t.expiries, "expiry", nil, "How long the metadata for a role is valid for once signed, such as targets=168h (can be repeated)")
+	cmd.AddCommand(cmdTufPublish)
+
 	cmd.AddCommand(cmdTufLookupTemplate.ToCommand(t.tufLookup))
 	cmd.AddCommand(cmdTufVerifyTemplate.ToCommand(t.tufVerify))
 
+	cmdTufCheck := cmdTufCheckTemplate.ToCommand(t.tufCheck)
+	cmdTufCheck.Flags().DurationVar(&t.warnWithin, "warn-within", 7*24*time.Hour, "Fail if any metadata or certificate expires within this long")
+	cmd.AddCommand(cmdTufCheck)
+
+	cmdTufDelete := cmdTufDeleteTemplate.ToCommand(t.tufDelete)
+	cmdTufDelete.Flags().BoolVar(&t.remote, "remote", false, "Delete the trusted collection from the remote trust server too")
+	cmdTufDelete.Flags().BoolVarP(&t.yes, "yes", "y", false, "Answer yes to the deletion question (no confirmation)")
+	cmd.AddCommand(cmdTufDelete)
+
 	cmdTufList := cmdTufListTemplate.ToCommand(t.tufList)
 	cmdTufList.Flags().StringSliceVarP(
 		&t.roles, "roles", "r", nil, "Delegation roles to list targets for (will shadow targets role)")
 	cmd.AddCommand(cmdTufList)
 
+	cmdTufListRepos := cmdTufListReposTemplate.ToCommand(t.tufListRepos)
+	cmdTufListRepos.Flags().StringVarP(&t.prefix, "prefix", "p", "", "Only list the trusted collections whose names start with this prefix")
+	cmd.AddCommand(cmdTufListRepos)
+
 	cmdTufAdd := cmdTufAddTemplate.ToCommand(t.tufAdd)
 	cmdTufAdd.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to add this target to")
 	cmd.AddCommand(cmdTufAdd)
 
+	cmd.AddCommand(cmdTufWitnessTemplate.ToCommand(t.tufWitness))
+
 	cmdTufRemove := cmdTufRemoveTemplate.ToCommand(t.tufRemove)
 	cmdTufRemove.Flags().StringSliceVarP(&t.roles, "roles", "r", nil, "Delegation roles to remove this target from")
 	cmd.AddCommand(cmdTufRemove)
+
+	cmdTufSign := cmdTufSignTemplate.ToCommand(t.tufSign)
+	cmdTufSign.Flags().StringVarP(&t.input, "input", "i", "", "Path to the partially signed metadata to sign")
+	cmdTufSign.Flags().StringVarP(&t.output, "output", "o", "", "Path to write the signed metadata to")
+	cmd.AddCommand(cmdTufSign)
+
+	cmdTufExportUnsigned := cmdTufExportUnsignedTemplate.ToCommand(t.tufExportUnsigned)
+	cmdTufExportUnsigned.Flags().StringVarP(&t.output, "output", "o", "", "Path to write the bundle to")
+	cmd.AddCommand(cmdTufExportUnsigned)
+
+	cmdTufSignBundle := cmdTufSignBundleTemplate.ToCommand(t.tufSignBundle)
+	cmdTufSignBundle.Flags().StringVarP(&t.output, "output", "o", "", "Path to write the signed bundle to")
+	cmd.AddCommand(cmdTufSignBundle)
 }
 
 func (t *tufCommander) tufAdd(cmd *cobra.Command, args []string) error {
@@ -116,10 +202,15 @@ func (t *tufCommander) tufAdd(cmd *cobra.Command, args []string) error {
 	targetName := args[1]
 	targetPath := args[2]
 
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
 	// no online operations are performed by add so the transport argument
 	// should be nil
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -150,13 +241,18 @@ func (t *tufCommander) tufInit(cmd *cobra.Command, args []string) error {
 	}
 	gun := args[0]
 
-	rt, err := getTransport(config, gun, false)
+	rt, err := getTransport(config, gun, readWrite)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -195,13 +291,18 @@ func (t *tufCommander) tufList(cmd *cobra.Command, args []string) error {
 	}
 	gun := args[0]
 
-	rt, err := getTransport(config, gun, true)
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -217,6 +318,32 @@ func (t *tufCommander) tufList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func (t *tufCommander) tufListRepos(cmd *cobra.Command, args []string) error {
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+
+	rt, err := getTransport(config, "", catalog)
+	if err != nil {
+		return err
+	}
+
+	guns, err := notaryclient.ListRepositories(getRemoteTrustServer(config), rt, t.prefix)
+	if err != nil {
+		return err
+	}
+
+	if len(guns) == 0 {
+		cmd.Out().Write([]byte("\nNo trusted collections found.\n\n"))
+		return nil
+	}
+	for _, gun := range guns {
+		fmt.Fprintln(cmd.Out(), gun)
+	}
+	return nil
+}
+
 func (t *tufCommander) tufLookup(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		cmd.Usage()
@@ -230,13 +357,18 @@ func (t *tufCommander) tufLookup(cmd *cobra.Command, args []string) error {
 	gun := args[0]
 	targetName := args[1]
 
-	rt, err := getTransport(config, gun, true)
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -262,8 +394,13 @@ func (t *tufCommander) tufStatus(cmd *cobra.Command, args []string) error {
 	}
 	gun := args[0]
 
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -299,25 +436,234 @@ func (t *tufCommander) tufPublish(cmd *cobra.Command, args []string) error {
 	}
 	gun := args[0]
 
+	expiries, err := getExpiries(config, gun, t.expiries)
+	if err != nil {
+		return err
+	}
+
 	cmd.Println("Pushing changes to", gun)
 
-	rt, err := getTransport(config, gun, false)
+	rt, err := getTransport(config, gun, readWrite)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
+	nRepo.Expiries = expiries
+
+	if t.bundle != "" {
+		bundle, err := readBundle(t.bundle)
+		if err != nil {
+			return err
+		}
+		return nRepo.PublishBundle(bundle)
+	}
 
 	if err = nRepo.Publish(); err != nil {
+		if partialErr, ok := err.(notaryclient.ErrPartiallySigned); ok {
+			cmd.Printf(
+				"More signatures are required for %s.  Please have the other key holders sign the metadata in %s with `notary sign %s <role> --input <file>`, and publish again once it has been saved here.\n",
+				strings.Join(partialErr.Roles, ", "), partialErr.Dir, gun)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *tufCommander) tufSign(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN and a role")
+	}
+	if t.input == "" {
+		cmd.Usage()
+		return fmt.Errorf("Must specify the partially signed metadata to sign with --input")
+	}
+
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := args[0]
+	role := args[1]
+
+	metaJSON, err := ioutil.ReadFile(t.input)
+	if err != nil {
+		return err
+	}
+
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	nRepo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
+	if err != nil {
+		return err
+	}
+
+	signedJSON, err := nRepo.SignPartial(role, metaJSON)
+	if err != nil {
+		return err
+	}
+
+	if t.output != "" {
+		if err := ioutil.WriteFile(t.output, signedJSON, 0644); err != nil {
+			return err
+		}
+		cmd.Printf("Signed metadata for %s written to %s.\n", role, t.output)
+		return nil
+	}
+	if err := nRepo.SavePartial(role, signedJSON); err != nil {
+		return err
+	}
+	cmd.Printf("Signed metadata for %s saved for the next publish to repository \"%s\".\n", role, gun)
+	return nil
+}
+
+func (t *tufCommander) tufExportUnsigned(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	if t.output == "" {
+		cmd.Usage()
+		return fmt.Errorf("Must specify the file to write the bundle to with --output")
+	}
+
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := args[0]
+
+	expiries, err := getExpiries(config, gun, nil)
+	if err != nil {
+		return err
+	}
+
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	nRepo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
+	if err != nil {
+		return err
+	}
+	nRepo.Expiries = expiries
+
+	bundle, err := nRepo.ExportUnsigned()
+	if err != nil {
+		return err
+	}
+	if err := writeBundle(t.output, bundle); err != nil {
+		return err
+	}
+
+	// the staged changes are now in the bundle
+	cl, err := nRepo.GetChangelist()
+	if err != nil {
+		return err
+	}
+	if err := cl.Clear(""); err != nil {
+		return err
+	}
+
+	roles := make([]string, 0, len(bundle.Roles))
+	for _, bundleRole := range bundle.Roles {
+		roles = append(roles, bundleRole.Role)
+	}
+	cmd.Printf("Unsigned metadata for %s in repository \"%s\" written to %s.\n", strings.Join(roles, ", "), gun, t.output)
+	return nil
+}
+
+func (t *tufCommander) tufSignBundle(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a bundle")
+	}
+
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+
+	bundle, err := readBundle(args[0])
+	if err != nil {
 		return err
 	}
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	// no online operations are performed when signing a bundle so the
+	// transport argument should be nil
+	nRepo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), bundle.GUN, getRemoteTrustServer(config), nil, t.retriever, trustPin)
+	if err != nil {
+		return err
+	}
+
+	signedRoles, err := nRepo.SignBundle(bundle)
+	if err != nil {
+		return err
+	}
+
+	output := t.output
+	if output == "" {
+		output = args[0]
+	}
+	if err := writeBundle(output, bundle); err != nil {
+		return err
+	}
+	cmd.Printf("Signed metadata for %s in repository \"%s\" written to %s.\n", strings.Join(signedRoles, ", "), bundle.GUN, output)
 	return nil
 }
 
+func readBundle(bundlePath string) (*notaryclient.Bundle, error) {
+	bundleJSON, err := ioutil.ReadFile(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &notaryclient.Bundle{}
+	if err := json.Unmarshal(bundleJSON, bundle); err != nil {
+		return nil, fmt.Errorf("unable to parse bundle %s: %v", bundlePath, err)
+	}
+	return bundle, nil
+}
+
+func writeBundle(bundlePath string, bundle *notaryclient.Bundle) error {
+	bundleJSON, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(bundlePath, bundleJSON, 0644)
+}
+
 func (t *tufCommander) tufRemove(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("Must specify a GUN and target")
@@ -330,10 +676,15 @@ func (t *tufCommander) tufRemove(cmd *cobra.Command, args []string) error {
 	gun := args[0]
 	targetName := args[1]
 
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
 	// no online operation are performed by remove so the transport argument
 	// should be nil.
 	repo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -346,6 +697,139 @@ func (t *tufCommander) tufRemove(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func (t *tufCommander) tufWitness(cmd *cobra.Command, args []string) error {
+	if len(args) < 2 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN and at least one role")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+
+	gun := args[0]
+	roles := args[1:]
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	// no online operations are performed by witness so the transport argument
+	// should be nil.
+	repo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), nil, t.retriever, trustPin)
+	if err != nil {
+		return err
+	}
+	if err = repo.Witness(roles...); err != nil {
+		return err
+	}
+
+	cmd.Printf("Witnessing of %s in %s staged for next publish.\n", strings.Join(roles, ", "), gun)
+	return nil
+}
+
+func (t *tufCommander) tufCheck(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify at least one GUN")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, gun := range args {
+		rt, err := getTransport(config, gun, readOnly)
+		if err != nil {
+			return err
+		}
+
+		nRepo, err := notaryclient.NewNotaryRepository(
+			config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
+		if err != nil {
+			return err
+		}
+
+		report, err := nRepo.Health()
+		if err != nil {
+			return err
+		}
+
+		cmd.Printf("\n%s\n", gun)
+		prettyPrintHealth(report, cmd.Out())
+		for _, problem := range report.Problems(t.warnWithin) {
+			cmd.Printf("WARNING: %s\n", problem)
+			problems++
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problems found within %s", problems, t.warnWithin)
+	}
+	return nil
+}
+
+func (t *tufCommander) tufDelete(cmd *cobra.Command, args []string) error {
+	if len(args) < 1 {
+		cmd.Usage()
+		return fmt.Errorf("Must specify a GUN")
+	}
+	config, err := t.configGetter()
+	if err != nil {
+		return err
+	}
+	gun := args[0]
+
+	trustPin, err := getTrustPinning(config)
+	if err != nil {
+		return err
+	}
+
+	// deleting local data is an offline operation, so only get a transport,
+	// which needs full access to the GUN, if the remote data is being deleted too
+	var rt http.RoundTripper
+	if t.remote {
+		rt, err = getTransport(config, gun, admin)
+		if err != nil {
+			return err
+		}
+	}
+
+	nRepo, err := notaryclient.NewNotaryRepository(
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
+	if err != nil {
+		return err
+	}
+
+	where := "locally"
+	if t.remote {
+		where = "locally and on the remote trust server"
+	}
+	cmd.Printf("\nAll trust data for %s will be deleted %s.\n", gun, where)
+	cmd.Println("\nAre you sure you want to delete it? (yes/no)")
+	// Ask for confirmation before deleting, unless -y is provided
+	if !t.yes {
+		if !askConfirm() {
+			return fmt.Errorf("Aborting action.")
+		}
+	} else {
+		cmd.Println("Confirmed `yes` from flag")
+	}
+
+	if err := nRepo.DeleteTrustData(t.remote); err != nil {
+		return err
+	}
+	cmd.Printf("Successfully deleted trust data for %s %s.\n", gun, where)
+	return nil
+}
+
 func (t *tufCommander) tufVerify(cmd *cobra.Command, args []string) error {
 	if len(args) < 2 {
 		cmd.Usage()
@@ -366,13 +850,18 @@ func (t *tufCommander) tufVerify(cmd *cobra.Command, args []string) error {
 	gun := args[0]
 	targetName := args[1]
 
-	rt, err := getTransport(config, gun, true)
+	rt, err := getTransport(config, gun, readOnly)
+	if err != nil {
+		return err
+	}
+
+	trustPin, err := getTrustPinning(config)
 	if err != nil {
 		return err
 	}
 
 	nRepo, err := notaryclient.NewNotaryRepository(
-		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever)
+		config.GetString("trust_dir"), gun, getRemoteTrustServer(config), rt, t.retriever, trustPin)
 	if err != nil {
 		return err
 	}
@@ -431,14 +920,51 @@ func (ps passwordStore) Basic(u *url.URL) (string, string) {
 	return username, password
 }
 
+// httpAccess is the access to a GUN on the notary server that a command needs
+type httpAccess int
+
+const (
+	// readOnly is anonymous pull access
+	readOnly httpAccess = iota
+	// readWrite is push and pull access
+	readWrite
+	// admin is full access, which is needed to delete a GUN from the server
+	admin
+	// catalog is access to list every GUN on the server, rather than access
+	// to a single GUN
+	catalog
+)
+
+// catalogScope is the token scope that grants access to the catalog
+const catalogScope = "registry:catalog:*"
+
+// catalogTransport asks for the catalog scope in the token requests made
+// through it, because the token handler can only ask for access to a GUN
+type catalogTransport struct {
+	http.RoundTripper
+}
+
+// RoundTrip replaces the scope of a token request with the catalog scope
+func (t catalogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	if _, ok := query["scope"]; ok {
+		query.Set("scope", catalogScope)
+		u := *req.URL
+		u.RawQuery = query.Encode()
+		scoped := *req
+		scoped.URL = &u
+		req = &scoped
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
 // getTransport returns an http.RoundTripper to be used for all http requests.
 // It correctly handles the auth challenge/credentials required to interact
 // with a notary server over both HTTP Basic Auth and the JWT auth implemented
 // in the notary-server
-// The readOnly flag indicates if the operation should be performed as an
-// anonymous read only operation. If the command entered requires write
-// permissions on the server, readOnly must be false
-func getTransport(config *viper.Viper, gun string, readOnly bool) (http.RoundTripper, error) {
+// The permission is the access the command needs to the GUN.  Only readOnly
+// operations are performed anonymously.
+func getTransport(config *viper.Viper, gun string, permission httpAccess) (http.RoundTripper, error) {
 	// Attempt to get a root CA from the config file. Nil is the host defaults.
 	rootCAFile := utils.GetPathRelativeToConfig(config, "remote_server.root_ca")
 	clientCert := utils.GetPathRelativeToConfig(config, "remote_server.tls_client_cert")
@@ -475,11 +1001,11 @@ func getTransport(config *viper.Viper, gun string, readOnly bool) (http.RoundTri
 		DisableKeepAlives:   true,
 	}
 	trustServerURL := getRemoteTrustServer(config)
-	return tokenAuth(trustServerURL, base, gun, readOnly)
+	return tokenAuth(trustServerURL, base, gun, permission)
 }
 
 func tokenAuth(trustServerURL string, baseTransport *http.Transport, gun string,
-	readOnly bool) (http.RoundTripper, error) {
+	permission httpAccess) (http.RoundTripper, error) {
 
 	// TODO(dmcgowan): add notary specific headers
 	authTransport := transport.NewTransport(baseTransport)
@@ -526,15 +1052,21 @@ func tokenAuth(trustServerURL string, baseTransport *http.Transport, gun string,
 		return nil, err
 	}
 
-	ps := passwordStore{anonymous: readOnly}
+	ps := passwordStore{anonymous: permission == readOnly}
 
+	tokenTransport := authTransport
 	var actions []string
-	if readOnly {
-		actions = []string{"pull"}
-	} else {
+	switch permission {
+	case catalog:
+		tokenTransport = catalogTransport{authTransport}
+	case admin:
+		actions = []string{"*"}
+	case readWrite:
 		actions = []string{"push", "pull"}
+	default:
+		actions = []string{"pull"}
 	}
-	tokenHandler := auth.NewTokenHandler(authTransport, ps, gun, actions...)
+	tokenHandler := auth.NewTokenHandler(tokenTransport, ps, gun, actions...)
 	basicHandler := auth.NewBasicHandler(ps)
 	modifier := transport.RequestModifier(auth.NewAuthorizer(challengeManager, tokenHandler, basicHandler))
 	return transport.NewTransport(baseTransport, modifier), nil
@@ -546,3 +1078,91 @@ func getRemoteTrustServer(config *viper.Viper) string {
 	}
 	return defaultServerURL
 }
+
+// getExpiries returns how long the metadata for each role of a GUN should be
+// valid for once signed: the expiries configured for the GUN in the `expiry`
+// section of the config, overridden by any given as role=duration flags
+func getExpiries(config *viper.Viper, gun string, flags []string) (map[string]time.Duration, error) {
+	expiries := make(map[string]time.Duration)
+	// viper lowercases the keys of maps in the config
+	configured, ok := config.GetStringMap("expiry")[strings.ToLower(gun)]
+	if ok {
+		roles, ok := configured.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf(
+				"invalid format for expiry: expected a map of roles to durations for %s", gun)
+		}
+		for role, rawExpiry := range roles {
+			expiry, ok := rawExpiry.(string)
+			if !ok {
+				return nil, fmt.Errorf(
+					"invalid format for expiry: expected a map of roles to durations for %s", gun)
+			}
+			if err := parseExpiry(expiries, role, expiry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, flag := range flags {
+		parts := strings.SplitN(flag, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid expiry %s: expected role=duration, such as targets=168h", flag)
+		}
+		if err := parseExpiry(expiries, parts[0], parts[1]); err != nil {
+			return nil, err
+		}
+	}
+	return expiries, nil
+}
+
+// parseExpiry parses the expiry for a role that is signed by the client into
+// expiries
+func parseExpiry(expiries map[string]time.Duration, role, expiry string) error {
+	switch {
+	case role == data.CanonicalRootRole, role == data.CanonicalTargetsRole,
+		role == data.CanonicalSnapshotRole, data.IsDelegation(role):
+	default:
+		return fmt.Errorf("cannot set the expiry of %s: only root, targets, snapshot and delegation roles are signed by the client", role)
+	}
+	d, err := time.ParseDuration(expiry)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid expiry for %s: expected a positive duration, such as 168h", role)
+	}
+	expiries[role] = d
+	return nil
+}
+
+// getTrustPinning parses the trust_pinning section of the config into a
+// certs.TrustPinConfig.  Pinned CA paths are relative to the config file.
+func getTrustPinning(config *viper.Viper) (certs.TrustPinConfig, error) {
+	certMap := make(map[string][]string)
+	for gun, certList := range config.GetStringMap("trust_pinning.certs") {
+		certIDs, ok := certList.([]interface{})
+		if !ok {
+			return certs.TrustPinConfig{}, fmt.Errorf(
+				"invalid format for trust_pinning.certs: expected a list of certificate IDs for %s", gun)
+		}
+		for _, certID := range certIDs {
+			id, ok := certID.(string)
+			if !ok {
+				return certs.TrustPinConfig{}, fmt.Errorf(
+					"invalid format for trust_pinning.certs: expected a list of certificate IDs for %s", gun)
+			}
+			certMap[gun] = append(certMap[gun], id)
+		}
+	}
+
+	caMap := make(map[string]string)
+	for gunPrefix, caPath := range config.GetStringMapString("trust_pinning.ca") {
+		if caPath != "" && !filepath.IsAbs(caPath) {
+			caPath = filepath.Join(filepath.Dir(config.ConfigFileUsed()), caPath)
+		}
+		caMap[gunPrefix] = caPath
+	}
+
+	return certs.TrustPinConfig{
+		DisableTOFU: config.GetBool("trust_pinning.disable_tofu"),
+		CA:          caMap,
+		Certs:       certMap,
+	}, nil
+}
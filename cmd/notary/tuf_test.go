@@ -1,20 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 )
 
 func TestTokenAuth(t *testing.T) {
 	var (
-		readOnly      bool
 		baseTransport = &http.Transport{}
 		gun           = "test"
 	)
-	auth, err := tokenAuth("https://localhost:9999", baseTransport, gun, readOnly)
+	auth, err := tokenAuth("https://localhost:9999", baseTransport, gun, readWrite)
 	require.NoError(t, err)
 	require.Nil(t, auth)
 }
@@ -26,14 +31,13 @@ func StatusOKTestHandler(w http.ResponseWriter, r *http.Request) {
 
 func TestTokenAuth200Status(t *testing.T) {
 	var (
-		readOnly      bool
 		baseTransport = &http.Transport{}
 		gun           = "test"
 	)
 	s := httptest.NewServer(http.HandlerFunc(NotAuthorizedTestHandler))
 	defer s.Close()
 
-	auth, err := tokenAuth(s.URL, baseTransport, gun, readOnly)
+	auth, err := tokenAuth(s.URL, baseTransport, gun, readWrite)
 	require.NoError(t, err)
 	require.NotNil(t, auth)
 }
@@ -44,14 +48,13 @@ func NotAuthorizedTestHandler(w http.ResponseWriter, r *http.Request) {
 
 func TestTokenAuth401Status(t *testing.T) {
 	var (
-		readOnly      bool
 		baseTransport = &http.Transport{}
 		gun           = "test"
 	)
 	s := httptest.NewServer(http.HandlerFunc(NotAuthorizedTestHandler))
 	defer s.Close()
 
-	auth, err := tokenAuth(s.URL, baseTransport, gun, readOnly)
+	auth, err := tokenAuth(s.URL, baseTransport, gun, readWrite)
 	require.NoError(t, err)
 	require.NotNil(t, auth)
 }
@@ -62,14 +65,131 @@ func NotFoundTestHandler(w http.ResponseWriter, r *http.Request) {
 
 func TestTokenAuthNon200Non401Status(t *testing.T) {
 	var (
-		readOnly      bool
 		baseTransport = &http.Transport{}
 		gun           = "test"
 	)
 	s := httptest.NewServer(http.HandlerFunc(NotFoundTestHandler))
 	defer s.Close()
 
-	auth, err := tokenAuth(s.URL, baseTransport, gun, readOnly)
+	auth, err := tokenAuth(s.URL, baseTransport, gun, readWrite)
 	require.NoError(t, err)
 	require.Nil(t, auth)
 }
+
+// The trust pinning config is parsed from the config file, and relative CA
+// paths are relative to the config file
+// recordingTransport records the URLs of the requests made through it
+type recordingTransport struct {
+	urls []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.urls = append(rt.urls, req.URL.String())
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+}
+
+// The catalog transport asks for the catalog scope in token requests, and
+// leaves other requests alone
+func TestCatalogTransport(t *testing.T) {
+	recorder := &recordingTransport{}
+	client := &http.Client{Transport: catalogTransport{recorder}}
+
+	_, err := client.Get("https://auth.example.com/token?scope=repository%3A%3A&service=notary")
+	require.NoError(t, err)
+	_, err = client.Get("https://notary.example.com/v2/")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"https://auth.example.com/token?scope=registry%3Acatalog%3A%2A&service=notary",
+		"https://notary.example.com/v2/",
+	}, recorder.urls)
+}
+
+func TestGetTrustPinning(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "config.json")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{
+		"trust_pinning": {
+			"certs": {"docker.com/notary": ["abc", "def"]},
+			"ca": {"docker.com/": "ca.crt", "example.com/": "/absolute/ca.crt"},
+			"disable_tofu": true
+		}
+	}`), 0644))
+
+	config := viper.New()
+	config.SetConfigFile(configFile)
+	require.NoError(t, config.ReadInConfig())
+
+	trustPin, err := getTrustPinning(config)
+	require.NoError(t, err)
+	require.True(t, trustPin.DisableTOFU)
+	require.Equal(t, map[string][]string{"docker.com/notary": {"abc", "def"}}, trustPin.Certs)
+	require.Equal(t, map[string]string{
+		"docker.com/":  filepath.Join(tempDir, "ca.crt"),
+		"example.com/": "/absolute/ca.crt",
+	}, trustPin.CA)
+
+	// certs must be a list of IDs
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{
+		"trust_pinning": {"certs": {"docker.com/notary": "abc"}}
+	}`), 0644))
+	require.NoError(t, config.ReadInConfig())
+	_, err = getTrustPinning(config)
+	require.Error(t, err)
+}
+
+func TestGetExpiries(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir)
+
+	configFile := filepath.Join(tempDir, "config.json")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{
+		"expiry": {
+			"docker.com/notary": {"targets": "168h", "root": "43800h"},
+			"docker.com/other": {"targets": "1h"}
+		}
+	}`), 0644))
+
+	config := viper.New()
+	config.SetConfigFile(configFile)
+	require.NoError(t, config.ReadInConfig())
+
+	expiries, err := getExpiries(config, "docker.com/notary", nil)
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Duration{
+		"targets": 168 * time.Hour,
+		"root":    43800 * time.Hour,
+	}, expiries)
+
+	// flags override the config
+	expiries, err = getExpiries(config, "docker.com/notary", []string{"targets=24h", "targets/releases=48h"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]time.Duration{
+		"targets":          24 * time.Hour,
+		"targets/releases": 48 * time.Hour,
+		"root":             43800 * time.Hour,
+	}, expiries)
+
+	expiries, err = getExpiries(config, "docker.com/unconfigured", nil)
+	require.NoError(t, err)
+	require.Len(t, expiries, 0)
+
+	// the timestamp is signed by the server, durations must be positive, and
+	// flags must be role=duration
+	for _, flag := range []string{"timestamp=1h", "targets=-1h", "targets=forever", "targets"} {
+		_, err = getExpiries(config, "docker.com/notary", []string{flag})
+		require.Error(t, err, flag)
+	}
+
+	// the config for a GUN must be a map of roles to durations
+	require.NoError(t, ioutil.WriteFile(configFile, []byte(`{
+		"expiry": {"docker.com/notary": {"targets": 168}}
+	}`), 0644))
+	require.NoError(t, config.ReadInConfig())
+	_, err = getExpiries(config, "docker.com/notary", nil)
+	require.Error(t, err)
+}
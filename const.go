@@ -12,7 +12,7 @@ const (
 	MaxTimestampSize int64 = 1 << 20
 	// MinRSABitSize is the minimum bit size for RSA keys allowed in notary
 	MinRSABitSize = 2048
-	// MinThreshold requires a minimum of one threshold for roles; currently we do not support a higher threshold
+	// MinThreshold requires a minimum of one threshold for roles; this is also the default threshold for new roles
 	MinThreshold = 1
 	// PrivKeyPerms are the file permissions to use when writing private keys to disk
 	PrivKeyPerms = 0700
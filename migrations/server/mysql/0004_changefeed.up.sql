@@ -0,0 +1,10 @@
+CREATE TABLE `changefeed` (
+	  `id` int(11) NOT NULL AUTO_INCREMENT,
+	  `created_at` timestamp NULL DEFAULT NULL,
+	  `gun` varchar(255) NOT NULL,
+	  `version` int(11) NOT NULL,
+	  `sha256` CHAR(64) DEFAULT NULL,
+	  `category` varchar(20) NOT NULL,
+	  PRIMARY KEY (`id`),
+	  INDEX `idx_changefeed_gun` (`gun`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1 @@
+ALTER TABLE `timestamp_keys` DROP KEY `gun_role`, ADD COLUMN `version` INT(11) NOT NULL DEFAULT 0, ADD UNIQUE KEY `gun_role_version` (`gun`, `role`, `version`);
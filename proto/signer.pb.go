@@ -15,6 +15,9 @@ It has these top-level messages:
 	PublicKey
 	Signature
 	SignatureRequest
+	SignatureRequests
+	SignatureResult
+	Signatures
 	Void
 	HealthStatus
 */
@@ -135,6 +138,55 @@ func (m *SignatureRequest) GetKeyID() *KeyID {
 	return nil
 }
 
+// SignatureRequests is a batch of SignatureRequests to be signed at once
+type SignatureRequests struct {
+	Requests []*SignatureRequest `protobuf:"bytes,1,rep,name=requests" json:"requests,omitempty"`
+}
+
+func (m *SignatureRequests) Reset()         { *m = SignatureRequests{} }
+func (m *SignatureRequests) String() string { return proto1.CompactTextString(m) }
+func (*SignatureRequests) ProtoMessage()    {}
+
+func (m *SignatureRequests) GetRequests() []*SignatureRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+// SignatureResult holds either the Signature for one of a batch of SignatureRequests, or the reason it could not be signed
+type SignatureResult struct {
+	Signature *Signature `protobuf:"bytes,1,opt,name=signature" json:"signature,omitempty"`
+	Error     string     `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SignatureResult) Reset()         { *m = SignatureResult{} }
+func (m *SignatureResult) String() string { return proto1.CompactTextString(m) }
+func (*SignatureResult) ProtoMessage()    {}
+
+func (m *SignatureResult) GetSignature() *Signature {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// Signatures holds a SignatureResult for each of a batch of SignatureRequests, in the same order
+type Signatures struct {
+	Results []*SignatureResult `protobuf:"bytes,1,rep,name=results" json:"results,omitempty"`
+}
+
+func (m *Signatures) Reset()         { *m = Signatures{} }
+func (m *Signatures) String() string { return proto1.CompactTextString(m) }
+func (*Signatures) ProtoMessage()    {}
+
+func (m *Signatures) GetResults() []*SignatureResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
 // Void represents an empty message type
 type Void struct {
 }
@@ -314,6 +366,8 @@ var _KeyManagement_serviceDesc = grpc.ServiceDesc{
 type SignerClient interface {
 	// Sign calculates a cryptographic signature using the Key associated with a KeyID and returns the signature
 	Sign(ctx context.Context, in *SignatureRequest, opts ...grpc.CallOption) (*Signature, error)
+	// SignMany calculates a signature for each of a batch of SignatureRequests, reporting an error for each request that could not be signed rather than failing the whole batch
+	SignMany(ctx context.Context, in *SignatureRequests, opts ...grpc.CallOption) (*Signatures, error)
 	// CheckHealth returns the HealthStatus with the service
 	CheckHealth(ctx context.Context, in *Void, opts ...grpc.CallOption) (*HealthStatus, error)
 }
@@ -335,6 +389,15 @@ func (c *signerClient) Sign(ctx context.Context, in *SignatureRequest, opts ...g
 	return out, nil
 }
 
+func (c *signerClient) SignMany(ctx context.Context, in *SignatureRequests, opts ...grpc.CallOption) (*Signatures, error) {
+	out := new(Signatures)
+	err := grpc.Invoke(ctx, "/proto.Signer/SignMany", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *signerClient) CheckHealth(ctx context.Context, in *Void, opts ...grpc.CallOption) (*HealthStatus, error) {
 	out := new(HealthStatus)
 	err := grpc.Invoke(ctx, "/proto.Signer/CheckHealth", in, out, c.cc, opts...)
@@ -349,6 +412,8 @@ func (c *signerClient) CheckHealth(ctx context.Context, in *Void, opts ...grpc.C
 type SignerServer interface {
 	// Sign calculates a cryptographic signature using the Key associated with a KeyID and returns the signature
 	Sign(context.Context, *SignatureRequest) (*Signature, error)
+	// SignMany calculates a signature for each of a batch of SignatureRequests, reporting an error for each request that could not be signed rather than failing the whole batch
+	SignMany(context.Context, *SignatureRequests) (*Signatures, error)
 	// CheckHealth returns the HealthStatus with the service
 	CheckHealth(context.Context, *Void) (*HealthStatus, error)
 }
@@ -369,6 +434,18 @@ func _Signer_Sign_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return out, nil
 }
 
+func _Signer_SignMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(SignatureRequests)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(SignerServer).SignMany(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Signer_CheckHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(Void)
 	if err := dec(in); err != nil {
@@ -389,6 +466,10 @@ var _Signer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Sign",
 			Handler:    _Signer_Sign_Handler,
 		},
+		{
+			MethodName: "SignMany",
+			Handler:    _Signer_SignMany_Handler,
+		},
 		{
 			MethodName: "CheckHealth",
 			Handler:    _Signer_CheckHealth_Handler,
@@ -0,0 +1,202 @@
+// Package mtls provides an access controller that authorizes requests based on
+// the verified TLS client certificate they were made with.  The identities in
+// the certificate (its common name and DNS subject alternative names) are
+// mapped to the GUNs they may access, and the actions they may perform on
+// them, by a list of rules in the configuration.
+//
+// This access controller requires the server to verify client certificates, by
+// configuring a client CA.
+package mtls
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"regexp"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/docker/notary/utils"
+	"golang.org/x/net/context"
+)
+
+// Name is the name the access controller is registered under
+const Name = "mtls"
+
+// The actions a rule may allow.  Deleting a GUN requires the "delete" action,
+// as well as "push" and "pull", and the other administrative routes, such as
+// rotating the server's keys, require the "admin" action.
+const (
+	ActionPush   = "push"
+	ActionPull   = "pull"
+	ActionDelete = "delete"
+	ActionAdmin  = "admin"
+)
+
+var (
+	// ErrNoClientCertificate is returned when a request was not made with a
+	// verified client certificate
+	ErrNoClientCertificate = errors.New("no verified client certificate")
+
+	// ErrAccessDenied is returned when no rule allows the identities in the
+	// client certificate the requested access
+	ErrAccessDenied = errors.New("access denied")
+)
+
+func init() {
+	auth.Register(Name, auth.InitFunc(newAccessController))
+}
+
+// rule allows an identity to perform some actions on the GUNs matching any of
+// a set of globs
+type rule struct {
+	identity string
+	guns     []*regexp.Regexp
+	actions  map[string]bool
+}
+
+func (r rule) matches(identities []string, gun, action string) bool {
+	if !r.actions[action] {
+		return false
+	}
+	identified := r.identity == "*"
+	for _, identity := range identities {
+		identified = identified || identity == r.identity
+	}
+	if !identified {
+		return false
+	}
+	for _, glob := range r.guns {
+		if glob.MatchString(gun) {
+			return true
+		}
+	}
+	return false
+}
+
+type accessController struct {
+	rules []rule
+}
+
+var _ auth.AccessController = &accessController{}
+
+// newAccessController parses the "rules" option, which is a list of rules each
+// containing an "identity", a list of "guns" globs, and a list of "actions".
+// An identity of "*" matches any verified client certificate, and a "*" in a
+// GUN glob matches any sequence of characters, including "/".
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	rawRules, ok := options["rules"].([]interface{})
+	if !ok || len(rawRules) == 0 {
+		return nil, fmt.Errorf(`"rules" must be set for the mtls access controller`)
+	}
+
+	ac := &accessController{}
+	for i, rawRule := range rawRules {
+		ruleOptions, ok := rawRule.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("mtls rule %d must be a map", i)
+		}
+		identity, ok := ruleOptions["identity"].(string)
+		if !ok || identity == "" {
+			return nil, fmt.Errorf("mtls rule %d must have an identity", i)
+		}
+		r := rule{identity: identity, actions: make(map[string]bool)}
+
+		globs, err := stringList(ruleOptions["guns"])
+		if err != nil || len(globs) == 0 {
+			return nil, fmt.Errorf("mtls rule %d must have a list of guns", i)
+		}
+		for _, glob := range globs {
+			r.guns = append(r.guns, utils.GlobToRegexp(glob))
+		}
+
+		actions, err := stringList(ruleOptions["actions"])
+		if err != nil || len(actions) == 0 {
+			return nil, fmt.Errorf("mtls rule %d must have a list of actions", i)
+		}
+		for _, action := range actions {
+			switch action {
+			case ActionPush, ActionPull, ActionDelete, ActionAdmin:
+				r.actions[action] = true
+			default:
+				return nil, fmt.Errorf("mtls rule %d has an invalid action: %s", i, action)
+			}
+		}
+		ac.rules = append(ac.rules, r)
+	}
+	return ac, nil
+}
+
+// Authorized checks that the request was made with a verified client
+// certificate, and that a rule allows one of its identities every requested
+// access
+func (ac *accessController) Authorized(ctx context.Context, accessRecords ...auth.Access) (context.Context, error) {
+	req, err := ctxu.GetRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoClientCertificate
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	identities := certIdentities(cert)
+
+	for _, access := range accessRecords {
+		// rules only grant access to GUNs, so other resources, such as the
+		// catalog, can't be accessed - the catalog is instead filtered by the
+		// GUNs that may be pulled
+		if access.Type != "repository" {
+			return nil, ErrAccessDenied
+		}
+		var actions []string
+		switch {
+		case req.Method == "DELETE":
+			actions = []string{ActionPush, ActionPull, ActionDelete}
+		case access.Action == "*":
+			actions = []string{ActionAdmin}
+		default:
+			actions = []string{access.Action}
+		}
+		for _, action := range actions {
+			if !ac.allowed(identities, access.Name, action) {
+				ctxu.GetLogger(ctx).Errorf("%q may not %s %s", cert.Subject.CommonName, action, access.Name)
+				return nil, ErrAccessDenied
+			}
+		}
+	}
+	return auth.WithUser(ctx, auth.UserInfo{Name: cert.Subject.CommonName}), nil
+}
+
+func (ac *accessController) allowed(identities []string, gun, action string) bool {
+	for _, r := range ac.rules {
+		if r.matches(identities, gun, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// certIdentities returns the common name and DNS names of a certificate
+func certIdentities(cert *x509.Certificate) []string {
+	identities := append([]string{}, cert.DNSNames...)
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	return identities
+}
+
+func stringList(value interface{}) ([]string, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a list")
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("not a list of strings")
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
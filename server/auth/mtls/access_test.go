@@ -0,0 +1,156 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+)
+
+func testRules() map[string]interface{} {
+	return map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"identity": "ci.example.com",
+				"guns":     []interface{}{"example.com/*"},
+				"actions":  []interface{}{"push", "pull"},
+			},
+			map[string]interface{}{
+				"identity": "admin",
+				"guns":     []interface{}{"*"},
+				"actions":  []interface{}{"push", "pull", "delete", "admin"},
+			},
+			map[string]interface{}{
+				"identity": "*",
+				"guns":     []interface{}{"public/*", "library"},
+				"actions":  []interface{}{"pull"},
+			},
+		},
+	}
+}
+
+// returns a context with a request made with a verified client certificate
+// with the given common name and DNS names, or without one if cn is empty
+func requestContext(method, cn string, dnsNames ...string) context.Context {
+	req := &http.Request{Method: method}
+	if cn != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}, DNSNames: dnsNames}
+		req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	return ctxu.WithRequest(context.Background(), req)
+}
+
+func repoAccess(gun string, actions ...string) []auth.Access {
+	var access []auth.Access
+	for _, action := range actions {
+		access = append(access, auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: gun},
+			Action:   action,
+		})
+	}
+	return access
+}
+
+func TestNewAccessControllerInvalidOptions(t *testing.T) {
+	validRule := func() map[string]interface{} {
+		return map[string]interface{}{
+			"identity": "ci",
+			"guns":     []interface{}{"*"},
+			"actions":  []interface{}{"pull"},
+		}
+	}
+	_, err := newAccessController(map[string]interface{}{"rules": []interface{}{validRule()}})
+	require.NoError(t, err)
+
+	invalids := []map[string]interface{}{
+		{},
+		{"rules": []interface{}{}},
+		{"rules": []interface{}{"ci"}},
+	}
+	for _, field := range []string{"identity", "guns", "actions"} {
+		rule := validRule()
+		delete(rule, field)
+		invalids = append(invalids, map[string]interface{}{"rules": []interface{}{rule}})
+	}
+	rule := validRule()
+	rule["actions"] = []interface{}{"pull", "*"}
+	invalids = append(invalids, map[string]interface{}{"rules": []interface{}{rule}})
+	rule = validRule()
+	rule["guns"] = []interface{}{1}
+	invalids = append(invalids, map[string]interface{}{"rules": []interface{}{rule}})
+
+	for _, invalid := range invalids {
+		_, err := newAccessController(invalid)
+		require.Error(t, err, "%v", invalid)
+	}
+}
+
+// The access controller is registered under its name
+func TestRegistered(t *testing.T) {
+	ac, err := auth.GetAccessController(Name, testRules())
+	require.NoError(t, err)
+	require.IsType(t, &accessController{}, ac)
+}
+
+func TestAuthorizedRequiresClientCertificate(t *testing.T) {
+	ac, err := newAccessController(testRules())
+	require.NoError(t, err)
+
+	_, err = ac.Authorized(requestContext("GET", ""), repoAccess("library", "pull")...)
+	require.Equal(t, ErrNoClientCertificate, err)
+
+	_, err = ac.Authorized(context.Background(), repoAccess("library", "pull")...)
+	require.Error(t, err)
+}
+
+func TestAuthorized(t *testing.T) {
+	ac, err := newAccessController(testRules())
+	require.NoError(t, err)
+
+	allowed := []struct {
+		ctx    context.Context
+		access []auth.Access
+	}{
+		// the common name or any DNS name may match the identity
+		{requestContext("POST", "ci.example.com"), repoAccess("example.com/notary", "push", "pull")},
+		{requestContext("POST", "ci", "ci.example.com"), repoAccess("example.com/a/b", "push", "pull")},
+		{requestContext("DELETE", "admin"), repoAccess("anything", "*")},
+		// the administrative routes require the admin action
+		{requestContext("POST", "admin"), repoAccess("anything", "*")},
+		// the wildcard identity matches any certificate
+		{requestContext("GET", "someone"), repoAccess("public/image", "pull")},
+		{requestContext("GET", "ci.example.com"), repoAccess("library", "pull")},
+		// requests that need no access only need a certificate
+		{requestContext("GET", "someone"), nil},
+	}
+	for _, a := range allowed {
+		ctx, err := ac.Authorized(a.ctx, a.access...)
+		require.NoError(t, err, "%v", a.access)
+		require.NotNil(t, ctx.Value("auth.user"))
+	}
+
+	denied := []struct {
+		ctx    context.Context
+		access []auth.Access
+	}{
+		{requestContext("POST", "ci.example.com"), repoAccess("other.com/notary", "push", "pull")},
+		{requestContext("POST", "someone"), repoAccess("public/image", "push", "pull")},
+		{requestContext("GET", "someone"), repoAccess("library/nested", "pull")},
+		// deletion requires the delete action, rather than the admin action
+		{requestContext("DELETE", "ci.example.com"), repoAccess("example.com/notary", "*")},
+		{requestContext("POST", "ci.example.com"), repoAccess("example.com/notary", "*")},
+		// rules can't grant access to the catalog
+		{requestContext("GET", "admin"), []auth.Access{
+			{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}}},
+	}
+	for _, d := range denied {
+		_, err := ac.Authorized(d.ctx, d.access...)
+		require.Equal(t, ErrAccessDenied, err, "%v", d.access)
+	}
+}
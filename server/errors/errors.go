@@ -86,5 +86,11 @@ var (
 		Description:    "No key algorihtm has been configured for the server and it has been asked to perform an operation that requires generation.",
 		HTTPStatusCode: http.StatusInternalServerError,
 	})
+	ErrInvalidParams = errcode.Register(errGroup, errcode.ErrorDescriptor{
+		Value:          "INVALID_PARAMETERS",
+		Message:        "The parameters provided are not valid.",
+		Description:    "The user provided query parameters that could not be parsed or are out of range.",
+		HTTPStatusCode: http.StatusBadRequest,
+	})
 	ErrUnknown = errcode.ErrorCodeUnknown
 )
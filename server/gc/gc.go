@@ -0,0 +1,188 @@
+package gc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/go/canonical/json"
+
+	"github.com/docker/notary"
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+)
+
+// gunsPerPage is the number of GUNs fetched from the store at a time when
+// collecting every GUN
+const gunsPerPage = 100
+
+// Policy decides which old versions of each role's metadata are kept.  A
+// version is deleted only if it is neither one of the KeepVersions newest
+// versions of its role, nor newer than KeepFor.  The current version of each
+// role, every version of the root (which clients need to walk the chain of
+// roots), and any version referenced by the current timestamp or snapshot
+// are always kept.
+type Policy struct {
+	// KeepVersions is how many of the newest versions of each role to keep
+	KeepVersions int
+	// KeepFor is how long to keep every version of each role for
+	KeepFor time.Duration
+}
+
+// Valid returns an error if the policy would not keep anything
+func (p Policy) Valid() error {
+	if p.KeepVersions < 0 || p.KeepFor < 0 {
+		return fmt.Errorf("retention policy cannot be negative")
+	}
+	if p.KeepVersions == 0 && p.KeepFor == 0 {
+		return fmt.Errorf("retention policy must keep a number of versions, or keep versions for a duration")
+	}
+	return nil
+}
+
+// CollectAll applies the policy to every GUN in the store, and returns the
+// number of versions that were deleted
+func CollectAll(store storage.MetaStore, policy Policy, now time.Time) (int, error) {
+	if err := policy.Valid(); err != nil {
+		return 0, err
+	}
+	var (
+		deleted int
+		last    string
+	)
+	for {
+		guns, err := store.GetGUNs("", last, gunsPerPage)
+		if err != nil {
+			return deleted, err
+		}
+		for _, gun := range guns {
+			n, err := Collect(store, gun, policy, now)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+		if len(guns) < gunsPerPage {
+			return deleted, nil
+		}
+		last = guns[len(guns)-1]
+	}
+}
+
+// Collect applies the policy to a single GUN, and returns the number of
+// versions that were deleted
+func Collect(store storage.MetaStore, gun string, policy Policy, now time.Time) (int, error) {
+	if err := policy.Valid(); err != nil {
+		return 0, err
+	}
+	referenced, err := referencedChecksums(store, gun)
+	if err != nil {
+		return 0, err
+	}
+	versions, err := store.ListVersions(gun)
+	if err != nil {
+		return 0, err
+	}
+
+	byRole := make(map[string][]storage.MetaVersion)
+	for _, v := range versions {
+		byRole[v.Role] = append(byRole[v.Role], v)
+	}
+
+	deleted := 0
+	for role, roleVersions := range byRole {
+		if role == data.CanonicalRootRole {
+			continue
+		}
+		sort.Sort(newestFirst(roleVersions))
+
+		var expired []int
+		for i, v := range roleVersions {
+			// the first version is the current one, which is always kept
+			if i == 0 || i < policy.KeepVersions || now.Sub(v.CreatedAt) < policy.KeepFor ||
+				referenced[role][v.SHA256] {
+				continue
+			}
+			expired = append(expired, v.Version)
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		if err := store.DeleteVersions(gun, role, expired); err != nil {
+			return deleted, err
+		}
+		logrus.Debugf("deleted %d old versions of %s for %s", len(expired), role, gun)
+		deleted += len(expired)
+	}
+	return deleted, nil
+}
+
+// referencedChecksums returns the SHA256 checksums, by role, of the metadata
+// referenced by the current timestamp, by the snapshot that timestamp
+// references, and by the current snapshot
+func referencedChecksums(store storage.MetaStore, gun string) (map[string]map[string]bool, error) {
+	referenced := make(map[string]map[string]bool)
+	addReferences := func(files data.Files) {
+		for role, meta := range files {
+			checksum, ok := meta.Hashes[notary.SHA256]
+			if !ok {
+				continue
+			}
+			if referenced[role] == nil {
+				referenced[role] = make(map[string]bool)
+			}
+			referenced[role][hex.EncodeToString(checksum)] = true
+		}
+	}
+
+	_, tsJSON, err := store.GetCurrent(gun, data.CanonicalTimestampRole)
+	switch err.(type) {
+	case nil:
+		ts := &data.SignedTimestamp{}
+		if err := json.Unmarshal(tsJSON, ts); err != nil {
+			return nil, err
+		}
+		addReferences(ts.Signed.Meta)
+	case storage.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	snapshots := [][]byte{}
+	_, snapshotJSON, err := store.GetCurrent(gun, data.CanonicalSnapshotRole)
+	switch err.(type) {
+	case nil:
+		snapshots = append(snapshots, snapshotJSON)
+	case storage.ErrNotFound:
+	default:
+		return nil, err
+	}
+	for checksum := range referenced[data.CanonicalSnapshotRole] {
+		_, snapshotJSON, err := store.GetChecksum(gun, data.CanonicalSnapshotRole, checksum)
+		switch err.(type) {
+		case nil:
+			snapshots = append(snapshots, snapshotJSON)
+		case storage.ErrNotFound:
+		default:
+			return nil, err
+		}
+	}
+
+	for _, snapshotJSON := range snapshots {
+		sn := &data.SignedSnapshot{}
+		if err := json.Unmarshal(snapshotJSON, sn); err != nil {
+			return nil, err
+		}
+		addReferences(sn.Signed.Meta)
+	}
+	return referenced, nil
+}
+
+// newestFirst sorts the versions of a role from the newest to the oldest
+type newestFirst []storage.MetaVersion
+
+func (v newestFirst) Len() int           { return len(v) }
+func (v newestFirst) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v newestFirst) Less(i, j int) bool { return v[i].Version > v[j].Version }
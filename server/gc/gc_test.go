@@ -0,0 +1,160 @@
+package gc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// publish signs every top level role in the repo, which bumps all their
+// versions, and stores them
+func publish(t *testing.T, store storage.MetaStore, gun string, repo *tuf.Repo) {
+	root, targets, snapshot, timestamp, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	rootJSON, targetsJSON, snapshotJSON, timestampJSON, err := testutils.Serialize(
+		root, targets, snapshot, timestamp)
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateMany(gun, []storage.MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: repo.Root.Signed.Version, Data: rootJSON},
+		{Role: data.CanonicalTargetsRole, Version: repo.Targets[data.CanonicalTargetsRole].Signed.Version, Data: targetsJSON},
+		{Role: data.CanonicalSnapshotRole, Version: repo.Snapshot.Signed.Version, Data: snapshotJSON},
+		{Role: data.CanonicalTimestampRole, Version: repo.Timestamp.Signed.Version, Data: timestampJSON},
+	}))
+}
+
+// storedVersions returns the versions of each role that are stored for a GUN
+func storedVersions(t *testing.T, store storage.MetaStore, gun string) map[string]int {
+	versions, err := store.ListVersions(gun)
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for _, v := range versions {
+		counts[fmt.Sprintf("%s.%d", v.Role, v.Version)]++
+	}
+	return counts
+}
+
+func newPublishedRepo(t *testing.T, store storage.MetaStore, gun string, publishes int) *tuf.Repo {
+	repo, _, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+	for i := 0; i < publishes; i++ {
+		publish(t, store, gun, repo)
+	}
+	return repo
+}
+
+func TestPolicyValid(t *testing.T) {
+	require.NoError(t, Policy{KeepVersions: 1}.Valid())
+	require.NoError(t, Policy{KeepFor: time.Hour}.Valid())
+	require.Error(t, Policy{}.Valid())
+	require.Error(t, Policy{KeepVersions: -1, KeepFor: time.Hour}.Valid())
+	require.Error(t, Policy{KeepVersions: 1, KeepFor: -time.Hour}.Valid())
+
+	_, err := Collect(storage.NewMemStorage(), "gun", Policy{}, time.Now())
+	require.Error(t, err)
+	_, err = CollectAll(storage.NewMemStorage(), Policy{}, time.Now())
+	require.Error(t, err)
+}
+
+// Only the newest versions of each role are kept, except for the root, every
+// version of which is kept
+func TestCollectKeepVersions(t *testing.T) {
+	store := storage.NewMemStorage()
+	newPublishedRepo(t, store, "gun", 5)
+
+	deleted, err := Collect(store, "gun", Policy{KeepVersions: 2}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 9, deleted)
+
+	expected := map[string]int{}
+	for version := 1; version <= 5; version++ {
+		expected[fmt.Sprintf("root.%d", version)] = 1
+	}
+	for _, role := range []string{"targets", "snapshot", "timestamp"} {
+		expected[role+".4"] = 1
+		expected[role+".5"] = 1
+	}
+	require.Equal(t, expected, storedVersions(t, store, "gun"))
+
+	// collecting again does nothing
+	deleted, err = Collect(store, "gun", Policy{KeepVersions: 2}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 0, deleted)
+}
+
+// Versions newer than the retention duration are kept, and the current version
+// is always kept no matter how old it is
+func TestCollectKeepFor(t *testing.T) {
+	store := storage.NewMemStorage()
+	newPublishedRepo(t, store, "gun", 3)
+
+	deleted, err := Collect(store, "gun", Policy{KeepFor: time.Hour}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 0, deleted)
+
+	deleted, err = Collect(store, "gun", Policy{KeepFor: time.Hour}, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 6, deleted)
+
+	require.Equal(t, map[string]int{
+		"root.1": 1, "root.2": 1, "root.3": 1,
+		"targets.3": 1, "snapshot.3": 1, "timestamp.3": 1,
+	}, storedVersions(t, store, "gun"))
+}
+
+// Versions referenced by the current timestamp, or the snapshots referenced
+// by it, are kept even if the policy would otherwise delete them
+func TestCollectKeepsReferencedVersions(t *testing.T) {
+	store := storage.NewMemStorage()
+	repo := newPublishedRepo(t, store, "gun", 3)
+
+	// publish a new targets and snapshot, but not a new timestamp, so that the
+	// current timestamp still references version 3 of the snapshot
+	targets, err := repo.SignTargets(data.CanonicalTargetsRole, data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	snapshot, err := repo.SignSnapshot(data.DefaultExpires(data.CanonicalSnapshotRole))
+	require.NoError(t, err)
+	_, targetsJSON, snapshotJSON, _, err := testutils.Serialize(nil, targets, snapshot, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateMany("gun", []storage.MetaUpdate{
+		{Role: data.CanonicalTargetsRole, Version: 4, Data: targetsJSON},
+		{Role: data.CanonicalSnapshotRole, Version: 4, Data: snapshotJSON},
+	}))
+
+	deleted, err := Collect(store, "gun", Policy{KeepVersions: 1}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 6, deleted)
+
+	require.Equal(t, map[string]int{
+		"root.1": 1, "root.2": 1, "root.3": 1,
+		"targets.3": 1, "targets.4": 1,
+		"snapshot.3": 1, "snapshot.4": 1,
+		"timestamp.3": 1,
+	}, storedVersions(t, store, "gun"))
+}
+
+// Every GUN in the store is collected
+func TestCollectAll(t *testing.T) {
+	store := storage.NewMemStorage()
+	guns := []string{"gun1", "gun2", "gun3"}
+	for _, gun := range guns {
+		newPublishedRepo(t, store, gun, 2)
+	}
+
+	deleted, err := CollectAll(store, Policy{KeepVersions: 1}, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 9, deleted)
+
+	for _, gun := range guns {
+		require.Equal(t, map[string]int{
+			"root.1": 1, "root.2": 1,
+			"targets.2": 1, "snapshot.2": 1, "timestamp.2": 1,
+		}, storedVersions(t, store, gun))
+	}
+}
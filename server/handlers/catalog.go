@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/errors"
+	"github.com/docker/notary/server/storage"
+)
+
+// catalogResponse is the body of a catalog response
+type catalogResponse struct {
+	NumberOfRepositories int      `json:"count"`
+	Repositories         []string `json:"repositories"`
+	// Last is the last GUN that was examined, which should be passed as last
+	// to continue listing GUNs, or empty if there are no more GUNs
+	Last string `json:"last"`
+}
+
+// Catalog returns a handler for the catalog, which lists the GUNs hosted by
+// the server in sorted order.  The prefix query parameter restricts the GUNs
+// to those starting with it, last is the last GUN the requester has seen
+// (empty to start from the beginning), and records is the maximum number of
+// GUNs to return.  If ac is not nil, and doesn't grant the requester access
+// to the catalog, only the GUNs the requester may pull are returned.  At most
+// maxScannedRecords GUNs are examined to find them, so fewer than records GUNs
+// may be returned even if there are more to come.
+func Catalog(ac auth.AccessController) func(context.Context, http.ResponseWriter, *http.Request) error {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		s := ctx.Value("metaStore")
+		store, ok := s.(storage.MetaStore)
+		if !ok {
+			return errors.ErrNoStorage.WithDetail(nil)
+		}
+
+		qs := r.URL.Query()
+		records, err := recordsParam(qs)
+		if err != nil {
+			return errors.ErrInvalidParams.WithDetail(err.Error())
+		}
+		prefix, last := qs.Get("prefix"), qs.Get("last")
+
+		logger := ctxu.GetLogger(ctx)
+		filter := ac != nil && !authorizedForCatalog(ctx, ac)
+		pageSize := records
+		if filter {
+			pageSize = maxScannedRecords
+		}
+		page, err := store.GetGUNs(prefix, last, pageSize)
+		if err != nil {
+			logger.Error("500 GET catalog")
+			return errors.ErrUnknown.WithDetail(err)
+		}
+
+		exhausted := len(page) < pageSize
+		canPull := make(map[string]bool)
+		guns := make([]string, 0, records)
+		for i, gun := range page {
+			last = gun
+			if filter && !authorizedToPull(ctx, ac, gun, canPull) {
+				continue
+			}
+			guns = append(guns, gun)
+			if len(guns) == records {
+				exhausted = exhausted && i == len(page)-1
+				break
+			}
+		}
+		if exhausted {
+			last = ""
+		}
+
+		out, err := json.Marshal(&catalogResponse{
+			NumberOfRepositories: len(guns),
+			Repositories:         guns,
+			Last:                 last,
+		})
+		if err != nil {
+			logger.Error("500 GET catalog: unable to marshal GUNs")
+			return errors.ErrUnknown.WithDetail(err)
+		}
+		w.Write(out)
+		return nil
+	}
+}
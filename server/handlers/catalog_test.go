@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/errors"
+	"github.com/docker/notary/server/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func getCatalog(t *testing.T, ac auth.AccessController, metaStore storage.MetaStore, query string) (catalogResponse, error) {
+	ctx := context.WithValue(context.Background(), "metaStore", metaStore)
+	req, err := http.NewRequest("GET", "/v2/_trust/catalog?"+query, nil)
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	var resp catalogResponse
+	if err := Catalog(ac)(ctx, rw, req); err != nil {
+		return resp, err
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	return resp, nil
+}
+
+func TestCatalogPaging(t *testing.T) {
+	metaStore := storeWithGUNs(t, "gun3", "gun1", "other/gun", "gun2")
+
+	resp, err := getCatalog(t, nil, metaStore, "")
+	require.NoError(t, err)
+	require.Equal(t, 4, resp.NumberOfRepositories)
+	require.Equal(t, []string{"gun1", "gun2", "gun3", "other/gun"}, resp.Repositories)
+	require.Equal(t, "", resp.Last)
+
+	resp, err = getCatalog(t, nil, metaStore, "prefix=gun&records=2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun1", "gun2"}, resp.Repositories)
+	require.Equal(t, "gun2", resp.Last)
+
+	resp, err = getCatalog(t, nil, metaStore, "prefix=gun&records=2&last=gun2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun3"}, resp.Repositories)
+	require.Equal(t, "", resp.Last)
+
+	resp, err = getCatalog(t, nil, metaStore, "prefix=nothing")
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.NumberOfRepositories)
+	require.Len(t, resp.Repositories, 0)
+}
+
+func TestCatalogInvalidParams(t *testing.T) {
+	_, err := getCatalog(t, nil, storeWithGUNs(t, "gun1"), "records=0")
+	require.Error(t, err)
+	errc, ok := err.(errcode.Error)
+	require.True(t, ok)
+	require.Equal(t, errors.ErrInvalidParams, errc.Code)
+}
+
+func TestCatalogNoStorage(t *testing.T) {
+	req, err := http.NewRequest("GET", "/v2/_trust/catalog", nil)
+	require.NoError(t, err)
+	err = Catalog(nil)(context.Background(), httptest.NewRecorder(), req)
+	require.Error(t, err)
+}
+
+// Only GUNs the requester can pull are listed, and the requested number of
+// GUNs is still filled from later GUNs if possible
+func TestCatalogFilteredByAccess(t *testing.T) {
+	metaStore := storeWithGUNs(t, "gun1", "gun2", "gun3", "secret1", "secret2", "zzz")
+	ac := gunAccessController{"gun1": true, "gun3": true, "zzz": true}
+
+	resp, err := getCatalog(t, ac, metaStore, "records=2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun1", "gun3"}, resp.Repositories)
+	require.Equal(t, "gun3", resp.Last)
+
+	resp, err = getCatalog(t, ac, metaStore, "records=2&last=gun3")
+	require.NoError(t, err)
+	require.Equal(t, []string{"zzz"}, resp.Repositories)
+	require.Equal(t, "", resp.Last)
+}
+
+// When filtering by access, at most maxScannedRecords GUNs are examined for a
+// request, and the last one examined is returned so the listing can continue
+func TestCatalogFilteredScanIsBounded(t *testing.T) {
+	guns := make([]string, 0, maxScannedRecords+1)
+	for i := 0; i <= maxScannedRecords; i++ {
+		guns = append(guns, fmt.Sprintf("gun%05d", i))
+	}
+	metaStore := storeWithGUNs(t, guns...)
+	ac := gunAccessController{guns[maxScannedRecords]: true}
+
+	resp, err := getCatalog(t, ac, metaStore, "records=10")
+	require.NoError(t, err)
+	require.Len(t, resp.Repositories, 0)
+	require.Equal(t, guns[maxScannedRecords-1], resp.Last)
+
+	resp, err = getCatalog(t, ac, metaStore, "records=10&last="+resp.Last)
+	require.NoError(t, err)
+	require.Equal(t, []string{guns[maxScannedRecords]}, resp.Repositories)
+	require.Equal(t, "", resp.Last)
+}
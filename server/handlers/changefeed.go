@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	ctxu "github.com/docker/distribution/context"
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/errors"
+	"github.com/docker/notary/server/storage"
+)
+
+const (
+	// defaultPageRecords is the number of records returned by a paginated
+	// endpoint if the request doesn't say how many it wants
+	defaultPageRecords = 100
+	// maxPageRecords is the most records returned for a single request
+	maxPageRecords = 1000
+	// maxScannedRecords is the most records a paginated endpoint examines for
+	// a single request when it has to filter them by the GUNs the requester
+	// may pull
+	maxScannedRecords = 1000
+)
+
+type changefeedResponse struct {
+	NumberOfRecords int              `json:"count"`
+	Records         []storage.Change `json:"records"`
+	// ChangeID is the ID of the last change that was examined, which should
+	// be passed as change_id to continue reading the changefeed
+	ChangeID uint `json:"change_id"`
+}
+
+// Changefeed returns a handler for the changefeed, which lists the publishes
+// and deletions of GUNs in the order they happened.  The change_id query
+// parameter is the ID of the last change the requester has seen (0 to start
+// from the beginning), and records is the maximum number of changes to return.
+// If ac is not nil, and doesn't grant the requester access to the catalog,
+// only changes to GUNs the requester may pull are returned.  At most
+// maxScannedRecords changes are examined to find them, so fewer than records
+// changes may be returned even if there are more to come.
+func Changefeed(ac auth.AccessController) func(context.Context, http.ResponseWriter, *http.Request) error {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		s := ctx.Value("metaStore")
+		store, ok := s.(storage.MetaStore)
+		if !ok {
+			return errors.ErrNoStorage.WithDetail(nil)
+		}
+
+		changeID, records, err := changefeedParams(r)
+		if err != nil {
+			return errors.ErrInvalidParams.WithDetail(err.Error())
+		}
+
+		logger := ctxu.GetLogger(ctx)
+		filter := ac != nil && !authorizedForCatalog(ctx, ac)
+		pageSize := records
+		if filter {
+			pageSize = maxScannedRecords
+		}
+		page, err := store.GetChanges(changeID, pageSize)
+		if err != nil {
+			logger.Error("500 GET changefeed")
+			return errors.ErrUnknown.WithDetail(err)
+		}
+
+		canPull := make(map[string]bool)
+		changes := make([]storage.Change, 0, records)
+		for _, change := range page {
+			changeID = change.ID
+			if filter && !authorizedToPull(ctx, ac, change.GUN, canPull) {
+				continue
+			}
+			changes = append(changes, change)
+			if len(changes) == records {
+				break
+			}
+		}
+
+		out, err := json.Marshal(&changefeedResponse{
+			NumberOfRecords: len(changes),
+			Records:         changes,
+			ChangeID:        changeID,
+		})
+		if err != nil {
+			logger.Error("500 GET changefeed: unable to marshal changes")
+			return errors.ErrUnknown.WithDetail(err)
+		}
+		w.Write(out)
+		return nil
+	}
+}
+
+// changefeedParams parses the change_id and records query parameters
+func changefeedParams(r *http.Request) (uint, int, error) {
+	var changeID uint64
+	qs := r.URL.Query()
+	if s := qs.Get("change_id"); s != "" {
+		var err error
+		if changeID, err = strconv.ParseUint(s, 10, 32); err != nil {
+			return 0, 0, fmt.Errorf("change_id must be a change ID, or 0")
+		}
+	}
+	records, err := recordsParam(qs)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(changeID), records, nil
+}
+
+// recordsParam parses the records query parameter of a paginated endpoint
+func recordsParam(qs url.Values) (int, error) {
+	s := qs.Get("records")
+	if s == "" {
+		return defaultPageRecords, nil
+	}
+	records, err := strconv.Atoi(s)
+	if err != nil || records < 1 || records > maxPageRecords {
+		return 0, fmt.Errorf("records must be between 1 and %d", maxPageRecords)
+	}
+	return records, nil
+}
+
+// authorizedForCatalog returns whether the requester has been granted access
+// to the catalog, which allows them to see every GUN without the results being
+// filtered by the GUNs they may pull
+func authorizedForCatalog(ctx context.Context, ac auth.AccessController) bool {
+	_, err := ac.Authorized(ctx, auth.Access{
+		Resource: auth.Resource{Type: "registry", Name: "catalog"},
+		Action:   "*",
+	})
+	return err == nil
+}
+
+// authorizedToPull returns whether the requester may pull the GUN, remembering
+// the answer for each GUN in canPull
+func authorizedToPull(ctx context.Context, ac auth.AccessController, gun string, canPull map[string]bool) bool {
+	allowed, ok := canPull[gun]
+	if !ok {
+		_, err := ac.Authorized(ctx, auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: gun},
+			Action:   "pull",
+		})
+		allowed = err == nil
+		canPull[gun] = allowed
+	}
+	return allowed
+}
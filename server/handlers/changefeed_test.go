@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/docker/distribution/registry/auth"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/errors"
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+	"github.com/stretchr/testify/require"
+)
+
+// gunAccessController only authorizes pulls of the GUNs it was given
+type gunAccessController map[string]bool
+
+func (ac gunAccessController) Authorized(ctx context.Context, access ...auth.Access) (context.Context, error) {
+	for _, a := range access {
+		if a.Action != "pull" || !ac[a.Name] {
+			return nil, fmt.Errorf("not authorized to %s %s", a.Action, a.Name)
+		}
+	}
+	return ctx, nil
+}
+
+// storeWithGUNs returns a store with one publish of each of the GUNs, in order
+func storeWithGUNs(t *testing.T, guns ...string) storage.MetaStore {
+	metaStore := storage.NewMemStorage()
+	for _, gun := range guns {
+		require.NoError(t, metaStore.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalTimestampRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+	return metaStore
+}
+
+func getChangefeed(t *testing.T, ac auth.AccessController, metaStore storage.MetaStore, query string) (changefeedResponse, error) {
+	ctx := context.WithValue(context.Background(), "metaStore", metaStore)
+	req, err := http.NewRequest("GET", "/v2/_trust/changefeed?"+query, nil)
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	var resp changefeedResponse
+	if err := Changefeed(ac)(ctx, rw, req); err != nil {
+		return resp, err
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &resp))
+	return resp, nil
+}
+
+func changefeedGUNs(resp changefeedResponse) []string {
+	guns := make([]string, 0, len(resp.Records))
+	for _, change := range resp.Records {
+		guns = append(guns, change.GUN)
+	}
+	return guns
+}
+
+func TestChangefeedPaging(t *testing.T) {
+	metaStore := storeWithGUNs(t, "gun1", "gun2", "gun3")
+
+	resp, err := getChangefeed(t, nil, metaStore, "")
+	require.NoError(t, err)
+	require.Equal(t, 3, resp.NumberOfRecords)
+	require.Equal(t, []string{"gun1", "gun2", "gun3"}, changefeedGUNs(resp))
+
+	resp, err = getChangefeed(t, nil, metaStore, "records=2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun1", "gun2"}, changefeedGUNs(resp))
+
+	resp, err = getChangefeed(t, nil, metaStore, fmt.Sprintf("change_id=%d&records=2", resp.Records[1].ID))
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun3"}, changefeedGUNs(resp))
+
+	require.Equal(t, resp.Records[0].ID, resp.ChangeID)
+
+	last := resp.ChangeID
+	resp, err = getChangefeed(t, nil, metaStore, fmt.Sprintf("change_id=%d", last))
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.NumberOfRecords)
+	require.Len(t, resp.Records, 0)
+	// with nothing new, the change ID to continue from stays the same
+	require.Equal(t, last, resp.ChangeID)
+}
+
+func TestChangefeedInvalidParams(t *testing.T) {
+	metaStore := storeWithGUNs(t, "gun1")
+	for _, query := range []string{
+		"change_id=-1",
+		"change_id=abc",
+		"records=0",
+		"records=abc",
+		fmt.Sprintf("records=%d", maxPageRecords+1),
+	} {
+		_, err := getChangefeed(t, nil, metaStore, query)
+		require.Error(t, err, query)
+		errc, ok := err.(errcode.Error)
+		require.True(t, ok, query)
+		require.Equal(t, errors.ErrInvalidParams, errc.Code, query)
+	}
+}
+
+func TestChangefeedNoStorage(t *testing.T) {
+	req, err := http.NewRequest("GET", "/v2/_trust/changefeed", nil)
+	require.NoError(t, err)
+	err = Changefeed(nil)(context.Background(), httptest.NewRecorder(), req)
+	require.Error(t, err)
+}
+
+// Only changes to GUNs the requester can pull are returned, and the requested
+// number of records is still filled from later changes if possible
+func TestChangefeedFilteredByAccess(t *testing.T) {
+	metaStore := storeWithGUNs(t, "gun1", "secret1", "gun2", "secret2", "gun3")
+	ac := gunAccessController{"gun1": true, "gun2": true, "gun3": true}
+
+	resp, err := getChangefeed(t, ac, metaStore, "records=2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun1", "gun2"}, changefeedGUNs(resp))
+
+	resp, err = getChangefeed(t, ac, metaStore, fmt.Sprintf("change_id=%d", resp.Records[1].ID))
+	require.NoError(t, err)
+	require.Equal(t, []string{"gun3"}, changefeedGUNs(resp))
+
+	resp, err = getChangefeed(t, gunAccessController{}, metaStore, "")
+	require.NoError(t, err)
+	require.Equal(t, 0, resp.NumberOfRecords)
+	require.EqualValues(t, 5, resp.ChangeID)
+}
+
+// When filtering by access, at most maxScannedRecords changes are examined for
+// a request, and the ID of the last one examined is returned so the changefeed
+// can be read on from there
+func TestChangefeedFilteredScanIsBounded(t *testing.T) {
+	guns := make([]string, 0, maxScannedRecords+1)
+	for i := 0; i <= maxScannedRecords; i++ {
+		guns = append(guns, fmt.Sprintf("gun%d", i))
+	}
+	metaStore := storeWithGUNs(t, guns...)
+	ac := gunAccessController{guns[maxScannedRecords]: true}
+
+	resp, err := getChangefeed(t, ac, metaStore, "records=10")
+	require.NoError(t, err)
+	require.Len(t, resp.Records, 0)
+	require.EqualValues(t, maxScannedRecords, resp.ChangeID)
+
+	resp, err = getChangefeed(t, ac, metaStore, fmt.Sprintf("records=10&change_id=%d", resp.ChangeID))
+	require.NoError(t, err)
+	require.Equal(t, []string{guns[maxScannedRecords]}, changefeedGUNs(resp))
+	require.EqualValues(t, maxScannedRecords+1, resp.ChangeID)
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -13,9 +15,11 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/docker/notary/server/errors"
+	"github.com/docker/notary/server/policy"
 	"github.com/docker/notary/server/snapshot"
 	"github.com/docker/notary/server/storage"
 	"github.com/docker/notary/server/timestamp"
+	"github.com/docker/notary/server/webhooks"
 	"github.com/docker/notary/tuf/data"
 	"github.com/docker/notary/tuf/signed"
 	"github.com/docker/notary/tuf/validation"
@@ -87,7 +91,8 @@ func atomicUpdateHandler(ctx context.Context, w http.ResponseWriter, r *http.Req
 			Data:    inBuf.Bytes(),
 		})
 	}
-	updates, err = validateUpdate(cryptoService, gun, updates, store)
+	policies, _ := ctx.Value("policies").(policy.Policies)
+	updates, err = validateUpdate(cryptoService, gun, updates, store, policies)
 	if err != nil {
 		serializable, serializableError := validation.NewSerializableError(err)
 		if serializableError != nil {
@@ -95,15 +100,35 @@ func atomicUpdateHandler(ctx context.Context, w http.ResponseWriter, r *http.Req
 		}
 		return errors.ErrInvalidUpdate.WithDetail(serializable)
 	}
+	dispatcher, _ := ctx.Value("webhooks").(*webhooks.Dispatcher)
+	var previous map[string]data.Files
+	if dispatcher != nil {
+		// the targets have to be read before the update to tell what changed
+		previous, err = webhooks.PreviousTargets(store, gun, updates)
+		if err != nil {
+			return errors.ErrUnknown.WithDetail(err)
+		}
+	}
 	err = store.UpdateMany(gun, updates)
 	if err != nil {
 		// If we have an old version error, surface to user with error code
-		if _, ok := err.(storage.ErrOldVersion); ok {
+		// (the storage backends return it as a pointer)
+		switch err.(type) {
+		case storage.ErrOldVersion, *storage.ErrOldVersion:
 			return errors.ErrOldVersion.WithDetail(err)
 		}
 		// More generic storage update error, possibly due to attempted rollback
 		return errors.ErrUpdating.WithDetail(nil)
 	}
+	if dispatcher != nil {
+		event, err := webhooks.NewPublishEvent(gun, updates, previous)
+		if err != nil {
+			// the update has already succeeded, so just log the error
+			logrus.Errorf("unable to create publish event for %s: %v", gun, err)
+			return nil
+		}
+		dispatcher.Notify(event)
+	}
 	return nil
 }
 
@@ -117,6 +142,7 @@ func GetHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) err
 func getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	gun := vars["imageName"]
 	checksum := vars["checksum"]
+	version := vars["version"]
 	tufRole := vars["tufRole"]
 	s := ctx.Value("metaStore")
 
@@ -125,7 +151,7 @@ func getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, var
 		return errors.ErrNoStorage.WithDetail(nil)
 	}
 
-	lastModified, output, err := getRole(ctx, store, gun, tufRole, checksum)
+	lastModified, output, err := getRole(ctx, store, gun, tufRole, checksum, version)
 	if err != nil {
 		return err
 	}
@@ -135,8 +161,16 @@ func getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, var
 		// of time.
 		utils.SetLastModifiedHeader(w.Header(), *lastModified)
 	} else {
-		logrus.Warnf("Got bytes out for %s's %s (checksum: %s), but missing lastModified date",
-			gun, tufRole, checksum)
+		logrus.Warnf("Got bytes out for %s's %s (checksum: %s, version: %s), but missing lastModified date",
+			gun, tufRole, checksum, version)
+	}
+
+	// the entity tag is the same checksum the metadata is stored and referenced by
+	etag := sha256.Sum256(output)
+	utils.SetETagHeader(w.Header(), hex.EncodeToString(etag[:]))
+	if utils.NotModified(r, hex.EncodeToString(etag[:]), lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
 	}
 
 	w.Write(output)
@@ -145,12 +179,16 @@ func getHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, var
 
 // DeleteHandler deletes all data for a GUN. A 200 responses indicates success.
 func DeleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	vars := mux.Vars(r)
+	return deleteHandler(ctx, w, r, vars)
+}
+
+func deleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	s := ctx.Value("metaStore")
 	store, ok := s.(storage.MetaStore)
 	if !ok {
 		return errors.ErrNoStorage.WithDetail(nil)
 	}
-	vars := mux.Vars(r)
 	gun := vars["imageName"]
 	logger := ctxu.GetLoggerWithField(ctx, gun, "gun")
 	err := store.Delete(gun)
@@ -158,6 +196,9 @@ func DeleteHandler(ctx context.Context, w http.ResponseWriter, r *http.Request)
 		logger.Error("500 DELETE repository")
 		return errors.ErrUnknown.WithDetail(err)
 	}
+	if dispatcher, ok := ctx.Value("webhooks").(*webhooks.Dispatcher); ok && dispatcher != nil {
+		dispatcher.Notify(webhooks.NewDeleteEvent(gun))
+	}
 	return nil
 }
 
@@ -170,6 +211,30 @@ func GetKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request)
 }
 
 func getKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return keyHandler(ctx, w, vars, false)
+}
+
+// RotateKeyHandler creates a new key-pair for the specified role, which
+// replaces the current one, and returns its public key.  The replaced keys are
+// kept, so metadata is still signed with the key the current root trusts until
+// a new root that trusts the new key is published.
+func RotateKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	return rotateKeyHandler(ctx, w, r, vars)
+}
+
+func rotateKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	return keyHandler(ctx, w, vars, true)
+}
+
+// keyHandler returns the public key for the specified role, either getting it
+// (and creating it if it doesn't exist yet), or rotating it
+func keyHandler(ctx context.Context, w http.ResponseWriter, vars map[string]string, rotate bool) error {
+	method := "GET"
+	if rotate {
+		method = "POST"
+	}
 	gun, ok := vars["imageName"]
 	if !ok || gun == "" {
 		return errors.ErrUnknown.WithDetail("no gun")
@@ -184,19 +249,19 @@ func getKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request,
 	s := ctx.Value("metaStore")
 	store, ok := s.(storage.MetaStore)
 	if !ok || store == nil {
-		logger.Error("500 GET storage not configured")
+		logger.Errorf("500 %s storage not configured", method)
 		return errors.ErrNoStorage.WithDetail(nil)
 	}
 	c := ctx.Value("cryptoService")
 	crypto, ok := c.(signed.CryptoService)
 	if !ok || crypto == nil {
-		logger.Error("500 GET crypto service not configured")
+		logger.Errorf("500 %s crypto service not configured", method)
 		return errors.ErrNoCryptoService.WithDetail(nil)
 	}
 	algo := ctx.Value("keyAlgorithm")
 	keyAlgo, ok := algo.(string)
 	if !ok || keyAlgo == "" {
-		logger.Error("500 GET key algorithm not configured")
+		logger.Errorf("500 %s key algorithm not configured", method)
 		return errors.ErrNoKeyAlgorithm.WithDetail(nil)
 	}
 	keyAlgorithm := keyAlgo
@@ -205,26 +270,30 @@ func getKeyHandler(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		key data.PublicKey
 		err error
 	)
-	switch role {
-	case data.CanonicalTimestampRole:
+	switch {
+	case role == data.CanonicalTimestampRole && rotate:
+		key, err = timestamp.RotateTimestampKey(gun, store, crypto, keyAlgorithm)
+	case role == data.CanonicalTimestampRole:
 		key, err = timestamp.GetOrCreateTimestampKey(gun, store, crypto, keyAlgorithm)
-	case data.CanonicalSnapshotRole:
+	case role == data.CanonicalSnapshotRole && rotate:
+		key, err = snapshot.RotateSnapshotKey(gun, store, crypto, keyAlgorithm)
+	case role == data.CanonicalSnapshotRole:
 		key, err = snapshot.GetOrCreateSnapshotKey(gun, store, crypto, keyAlgorithm)
 	default:
-		logger.Errorf("400 GET %s key: %v", role, err)
+		logger.Errorf("400 %s %s key: %v", method, role, err)
 		return errors.ErrInvalidRole.WithDetail(role)
 	}
 	if err != nil {
-		logger.Errorf("500 GET %s key: %v", role, err)
+		logger.Errorf("500 %s %s key: %v", method, role, err)
 		return errors.ErrUnknown.WithDetail(err)
 	}
 
 	out, err := json.Marshal(key)
 	if err != nil {
-		logger.Errorf("500 GET %s key", role)
+		logger.Errorf("500 %s %s key", method, role)
 		return errors.ErrUnknown.WithDetail(err)
 	}
-	logger.Debugf("200 GET %s key", role)
+	logger.Debugf("200 %s %s key", method, role)
 	w.Write(out)
 	return nil
 }
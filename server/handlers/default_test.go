@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -16,6 +18,7 @@ import (
 	"github.com/docker/distribution/registry/api/errcode"
 	"github.com/docker/notary/server/errors"
 	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/server/webhooks"
 	"github.com/docker/notary/tuf/data"
 	"github.com/docker/notary/tuf/signed"
 	"github.com/docker/notary/tuf/store"
@@ -172,6 +175,46 @@ func TestGetKeyHandlerCreatesOnce(t *testing.T) {
 	}
 }
 
+// Rotating a key replaces the key returned by GetKeyHandler, but keeps the
+// previous one
+func TestRotateKeyHandler(t *testing.T) {
+	state := defaultState()
+	ctx := getContext(state)
+	req := &http.Request{Body: ioutil.NopCloser(bytes.NewBuffer(nil))}
+
+	for _, role := range []string{data.CanonicalTimestampRole, data.CanonicalSnapshotRole} {
+		vars := map[string]string{"imageName": "gun", "tufRole": role}
+
+		getKey := func(handler func(context.Context, http.ResponseWriter, *http.Request, map[string]string) error) string {
+			recorder := httptest.NewRecorder()
+			require.NoError(t, handler(ctx, recorder, req, vars))
+			key, err := data.UnmarshalPublicKey(recorder.Body.Bytes())
+			require.NoError(t, err)
+			return key.ID()
+		}
+
+		original := getKey(getKeyHandler)
+		rotated := getKey(rotateKeyHandler)
+		require.NotEqual(t, original, rotated)
+		require.Equal(t, rotated, getKey(getKeyHandler))
+
+		// the crypto service still has the original key
+		require.NotNil(t, state.crypto.(signed.CryptoService).GetKey(original))
+
+		keys, err := state.store.(storage.MetaStore).GetKeys("gun", role)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		require.Equal(t, original, keys[0].ID())
+		require.Equal(t, rotated, keys[1].ID())
+	}
+
+	// only the timestamp and snapshot keys can be rotated
+	vars := map[string]string{"imageName": "gun", "tufRole": data.CanonicalTargetsRole}
+	err := rotateKeyHandler(ctx, httptest.NewRecorder(), req, vars)
+	require.Error(t, err)
+	require.Equal(t, errors.ErrInvalidRole, err.(errcode.Error).Code)
+}
+
 func TestGetHandlerRoot(t *testing.T) {
 	metaStore := storage.NewMemStorage()
 	repo, _, err := testutils.EmptyRepo("gun")
@@ -262,6 +305,70 @@ func TestGetHandlerSnapshot(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// The ETag is the checksum of the metadata, and a conditional GET for metadata
+// the client already has gets a 304 without a body
+func TestGetHandlerConditional(t *testing.T) {
+	metaStore := storage.NewMemStorage()
+	repo, _, err := testutils.EmptyRepo("gun")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, "metaStore", metaStore)
+
+	root, err := repo.SignRoot(data.DefaultExpires("root"))
+	require.NoError(t, err)
+	rootJSON, err := json.Marshal(root)
+	require.NoError(t, err)
+	metaStore.UpdateCurrent("gun", storage.MetaUpdate{Role: "root", Version: 1, Data: rootJSON})
+	checksum := sha256.Sum256(rootJSON)
+	etag := hex.EncodeToString(checksum[:])
+
+	vars := map[string]string{
+		"imageName": "gun",
+		"tufRole":   "root",
+	}
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := &http.Request{Header: header, Body: ioutil.NopCloser(bytes.NewBuffer(nil))}
+		rw := httptest.NewRecorder()
+		require.NoError(t, getHandler(ctx, rw, req, vars))
+		return rw
+	}
+
+	rw := get(nil)
+	require.Equal(t, http.StatusOK, rw.Code)
+	require.Equal(t, rootJSON, rw.Body.Bytes())
+	require.Equal(t, `"`+etag+`"`, rw.HeaderMap.Get("ETag"))
+	lastModified := rw.HeaderMap.Get("Last-Modified")
+
+	notModified := []http.Header{
+		{"If-None-Match": {`"` + etag + `"`}},
+		{"If-None-Match": {`"other", W/"` + etag + `"`}},
+		{"If-None-Match": {"*"}},
+		{"If-Modified-Since": {lastModified}},
+		{"If-Modified-Since": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}},
+	}
+	for _, header := range notModified {
+		rw := get(header)
+		require.Equal(t, http.StatusNotModified, rw.Code, "%v", header)
+		require.Empty(t, rw.Body.Bytes())
+		require.Equal(t, `"`+etag+`"`, rw.HeaderMap.Get("ETag"))
+	}
+
+	modified := []http.Header{
+		{"If-None-Match": {`"other"`}},
+		// If-None-Match takes precedence over If-Modified-Since
+		{"If-None-Match": {`"other"`}, "If-Modified-Since": {lastModified}},
+		{"If-Modified-Since": {time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)}},
+		{"If-Modified-Since": {"not a date"}},
+	}
+	for _, header := range modified {
+		rw := get(header)
+		require.Equal(t, http.StatusOK, rw.Code, "%v", header)
+		require.Equal(t, rootJSON, rw.Body.Bytes())
+	}
+}
+
 func TestGetHandler404(t *testing.T) {
 	metaStore := storage.NewMemStorage()
 
@@ -433,3 +540,143 @@ func TestAtomicUpdateVersionErrorPropagated(t *testing.T) {
 	require.Equal(t, errors.ErrOldVersion, errorObj.Code)
 	require.Equal(t, storage.ErrOldVersion{}, errorObj.Detail)
 }
+
+// the storage backends return a pointer to an ErrOldVersion, which is also
+// surfaced to the user with its own error code
+func TestAtomicUpdateVersionPointerErrorPropagated(t *testing.T) {
+	gun := "testGUN"
+	metaStore := storage.NewMemStorage()
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+	state := handlerState{store: metaStore, crypto: copyKeys(t, cs, data.CanonicalTimestampRole)}
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	rs, tgs, sns, _, err := testutils.Serialize(r, tg, sn, ts)
+	require.NoError(t, err)
+	metas := map[string][]byte{
+		data.CanonicalRootRole:     rs,
+		data.CanonicalTargetsRole:  tgs,
+		data.CanonicalSnapshotRole: sns,
+	}
+
+	req, err := store.NewMultiPartMetaRequest("", metas)
+	require.NoError(t, err)
+	require.NoError(t, atomicUpdateHandler(getContext(state), httptest.NewRecorder(), req, map[string]string{"imageName": gun}))
+
+	// publishing the same versions again is rejected
+	req, err = store.NewMultiPartMetaRequest("", metas)
+	require.NoError(t, err)
+	err = atomicUpdateHandler(getContext(state), httptest.NewRecorder(), req, map[string]string{"imageName": gun})
+	require.Error(t, err)
+	errorObj, ok := err.(errcode.Error)
+	require.True(t, ok, "Expected an errcode.Error, got %v", err)
+	require.Equal(t, errors.ErrOldVersion, errorObj.Code)
+}
+
+// collects the events POSTed to a webhook endpoint
+func webhookReceiver(t *testing.T) (*httptest.Server, chan webhooks.Event) {
+	events := make(chan webhooks.Event, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	return server, events
+}
+
+// a successful update notifies webhooks of the new versions of every role,
+// including the ones the server signs, and of the targets that were added
+func TestAtomicUpdateNotifiesWebhooks(t *testing.T) {
+	server, events := webhookReceiver(t)
+	defer server.Close()
+	dispatcher := webhooks.NewDispatcher(webhooks.Config{
+		Endpoints: []webhooks.Endpoint{{URL: server.URL, Secret: "secret"}}})
+
+	gun := "testGUN"
+	vars := map[string]string{"imageName": gun}
+
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+	state := handlerState{store: storage.NewMemStorage(), crypto: copyKeys(t, cs, data.CanonicalTimestampRole)}
+	ctx := context.WithValue(getContext(state), "webhooks", dispatcher)
+
+	publish := func() {
+		r, tg, sn, ts, err := testutils.Sign(repo)
+		require.NoError(t, err)
+		rs, tgs, sns, _, err := testutils.Serialize(r, tg, sn, ts)
+		require.NoError(t, err)
+		req, err := store.NewMultiPartMetaRequest("", map[string][]byte{
+			data.CanonicalRootRole:     rs,
+			data.CanonicalTargetsRole:  tgs,
+			data.CanonicalSnapshotRole: sns,
+		})
+		require.NoError(t, err)
+		require.NoError(t, atomicUpdateHandler(ctx, httptest.NewRecorder(), req, vars))
+		dispatcher.Wait()
+	}
+
+	publish()
+	event := <-events
+	require.Equal(t, webhooks.ActionPublish, event.Action)
+	require.Equal(t, gun, event.GUN)
+	require.Len(t, event.Roles, 4)
+	require.Empty(t, event.Added)
+
+	_, err = repo.AddTargets(data.CanonicalTargetsRole, data.Files{"latest": data.FileMeta{
+		Length: 1, Hashes: data.Hashes{"sha256": []byte("1")}}})
+	require.NoError(t, err)
+	publish()
+	event = <-events
+	require.Equal(t, []webhooks.TargetPath{{Role: data.CanonicalTargetsRole, Path: "latest"}}, event.Added)
+	require.Empty(t, event.Removed)
+}
+
+// a failed update does not notify webhooks
+func TestAtomicUpdateFailureDoesNotNotifyWebhooks(t *testing.T) {
+	server, events := webhookReceiver(t)
+	defer server.Close()
+	dispatcher := webhooks.NewDispatcher(webhooks.Config{
+		Endpoints: []webhooks.Endpoint{{URL: server.URL, Secret: "secret"}}})
+
+	gun := "testGUN"
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+	state := handlerState{
+		store:  &invalidVersionStore{*storage.NewMemStorage()},
+		crypto: copyKeys(t, cs, data.CanonicalTimestampRole),
+	}
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	rs, tgs, sns, _, err := testutils.Serialize(r, tg, sn, ts)
+	require.NoError(t, err)
+	req, err := store.NewMultiPartMetaRequest("", map[string][]byte{
+		data.CanonicalRootRole:     rs,
+		data.CanonicalTargetsRole:  tgs,
+		data.CanonicalSnapshotRole: sns,
+	})
+	require.NoError(t, err)
+
+	err = atomicUpdateHandler(context.WithValue(getContext(state), "webhooks", dispatcher),
+		httptest.NewRecorder(), req, map[string]string{"imageName": gun})
+	require.Error(t, err)
+	dispatcher.Wait()
+	require.Len(t, events, 0)
+}
+
+func TestDeleteHandlerNotifiesWebhooks(t *testing.T) {
+	server, events := webhookReceiver(t)
+	defer server.Close()
+	dispatcher := webhooks.NewDispatcher(webhooks.Config{
+		Endpoints: []webhooks.Endpoint{{URL: server.URL, Secret: "secret"}}})
+
+	ctx := context.WithValue(getContext(defaultState()), "webhooks", dispatcher)
+	require.NoError(t, deleteHandler(ctx, httptest.NewRecorder(), &http.Request{},
+		map[string]string{"imageName": "gun"}))
+	dispatcher.Wait()
+
+	event := <-events
+	require.Equal(t, webhooks.ActionDelete, event.Action)
+	require.Equal(t, "gun", event.GUN)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"strconv"
 	"time"
 
 	"golang.org/x/net/context"
@@ -13,13 +14,22 @@ import (
 	"github.com/docker/notary/tuf/signed"
 )
 
-func getRole(ctx context.Context, store storage.MetaStore, gun, role, checksum string) (*time.Time, []byte, error) {
+func getRole(ctx context.Context, store storage.MetaStore, gun, role, checksum, version string) (*time.Time, []byte, error) {
 	var (
 		lastModified *time.Time
 		out          []byte
 		err          error
 	)
-	if checksum == "" {
+	switch {
+	case checksum != "":
+		lastModified, out, err = store.GetChecksum(gun, role, checksum)
+	case version != "":
+		var v int
+		if v, err = strconv.Atoi(version); err != nil {
+			return nil, nil, errors.ErrMetadataNotFound.WithDetail(err)
+		}
+		lastModified, out, err = store.GetVersion(gun, role, v)
+	default:
 		// the timestamp and snapshot might be server signed so are
 		// handled specially
 		switch role {
@@ -27,8 +37,6 @@ func getRole(ctx context.Context, store storage.MetaStore, gun, role, checksum s
 			return getMaybeServerSigned(ctx, store, gun, role)
 		}
 		lastModified, out, err = store.GetCurrent(gun, role)
-	} else {
-		lastModified, out, err = store.GetChecksum(gun, role, checksum)
 	}
 
 	if err != nil {
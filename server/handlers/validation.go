@@ -8,9 +8,11 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 
+	"github.com/docker/notary/server/policy"
 	"github.com/docker/notary/server/snapshot"
 	"github.com/docker/notary/server/storage"
 	"github.com/docker/notary/server/timestamp"
@@ -22,12 +24,14 @@ import (
 )
 
 // validateUpload checks that the updates being pushed
-// are semantically correct and the signatures are correct
+// are semantically correct and the signatures are correct,
+// and that they comply with any policies that apply to the GUN
 // A list of possibly modified updates are returned if all
 // validation was successful. This allows the snapshot to be
 // created and added if snapshotting has been delegated to the
 // server
-func validateUpdate(cs signed.CryptoService, gun string, updates []storage.MetaUpdate, store storage.MetaStore) ([]storage.MetaUpdate, error) {
+func validateUpdate(cs signed.CryptoService, gun string, updates []storage.MetaUpdate, store storage.MetaStore,
+	policies policy.Policies) ([]storage.MetaUpdate, error) {
 	repo := tuf.NewRepo(cs)
 	rootRole := data.CanonicalRootRole
 	snapshotRole := data.CanonicalSnapshotRole
@@ -127,6 +131,19 @@ func validateUpdate(cs signed.CryptoService, gun string, updates []storage.MetaU
 		updatesToApply = append(updatesToApply, *update)
 	}
 
+	// only the metadata that was uploaded is subject to the policies, not the
+	// metadata generated by the server
+	uploaded := make([]storage.MetaUpdate, 0, len(updatesToApply))
+	for _, update := range updatesToApply {
+		if _, ok := roles[update.Role]; ok {
+			uploaded = append(uploaded, update)
+		}
+	}
+	if err := policies.Check(gun, uploaded, time.Now()); err != nil {
+		logrus.Error("ErrValidation: ", err.Error())
+		return nil, err
+	}
+
 	// generate a timestamp immediately
 	update, err := generateTimestamp(gun, repo, store)
 	if err != nil {
@@ -336,6 +353,9 @@ func checkHashes(meta data.FileMeta, update []byte) bool {
 	return true
 }
 
+// validateTargets returns the parsed data.SignedTargets object if the targets
+// or delegation metadata is valid and has enough valid signatures to meet the
+// threshold of its role, as defined in root or in its parent's delegations
 func validateTargets(role string, roles map[string]storage.MetaUpdate, repo *tuf.Repo) (*data.SignedTargets, error) {
 	// TODO: when delegations are being validated, validate parent
 	//       role exists for any delegation
@@ -371,6 +391,13 @@ func validateTargets(role string, roles map[string]storage.MetaUpdate, repo *tuf
 	if !data.ValidTUFType(t.Signed.Type, data.CanonicalTargetsRole) {
 		return nil, fmt.Errorf("%s has wrong type", role)
 	}
+	// a delegation whose threshold can never be met could never be updated
+	for _, delgRole := range t.Signed.Delegations.Roles {
+		if delgRole.Threshold > len(delgRole.KeyIDs) {
+			return nil, fmt.Errorf("%s has a threshold of %d but only %d keys",
+				delgRole.Name, delgRole.Threshold, len(delgRole.KeyIDs))
+		}
+	}
 	return t, nil
 }
 
@@ -379,6 +406,8 @@ func validateTargets(role string, roles map[string]storage.MetaUpdate, repo *tuf
 // - has the correct number of timestamp keys
 // - validates against the previous root's signatures (if there was a rotation)
 // - is valid against itself (signature-wise)
+// In both cases, the root must have enough valid signatures to meet the
+// threshold of the root role.
 func validateRoot(gun string, oldRoot, newRoot []byte) (
 	*data.SignedRoot, error) {
 
@@ -417,7 +446,7 @@ func validateRoot(gun string, oldRoot, newRoot []byte) (
 		}
 	}
 
-	if err := signed.VerifyRoot(parsedNewSigned, newRootRole.Threshold, newRootRole.Keys); err != nil {
+	if err := signed.VerifySignatures(parsedNewSigned, newRootRole); err != nil {
 		return nil, validation.ErrBadRoot{Msg: err.Error()}
 	}
 
@@ -440,7 +469,7 @@ func checkAgainstOldRoot(oldRoot []byte, newRootRole data.BaseRole, newSigned *d
 	}
 
 	// Always verify the new root against the old root
-	if err := signed.VerifyRoot(newSigned, oldRootRole.Threshold, oldRootRole.Keys); err != nil {
+	if err := signed.VerifySignatures(newSigned, oldRootRole); err != nil {
 		return validation.ErrBadRoot{Msg: fmt.Sprintf(
 			"rotation detected and new root was not signed with at least %d old keys",
 			oldRootRole.Threshold)}
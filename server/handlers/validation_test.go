@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/docker/notary/cryptoservice"
 	"github.com/docker/notary/passphrase"
+	"github.com/docker/notary/server/policy"
 	"github.com/docker/notary/server/storage"
 	"github.com/docker/notary/trustmanager"
 	"github.com/docker/notary/tuf"
@@ -45,6 +47,16 @@ func (f getFailStore) GetChecksum(gun, tufRole, checksum string) (*time.Time, []
 	return nil, nil, err
 }
 
+// GetVersion returns the metadata with this version, or an error depending on
+// whether getFailStore is configured to return an error for this role
+func (f getFailStore) GetVersion(gun, tufRole string, version int) (*time.Time, []byte, error) {
+	err := f.errsToReturn[tufRole]
+	if err == nil {
+		return f.MetaStore.GetVersion(gun, tufRole, version)
+	}
+	return nil, nil, err
+}
+
 func copyKeys(t *testing.T, from signed.CryptoService, roles ...string) signed.CryptoService {
 	memKeyStore := trustmanager.NewKeyMemoryStore(passphrase.ConstantRetriever("pass"))
 	for _, role := range roles {
@@ -102,7 +114,7 @@ func TestValidateEmptyNew(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 
 	// we generated our own timestamp, and did not take the other timestamp,
@@ -127,6 +139,108 @@ func TestValidateEmptyNew(t *testing.T) {
 	require.Len(t, founds, 4)
 }
 
+// a CryptoService which signs in batches, recording the requests in each batch
+type batchCryptoService struct {
+	signed.CryptoService
+	batches [][]signed.SignatureRequest
+}
+
+func (b *batchCryptoService) SignMany(requests []signed.SignatureRequest) ([][]byte, []error) {
+	b.batches = append(b.batches, requests)
+	sigs := make([][]byte, len(requests))
+	errs := make([]error, len(requests))
+	for i, request := range requests {
+		privKey, _, err := b.GetPrivateKey(request.KeyID)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		sigs[i], errs[i] = privKey.Sign(rand.Reader, request.Content, nil)
+	}
+	return sigs, errs
+}
+
+// The server asks a batch signer for all the signatures on each role it signs
+// in one request: one for a publish with a snapshot, and one each for the
+// snapshot and the timestamp it generates otherwise, since the timestamp covers
+// the signed snapshot
+func TestValidateSignsInBatches(t *testing.T) {
+	gun := "docker.com/notary"
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	root, targets, snapshot, timestamp, err := getUpdates(r, tg, sn, ts)
+	require.NoError(t, err)
+
+	serverCrypto := &batchCryptoService{
+		CryptoService: copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)}
+	timestampKeyID := repo.Root.Signed.Roles[data.CanonicalTimestampRole].KeyIDs[0]
+	snapshotKeyID := repo.Root.Signed.Roles[data.CanonicalSnapshotRole].KeyIDs[0]
+
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets, snapshot, timestamp}, storage.NewMemStorage(), nil)
+	require.NoError(t, err)
+	require.Len(t, serverCrypto.batches, 1)
+	require.Len(t, serverCrypto.batches[0], 1)
+	require.Equal(t, timestampKeyID, serverCrypto.batches[0][0].KeyID)
+
+	serverCrypto.batches = nil
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets}, storage.NewMemStorage(), nil)
+	require.NoError(t, err)
+	require.Len(t, serverCrypto.batches, 2)
+	for i, keyID := range []string{snapshotKeyID, timestampKeyID} {
+		require.Len(t, serverCrypto.batches[i], 1)
+		require.Equal(t, keyID, serverCrypto.batches[i][0].KeyID)
+	}
+}
+
+// Updates that violate a policy for the GUN are rejected, but metadata generated
+// by the server is not subject to the policies
+func TestValidatePolicies(t *testing.T) {
+	gun := "docker.com/notary"
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	root, targets, snapshot, timestamp, err := getUpdates(r, tg, sn, ts)
+	require.NoError(t, err)
+
+	policies := policy.Policies{{
+		GUNs:      []string{"docker.com/*"},
+		MaxExpiry: map[string]time.Duration{data.CanonicalSnapshotRole: time.Hour},
+	}}
+	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
+
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets, snapshot, timestamp}, storage.NewMemStorage(), policies)
+	require.Error(t, err)
+	require.IsType(t, validation.ErrValidation{}, err)
+	require.Contains(t, err.Error(), "snapshot expires")
+
+	// the policy doesn't apply to other GUNs
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets, snapshot, timestamp}, storage.NewMemStorage(),
+		policy.Policies{{GUNs: []string{"example.com/*"}, MaxTargets: 1}})
+	require.NoError(t, err)
+
+	// the server generates the snapshot if it isn't uploaded
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets}, storage.NewMemStorage(), policies)
+	require.NoError(t, err)
+
+	// but the uploaded metadata is still checked
+	_, err = validateUpdate(serverCrypto, gun,
+		[]storage.MetaUpdate{root, targets}, storage.NewMemStorage(),
+		policy.Policies{{GUNs: []string{"docker.com/*"},
+			MaxExpiry: map[string]time.Duration{data.CanonicalTargetsRole: time.Hour}}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "targets expires")
+}
+
 func TestValidatePrevTimestamp(t *testing.T) {
 	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
 	require.NoError(t, err)
@@ -142,7 +256,7 @@ func TestValidatePrevTimestamp(t *testing.T) {
 	store.UpdateCurrent("testGUN", timestamp)
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 
 	// we generated our own timestamp, and did not take the other timestamp,
@@ -177,7 +291,7 @@ func TestValidatePreviousTimestampCorrupt(t *testing.T) {
 	store.UpdateCurrent("testGUN", timestamp)
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, &json.SyntaxError{}, err)
 }
@@ -199,7 +313,7 @@ func TestValidateGetCurrentTimestampBroken(t *testing.T) {
 	}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, data.ErrNoSuchRole{}, err)
 }
@@ -218,7 +332,7 @@ func TestValidateNoNewRoot(t *testing.T) {
 	updates := []storage.MetaUpdate{targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -236,7 +350,7 @@ func TestValidateNoNewTargets(t *testing.T) {
 	updates := []storage.MetaUpdate{root, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -256,7 +370,7 @@ func TestValidateOnlySnapshot(t *testing.T) {
 	updates := []storage.MetaUpdate{snapshot}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -274,7 +388,7 @@ func TestValidateOldRoot(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -297,7 +411,7 @@ func TestValidateOldRootCorrupt(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, &json.SyntaxError{}, err)
 }
@@ -329,7 +443,7 @@ func TestValidateOldRootCorruptRootRole(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, data.ErrInvalidRole{}, err)
 }
@@ -354,7 +468,7 @@ func TestValidateRootGetCurrentRootBroken(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, data.ErrNoSuchRole{}, err)
 }
@@ -402,7 +516,7 @@ func TestValidateRootRotation(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, crypto, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -445,11 +559,60 @@ func TestRootRotationNotSignedWithOldKeys(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, crypto, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "new root was not signed with at least 1 old keys")
 }
 
+// A root whose root role has a threshold of 2 is only valid once it has been
+// signed by 2 of the root keys
+func TestValidateRootThreshold(t *testing.T) {
+	repo, crypto, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	store := storage.NewMemStorage()
+
+	oldRootRole := repo.Root.Signed.Roles["root"]
+	oldRootKey := repo.Root.Signed.Keys[oldRootRole.KeyIDs[0]]
+
+	rootKey, err := crypto.Create("root", "testGUN", data.ED25519Key)
+	require.NoError(t, err)
+	rootRole, err := data.NewRole("root", 2, []string{oldRootKey.ID(), rootKey.ID()}, nil)
+	require.NoError(t, err)
+	repo.Root.Signed.Roles["root"] = &rootRole.RootRole
+	repo.Root.Signed.Keys[rootKey.ID()] = rootKey
+
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	serverCrypto := copyKeys(t, crypto, data.CanonicalTimestampRole)
+
+	// only signed with one of the root keys
+	err = signed.Sign(crypto, r, oldRootKey)
+	require.NoError(t, err)
+	require.Len(t, r.Signatures, 1)
+	root, targets, snapshot, timestamp, err := getUpdates(r, tg, sn, ts)
+	require.NoError(t, err)
+	_, err = validateUpdate(serverCrypto, "testGUN",
+		[]storage.MetaUpdate{root, targets, snapshot, timestamp}, store, nil)
+	require.Error(t, err)
+	require.IsType(t, validation.ErrBadRoot{}, err)
+	require.Contains(t, err.Error(), signed.ErrRoleThreshold{}.Error())
+
+	// signed with both of the root keys
+	err = signed.Sign(crypto, r, oldRootKey, rootKey)
+	require.NoError(t, err)
+	require.Len(t, r.Signatures, 2)
+	rt, err := data.RootFromSigned(r)
+	require.NoError(t, err)
+	repo.SetRoot(rt)
+	sn, err = repo.SignSnapshot(data.DefaultExpires(data.CanonicalSnapshotRole))
+	require.NoError(t, err)
+	root, targets, snapshot, timestamp, err = getUpdates(r, tg, sn, ts)
+	require.NoError(t, err)
+	_, err = validateUpdate(serverCrypto, "testGUN",
+		[]storage.MetaUpdate{root, targets, snapshot, timestamp}, store, nil)
+	require.NoError(t, err)
+}
+
 // An update is not valid without the root metadata.
 func TestValidateNoRoot(t *testing.T) {
 	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
@@ -464,7 +627,7 @@ func TestValidateNoRoot(t *testing.T) {
 	updates := []storage.MetaUpdate{targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrValidation{}, err)
 }
@@ -482,7 +645,7 @@ func TestValidateSnapshotMissingNoSnapshotKey(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadHierarchy{}, err)
 }
@@ -507,7 +670,7 @@ func TestValidateSnapshotGenerateNoPrev(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -538,7 +701,7 @@ func TestValidateSnapshotGenerateWithPrev(t *testing.T) {
 	require.NoError(t, err)
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 
 	for _, u := range updates {
@@ -577,7 +740,7 @@ func TestValidateSnapshotGeneratePrevCorrupt(t *testing.T) {
 	store.UpdateCurrent("testGUN", snapshot)
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, &json.SyntaxError{}, err)
 }
@@ -606,7 +769,7 @@ func TestValidateSnapshotGenerateStoreGetCurrentSnapshotBroken(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, data.ErrNoSuchRole{}, err)
 }
@@ -631,7 +794,7 @@ func TestValidateSnapshotGenerateNoTargets(t *testing.T) {
 	updates := []storage.MetaUpdate{root}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 }
 
@@ -657,7 +820,7 @@ func TestValidateSnapshotGenerate(t *testing.T) {
 	store.UpdateCurrent("testGUN", root)
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole, data.CanonicalSnapshotRole)
-	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	updates, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.NoError(t, err)
 }
 
@@ -678,7 +841,7 @@ func TestValidateRootNoTimestampKey(t *testing.T) {
 
 	// do not copy the targets key to the storage, and try to update the root
 	serverCrypto := signed.NewEd25519()
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 
@@ -707,7 +870,7 @@ func TestValidateRootInvalidTimestampKey(t *testing.T) {
 	_, err = serverCrypto.Create(data.CanonicalTimestampRole, "testGUN", data.ED25519Key)
 	require.NoError(t, err)
 
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -729,7 +892,7 @@ func TestValidateRootInvalidTimestampThreshold(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "timestamp role has invalid threshold")
 }
@@ -752,7 +915,7 @@ func TestValidateRootInvalidZeroThreshold(t *testing.T) {
 		updates := []storage.MetaUpdate{root, targets, snapshot}
 
 		serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-		_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+		_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "invalid threshold")
 	}
@@ -776,7 +939,7 @@ func TestValidateRootRoleMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -796,7 +959,7 @@ func TestValidateTargetsRoleMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -816,7 +979,7 @@ func TestValidateSnapshotRoleMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -842,7 +1005,7 @@ func TestValidateRootSigMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -863,7 +1026,7 @@ func TestValidateTargetsSigMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadTargets{}, err)
 }
@@ -884,7 +1047,7 @@ func TestValidateSnapshotSigMissing(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadSnapshot{}, err)
 }
@@ -908,7 +1071,7 @@ func TestValidateRootCorrupt(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -929,7 +1092,7 @@ func TestValidateTargetsCorrupt(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadTargets{}, err)
 }
@@ -950,7 +1113,7 @@ func TestValidateSnapshotCorrupt(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadSnapshot{}, err)
 }
@@ -978,7 +1141,7 @@ func TestValidateRootModifiedSize(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadRoot{}, err)
 }
@@ -1000,7 +1163,7 @@ func TestValidateTargetsModifiedSize(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadSnapshot{}, err)
 }
@@ -1029,7 +1192,7 @@ func TestValidateRootModifiedHash(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadSnapshot{}, err)
 }
@@ -1055,7 +1218,7 @@ func TestValidateTargetsModifiedHash(t *testing.T) {
 	updates := []storage.MetaUpdate{root, targets, snapshot, timestamp}
 
 	serverCrypto := copyKeys(t, cs, data.CanonicalTimestampRole)
-	_, err = validateUpdate(serverCrypto, "testGUN", updates, store)
+	_, err = validateUpdate(serverCrypto, "testGUN", updates, store, nil)
 	require.Error(t, err)
 	require.IsType(t, validation.ErrBadSnapshot{}, err)
 }
@@ -1238,6 +1401,105 @@ func TestValidateTargetsParentInUpdate(t *testing.T) {
 	require.Equal(t, delJSON, updates[1].Data)
 }
 
+// Delegation metadata is only valid once it has been signed by enough of the
+// delegation's keys to meet the threshold in its parent
+func TestValidateTargetsDelegationThreshold(t *testing.T) {
+	baseRepo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	store := storage.NewMemStorage()
+
+	k1, err := cs.Create("targets/level1", "docker.com/notary", data.ED25519Key)
+	require.NoError(t, err)
+	k2, err := cs.Create("targets/level1", "docker.com/notary", data.ED25519Key)
+	require.NoError(t, err)
+
+	err = baseRepo.UpdateDelegationKeys("targets/level1", []data.PublicKey{k1, k2}, []string{}, 2)
+	require.NoError(t, err)
+	err = baseRepo.UpdateDelegationPaths("targets/level1", []string{""}, []string{}, false)
+	require.NoError(t, err)
+
+	// no targets file is created for the new delegations, so force one
+	baseRepo.InitTargets("targets/level1")
+
+	targets, err := baseRepo.SignTargets("targets", data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	tgtsJSON, err := json.Marshal(targets)
+	require.NoError(t, err)
+	update := storage.MetaUpdate{
+		Role:    data.CanonicalTargetsRole,
+		Version: 1,
+		Data:    tgtsJSON,
+	}
+	store.UpdateCurrent("gun", update)
+
+	del, err := baseRepo.SignTargets("targets/level1", data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	require.Len(t, del.Signatures, 2)
+
+	for _, numSigs := range []int{1, 2} {
+		signedDel := *del
+		signedDel.Signatures = del.Signatures[:numSigs]
+		delJSON, err := json.Marshal(signedDel)
+		require.NoError(t, err)
+
+		roles := map[string]storage.MetaUpdate{
+			"targets/level1": {
+				Role:    "targets/level1",
+				Version: 1,
+				Data:    delJSON,
+			},
+		}
+
+		valRepo := tuf.NewRepo(nil)
+		valRepo.SetRoot(baseRepo.Root)
+
+		updates, err := loadAndValidateTargets("gun", valRepo, roles, store)
+		if numSigs < 2 {
+			require.Error(t, err)
+			require.IsType(t, validation.ErrBadTargets{}, err)
+			require.Contains(t, err.Error(), signed.ErrRoleThreshold{}.Error())
+		} else {
+			require.NoError(t, err)
+			require.Len(t, updates, 1)
+		}
+	}
+}
+
+// Targets metadata is not valid if it has a delegation whose threshold is
+// greater than its number of keys, since the delegation could never be signed
+func TestValidateTargetsDelegationThresholdTooHigh(t *testing.T) {
+	baseRepo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	store := storage.NewMemStorage()
+
+	k, err := cs.Create("targets/level1", "docker.com/notary", data.ED25519Key)
+	require.NoError(t, err)
+
+	err = baseRepo.UpdateDelegationKeys("targets/level1", []data.PublicKey{k}, []string{}, 1)
+	require.NoError(t, err)
+	baseRepo.Targets[data.CanonicalTargetsRole].Signed.Delegations.Roles[0].Threshold = 2
+
+	targets, err := baseRepo.SignTargets("targets", data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	tgtsJSON, err := json.Marshal(targets)
+	require.NoError(t, err)
+	roles := map[string]storage.MetaUpdate{
+		data.CanonicalTargetsRole: {
+			Role:    data.CanonicalTargetsRole,
+			Version: 1,
+			Data:    tgtsJSON,
+		},
+	}
+
+	valRepo := tuf.NewRepo(nil)
+	valRepo.SetRoot(baseRepo.Root)
+
+	_, err = loadAndValidateTargets("gun", valRepo, roles, store)
+	require.Error(t, err)
+	require.IsType(t, validation.ErrBadTargets{}, err)
+	require.Contains(t, err.Error(), "threshold of 2 but only 1 keys")
+}
+
 func TestValidateTargetsParentNotFound(t *testing.T) {
 	baseRepo, cs, err := testutils.EmptyRepo("docker.com/notary")
 	require.NoError(t, err)
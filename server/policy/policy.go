@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/validation"
+	"github.com/docker/notary/utils"
+)
+
+// Policy is a set of organizational rules that the metadata published to every
+// GUN matching one of its globs must follow, on top of the usual validation.
+// Zero values are not enforced.
+type Policy struct {
+	// GUNs are the globs of the GUNs the policy applies to, in which "*"
+	// matches any sequence of characters
+	GUNs []string
+	// MinThresholds is the minimum signature threshold of each role
+	MinThresholds map[string]int
+	// KeyAlgorithms are the algorithms that keys may use, such as
+	// data.ECDSAKey - certificates are allowed if their key's algorithm is
+	KeyAlgorithms []string
+	// MaxExpiry is how far in the future each role may expire
+	MaxExpiry map[string]time.Duration
+	// RequiredDelegations are the delegation roles that must exist
+	RequiredDelegations []string
+	// MaxTargets is the maximum number of targets in each targets role
+	MaxTargets int
+}
+
+// Policies are all the policies configured for a server
+type Policies []Policy
+
+// Applies returns whether the policy applies to a GUN
+func (p Policy) Applies(gun string) bool {
+	for _, glob := range p.GUNs {
+		if utils.GlobToRegexp(glob).MatchString(gun) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a validation.ErrValidation describing the first violation, by
+// the updates being published to a GUN, of any policy that applies to it.
+// Only the updated roles are checked.
+func (ps Policies) Check(gun string, updates []storage.MetaUpdate, now time.Time) error {
+	for _, p := range ps {
+		if !p.Applies(gun) {
+			continue
+		}
+		for _, update := range updates {
+			if err := p.check(update, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func violation(format string, args ...interface{}) error {
+	return validation.ErrValidation{Msg: "policy violation: " + fmt.Sprintf(format, args...)}
+}
+
+// limitFor returns the limit for a role - delegations that have no limit of
+// their own have the limit of the targets role
+func limitFor(role string, hasLimit func(string) bool) (string, bool) {
+	if hasLimit(role) {
+		return role, true
+	}
+	if data.IsDelegation(role) && hasLimit(data.CanonicalTargetsRole) {
+		return data.CanonicalTargetsRole, true
+	}
+	return "", false
+}
+
+func (p Policy) check(update storage.MetaUpdate, now time.Time) error {
+	switch {
+	case update.Role == data.CanonicalRootRole:
+		root := &data.SignedRoot{}
+		if err := json.Unmarshal(update.Data, root); err != nil {
+			return validation.ErrBadRoot{Msg: err.Error()}
+		}
+		if err := p.checkExpiry(update.Role, root.Signed.Expires, now); err != nil {
+			return err
+		}
+		for role, rootRole := range root.Signed.Roles {
+			if err := p.checkThreshold(role, rootRole.Threshold); err != nil {
+				return err
+			}
+		}
+		return p.checkKeys(update.Role, root.Signed.Keys)
+
+	case update.Role == data.CanonicalTargetsRole || data.IsDelegation(update.Role):
+		targets := &data.SignedTargets{}
+		if err := json.Unmarshal(update.Data, targets); err != nil {
+			return validation.ErrBadTargets{Msg: err.Error()}
+		}
+		if err := p.checkExpiry(update.Role, targets.Signed.Expires, now); err != nil {
+			return err
+		}
+		if p.MaxTargets > 0 && len(targets.Signed.Targets) > p.MaxTargets {
+			return violation("%s has %d targets, more than the maximum of %d",
+				update.Role, len(targets.Signed.Targets), p.MaxTargets)
+		}
+		delegated := make(map[string]bool)
+		for _, role := range targets.Signed.Delegations.Roles {
+			delegated[role.Name] = true
+			if err := p.checkThreshold(role.Name, role.Threshold); err != nil {
+				return err
+			}
+		}
+		for _, required := range p.RequiredDelegations {
+			if path.Dir(required) == update.Role && !delegated[required] {
+				return violation("%s must delegate to %s", update.Role, required)
+			}
+		}
+		return p.checkKeys(update.Role, targets.Signed.Delegations.Keys)
+
+	default:
+		meta := &data.SignedMeta{}
+		if err := json.Unmarshal(update.Data, meta); err != nil {
+			return validation.ErrValidation{Msg: err.Error()}
+		}
+		return p.checkExpiry(update.Role, meta.Signed.Expires, now)
+	}
+}
+
+func (p Policy) checkThreshold(role string, threshold int) error {
+	limitRole, ok := limitFor(role, func(r string) bool { _, ok := p.MinThresholds[r]; return ok })
+	if ok && threshold < p.MinThresholds[limitRole] {
+		return violation("the threshold of %s is %d, less than the minimum of %d",
+			role, threshold, p.MinThresholds[limitRole])
+	}
+	return nil
+}
+
+func (p Policy) checkExpiry(role string, expires, now time.Time) error {
+	limitRole, ok := limitFor(role, func(r string) bool { _, ok := p.MaxExpiry[r]; return ok })
+	if ok && expires.Sub(now) > p.MaxExpiry[limitRole] {
+		return violation("%s expires more than %s in the future", role, p.MaxExpiry[limitRole])
+	}
+	return nil
+}
+
+func (p Policy) checkKeys(role string, keys data.Keys) error {
+	if len(p.KeyAlgorithms) == 0 {
+		return nil
+	}
+	for keyID, key := range keys {
+		algorithm := strings.TrimSuffix(key.Algorithm(), "-x509")
+		allowed := false
+		for _, a := range p.KeyAlgorithms {
+			allowed = allowed || a == algorithm
+		}
+		if !allowed {
+			return violation("key %s in %s uses %s, which is not one of the allowed algorithms: %s",
+				keyID, role, key.Algorithm(), strings.Join(p.KeyAlgorithms, ", "))
+		}
+	}
+	return nil
+}
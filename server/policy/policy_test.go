@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/testutils"
+	"github.com/docker/notary/tuf/validation"
+)
+
+// returns the updates for a new repo with a delegation and a couple of targets
+func newRepoUpdates(t *testing.T, gun string) []storage.MetaUpdate {
+	repo, _, err := testutils.EmptyRepo(gun, "targets/releases")
+	require.NoError(t, err)
+	_, err = repo.AddTargets(data.CanonicalTargetsRole, data.Files{
+		"v1": data.FileMeta{Length: 1, Hashes: data.Hashes{"sha256": []byte("1")}},
+		"v2": data.FileMeta{Length: 2, Hashes: data.Hashes{"sha256": []byte("2")}},
+	})
+	require.NoError(t, err)
+
+	_, err = repo.InitTargets("targets/releases")
+	require.NoError(t, err)
+	delegation, err := repo.SignTargets("targets/releases", data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	ds, err := json.Marshal(delegation)
+	require.NoError(t, err)
+	r, tg, sn, ts, err := testutils.Sign(repo)
+	require.NoError(t, err)
+	rs, tgs, sns, tss, err := testutils.Serialize(r, tg, sn, ts)
+	require.NoError(t, err)
+
+	return []storage.MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: rs},
+		{Role: data.CanonicalTargetsRole, Version: 1, Data: tgs},
+		{Role: "targets/releases", Version: 1, Data: ds},
+		{Role: data.CanonicalSnapshotRole, Version: 1, Data: sns},
+		{Role: data.CanonicalTimestampRole, Version: 1, Data: tss},
+	}
+}
+
+func requireViolation(t *testing.T, err error, contains string) {
+	require.Error(t, err)
+	require.IsType(t, validation.ErrValidation{}, err)
+	require.Contains(t, err.Error(), "policy violation")
+	require.Contains(t, err.Error(), contains)
+
+	// the reason survives serialization
+	serializable, err := validation.NewSerializableError(err)
+	require.NoError(t, err)
+	require.Equal(t, "ErrValidation", serializable.Name)
+}
+
+func TestApplies(t *testing.T) {
+	p := Policy{GUNs: []string{"docker.com/*", "exact"}}
+	require.True(t, p.Applies("docker.com/notary"))
+	require.True(t, p.Applies("docker.com/library/notary"))
+	require.True(t, p.Applies("exact"))
+	require.False(t, p.Applies("exactly"))
+	require.False(t, p.Applies("example.com/notary"))
+}
+
+// Metadata that follows every rule, or that is published to a GUN no policy
+// applies to, is allowed
+func TestCheckAllowed(t *testing.T) {
+	updates := newRepoUpdates(t, "docker.com/notary")
+	strict := Policy{
+		GUNs:                []string{"docker.com/*"},
+		MinThresholds:       map[string]int{data.CanonicalRootRole: 1, data.CanonicalTargetsRole: 1},
+		KeyAlgorithms:       []string{data.ECDSAKey},
+		MaxExpiry:           map[string]time.Duration{data.CanonicalRootRole: 20 * 365 * 24 * time.Hour},
+		RequiredDelegations: []string{"targets/releases"},
+		MaxTargets:          2,
+	}
+
+	require.NoError(t, Policies(nil).Check("docker.com/notary", updates, time.Now()))
+	require.NoError(t, Policies{strict}.Check("docker.com/notary", updates, time.Now()))
+
+	strict.MaxTargets = 1
+	require.NoError(t, Policies{strict}.Check("example.com/notary", updates, time.Now()))
+}
+
+func TestCheckViolations(t *testing.T) {
+	gun := "docker.com/notary"
+	updates := newRepoUpdates(t, gun)
+
+	violations := []struct {
+		policy   Policy
+		contains string
+	}{
+		{Policy{MinThresholds: map[string]int{data.CanonicalRootRole: 2}}, "threshold of root is 1"},
+		// delegations are subject to the limit for the targets role
+		{Policy{MinThresholds: map[string]int{data.CanonicalTargetsRole: 2}}, "threshold of targets"},
+		{Policy{MinThresholds: map[string]int{"targets/releases": 2}}, "threshold of targets/releases is 1"},
+		{Policy{KeyAlgorithms: []string{data.RSAKey, data.ED25519Key}}, "not one of the allowed algorithms"},
+		{Policy{MaxExpiry: map[string]time.Duration{data.CanonicalRootRole: 24 * time.Hour}}, "root expires"},
+		{Policy{MaxExpiry: map[string]time.Duration{data.CanonicalTargetsRole: 24 * time.Hour}}, "targets expires"},
+		{Policy{MaxExpiry: map[string]time.Duration{data.CanonicalTimestampRole: time.Hour}}, "timestamp expires"},
+		{Policy{RequiredDelegations: []string{"targets/other"}}, "targets must delegate to targets/other"},
+		{Policy{MaxTargets: 1}, "targets has 2 targets"},
+	}
+
+	for _, v := range violations {
+		v.policy.GUNs = []string{gun}
+		requireViolation(t, Policies{v.policy}.Check(gun, updates, time.Now()), v.contains)
+	}
+
+	// every policy that applies must be followed
+	policies := Policies{
+		{GUNs: []string{"*"}},
+		{GUNs: []string{"docker.com/*"}, MaxTargets: 1},
+	}
+	requireViolation(t, policies.Check(gun, updates, time.Now()), "targets has 2 targets")
+}
+
+// Only the roles being updated are checked
+func TestCheckOnlyUpdatedRoles(t *testing.T) {
+	gun := "docker.com/notary"
+	updates := newRepoUpdates(t, gun)
+	policies := Policies{{GUNs: []string{gun}, MinThresholds: map[string]int{data.CanonicalRootRole: 2}}}
+
+	require.Error(t, policies.Check(gun, updates, time.Now()))
+	require.NoError(t, policies.Check(gun, updates[1:], time.Now()))
+}
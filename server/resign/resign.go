@@ -0,0 +1,244 @@
+package resign
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/go/canonical/json"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/snapshot"
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/server/timestamp"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+)
+
+// gunsPerPage is the number of GUNs fetched from the store at a time when
+// scanning every GUN
+const gunsPerPage = 100
+
+var (
+	resigned = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "notary_server",
+			Subsystem: "resign",
+			Name:      "resigned_total",
+			Help:      "Number of server-signed metadata files re-signed before they expired.",
+		},
+		[]string{"role"},
+	)
+	failures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "notary_server",
+			Subsystem: "resign",
+			Name:      "failures_total",
+			Help:      "Number of server-signed metadata files that could not be re-signed.",
+		},
+		[]string{"role"},
+	)
+	scanDuration = prometheus.NewSummary(
+		prometheus.SummaryOpts{
+			Namespace: "notary_server",
+			Subsystem: "resign",
+			Name:      "scan_duration_seconds",
+			Help:      "How long a scan of every GUN for metadata to re-sign takes.",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(resigned)
+	prometheus.MustRegister(failures)
+	prometheus.MustRegister(scanDuration)
+}
+
+// Config tells a Worker how often to scan for metadata to re-sign, and how
+// far ahead of its expiry to re-sign it
+type Config struct {
+	// Window is how long before it expires server-signed metadata is re-signed
+	Window time.Duration
+	// Interval is how long to wait between scans
+	Interval time.Duration
+	// Concurrency is the maximum number of GUNs re-signed at the same time
+	Concurrency int
+}
+
+// Valid returns an error if the worker could not run with the configuration
+func (c Config) Valid() error {
+	if c.Window <= 0 {
+		return fmt.Errorf("re-signing window must be positive")
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("re-signing interval must be positive")
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("re-signing concurrency must be positive")
+	}
+	return nil
+}
+
+// Worker re-signs the server-signed timestamps and snapshots that are about to
+// expire in the background, rather than waiting for the first GET after they
+// have expired to do so.  A GUN that can't be re-signed, for instance because
+// the signer is unavailable, is logged and retried on the next scan.
+type Worker struct {
+	store         storage.MetaStore
+	cryptoService signed.CryptoService
+	config        Config
+}
+
+// NewWorker returns a worker that re-signs the metadata in the store with the
+// keys in the crypto service
+func NewWorker(store storage.MetaStore, cryptoService signed.CryptoService, config Config) *Worker {
+	return &Worker{
+		store:         store,
+		cryptoService: cryptoService,
+		config:        config,
+	}
+}
+
+// Run scans every GUN immediately and then once every interval, until the
+// context is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Scan(time.Now()); err != nil {
+			logrus.Errorf("failed to scan for metadata to re-sign: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan re-signs the server-signed metadata of every GUN that expires within
+// the window after now.  Failing to re-sign a GUN does not stop the scan, so
+// an error is only returned if the GUNs could not be listed.
+func (w *Worker) Scan(now time.Time) error {
+	start := time.Now()
+	defer func() { scanDuration.Observe(time.Since(start).Seconds()) }()
+
+	concurrency := w.config.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	guns := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for gun := range guns {
+				w.Resign(gun, now)
+			}
+		}()
+	}
+	defer func() {
+		close(guns)
+		wg.Wait()
+	}()
+
+	last := ""
+	for {
+		page, err := w.store.GetGUNs("", last, gunsPerPage)
+		if err != nil {
+			return err
+		}
+		for _, gun := range page {
+			guns <- gun
+		}
+		if len(page) < gunsPerPage {
+			return nil
+		}
+		last = page[len(page)-1]
+	}
+}
+
+// Resign re-signs the snapshot of a GUN, if the server signs it, and its
+// timestamp if they expire within the window after now.  The timestamp is
+// also re-signed if the snapshot was.  It returns the roles that were
+// re-signed.
+func (w *Worker) Resign(gun string, now time.Time) []string {
+	expiresBefore := now.Add(w.config.Window)
+	var roles []string
+
+	signsSnapshot, err := w.signsSnapshot(gun)
+	if err != nil {
+		w.failed(gun, data.CanonicalSnapshotRole, err)
+		return roles
+	}
+	if signsSnapshot {
+		ok, err := snapshot.RefreshSnapshot(gun, w.store, w.cryptoService, expiresBefore)
+		if err != nil {
+			w.failed(gun, data.CanonicalSnapshotRole, err)
+			return roles
+		}
+		if ok {
+			resigned.WithLabelValues(data.CanonicalSnapshotRole).Inc()
+			roles = append(roles, data.CanonicalSnapshotRole)
+		}
+	}
+
+	ok, err := timestamp.RefreshTimestamp(gun, w.store, w.cryptoService, expiresBefore)
+	if err != nil {
+		w.failed(gun, data.CanonicalTimestampRole, err)
+		return roles
+	}
+	if ok {
+		resigned.WithLabelValues(data.CanonicalTimestampRole).Inc()
+		roles = append(roles, data.CanonicalTimestampRole)
+	}
+	if len(roles) > 0 {
+		logrus.Debugf("re-signed %v for %s", roles, gun)
+	}
+	return roles
+}
+
+// failed logs and counts a failure to re-sign a role, unless it was because
+// the GUN has no metadata for the role, or another server re-signed it first
+func (w *Worker) failed(gun, role string, err error) {
+	switch err.(type) {
+	case storage.ErrNotFound, *storage.ErrOldVersion:
+		return
+	}
+	failures.WithLabelValues(role).Inc()
+	logrus.Errorf("failed to re-sign %s for %s: %v", role, gun, err)
+}
+
+// signsSnapshot returns whether the root of a GUN trusts any of the snapshot
+// keys the server has held for it, in which case it is the server's job to
+// re-sign the snapshot
+func (w *Worker) signsSnapshot(gun string) (bool, error) {
+	keys, err := w.store.GetKeys(gun, data.CanonicalSnapshotRole)
+	if err != nil || len(keys) == 0 {
+		return false, err
+	}
+
+	_, rootJSON, err := w.store.GetCurrent(gun, data.CanonicalRootRole)
+	if err != nil {
+		return false, err
+	}
+	root := &data.SignedRoot{}
+	if err := json.Unmarshal(rootJSON, root); err != nil {
+		return false, err
+	}
+	role, ok := root.Signed.Roles[data.CanonicalSnapshotRole]
+	if !ok {
+		return false, nil
+	}
+	for _, key := range keys {
+		for _, id := range role.KeyIDs {
+			if id == key.ID() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
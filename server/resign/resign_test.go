@@ -0,0 +1,207 @@
+package resign
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
+	"github.com/docker/notary/tuf/testutils"
+)
+
+var testConfig = Config{Window: 6 * time.Hour, Interval: time.Hour, Concurrency: 2}
+
+// addGUN stores the metadata of a new GUN, whose snapshot and timestamp expire
+// at the given times.  The server is given the GUN's timestamp key, and its
+// snapshot key too if serverSnapshot is true.
+func addGUN(t *testing.T, store storage.MetaStore, server *signed.Ed25519, gun string,
+	serverSnapshot bool, snapshotExpires, timestampExpires time.Time) {
+
+	repo, cs, err := testutils.EmptyRepo(gun)
+	require.NoError(t, err)
+
+	r, err := repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole))
+	require.NoError(t, err)
+	tg, err := repo.SignTargets(data.CanonicalTargetsRole, data.DefaultExpires(data.CanonicalTargetsRole))
+	require.NoError(t, err)
+	sn, err := repo.SignSnapshot(snapshotExpires)
+	require.NoError(t, err)
+	ts, err := repo.SignTimestamp(timestampExpires)
+	require.NoError(t, err)
+	rs, tgs, sns, tss, err := testutils.Serialize(r, tg, sn, ts)
+	require.NoError(t, err)
+	require.NoError(t, store.UpdateMany(gun, []storage.MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: rs},
+		{Role: data.CanonicalTargetsRole, Version: 1, Data: tgs},
+		{Role: data.CanonicalSnapshotRole, Version: 1, Data: sns},
+		{Role: data.CanonicalTimestampRole, Version: 1, Data: tss},
+	}))
+
+	roles := []string{data.CanonicalTimestampRole}
+	if serverSnapshot {
+		roles = append(roles, data.CanonicalSnapshotRole)
+	}
+	for _, role := range roles {
+		keyID := repo.Root.Signed.Roles[role].KeyIDs[0]
+		public := repo.Root.Signed.Keys[keyID]
+		require.NoError(t, store.SetKey(gun, role, public.Algorithm(), public.Public()))
+		private, _, err := cs.GetPrivateKey(keyID)
+		require.NoError(t, err)
+		require.NoError(t, server.AddKey(role, gun, private))
+	}
+}
+
+// returns the version and expiry of the current metadata for a role
+func current(t *testing.T, store storage.MetaStore, gun, role string) (int, time.Time) {
+	_, raw, err := store.GetCurrent(gun, role)
+	require.NoError(t, err)
+	meta := &data.SignedMeta{}
+	require.NoError(t, json.Unmarshal(raw, meta))
+	return meta.Signed.Version, meta.Signed.Expires
+}
+
+func TestConfigValid(t *testing.T) {
+	require.NoError(t, testConfig.Valid())
+
+	invalid := []Config{
+		{Interval: time.Hour, Concurrency: 1},
+		{Window: -time.Hour, Interval: time.Hour, Concurrency: 1},
+		{Window: time.Hour, Concurrency: 1},
+		{Window: time.Hour, Interval: time.Hour},
+	}
+	for _, c := range invalid {
+		require.Error(t, c.Valid(), "%v", c)
+	}
+}
+
+// A snapshot the server signs and a timestamp that expire within the window
+// are re-signed, and the new timestamp references the new snapshot
+func TestResign(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	soon := time.Now().Add(time.Hour)
+	addGUN(t, store, server, "gun", true, soon, soon)
+
+	w := NewWorker(store, server, testConfig)
+	require.Equal(t, []string{data.CanonicalSnapshotRole, data.CanonicalTimestampRole}, w.Resign("gun", time.Now()))
+
+	for _, role := range []string{data.CanonicalSnapshotRole, data.CanonicalTimestampRole} {
+		version, expires := current(t, store, "gun", role)
+		require.Equal(t, 2, version)
+		require.True(t, expires.After(time.Now().Add(testConfig.Window)))
+	}
+	_, snapshotJSON, err := store.GetCurrent("gun", data.CanonicalSnapshotRole)
+	require.NoError(t, err)
+	_, timestampJSON, err := store.GetCurrent("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	ts := &data.SignedTimestamp{}
+	require.NoError(t, json.Unmarshal(timestampJSON, ts))
+	require.NoError(t, data.CheckHashes(snapshotJSON, ts.Signed.Meta[data.CanonicalSnapshotRole].Hashes))
+
+	// now that they are fresh, there is nothing more to do
+	require.Empty(t, w.Resign("gun", time.Now()))
+}
+
+// Metadata that doesn't expire within the window is left alone
+func TestResignNotExpiring(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	later := time.Now().Add(2 * testConfig.Window)
+	addGUN(t, store, server, "gun", true, later, later)
+
+	require.Empty(t, NewWorker(store, server, testConfig).Resign("gun", time.Now()))
+	version, _ := current(t, store, "gun", data.CanonicalTimestampRole)
+	require.Equal(t, 1, version)
+}
+
+// A snapshot the server doesn't sign is never re-signed, even if it is about
+// to expire
+func TestResignClientSignedSnapshot(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	soon := time.Now().Add(time.Hour)
+	addGUN(t, store, server, "gun", false, soon, soon)
+
+	require.Equal(t, []string{data.CanonicalTimestampRole},
+		NewWorker(store, server, testConfig).Resign("gun", time.Now()))
+	version, _ := current(t, store, "gun", data.CanonicalSnapshotRole)
+	require.Equal(t, 1, version)
+}
+
+// Until the root is updated, the snapshot is still re-signed with the key the
+// root trusts after the server's snapshot key has been rotated
+func TestResignRotatedSnapshotKey(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	soon := time.Now().Add(time.Hour)
+	addGUN(t, store, server, "gun", true, soon, soon)
+
+	rotated, err := server.Create(data.CanonicalSnapshotRole, "gun", data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, store.RotateKey("gun", data.CanonicalSnapshotRole, rotated.Algorithm(), rotated.Public()))
+
+	require.Equal(t, []string{data.CanonicalSnapshotRole, data.CanonicalTimestampRole},
+		NewWorker(store, server, testConfig).Resign("gun", time.Now()))
+}
+
+// If the signer can't sign, the metadata is left as it was
+func TestResignSignerFailure(t *testing.T) {
+	store := storage.NewMemStorage()
+	soon := time.Now().Add(time.Hour)
+	addGUN(t, store, signed.NewEd25519(), "gun", false, soon, soon)
+
+	// this signer doesn't have the timestamp key
+	require.Empty(t, NewWorker(store, signed.NewEd25519(), testConfig).Resign("gun", time.Now()))
+	version, _ := current(t, store, "gun", data.CanonicalTimestampRole)
+	require.Equal(t, 1, version)
+}
+
+// A scan re-signs every GUN that needs it, even if some of them fail
+func TestScan(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	soon := time.Now().Add(time.Hour)
+	for i := 0; i < 5; i++ {
+		addGUN(t, store, server, fmt.Sprintf("gun%d", i), true, soon, soon)
+	}
+	// the server doesn't have the keys for this one
+	addGUN(t, store, signed.NewEd25519(), "failing", true, soon, soon)
+
+	require.NoError(t, NewWorker(store, server, testConfig).Scan(time.Now()))
+	for i := 0; i < 5; i++ {
+		version, _ := current(t, store, fmt.Sprintf("gun%d", i), data.CanonicalTimestampRole)
+		require.Equal(t, 2, version)
+	}
+	version, _ := current(t, store, "failing", data.CanonicalTimestampRole)
+	require.Equal(t, 1, version)
+}
+
+// Run scans straight away, and stops once the context is cancelled
+func TestRun(t *testing.T) {
+	store := storage.NewMemStorage()
+	server := signed.NewEd25519()
+	soon := time.Now().Add(time.Hour)
+	addGUN(t, store, server, "gun", true, soon, soon)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		NewWorker(store, server, testConfig).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+	version, _ := current(t, store, "gun", data.CanonicalTimestampRole)
+	require.Equal(t, 2, version)
+}
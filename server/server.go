@@ -10,7 +10,9 @@ import (
 	"github.com/docker/distribution/health"
 	"github.com/docker/distribution/registry/auth"
 	"github.com/docker/notary"
+	"github.com/docker/notary/server/auth/mtls"
 	"github.com/docker/notary/server/handlers"
+	"github.com/docker/notary/server/resign"
 	"github.com/docker/notary/tuf/data"
 	"github.com/docker/notary/tuf/signed"
 	"github.com/docker/notary/utils"
@@ -40,6 +42,8 @@ type Config struct {
 	AuthOpts                     interface{}
 	ConsistentCacheControlConfig utils.CacheControlConfig
 	CurrentCacheControlConfig    utils.CacheControlConfig
+	// Resigner, if set, re-signs server-signed metadata before it expires
+	Resigner *resign.Worker
 }
 
 // Run sets up and starts a TLS server that can be cancelled using the
@@ -62,7 +66,7 @@ func Run(ctx context.Context, conf Config) error {
 	}
 
 	var ac auth.AccessController
-	if conf.AuthMethod == "token" {
+	if conf.AuthMethod == "token" || conf.AuthMethod == mtls.Name {
 		authOptions, ok := conf.AuthOpts.(map[string]interface{})
 		if !ok {
 			return fmt.Errorf("auth.options must be a map[string]interface{}")
@@ -73,6 +77,11 @@ func Run(ctx context.Context, conf Config) error {
 		}
 	}
 
+	if conf.Resigner != nil {
+		logrus.Info("Starting re-signing worker")
+		go conf.Resigner.Run(ctx)
+	}
+
 	svr := http.Server{
 		Addr:    conf.Addr,
 		Handler: RootHandler(ac, ctx, conf.Trust, conf.ConsistentCacheControlConfig, conf.CurrentCacheControlConfig),
@@ -94,6 +103,14 @@ func RootHandler(ac auth.AccessController, ctx context.Context, trust signed.Cry
 
 	r := mux.NewRouter()
 	r.Methods("GET").Path("/v2/").Handler(hand(handlers.MainHandler))
+	r.Methods("GET").Path("/v2/_trust/changefeed").Handler(
+		prometheus.InstrumentHandlerWithOpts(
+			prometheusOpts("Changefeed"),
+			hand(handlers.Changefeed(ac))))
+	r.Methods("GET").Path("/v2/_trust/catalog").Handler(
+		prometheus.InstrumentHandlerWithOpts(
+			prometheusOpts("Catalog"),
+			hand(handlers.Catalog(ac))))
 	r.Methods("POST").Path("/v2/{imageName:.*}/_trust/tuf/").Handler(
 		prometheus.InstrumentHandlerWithOpts(
 			prometheusOpts("UpdateTuf"),
@@ -102,6 +119,10 @@ func RootHandler(ac auth.AccessController, ctx context.Context, trust signed.Cry
 		prometheus.InstrumentHandlerWithOpts(
 			prometheusOpts("GetRoleByHash"),
 			utils.WrapWithCacheHandler(consistent, hand(handlers.GetHandler, "pull"))))
+	r.Methods("GET").Path("/v2/{imageName:.*}/_trust/tuf/{tufRole:root|targets(?:/[^/\\s]+)*}.{version:[1-9][0-9]*}.json").Handler(
+		prometheus.InstrumentHandlerWithOpts(
+			prometheusOpts("GetRoleByVersion"),
+			utils.WrapWithCacheHandler(consistent, hand(handlers.GetHandler, "pull"))))
 	r.Methods("GET").Path("/v2/{imageName:.*}/_trust/tuf/{tufRole:root|targets(?:/[^/\\s]+)*|snapshot|timestamp}.json").Handler(
 		prometheus.InstrumentHandlerWithOpts(
 			prometheusOpts("GetRole"),
@@ -111,10 +132,15 @@ func RootHandler(ac auth.AccessController, ctx context.Context, trust signed.Cry
 		prometheus.InstrumentHandlerWithOpts(
 			prometheusOpts("GetKey"),
 			hand(handlers.GetKeyHandler, "push", "pull")))
+	r.Methods("POST").Path(
+		"/v2/{imageName:.*}/_trust/tuf/{tufRole:snapshot|timestamp}.key").Handler(
+		prometheus.InstrumentHandlerWithOpts(
+			prometheusOpts("RotateKey"),
+			hand(handlers.RotateKeyHandler, "*")))
 	r.Methods("DELETE").Path("/v2/{imageName:.*}/_trust/tuf/").Handler(
 		prometheus.InstrumentHandlerWithOpts(
 			prometheusOpts("DeleteTuf"),
-			hand(handlers.DeleteHandler, "push", "pull")))
+			hand(handlers.DeleteHandler, "*")))
 
 	r.Methods("GET").Path("/_notary_server/health").HandlerFunc(health.StatusHandler)
 	r.Methods("GET").Path("/metrics").Handler(prometheus.Handler())
@@ -2,18 +2,30 @@ package server
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/docker/distribution/registry/auth"
 	_ "github.com/docker/distribution/registry/auth/silly"
+	"github.com/docker/distribution/registry/auth/token"
+	"github.com/docker/libtrust"
+	"github.com/docker/notary/server/auth/mtls"
 	"github.com/docker/notary/server/storage"
 	"github.com/docker/notary/tuf/data"
 	"github.com/docker/notary/tuf/signed"
@@ -163,6 +175,69 @@ func TestGetRoleByHash(t *testing.T) {
 // This just checks the URL routing is working correctly and cache headers are set correctly.
 // More detailed tests for this path including negative
 // tests are located in /server/handlers/
+func TestGetRootByVersion(t *testing.T) {
+	store := storage.NewMemStorage()
+
+	var roots [][]byte
+	for version := 1; version <= 2; version++ {
+		root := data.SignedRoot{
+			Signatures: make([]data.Signature, 0),
+			Signed: data.Root{
+				SignedCommon: data.SignedCommon{
+					Type:    data.TUFTypes[data.CanonicalRootRole],
+					Version: version,
+					Expires: data.DefaultExpires(data.CanonicalRootRole),
+				},
+			},
+		}
+		j, err := json.Marshal(&root)
+		require.NoError(t, err)
+		require.NoError(t, store.UpdateCurrent("gun", storage.MetaUpdate{
+			Role:    data.CanonicalRootRole,
+			Version: version,
+			Data:    j,
+		}))
+		roots = append(roots, j)
+	}
+
+	ctx := context.WithValue(
+		context.Background(), "metaStore", store)
+
+	ctx = context.WithValue(ctx, "keyAlgorithm", data.ED25519Key)
+
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(nil, ctx, signed.NewEd25519(), ccc, ccc)
+	serv := httptest.NewServer(handler)
+	defer serv.Close()
+
+	for i, j := range roots {
+		res, err := http.Get(fmt.Sprintf("%s/v2/gun/_trust/tuf/root.%d.json", serv.URL, i+1))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		verifyGetResponse(t, res, j)
+	}
+
+	// so can targets and delegation roles
+	delegation := []byte(`{"signed": {}}`)
+	require.NoError(t, store.UpdateCurrent("gun", storage.MetaUpdate{
+		Role:    "targets/releases",
+		Version: 1,
+		Data:    delegation,
+	}))
+	res, err := http.Get(fmt.Sprintf("%s/v2/gun/_trust/tuf/targets/releases.1.json", serv.URL))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	verifyGetResponse(t, res, delegation)
+
+	// versions that don't exist, and the snapshot and timestamp, can't be
+	// requested by version
+	for _, path := range []string{"root.3.json", "root.0.json", "targets.1.json", "snapshot.1.json", "timestamp.1.json"} {
+		res, err := http.Get(fmt.Sprintf("%s/v2/gun/_trust/tuf/%s", serv.URL, path))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	}
+}
+
 func TestGetCurrentRole(t *testing.T) {
 	store := storage.NewMemStorage()
 	metadata, _, err := testutils.NewRepoMetadata("gun")
@@ -202,6 +277,299 @@ func TestGetCurrentRole(t *testing.T) {
 	verifyGetResponse(t, res, metadata[data.CanonicalTimestampRole])
 }
 
+func TestChangefeedEndpoint(t *testing.T) {
+	store := storage.NewMemStorage()
+	require.NoError(t, store.UpdateMany("gun", []storage.MetaUpdate{
+		{Role: data.CanonicalTimestampRole, Version: 1, Data: []byte("timestamp")},
+	}))
+
+	ctx := context.WithValue(
+		context.Background(), "metaStore", store)
+
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(nil, ctx, signed.NewEd25519(), ccc, ccc)
+	serv := httptest.NewServer(handler)
+	defer serv.Close()
+
+	res, err := http.Get(serv.URL + "/v2/_trust/changefeed")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var feed struct {
+		Count   int              `json:"count"`
+		Records []storage.Change `json:"records"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&feed))
+	require.Equal(t, 1, feed.Count)
+	require.Len(t, feed.Records, 1)
+	require.Equal(t, "gun", feed.Records[0].GUN)
+	require.Equal(t, 1, feed.Records[0].Version)
+	require.Equal(t, storage.ChangeCategoryUpdate, feed.Records[0].Category)
+
+	res, err = http.Get(serv.URL + "/v2/_trust/changefeed?records=0")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestCatalogEndpoint(t *testing.T) {
+	store := storage.NewMemStorage()
+	for _, gun := range []string{"gun2", "gun1"} {
+		require.NoError(t, store.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalRootRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+
+	ctx := context.WithValue(
+		context.Background(), "metaStore", store)
+
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(nil, ctx, signed.NewEd25519(), ccc, ccc)
+	serv := httptest.NewServer(handler)
+	defer serv.Close()
+
+	res, err := http.Get(serv.URL + "/v2/_trust/catalog")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var catalog struct {
+		Count        int      `json:"count"`
+		Repositories []string `json:"repositories"`
+	}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&catalog))
+	require.Equal(t, 2, catalog.Count)
+	require.Equal(t, []string{"gun1", "gun2"}, catalog.Repositories)
+}
+
+// With mtls auth, requests are authorized by the client certificate they were
+// made with, and deleting a GUN requires the delete action
+func TestMTLSAuth(t *testing.T) {
+	ac, err := auth.GetAccessController(mtls.Name, map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{
+				"identity": "ci",
+				"guns":     []interface{}{"gun"},
+				"actions":  []interface{}{"push", "pull"},
+			},
+			map[string]interface{}{
+				"identity": "admin",
+				"guns":     []interface{}{"*"},
+				"actions":  []interface{}{"push", "pull", "delete"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.WithValue(
+		context.Background(), "metaStore", storage.NewMemStorage())
+	ctx = context.WithValue(ctx, "keyAlgorithm", data.ED25519Key)
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(ac, ctx, signed.NewEd25519(), ccc, ccc)
+
+	serve := func(method, path, cn string) int {
+		req, err := http.NewRequest(method, path, &bytes.Buffer{})
+		require.NoError(t, err)
+		if cn != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, serve("GET", "/v2/gun/_trust/tuf/snapshot.key", ""))
+	require.Equal(t, http.StatusUnauthorized, serve("GET", "/v2/other/_trust/tuf/snapshot.key", "ci"))
+	require.Equal(t, http.StatusOK, serve("GET", "/v2/gun/_trust/tuf/snapshot.key", "ci"))
+
+	require.Equal(t, http.StatusUnauthorized, serve("DELETE", "/v2/gun/_trust/tuf/", "ci"))
+	require.Equal(t, http.StatusOK, serve("DELETE", "/v2/gun/_trust/tuf/", "admin"))
+}
+
+// tokenAccessController returns a token access controller, and a function that
+// returns the Authorization header for a token it accepts that grants the given
+// access
+func tokenAccessController(t *testing.T) (auth.AccessController, func(...*token.ResourceActions) string) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	require.NoError(t, err)
+	cert, err := libtrust.GenerateSelfSignedServerCert(key, []string{"localhost"}, nil)
+	require.NoError(t, err)
+	bundle, err := ioutil.TempFile("", "notary-test-token-")
+	require.NoError(t, err)
+	defer os.Remove(bundle.Name())
+	require.NoError(t, pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	require.NoError(t, bundle.Close())
+
+	ac, err := auth.GetAccessController("token", map[string]interface{}{
+		"realm":          "https://auth.example.com/token",
+		"issuer":         "auth.example.com",
+		"service":        "notary.example.com",
+		"rootcertbundle": bundle.Name(),
+	})
+	require.NoError(t, err)
+
+	authHeader := func(access ...*token.ResourceActions) string {
+		now := time.Now()
+		header, err := json.Marshal(token.Header{Type: "JWT", SigningAlg: "ES256", KeyID: key.KeyID()})
+		require.NoError(t, err)
+		claims, err := json.Marshal(token.ClaimSet{
+			Issuer:     "auth.example.com",
+			Subject:    "user",
+			Audience:   "notary.example.com",
+			NotBefore:  now.Add(-time.Minute).Unix(),
+			IssuedAt:   now.Unix(),
+			Expiration: now.Add(time.Minute).Unix(),
+			Access:     access,
+		})
+		require.NoError(t, err)
+		payload := base64.RawURLEncoding.EncodeToString(header) + "." +
+			base64.RawURLEncoding.EncodeToString(claims)
+		sig, _, err := key.Sign(strings.NewReader(payload), crypto.SHA256)
+		require.NoError(t, err)
+		return "Bearer " + payload + "." + base64.RawURLEncoding.EncodeToString(sig)
+	}
+	return ac, authHeader
+}
+
+// With token auth, deleting a GUN requires full access to it - push and pull
+// access is not enough
+func TestTokenAuthDelete(t *testing.T) {
+	ac, authHeader := tokenAccessController(t)
+
+	ctx := context.WithValue(
+		context.Background(), "metaStore", storage.NewMemStorage())
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(ac, ctx, signed.NewEd25519(), ccc, ccc)
+
+	deleteWith := func(header string) int {
+		req, err := http.NewRequest("DELETE", "/v2/gun/_trust/tuf/", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, deleteWith(""))
+	require.Equal(t, http.StatusUnauthorized, deleteWith(authHeader(
+		&token.ResourceActions{Type: "repository", Name: "gun", Actions: []string{"push", "pull"}})))
+	require.Equal(t, http.StatusUnauthorized, deleteWith(authHeader(
+		&token.ResourceActions{Type: "repository", Name: "other", Actions: []string{"*"}})))
+	require.Equal(t, http.StatusOK, deleteWith(authHeader(
+		&token.ResourceActions{Type: "repository", Name: "gun", Actions: []string{"*"}})))
+}
+
+// With token auth, a token for the catalog lists every GUN, and otherwise only
+// the GUNs the token grants pull access to are listed
+func TestCatalogTokenAuth(t *testing.T) {
+	ac, authHeader := tokenAccessController(t)
+
+	store := storage.NewMemStorage()
+	for _, gun := range []string{"gun1", "gun2"} {
+		require.NoError(t, store.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalTimestampRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+	ctx := context.WithValue(context.Background(), "metaStore", store)
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(ac, ctx, signed.NewEd25519(), ccc, ccc)
+
+	listWith := func(header string) (int, []string) {
+		req, err := http.NewRequest("GET", "/v2/_trust/catalog", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.Code != http.StatusOK {
+			return rw.Code, nil
+		}
+		var catalog struct {
+			Repositories []string `json:"repositories"`
+		}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&catalog))
+		return rw.Code, catalog.Repositories
+	}
+
+	code, _ := listWith("")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, guns := listWith(authHeader(
+		&token.ResourceActions{Type: "registry", Name: "catalog", Actions: []string{"*"}}))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"gun1", "gun2"}, guns)
+
+	code, guns = listWith(authHeader(
+		&token.ResourceActions{Type: "repository", Name: "gun2", Actions: []string{"pull"}}))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"gun2"}, guns)
+
+	code, guns = listWith(authHeader())
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, guns, 0)
+}
+
+// With token auth, a token for the catalog reads every change in the
+// changefeed, and otherwise only changes to the GUNs the token grants pull
+// access to are read
+func TestChangefeedTokenAuth(t *testing.T) {
+	ac, authHeader := tokenAccessController(t)
+
+	store := storage.NewMemStorage()
+	for _, gun := range []string{"gun1", "gun2"} {
+		require.NoError(t, store.UpdateMany(gun, []storage.MetaUpdate{
+			{Role: data.CanonicalTimestampRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+	ctx := context.WithValue(context.Background(), "metaStore", store)
+	ccc := utils.NewCacheControlConfig(10, false)
+	handler := RootHandler(ac, ctx, signed.NewEd25519(), ccc, ccc)
+
+	readWith := func(header string) (int, []string) {
+		req, err := http.NewRequest("GET", "/v2/_trust/changefeed", nil)
+		require.NoError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.Code != http.StatusOK {
+			return rw.Code, nil
+		}
+		var feed struct {
+			Records []storage.Change `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(rw.Body).Decode(&feed))
+		guns := []string{}
+		for _, change := range feed.Records {
+			guns = append(guns, change.GUN)
+		}
+		return rw.Code, guns
+	}
+
+	code, _ := readWith("")
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, guns := readWith(authHeader(
+		&token.ResourceActions{Type: "registry", Name: "catalog", Actions: []string{"*"}}))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"gun1", "gun2"}, guns)
+
+	code, guns = readWith(authHeader(
+		&token.ResourceActions{Type: "repository", Name: "gun2", Actions: []string{"pull"}}))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"gun2"}, guns)
+
+	code, guns = readWith(authHeader())
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, guns, 0)
+}
+
 // Verifies that the body is as expected  and that there are cache control headers
 func verifyGetResponse(t *testing.T, r *http.Response, expectedBytes []byte) {
 	body, err := ioutil.ReadAll(r.Body)
@@ -44,25 +44,60 @@ func GetOrCreateSnapshotKey(gun string, store storage.KeyStore, crypto signed.Cr
 	return nil, err
 }
 
+// RotateSnapshotKey creates a new snapshot key, which replaces the current one
+// as the key returned by GetOrCreateSnapshotKey.  Only the PublicKey is
+// returned.  The previous keys are not deleted, so that the snapshot can still
+// be signed with whichever key the current root trusts until the root is
+// updated with the new key.
+func RotateSnapshotKey(gun string, store storage.KeyStore, crypto signed.CryptoService, createAlgorithm string) (data.PublicKey, error) {
+	key, err := crypto.Create(data.CanonicalSnapshotRole, gun, createAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debug("Rotating snapshot key for ", gun, ". With algo: ", key.Algorithm())
+	if err := store.RotateKey(gun, data.CanonicalSnapshotRole, key.Algorithm(), key.Public()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // GetOrCreateSnapshot either returns the exisiting latest snapshot, or uses
 // whatever the most recent snapshot is to create the next one, only updating
 // the expiry time and version.
 func GetOrCreateSnapshot(gun string, store storage.MetaStore, cryptoService signed.CryptoService) (
 	*time.Time, []byte, error) {
 
+	lastModified, currentJSON, _, err := getOrCreateSnapshot(gun, store, cryptoService, time.Now())
+	return lastModified, currentJSON, err
+}
+
+// RefreshSnapshot re-signs the latest snapshot, only updating the expiry time
+// and version, if it expires before the given time.  It returns whether the
+// snapshot was re-signed.  It should only be used for GUNs whose snapshot key
+// is managed by the server.
+func RefreshSnapshot(gun string, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiresBefore time.Time) (bool, error) {
+
+	_, _, resigned, err := getOrCreateSnapshot(gun, store, cryptoService, expiresBefore)
+	return resigned, err
+}
+
+func getOrCreateSnapshot(gun string, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiresBefore time.Time) (*time.Time, []byte, bool, error) {
+
 	lastModified, currentJSON, err := store.GetCurrent(gun, data.CanonicalSnapshotRole)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	prev := new(data.SignedSnapshot)
 	if err := json.Unmarshal(currentJSON, prev); err != nil {
 		logrus.Error("Failed to unmarshal existing snapshot for GUN ", gun)
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	if !snapshotExpired(prev) {
-		return lastModified, currentJSON, nil
+	if !prev.Signed.Expires.Before(expiresBefore) {
+		return lastModified, currentJSON, false, nil
 	}
 
 	repo := tuf.NewRepo(cryptoService)
@@ -71,32 +106,27 @@ func GetOrCreateSnapshot(gun string, store storage.MetaStore, cryptoService sign
 	_, rootJSON, err := store.GetCurrent(gun, data.CanonicalRootRole)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	root := &data.SignedRoot{}
 	if err := json.Unmarshal(rootJSON, root); err != nil {
 		logrus.Error("Failed to unmarshal existing root for GUN ", gun)
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	repo.SetRoot(root)
 
 	snapshotUpdate, err := NewSnapshotUpdate(prev, repo)
 	if err != nil {
 		logrus.Error("Failed to create a new snapshot")
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	c := time.Now()
 	if err = store.UpdateCurrent(gun, *snapshotUpdate); err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	return &c, snapshotUpdate.Data, nil
-}
-
-// snapshotExpired simply checks if the snapshot is past its expiry time
-func snapshotExpired(sn *data.SignedSnapshot) bool {
-	return signed.IsExpired(sn.Signed.Expires)
+	return &c, snapshotUpdate.Data, true, nil
 }
 
 // NewSnapshotUpdate produces a new snapshot and returns it as a metadata update, given the
@@ -13,24 +13,39 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestSnapshotExpired(t *testing.T) {
-	sn := &data.SignedSnapshot{
-		Signatures: nil,
-		Signed: data.Snapshot{
-			SignedCommon: data.SignedCommon{Expires: time.Now().AddDate(-1, 0, 0)},
-		},
-	}
-	require.True(t, snapshotExpired(sn), "Snapshot should have expired")
-}
+// The snapshot is only re-signed if it expires before the end of the window
+func TestRefreshSnapshotWindow(t *testing.T) {
+	store := storage.NewMemStorage()
+	repo, crypto, err := testutils.EmptyRepo("gun")
+	require.NoError(t, err)
 
-func TestSnapshotNotExpired(t *testing.T) {
-	sn := &data.SignedSnapshot{
-		Signatures: nil,
-		Signed: data.Snapshot{
-			SignedCommon: data.SignedCommon{Expires: time.Now().AddDate(1, 0, 0)},
-		},
-	}
-	require.False(t, snapshotExpired(sn), "Snapshot should NOT have expired")
+	rootJSON, err := json.Marshal(repo.Root)
+	require.NoError(t, err)
+	_, err = repo.SignSnapshot(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	snapshotJSON, err := json.Marshal(repo.Snapshot)
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateCurrent("gun",
+		storage.MetaUpdate{Role: data.CanonicalRootRole, Version: 0, Data: rootJSON}))
+	require.NoError(t, store.UpdateCurrent("gun",
+		storage.MetaUpdate{Role: data.CanonicalSnapshotRole, Version: 1, Data: snapshotJSON}))
+
+	resigned, err := RefreshSnapshot("gun", store, crypto, time.Now().Add(30*time.Minute))
+	require.NoError(t, err)
+	require.False(t, resigned)
+	_, gottenSnapshot, err := store.GetCurrent("gun", data.CanonicalSnapshotRole)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(snapshotJSON, gottenSnapshot))
+
+	resigned, err = RefreshSnapshot("gun", store, crypto, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.True(t, resigned)
+	_, gottenSnapshot, err = store.GetCurrent("gun", data.CanonicalSnapshotRole)
+	require.NoError(t, err)
+	signedMeta := &data.SignedMeta{}
+	require.NoError(t, json.Unmarshal(gottenSnapshot, signedMeta))
+	require.True(t, signedMeta.Signed.Expires.After(time.Now().Add(2*time.Hour)))
 }
 
 func TestGetSnapshotKeyCreate(t *testing.T) {
@@ -89,6 +104,14 @@ func (ks keyStore) SetKey(gun, role, algorithm string, public []byte) error {
 	return &storage.ErrKeyExists{}
 }
 
+func (ks keyStore) RotateKey(gun, role, algorithm string, public []byte) error {
+	return nil
+}
+
+func (ks keyStore) GetKeys(gun, role string) ([]data.PublicKey, error) {
+	return []data.PublicKey{ks.k}, nil
+}
+
 // Tests the race condition where the server is being asked to generate a new key
 // by 2 parallel requests and the second insert to be executed by the DB fails
 // due to duplicate key (gun + role). It should then return the key added by the
@@ -4,11 +4,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/notary/tuf/data"
 	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
+	"github.com/mattn/go-sqlite3"
 )
 
 // SQLStorage implements a versioned store using a relational database.
@@ -23,11 +26,39 @@ func NewSQLStorage(dialect string, args ...interface{}) (*SQLStorage, error) {
 	if err != nil {
 		return nil, err
 	}
+	if dialect == "sqlite3" {
+		// SQLite only allows one writer at a time, so share a single
+		// connection rather than failing when the database is locked
+		gormDB.DB().SetMaxOpenConns(1)
+	}
 	return &SQLStorage{
 		DB: gormDB,
 	}, nil
 }
 
+// CreateTables creates any of the tables SQLStorage needs that don't already
+// exist.  This is intended for databases like SQLite that are not set up with
+// the migrations.
+func (db *SQLStorage) CreateTables() error {
+	tables := []struct {
+		model  interface{}
+		create func(gorm.DB) error
+	}{
+		{&TUFFile{}, CreateTUFTable},
+		{&Key{}, CreateKeyTable},
+		{&Change{}, CreateChangefeedTable},
+	}
+	for _, table := range tables {
+		if db.HasTable(table.model) {
+			continue
+		}
+		if err := table.create(db.DB); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // translateOldVersionError captures DB errors, and attempts to translate
 // duplicate entry - currently only supports MySQL and Sqlite3
 func translateOldVersionError(err error) error {
@@ -39,6 +70,10 @@ func translateOldVersionError(err error) error {
 		if err.Number == 1022 || err.Number == 1062 {
 			return &ErrOldVersion{}
 		}
+	case sqlite3.Error:
+		if err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return &ErrOldVersion{}
+		}
 	}
 	return err
 }
@@ -66,6 +101,22 @@ func (db *SQLStorage) UpdateCurrent(gun string, update MetaUpdate) error {
 
 // UpdateMany atomically updates many TUF records in a single transaction
 func (db *SQLStorage) UpdateMany(gun string, updates []MetaUpdate) error {
+	for _, update := range updates {
+		// This looks like the same logic as UpdateCurrent, but if we just
+		// called, version ordering in the updates list must be enforced
+		// (you cannot insert the version 2 before version 1).  And we do
+		// not care about monotonic ordering in the updates.  This is
+		// checked before the transaction starts, so that it only sees the
+		// existing versions, and so that it doesn't need a second connection
+		// when the database only allows one (as we do with SQLite).
+		query := db.Where("gun = ? and role = ? and version >= ?",
+			gun, update.Role, update.Version).First(&TUFFile{})
+
+		if !query.RecordNotFound() {
+			return &ErrOldVersion{}
+		}
+	}
+
 	tx := db.Begin()
 	if tx.Error != nil {
 		return tx.Error
@@ -84,17 +135,6 @@ func (db *SQLStorage) UpdateMany(gun string, updates []MetaUpdate) error {
 		added = make(map[uint]bool)
 	)
 	for _, update := range updates {
-		// This looks like the same logic as UpdateCurrent, but if we just
-		// called, version ordering in the updates list must be enforced
-		// (you cannot insert the version 2 before version 1).  And we do
-		// not care about monotonic ordering in the updates.
-		query = db.Where("gun = ? and role = ? and version >= ?",
-			gun, update.Role, update.Version).First(&TUFFile{})
-
-		if !query.RecordNotFound() {
-			return rollback(&ErrOldVersion{})
-		}
-
 		var row TUFFile
 		checksum := sha256.Sum256(update.Data)
 		hexChecksum := hex.EncodeToString(checksum[:])
@@ -114,6 +154,10 @@ func (db *SQLStorage) UpdateMany(gun string, updates []MetaUpdate) error {
 		}
 		added[row.ID] = true
 	}
+	change := newUpdateChange(gun, updates)
+	if err := tx.Create(&change).Error; err != nil {
+		return rollback(err)
+	}
 	return tx.Commit().Error
 }
 
@@ -144,6 +188,20 @@ func (db *SQLStorage) GetChecksum(gun, tufRole, checksum string) (*time.Time, []
 	return &(row.CreatedAt), row.Data, nil
 }
 
+// GetVersion gets a specific TUF record by its version
+func (db *SQLStorage) GetVersion(gun, tufRole string, version int) (*time.Time, []byte, error) {
+	var row TUFFile
+	q := db.Select("created_at, data").Where(map[string]interface{}{
+		"gun":     gun,
+		"role":    tufRole,
+		"version": version,
+	}).First(&row)
+	if err := isReadErr(q, row); err != nil {
+		return nil, nil, err
+	}
+	return &(row.CreatedAt), row.Data, nil
+}
+
 func isReadErr(q *gorm.DB, row TUFFile) error {
 	if q.RecordNotFound() {
 		return ErrNotFound{}
@@ -153,17 +211,105 @@ func isReadErr(q *gorm.DB, row TUFFile) error {
 	return nil
 }
 
-// Delete deletes all the records for a specific GUN
+// Delete deletes all the records for a specific GUN, and records the deletion
+// in the changefeed if there were any
 func (db *SQLStorage) Delete(gun string) error {
-	return db.Where(&TUFFile{Gun: gun}).Delete(TUFFile{}).Error
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	rollback := func(err error) error {
+		if rxErr := tx.Rollback().Error; rxErr != nil {
+			logrus.Error("Failed on Tx rollback with error: ", rxErr.Error())
+			return rxErr
+		}
+		return err
+	}
+
+	query := tx.Where(&TUFFile{Gun: gun}).Delete(TUFFile{})
+	if query.Error != nil {
+		return rollback(query.Error)
+	}
+	if query.RowsAffected > 0 {
+		if err := tx.Create(&Change{GUN: gun, Category: ChangeCategoryDeletion}).Error; err != nil {
+			return rollback(err)
+		}
+	}
+	return tx.Commit().Error
 }
 
+// GetChanges returns up to records changes made after the change with the ID
+// changeID
+func (db *SQLStorage) GetChanges(changeID uint, records int) ([]Change, error) {
+	changes := []Change{}
+	if records <= 0 {
+		return changes, nil
+	}
+	query := db.Where("id > ?", changeID).Order("id asc").Limit(records).Find(&changes)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return changes, nil
+}
+
+// GetGUNs returns up to records GUNs starting with prefix, in sorted order,
+// that come after last
+func (db *SQLStorage) GetGUNs(prefix, last string, records int) ([]string, error) {
+	guns := []string{}
+	if records <= 0 {
+		return guns, nil
+	}
+	query := db.Model(&TUFFile{}).Where("gun > ?", last)
+	if prefix != "" {
+		query = query.Where("gun LIKE ? ESCAPE '!'", likeEscaper.Replace(prefix)+"%")
+	}
+	query = query.Order("gun asc").Limit(records).Pluck("DISTINCT gun", &guns)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	return guns, nil
+}
+
+// ListVersions returns every version of every role's metadata for a GUN
+func (db *SQLStorage) ListVersions(gun string) ([]MetaVersion, error) {
+	var rows []TUFFile
+	query := db.Select("role, version, sha256, created_at").Where(&TUFFile{Gun: gun}).Find(&rows)
+	if query.Error != nil {
+		return nil, query.Error
+	}
+	versions := make([]MetaVersion, 0, len(rows))
+	for _, row := range rows {
+		versions = append(versions, MetaVersion{
+			Role:      row.Role,
+			Version:   row.Version,
+			SHA256:    row.Sha256,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return versions, nil
+}
+
+// DeleteVersions permanently deletes the given versions of a role's metadata
+// for a GUN, so that the rows don't accumulate in the table
+func (db *SQLStorage) DeleteVersions(gun, role string, versions []int) error {
+	if len(versions) == 0 {
+		return nil
+	}
+	return db.Unscoped().Where("gun = ? AND role = ? AND version IN (?)",
+		gun, role, versions).Delete(TUFFile{}).Error
+}
+
+// likeEscaper escapes the wildcards in a string to be matched with LIKE
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 // GetKey returns the Public Key data for a gun+role
 func (db *SQLStorage) GetKey(gun, role string) (algorithm string, public []byte, err error) {
 	logrus.Debugf("retrieving timestamp key for %s:%s", gun, role)
 
 	var row Key
-	query := db.Select("cipher, public").Where(&Key{Gun: gun, Role: role}).Find(&row)
+	// the latest key replaces any previous ones
+	query := db.Select("cipher, public").Where(&Key{Gun: gun, Role: role}).Order("version desc").First(&row)
 
 	if query.RecordNotFound() {
 		return "", nil, &ErrNoKey{gun: gun}
@@ -174,30 +320,68 @@ func (db *SQLStorage) GetKey(gun, role string) (algorithm string, public []byte,
 	return row.Cipher, row.Public, nil
 }
 
-// SetKey attempts to write a key and returns an error if it already exists for the gun and role
+// SetKey attempts to write a key and returns an error if it already exists for
+// the gun and role.  The first key is always version 0, so the unique index on
+// gun, role and version rejects a key created concurrently by another server.
 func (db *SQLStorage) SetKey(gun, role, algorithm string, public []byte) error {
-
-	entry := Key{
-		Gun:  gun,
-		Role: role,
+	err := translateOldVersionError(db.Create(&Key{
+		Gun:     gun,
+		Role:    role,
+		Version: 0,
+		Cipher:  algorithm,
+		Public:  public,
+	}).Error)
+	if _, ok := err.(*ErrOldVersion); ok {
+		return &ErrKeyExists{gun: gun, role: role}
 	}
+	return err
+}
 
-	if !db.Where(&entry).First(&Key{}).RecordNotFound() {
-		return &ErrKeyExists{gun: gun, role: role}
+// RotateKey writes a new key for the gun and role, which replaces any previous
+// ones without deleting them.  If another key is rotated in at the same time,
+// only one of them is written and the other gets an ErrOldVersion.
+func (db *SQLStorage) RotateKey(gun, role, algorithm string, public []byte) error {
+	version := 0
+	var row Key
+	query := db.Select("version").Where(&Key{Gun: gun, Role: role}).Order("version desc").First(&row)
+	if query.Error == nil {
+		version = row.Version + 1
+	} else if !query.RecordNotFound() {
+		return query.Error
 	}
 
-	entry.Cipher = algorithm
-	entry.Public = public
+	return translateOldVersionError(db.Create(&Key{
+		Gun:     gun,
+		Role:    role,
+		Version: version,
+		Cipher:  algorithm,
+		Public:  public,
+	}).Error)
+}
 
-	return translateOldVersionError(
-		db.FirstOrCreate(&Key{}, &entry).Error)
+// GetKeys returns every key that has been written for the gun and role, oldest first
+func (db *SQLStorage) GetKeys(gun, role string) ([]data.PublicKey, error) {
+	var rows []Key
+	query := db.Select("cipher, public").Where(&Key{Gun: gun, Role: role}).Order("version").Find(&rows)
+	if query.Error != nil && !query.RecordNotFound() {
+		return nil, query.Error
+	}
+	keys := []data.PublicKey{}
+	for _, row := range rows {
+		keys = append(keys, data.NewPublicKey(row.Cipher, row.Public))
+	}
+	return keys, nil
 }
 
-// CheckHealth asserts that both required tables are present
+// CheckHealth asserts that the database can be reached and all the required
+// tables are present
 func (db *SQLStorage) CheckHealth() error {
+	if err := db.DB.DB().Ping(); err != nil {
+		return err
+	}
 	interfaces := []interface {
 		TableName() string
-	}{&TUFFile{}, &Key{}}
+	}{&TUFFile{}, &Key{}, &Change{}}
 
 	for _, model := range interfaces {
 		tableOk := db.HasTable(model)
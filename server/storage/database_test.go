@@ -12,7 +12,7 @@ import (
 
 	"github.com/docker/notary/tuf/data"
 	"github.com/jinzhu/gorm"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,9 +51,12 @@ func SetUpSQLite(t *testing.T, dbDir string) (*gorm.DB, *SQLStorage) {
 	err = CreateKeyTable(dbStore.DB)
 	require.NoError(t, err)
 
+	err = CreateChangefeedTable(dbStore.DB)
+	require.NoError(t, err)
+
 	// verify that the tables are empty
 	var count int
-	for _, model := range [2]interface{}{&TUFFile{}, &Key{}} {
+	for _, model := range [3]interface{}{&TUFFile{}, &Key{}, &Change{}} {
 		query := dbStore.DB.Model(model).Count(&count)
 		require.NoError(t, query.Error)
 		require.Equal(t, 0, count)
@@ -505,6 +508,46 @@ func TestDBGetChecksum(t *testing.T) {
 	require.True(t, cDate.Before(time.Now().Add(5*time.Second)))
 }
 
+func TestDBGetVersion(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	_, store := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+
+	updates := []MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: []byte("1")},
+		{Role: data.CanonicalRootRole, Version: 2, Data: []byte("2")},
+		{Role: data.CanonicalTargetsRole, Version: 1, Data: []byte("targets")},
+	}
+	require.NoError(t, store.UpdateMany("gun", updates))
+
+	cDate, data, err := store.GetVersion("gun", updates[0].Role, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, updates[0].Data, data)
+	// the creation date was sometime wthin the last minute
+	require.True(t, cDate.After(time.Now().Add(-1*time.Minute)))
+	require.True(t, cDate.Before(time.Now().Add(5*time.Second)))
+
+	_, data, err = store.GetVersion("gun", updates[1].Role, 2)
+	require.NoError(t, err)
+	require.EqualValues(t, updates[1].Data, data)
+}
+
+func TestDBGetVersionNotFound(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	_, store := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+
+	require.NoError(t, store.UpdateCurrent("gun", MetaUpdate{Role: data.CanonicalRootRole, Version: 1, Data: []byte("1")}))
+
+	_, _, err = store.GetVersion("gun", data.CanonicalRootRole, 2)
+	require.Error(t, err)
+	require.IsType(t, ErrNotFound{}, err)
+
+	_, _, err = store.GetVersion("gun", data.CanonicalRootRole, 0)
+	require.Error(t, err)
+	require.IsType(t, ErrNotFound{}, err)
+}
+
 func TestDBGetChecksumNotFound(t *testing.T) {
 	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
 	_, store := SetUpSQLite(t, tempBaseDir)
@@ -514,3 +557,96 @@ func TestDBGetChecksumNotFound(t *testing.T) {
 	require.Error(t, err)
 	require.IsType(t, ErrNotFound{}, err)
 }
+
+// TestDBChangefeed asserts that UpdateMany and Delete are recorded in the
+// changefeed, and that GetChanges pages through it in order.
+func TestDBChangefeed(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	_, dbStore := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+	defer dbStore.DB.Close()
+
+	testChangefeed(t, dbStore)
+}
+
+// TestDBGetGUNs asserts that GetGUNs lists each GUN with metadata once.
+func TestDBGetGUNs(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	_, dbStore := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+	defer dbStore.DB.Close()
+
+	testGetGUNs(t, dbStore)
+}
+
+// TestDBRotateKey asserts that rotated keys replace the current key while the
+// previous ones are kept.
+func TestDBRotateKey(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	_, dbStore := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+	defer dbStore.DB.Close()
+
+	testRotateKey(t, dbStore)
+}
+
+// TestDBCreateTables asserts that CreateTables creates all the tables needed
+// for the health check to pass, and can be run again on an existing database.
+func TestDBCreateTables(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	dbStore, err := NewSQLStorage("sqlite3", tempBaseDir+"test_db")
+	require.NoError(t, err)
+	defer dbStore.DB.Close()
+
+	require.Error(t, dbStore.CheckHealth())
+
+	require.NoError(t, dbStore.CreateTables())
+	require.NoError(t, dbStore.CheckHealth())
+
+	require.NoError(t, dbStore.UpdateMany("testGUN", []MetaUpdate{SampleUpdate(1)}))
+	require.NoError(t, dbStore.CreateTables())
+	_, data, err := dbStore.GetCurrent("testGUN", "root")
+	require.NoError(t, err)
+	require.Equal(t, []byte("1"), data)
+}
+
+// TestSQLiteDuplicateIsOldVersion asserts that a unique constraint failure from
+// SQLite is translated into an ErrOldVersion.
+func TestSQLiteDuplicateIsOldVersion(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	gormDB, dbStore := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+	defer dbStore.DB.Close()
+
+	tuf := SampleTUF(1)
+	require.NoError(t, gormDB.Create(&tuf).Error)
+
+	duplicate := SampleTUF(1)
+	err = gormDB.Create(&duplicate).Error
+	require.IsType(t, sqlite3.Error{}, err)
+	require.IsType(t, &ErrOldVersion{}, translateOldVersionError(err))
+}
+
+// TestDBDeleteVersions asserts that DeleteVersions removes the rows from the
+// table entirely, rather than just marking them as deleted.
+func TestDBDeleteVersions(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	gormDB, dbStore := SetUpSQLite(t, tempBaseDir)
+	defer os.RemoveAll(tempBaseDir)
+	defer dbStore.DB.Close()
+
+	testDeleteVersions(t, dbStore)
+
+	var count int
+	query := gormDB.Unscoped().Model(&TUFFile{}).Where("gun = ?", "gun").Count(&count)
+	require.NoError(t, query.Error)
+	require.Equal(t, 2, count)
+}
@@ -1,6 +1,10 @@
 package storage
 
-import "time"
+import (
+	"time"
+
+	"github.com/docker/notary/tuf/data"
+)
 
 // KeyStore provides a minimal interface for managing key persistence
 type KeyStore interface {
@@ -11,6 +15,17 @@ type KeyStore interface {
 	// SetKey sets the algorithm and public key for the given GUN and role if
 	// it doesn't already exist.  Otherwise an error is returned.
 	SetKey(gun, role, algorithm string, public []byte) error
+
+	// RotateKey replaces the key for the given GUN and role, whether or not
+	// one already exists, with the given algorithm and public key, which
+	// GetKey returns from then on.  The replaced keys are kept.  If another
+	// key is rotated in at the same time, an ErrOldVersion may be returned.
+	RotateKey(gun, role, algorithm string, public []byte) error
+
+	// GetKeys returns every key that has been set for the given GUN and role,
+	// oldest first, so the last one is the one GetKey returns.  If there are
+	// none, an empty list is returned.
+	GetKeys(gun, role string) ([]data.PublicKey, error)
 }
 
 // MetaStore holds the methods that are used for a Metadata Store
@@ -36,9 +51,32 @@ type MetaStore interface {
 	// not found, it returns storage.ErrNotFound
 	GetChecksum(gun, tufRole, checksum string) (created *time.Time, data []byte, err error)
 
+	// GetVersion returns the given TUF role file and creation date for the
+	// GUN with the provided version. If the given (gun, role, version) are
+	// not found, it returns storage.ErrNotFound
+	GetVersion(gun, tufRole string, version int) (created *time.Time, data []byte, err error)
+
 	// Delete removes all metadata for a given GUN.  It does not return an
 	// error if no metadata exists for the given GUN.
 	Delete(gun string) error
 
+	// GetChanges returns up to records changes, in the order they were made,
+	// starting after the change with the ID changeID.  A changeID of 0 starts
+	// from the beginning of the changefeed.
+	GetChanges(changeID uint, records int) ([]Change, error)
+
+	// GetGUNs returns up to records GUNs that have metadata, in sorted order,
+	// starting after the GUN last.  If prefix is not empty, only GUNs that
+	// start with it are returned.
+	GetGUNs(prefix, last string, records int) ([]string, error)
+
+	// ListVersions returns every version of every role's metadata stored for
+	// a GUN, in no particular order.
+	ListVersions(gun string) ([]MetaVersion, error)
+
+	// DeleteVersions permanently removes the given versions of a role's
+	// metadata for a GUN.  Versions that don't exist are ignored.
+	DeleteVersions(gun, role string, versions []int) error
+
 	KeyStore
 }
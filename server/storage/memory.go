@@ -4,9 +4,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/docker/notary/tuf/data"
 )
 
 type key struct {
@@ -25,31 +28,45 @@ type ver struct {
 type MemStorage struct {
 	lock      sync.Mutex
 	tufMeta   map[string][]*ver
-	keys      map[string]map[string]*key
+	keys      map[string]map[string][]*key
 	checksums map[string]map[string]ver
+	changes   []Change
 }
 
 // NewMemStorage instantiates a memStorage instance
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
 		tufMeta:   make(map[string][]*ver),
-		keys:      make(map[string]map[string]*key),
+		keys:      make(map[string]map[string][]*key),
 		checksums: make(map[string]map[string]ver),
 	}
 }
 
 // UpdateCurrent updates the meta data for a specific role
 func (st *MemStorage) UpdateCurrent(gun string, update MetaUpdate) error {
-	id := entryKey(gun, update.Role)
 	st.lock.Lock()
 	defer st.lock.Unlock()
-	if space, ok := st.tufMeta[id]; ok {
-		for _, v := range space {
-			if v.version >= update.Version {
-				return &ErrOldVersion{}
-			}
+	if st.isOldVersion(gun, update) {
+		return &ErrOldVersion{}
+	}
+	st.updateCurrent(gun, update)
+	return nil
+}
+
+// isOldVersion returns whether there is already a version of the role at least
+// as new as the update.  The caller must hold the lock.
+func (st *MemStorage) isOldVersion(gun string, update MetaUpdate) bool {
+	for _, v := range st.tufMeta[entryKey(gun, update.Role)] {
+		if v.version >= update.Version {
+			return true
 		}
 	}
+	return false
+}
+
+// updateCurrent stores the update.  The caller must hold the lock.
+func (st *MemStorage) updateCurrent(gun string, update MetaUpdate) {
+	id := entryKey(gun, update.Role)
 	version := ver{version: update.Version, data: update.Data, createupdate: time.Now()}
 	st.tufMeta[id] = append(st.tufMeta[id], &version)
 	checksumBytes := sha256.Sum256(update.Data)
@@ -60,14 +77,25 @@ func (st *MemStorage) UpdateCurrent(gun string, update MetaUpdate) error {
 		st.checksums[gun] = make(map[string]ver)
 	}
 	st.checksums[gun][checksum] = version
-	return nil
 }
 
-// UpdateMany updates multiple TUF records
+// UpdateMany updates multiple TUF records, and records the update in the
+// changefeed.  If any of the updates is not newer than what is already stored,
+// none of them are applied and ErrOldVersion is returned.
 func (st *MemStorage) UpdateMany(gun string, updates []MetaUpdate) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	versions := make(map[string]int)
+	for _, u := range updates {
+		if v, ok := versions[u.Role]; (ok && v >= u.Version) || st.isOldVersion(gun, u) {
+			return &ErrOldVersion{}
+		}
+		versions[u.Role] = u.Version
+	}
 	for _, u := range updates {
-		st.UpdateCurrent(gun, u)
+		st.updateCurrent(gun, u)
 	}
+	st.addChange(newUpdateChange(gun, updates))
 	return nil
 }
 
@@ -94,53 +122,204 @@ func (st *MemStorage) GetChecksum(gun, role, checksum string) (*time.Time, []byt
 	return &(space.createupdate), space.data, nil
 }
 
-// Delete deletes all the metadata for a given GUN
+// GetVersion returns the createupdate date and metadata for a given role and
+// version, under a GUN.
+func (st *MemStorage) GetVersion(gun, role string, version int) (*time.Time, []byte, error) {
+	id := entryKey(gun, role)
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	for _, v := range st.tufMeta[id] {
+		if v.version == version {
+			return &(v.createupdate), v.data, nil
+		}
+	}
+	return nil, nil, ErrNotFound{}
+}
+
+// Delete deletes all the metadata for a given GUN, and records the deletion in
+// the changefeed if there was any
 func (st *MemStorage) Delete(gun string) error {
 	st.lock.Lock()
 	defer st.lock.Unlock()
+	var deleted bool
 	for k := range st.tufMeta {
 		if strings.HasPrefix(k, gun) {
 			delete(st.tufMeta, k)
+			deleted = true
 		}
 	}
 	delete(st.checksums, gun)
+	if deleted {
+		st.addChange(Change{GUN: gun, Category: ChangeCategoryDeletion})
+	}
 	return nil
 }
 
+// GetChanges returns up to records changes made after the change with the ID
+// changeID
+func (st *MemStorage) GetChanges(changeID uint, records int) ([]Change, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	// change IDs start at 1, so the change after changeID is at index changeID
+	start := int(changeID)
+	if records <= 0 || start >= len(st.changes) {
+		return []Change{}, nil
+	}
+	end := start + records
+	if end > len(st.changes) {
+		end = len(st.changes)
+	}
+	changes := make([]Change, end-start)
+	copy(changes, st.changes[start:end])
+	return changes, nil
+}
+
+// GetGUNs returns up to records GUNs starting with prefix, in sorted order,
+// that come after last
+func (st *MemStorage) GetGUNs(prefix, last string, records int) ([]string, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	guns := []string{}
+	for gun := range st.checksums {
+		if gun > last && strings.HasPrefix(gun, prefix) {
+			guns = append(guns, gun)
+		}
+	}
+	sort.Strings(guns)
+	if records < len(guns) {
+		if records < 0 {
+			records = 0
+		}
+		guns = guns[:records]
+	}
+	return guns, nil
+}
+
+// ListVersions returns every version of every role's metadata for a GUN
+func (st *MemStorage) ListVersions(gun string) ([]MetaVersion, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	versions := []MetaVersion{}
+	for k, space := range st.tufMeta {
+		if !strings.HasPrefix(k, gun+".") {
+			continue
+		}
+		// GUNs may contain dots, so this may be the key of another GUN
+		role := strings.TrimPrefix(k, gun+".")
+		if !data.ValidRole(role) {
+			continue
+		}
+		for _, v := range space {
+			checksum := sha256.Sum256(v.data)
+			versions = append(versions, MetaVersion{
+				Role:      role,
+				Version:   v.version,
+				SHA256:    hex.EncodeToString(checksum[:]),
+				CreatedAt: v.createupdate,
+			})
+		}
+	}
+	return versions, nil
+}
+
+// DeleteVersions removes the given versions of a role's metadata for a GUN
+func (st *MemStorage) DeleteVersions(gun, role string, versions []int) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	toDelete := make(map[int]bool)
+	for _, version := range versions {
+		toDelete[version] = true
+	}
+	id := entryKey(gun, role)
+	var kept []*ver
+	for _, v := range st.tufMeta[id] {
+		if !toDelete[v.version] {
+			kept = append(kept, v)
+			continue
+		}
+		checksum := sha256.Sum256(v.data)
+		hexChecksum := hex.EncodeToString(checksum[:])
+		if c, ok := st.checksums[gun][hexChecksum]; ok && c.version == v.version {
+			delete(st.checksums[gun], hexChecksum)
+		}
+	}
+	if len(kept) == 0 {
+		delete(st.tufMeta, id)
+	} else {
+		st.tufMeta[id] = kept
+	}
+	return nil
+}
+
+// addChange adds a change to the changefeed - the lock must be held
+func (st *MemStorage) addChange(change Change) {
+	change.ID = uint(len(st.changes) + 1)
+	change.CreatedAt = time.Now()
+	st.changes = append(st.changes, change)
+}
+
 // GetKey returns the public key material of the timestamp key of a given gun
 func (st *MemStorage) GetKey(gun, role string) (algorithm string, public []byte, err error) {
-	// no need for lock. It's ok to return nil if an update
-	// wasn't observed
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	return st.getKey(gun, role)
+}
+
+// getKey returns the latest key under a gun and role - the lock must be held
+func (st *MemStorage) getKey(gun, role string) (algorithm string, public []byte, err error) {
 	g, ok := st.keys[gun]
 	if !ok {
 		return "", nil, &ErrNoKey{gun: gun}
 	}
-	k, ok := g[role]
-	if !ok {
+	keys, ok := g[role]
+	if !ok || len(keys) == 0 {
 		return "", nil, &ErrNoKey{gun: gun}
 	}
+	k := keys[len(keys)-1]
 
 	return k.algorithm, k.public, nil
 }
 
 // SetKey sets a key under a gun and role
 func (st *MemStorage) SetKey(gun, role, algorithm string, public []byte) error {
-	k := &key{algorithm: algorithm, public: public}
 	st.lock.Lock()
 	defer st.lock.Unlock()
 
 	// we hold the lock so nothing will be able to race to write a key
 	// between checking and setting
-	_, _, err := st.GetKey(gun, role)
+	_, _, err := st.getKey(gun, role)
 	if _, ok := err.(*ErrNoKey); !ok {
 		return &ErrKeyExists{gun: gun, role: role}
 	}
+	st.addKey(gun, role, algorithm, public)
+	return nil
+}
+
+// RotateKey replaces the key under a gun and role, keeping the old ones
+func (st *MemStorage) RotateKey(gun, role, algorithm string, public []byte) error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.addKey(gun, role, algorithm, public)
+	return nil
+}
+
+func (st *MemStorage) addKey(gun, role, algorithm string, public []byte) {
 	_, ok := st.keys[gun]
 	if !ok {
-		st.keys[gun] = make(map[string]*key)
+		st.keys[gun] = make(map[string][]*key)
 	}
-	st.keys[gun][role] = k
-	return nil
+	st.keys[gun][role] = append(st.keys[gun][role], &key{algorithm: algorithm, public: public})
+}
+
+// GetKeys returns every key that has been set under a gun and role, oldest first
+func (st *MemStorage) GetKeys(gun, role string) ([]data.PublicKey, error) {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	keys := []data.PublicKey{}
+	for _, k := range st.keys[gun][role] {
+		keys = append(keys, data.NewPublicKey(k.algorithm, k.public))
+	}
+	return keys, nil
 }
 
 func entryKey(gun, role string) string {
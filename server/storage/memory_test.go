@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/docker/notary/tuf/data"
@@ -19,6 +20,23 @@ func TestUpdateCurrent(t *testing.T) {
 	require.Equal(t, []byte("test"), v.data, "Data was incorrect")
 }
 
+func TestUpdateManyOldVersion(t *testing.T) {
+	s := NewMemStorage()
+	require.NoError(t, s.UpdateMany("gun", []MetaUpdate{{"role", 1, []byte("test")}}))
+
+	// none of the updates are applied if any of them is an old version
+	err := s.UpdateMany("gun", []MetaUpdate{
+		{"other", 1, []byte("other")},
+		{"role", 1, []byte("test2")},
+	})
+	require.IsType(t, &ErrOldVersion{}, err)
+	_, _, err = s.GetCurrent("gun", "other")
+	require.IsType(t, ErrNotFound{}, err)
+	_, d, err := s.GetCurrent("gun", "role")
+	require.NoError(t, err)
+	require.Equal(t, []byte("test"), d)
+}
+
 func TestGetCurrent(t *testing.T) {
 	s := NewMemStorage()
 
@@ -57,7 +75,7 @@ func TestSetKey(t *testing.T) {
 	err := s.SetKey("gun", data.CanonicalTimestampRole, data.RSAKey, []byte("test"))
 	require.NoError(t, err)
 
-	k := s.keys["gun"][data.CanonicalTimestampRole]
+	k := s.keys["gun"][data.CanonicalTimestampRole][0]
 	require.Equal(t, data.RSAKey, k.algorithm, "Expected algorithm to be rsa, received %s", k.algorithm)
 	require.Equal(t, []byte("test"), k.public, "Public key did not match expected")
 
@@ -71,11 +89,11 @@ func TestSetKeyMultipleRoles(t *testing.T) {
 	err = s.SetKey("gun", data.CanonicalSnapshotRole, data.RSAKey, []byte("test"))
 	require.NoError(t, err)
 
-	k := s.keys["gun"][data.CanonicalTimestampRole]
+	k := s.keys["gun"][data.CanonicalTimestampRole][0]
 	require.Equal(t, data.RSAKey, k.algorithm, "Expected algorithm to be rsa, received %s", k.algorithm)
 	require.Equal(t, []byte("test"), k.public, "Public key did not match expected")
 
-	k = s.keys["gun"][data.CanonicalSnapshotRole]
+	k = s.keys["gun"][data.CanonicalSnapshotRole][0]
 	require.Equal(t, data.RSAKey, k.algorithm, "Expected algorithm to be rsa, received %s", k.algorithm)
 	require.Equal(t, []byte("test"), k.public, "Public key did not match expected")
 }
@@ -89,15 +107,65 @@ func TestSetKeySameRoleGun(t *testing.T) {
 	err = s.SetKey("gun", data.CanonicalTimestampRole, data.ECDSAKey, []byte("test2"))
 	require.IsType(t, &ErrKeyExists{}, err, "Expected err to be ErrKeyExists")
 
-	k := s.keys["gun"][data.CanonicalTimestampRole]
+	require.Len(t, s.keys["gun"][data.CanonicalTimestampRole], 1)
+	k := s.keys["gun"][data.CanonicalTimestampRole][0]
 	require.Equal(t, data.RSAKey, k.algorithm, "Expected algorithm to be rsa, received %s", k.algorithm)
 	require.Equal(t, []byte("test"), k.public, "Public key did not match expected")
 
 }
 
+// GetKey can be called while keys are being rotated - this is only meaningful
+// when run with the race detector
+func TestGetKeyWhileRotating(t *testing.T) {
+	s := NewMemStorage()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			require.NoError(t, s.RotateKey(fmt.Sprintf("gun%d", i), data.CanonicalTimestampRole,
+				data.ECDSAKey, []byte("test")))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		s.GetKey(fmt.Sprintf("gun%d", i), data.CanonicalTimestampRole)
+	}
+	<-done
+
+	_, k, err := s.GetKey("gun99", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Equal(t, []byte("test"), k)
+}
+
 func TestGetChecksumNotFound(t *testing.T) {
 	s := NewMemStorage()
 	_, _, err := s.GetChecksum("gun", "root", "12345")
 	require.Error(t, err)
 	require.IsType(t, ErrNotFound{}, err)
 }
+
+func TestGetVersion(t *testing.T) {
+	s := NewMemStorage()
+	require.NoError(t, s.UpdateCurrent("gun", MetaUpdate{Role: "root", Version: 1, Data: []byte("v1")}))
+	require.NoError(t, s.UpdateCurrent("gun", MetaUpdate{Role: "root", Version: 2, Data: []byte("v2")}))
+
+	_, d, err := s.GetVersion("gun", "root", 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), d)
+
+	_, d, err = s.GetVersion("gun", "root", 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), d)
+}
+
+func TestGetVersionNotFound(t *testing.T) {
+	s := NewMemStorage()
+	require.NoError(t, s.UpdateCurrent("gun", MetaUpdate{Role: "root", Version: 1, Data: []byte("v1")}))
+
+	_, _, err := s.GetVersion("gun", "root", 2)
+	require.Error(t, err)
+	require.IsType(t, ErrNotFound{}, err)
+
+	_, _, err = s.GetVersion("gun", "targets", 1)
+	require.Error(t, err)
+	require.IsType(t, ErrNotFound{}, err)
+}
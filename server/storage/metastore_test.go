@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/docker/notary/tuf/data"
+	"github.com/stretchr/testify/require"
+)
+
+// testChangefeed asserts that a MetaStore records an update for each call to
+// UpdateMany, and a deletion for each Delete of a GUN that had metadata, and
+// that GetChanges returns them in order from after the given change ID
+func testChangefeed(t *testing.T, s MetaStore) {
+	tsData := []byte("timestamp")
+	checksum := sha256.Sum256(tsData)
+	tsChecksum := hex.EncodeToString(checksum[:])
+
+	require.NoError(t, s.UpdateMany("gun1", []MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: []byte("root")},
+		{Role: data.CanonicalTimestampRole, Version: 1, Data: tsData},
+	}))
+	require.NoError(t, s.UpdateMany("gun2", []MetaUpdate{
+		{Role: data.CanonicalTimestampRole, Version: 3, Data: tsData},
+	}))
+	// deleting a GUN with no metadata is not a change
+	require.NoError(t, s.Delete("gun3"))
+	require.NoError(t, s.Delete("gun1"))
+
+	changes, err := s.GetChanges(0, 10)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	expected := []Change{
+		{ID: 1, GUN: "gun1", Version: 1, SHA256: tsChecksum, Category: ChangeCategoryUpdate},
+		{ID: 2, GUN: "gun2", Version: 3, SHA256: tsChecksum, Category: ChangeCategoryUpdate},
+		{ID: 3, GUN: "gun1", Category: ChangeCategoryDeletion},
+	}
+	for i, change := range changes {
+		require.False(t, change.CreatedAt.IsZero())
+		change.CreatedAt = expected[i].CreatedAt
+		require.Equal(t, expected[i], change)
+	}
+
+	// paging
+	changes, err = s.GetChanges(0, 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, uint(2), changes[1].ID)
+
+	changes, err = s.GetChanges(2, 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.Equal(t, uint(3), changes[0].ID)
+
+	changes, err = s.GetChanges(3, 2)
+	require.NoError(t, err)
+	require.Len(t, changes, 0)
+}
+
+// testGetGUNs asserts that a MetaStore lists the GUNs that have metadata in
+// sorted order, filtered by prefix and paged by the last GUN seen
+func testGetGUNs(t *testing.T, s MetaStore) {
+	for _, gun := range []string{"docker.io/b", "docker.io/a", "quay.io/c", "docker_io/d"} {
+		require.NoError(t, s.UpdateMany(gun, []MetaUpdate{
+			{Role: data.CanonicalRootRole, Version: 1, Data: []byte(gun)},
+			{Role: data.CanonicalTimestampRole, Version: 1, Data: []byte(gun)},
+		}))
+	}
+	require.NoError(t, s.UpdateMany("deleted", []MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: []byte("deleted")},
+	}))
+	require.NoError(t, s.Delete("deleted"))
+
+	guns, err := s.GetGUNs("", "", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.io/a", "docker.io/b", "docker_io/d", "quay.io/c"}, guns)
+
+	// wildcards in the prefix are matched literally
+	guns, err = s.GetGUNs("docker.", "", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.io/a", "docker.io/b"}, guns)
+
+	guns, err = s.GetGUNs("docker_", "", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker_io/d"}, guns)
+
+	// paging
+	guns, err = s.GetGUNs("", "", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker.io/a", "docker.io/b"}, guns)
+
+	guns, err = s.GetGUNs("", "docker.io/b", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"docker_io/d", "quay.io/c"}, guns)
+
+	guns, err = s.GetGUNs("", "quay.io/c", 2)
+	require.NoError(t, err)
+	require.Len(t, guns, 0)
+}
+
+// testDeleteVersions asserts that a MetaStore lists all the versions stored
+// for a GUN, and that deleted versions can no longer be fetched
+func testDeleteVersions(t *testing.T, s MetaStore) {
+	for version := 1; version <= 3; version++ {
+		require.NoError(t, s.UpdateMany("gun", []MetaUpdate{
+			{Role: data.CanonicalTargetsRole, Version: version, Data: []byte(fmt.Sprintf("targets%d", version))},
+		}))
+	}
+	require.NoError(t, s.UpdateMany("gun", []MetaUpdate{
+		{Role: data.CanonicalRootRole, Version: 1, Data: []byte("root1")},
+	}))
+	require.NoError(t, s.UpdateMany("gun.other", []MetaUpdate{
+		{Role: data.CanonicalTargetsRole, Version: 1, Data: []byte("other")},
+	}))
+
+	versions, err := s.ListVersions("gun")
+	require.NoError(t, err)
+	require.Len(t, versions, 4)
+	found := make(map[string]MetaVersion)
+	for _, v := range versions {
+		require.False(t, v.CreatedAt.IsZero())
+		found[fmt.Sprintf("%s.%d", v.Role, v.Version)] = v
+	}
+	checksum := sha256.Sum256([]byte("targets1"))
+	require.Equal(t, hex.EncodeToString(checksum[:]), found["targets.1"].SHA256)
+	for _, key := range []string{"targets.2", "targets.3", "root.1"} {
+		_, ok := found[key]
+		require.True(t, ok, "missing %s", key)
+	}
+
+	// versions that don't exist are ignored
+	require.NoError(t, s.DeleteVersions("gun", data.CanonicalTargetsRole, []int{1, 2, 10}))
+	require.NoError(t, s.DeleteVersions("gun", data.CanonicalTargetsRole, nil))
+
+	versions, err = s.ListVersions("gun")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	_, _, err = s.GetVersion("gun", data.CanonicalTargetsRole, 1)
+	require.IsType(t, ErrNotFound{}, err)
+	_, _, err = s.GetChecksum("gun", data.CanonicalTargetsRole, hex.EncodeToString(checksum[:]))
+	require.IsType(t, ErrNotFound{}, err)
+
+	_, current, err := s.GetCurrent("gun", data.CanonicalTargetsRole)
+	require.NoError(t, err)
+	require.Equal(t, []byte("targets3"), current)
+
+	// other GUNs, even those whose names start with this one, are untouched
+	versions, err = s.ListVersions("gun.other")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+}
+
+// RotateKey replaces the key GetKey returns, and GetKeys returns all of them
+func testRotateKey(t *testing.T, s MetaStore) {
+	keys, err := s.GetKeys("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	// a key can be rotated in even if there wasn't one before
+	require.NoError(t, s.RotateKey("gun", data.CanonicalTimestampRole, data.ECDSAKey, []byte("1")))
+	_, _, err = s.GetKey("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.IsType(t, &ErrKeyExists{}, s.SetKey("gun", data.CanonicalTimestampRole, data.ECDSAKey, []byte("2")))
+
+	require.NoError(t, s.RotateKey("gun", data.CanonicalTimestampRole, data.ED25519Key, []byte("2")))
+	algorithm, public, err := s.GetKey("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Equal(t, data.ED25519Key, algorithm)
+	require.Equal(t, []byte("2"), public)
+
+	keys, err = s.GetKeys("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Equal(t, []data.PublicKey{
+		data.NewPublicKey(data.ECDSAKey, []byte("1")),
+		data.NewPublicKey(data.ED25519Key, []byte("2")),
+	}, keys)
+
+	// other roles and GUNs are unaffected
+	_, _, err = s.GetKey("gun", data.CanonicalSnapshotRole)
+	require.IsType(t, &ErrNoKey{}, err)
+	keys, err = s.GetKeys("gun2", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func TestMemChangefeed(t *testing.T) {
+	testChangefeed(t, NewMemStorage())
+}
+
+func TestMemGetGUNs(t *testing.T) {
+	testGetGUNs(t, NewMemStorage())
+}
+
+func TestMemDeleteVersions(t *testing.T) {
+	testDeleteVersions(t, NewMemStorage())
+}
+
+func TestMemRotateKey(t *testing.T) {
+	testRotateKey(t, NewMemStorage())
+}
@@ -1,6 +1,19 @@
 package storage
 
-import "github.com/jinzhu/gorm"
+import (
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+// Categories of Change
+const (
+	// ChangeCategoryUpdate is a publish of new metadata for a GUN
+	ChangeCategoryUpdate = "update"
+	// ChangeCategoryDeletion is the deletion of all the metadata for a GUN
+	ChangeCategoryDeletion = "deletion"
+)
 
 // TUFFile represents a TUF file in the database
 type TUFFile struct {
@@ -17,13 +30,16 @@ func (g TUFFile) TableName() string {
 	return "tuf_files"
 }
 
-// Key represents a single timestamp key in the database
+// Key represents a single timestamp key in the database.  A GUN and role may
+// have several keys if the key has been rotated, numbered by Version, in which
+// case the one with the highest version is the current key.
 type Key struct {
 	gorm.Model
-	Gun    string `sql:"type:varchar(255);not null;unique_index:gun_role"`
-	Role   string `sql:"type:varchar(255);not null;unique_index:gun_role"`
-	Cipher string `sql:"type:varchar(30);not null"`
-	Public []byte `sql:"type:blob;not null"`
+	Gun     string `sql:"type:varchar(255);not null;unique_index:gun_role_version"`
+	Role    string `sql:"type:varchar(255);not null;unique_index:gun_role_version"`
+	Version int    `sql:"not null;unique_index:gun_role_version"`
+	Cipher  string `sql:"type:varchar(30);not null"`
+	Public  []byte `sql:"type:blob;not null"`
 }
 
 // TableName sets a specific table name for our TimestampKey
@@ -31,10 +47,36 @@ func (g Key) TableName() string {
 	return "timestamp_keys"
 }
 
-// CreateTUFTable creates the DB table for TUFFile
+// Change represents a publish or deletion of a GUN in the changefeed.  For an
+// update, Version and SHA256 are those of the new timestamp.
+type Change struct {
+	ID        uint      `gorm:"primary_key" sql:"not null" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	GUN       string    `gorm:"column:gun" sql:"type:varchar(255);not null" json:"gun"`
+	Version   int       `sql:"not null" json:"version"`
+	SHA256    string    `gorm:"column:sha256" sql:"type:varchar(64);" json:"sha256"`
+	Category  string    `sql:"type:varchar(20);not null" json:"category"`
+}
+
+// TableName sets a specific table name for Change
+func (c Change) TableName() string {
+	return "changefeed"
+}
+
+// createTable creates the DB table for a model.  MySQL tables are created with
+// the InnoDB engine and utf8 charset, which other dialects don't support as
+// table options.
+func createTable(db gorm.DB, model interface{}) *gorm.DB {
+	if _, ok := db.DB().Driver().(*mysql.MySQLDriver); ok {
+		return db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8").CreateTable(model)
+	}
+	return db.CreateTable(model)
+}
+
+// CreateTUFTable creates the DB table for TUFFile.  MySQL databases should be
+// created with the migrations instead, but this works for any gorm dialect.
 func CreateTUFTable(db gorm.DB) error {
-	// TODO: gorm
-	query := db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8").CreateTable(&TUFFile{})
+	query := createTable(db, &TUFFile{})
 	if query.Error != nil {
 		return query.Error
 	}
@@ -46,14 +88,27 @@ func CreateTUFTable(db gorm.DB) error {
 	return nil
 }
 
-// CreateKeyTable creates the DB table for TUFFile
+// CreateKeyTable creates the DB table for Key
 func CreateKeyTable(db gorm.DB) error {
-	query := db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8").CreateTable(&Key{})
+	query := createTable(db, &Key{})
 	if query.Error != nil {
 		return query.Error
 	}
 	query = db.Model(&Key{}).AddUniqueIndex(
-		"idx_gun_role", "gun", "role")
+		"idx_gun_role_version", "gun", "role", "version")
+	if query.Error != nil {
+		return query.Error
+	}
+	return nil
+}
+
+// CreateChangefeedTable creates the DB table for Change
+func CreateChangefeedTable(db gorm.DB) error {
+	query := createTable(db, &Change{})
+	if query.Error != nil {
+		return query.Error
+	}
+	query = db.Model(&Change{}).AddIndex("idx_changefeed_gun", "gun")
 	if query.Error != nil {
 		return query.Error
 	}
@@ -1,8 +1,38 @@
 package storage
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/docker/notary/tuf/data"
+)
+
 // MetaUpdate packages up the fields required to update a TUF record
 type MetaUpdate struct {
 	Role    string
 	Version int
 	Data    []byte
 }
+
+// MetaVersion describes a single stored version of a role's metadata
+type MetaVersion struct {
+	Role      string
+	Version   int
+	SHA256    string
+	CreatedAt time.Time
+}
+
+// newUpdateChange returns the change to record in the changefeed for a set
+// of updates to a GUN, identified by the newest timestamp among them
+func newUpdateChange(gun string, updates []MetaUpdate) Change {
+	change := Change{GUN: gun, Category: ChangeCategoryUpdate}
+	for _, update := range updates {
+		if update.Role == data.CanonicalTimestampRole && update.Version >= change.Version {
+			checksum := sha256.Sum256(update.Data)
+			change.Version = update.Version
+			change.SHA256 = hex.EncodeToString(checksum[:])
+		}
+	}
+	return change
+}
@@ -47,51 +47,79 @@ func GetOrCreateTimestampKey(gun string, store storage.MetaStore, crypto signed.
 	return nil, err
 }
 
+// RotateTimestampKey creates a new timestamp key, which replaces the current
+// one as the key returned by GetOrCreateTimestampKey.  The previous keys are
+// not deleted, so that timestamps can still be signed with whichever key the
+// current root trusts until the root is updated with the new key.
+func RotateTimestampKey(gun string, store storage.MetaStore, crypto signed.CryptoService, createAlgorithm string) (data.PublicKey, error) {
+	key, err := crypto.Create(data.CanonicalTimestampRole, gun, createAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debug("Rotating timestamp key for ", gun, ". With algo: ", key.Algorithm())
+	if err := store.RotateKey(gun, data.CanonicalTimestampRole, key.Algorithm(), key.Public()); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // GetOrCreateTimestamp returns the current timestamp for the gun. This may mean
 // a new timestamp is generated either because none exists, or because the current
 // one has expired. Once generated, the timestamp is saved in the store.
 func GetOrCreateTimestamp(gun string, store storage.MetaStore, cryptoService signed.CryptoService) (
 	*time.Time, []byte, error) {
 
+	lastModified, timestampJSON, _, err := getOrCreateTimestamp(gun, store, cryptoService, time.Now())
+	return lastModified, timestampJSON, err
+}
+
+// RefreshTimestamp re-signs the current timestamp if it expires before the
+// given time, or if it does not reference the current snapshot.  It returns
+// whether the timestamp was re-signed.
+func RefreshTimestamp(gun string, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiresBefore time.Time) (bool, error) {
+
+	_, _, resigned, err := getOrCreateTimestamp(gun, store, cryptoService, expiresBefore)
+	return resigned, err
+}
+
+func getOrCreateTimestamp(gun string, store storage.MetaStore, cryptoService signed.CryptoService,
+	expiresBefore time.Time) (*time.Time, []byte, bool, error) {
+
 	lastModified, timestampJSON, err := store.GetCurrent(gun, data.CanonicalTimestampRole)
 	if err != nil {
 		logrus.Error("error retrieving timestamp: ", err.Error())
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	prev := &data.SignedTimestamp{}
 	if err := json.Unmarshal(timestampJSON, prev); err != nil {
 		logrus.Error("Failed to unmarshal existing timestamp")
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	_, snapshot, err := snapshot.GetOrCreateSnapshot(gun, store, cryptoService)
 	if err != nil {
 		logrus.Debug("Previous timestamp, but no valid snapshot for GUN ", gun)
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
-	if !timestampExpired(prev) && !snapshotExpired(prev, snapshot) {
-		return lastModified, timestampJSON, nil
+	if !prev.Signed.Expires.Before(expiresBefore) && !snapshotExpired(prev, snapshot) {
+		return lastModified, timestampJSON, false, nil
 	}
 
 	update, err := createTimestamp(gun, prev, snapshot, store, cryptoService)
 	if err != nil {
 		logrus.Error("Failed to create a new timestamp")
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	c := time.Now()
 
 	if err = store.UpdateCurrent(gun, *update); err != nil {
-		return nil, nil, err
+		return nil, nil, false, err
 	}
-	return &c, update.Data, nil
-}
-
-// timestampExpired compares the current time to the expiry time of the timestamp
-func timestampExpired(ts *data.SignedTimestamp) bool {
-	return signed.IsExpired(ts.Signed.Expires)
+	return &c, update.Data, true, nil
 }
 
 // snapshotExpired verifies the checksum(s) for the given snapshot using metadata from the timestamp
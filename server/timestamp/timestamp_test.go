@@ -2,6 +2,9 @@ package timestamp
 
 import (
 	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -14,24 +17,43 @@ import (
 	"github.com/docker/notary/server/storage"
 )
 
-func TestTimestampExpired(t *testing.T) {
-	ts := &data.SignedTimestamp{
-		Signatures: nil,
-		Signed: data.Timestamp{
-			SignedCommon: data.SignedCommon{Expires: time.Now().AddDate(-1, 0, 0)},
-		},
-	}
-	require.True(t, timestampExpired(ts), "Timestamp should have expired")
-}
+// The timestamp is only re-signed if it expires before the end of the window
+func TestRefreshTimestampWindow(t *testing.T) {
+	store := storage.NewMemStorage()
+	repo, crypto, err := testutils.EmptyRepo("gun")
+	require.NoError(t, err)
 
-func TestTimestampNotExpired(t *testing.T) {
-	ts := &data.SignedTimestamp{
-		Signatures: nil,
-		Signed: data.Timestamp{
-			SignedCommon: data.SignedCommon{Expires: time.Now().AddDate(1, 0, 0)},
-		},
-	}
-	require.False(t, timestampExpired(ts), "Timestamp should NOT have expired")
+	rootJSON, err := json.Marshal(repo.Root)
+	require.NoError(t, err)
+	snapJSON, err := json.Marshal(repo.Snapshot)
+	require.NoError(t, err)
+	_, err = repo.SignTimestamp(time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	timestampJSON, err := json.Marshal(repo.Timestamp)
+	require.NoError(t, err)
+
+	require.NoError(t, store.UpdateCurrent("gun",
+		storage.MetaUpdate{Role: data.CanonicalRootRole, Version: 0, Data: rootJSON}))
+	require.NoError(t, store.UpdateCurrent("gun",
+		storage.MetaUpdate{Role: data.CanonicalSnapshotRole, Version: 0, Data: snapJSON}))
+	require.NoError(t, store.UpdateCurrent("gun",
+		storage.MetaUpdate{Role: data.CanonicalTimestampRole, Version: 1, Data: timestampJSON}))
+
+	resigned, err := RefreshTimestamp("gun", store, crypto, time.Now().Add(30*time.Minute))
+	require.NoError(t, err)
+	require.False(t, resigned)
+	_, gottenTimestamp, err := store.GetCurrent("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(timestampJSON, gottenTimestamp))
+
+	resigned, err = RefreshTimestamp("gun", store, crypto, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.True(t, resigned)
+	_, gottenTimestamp, err = store.GetCurrent("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	signedMeta := &data.SignedMeta{}
+	require.NoError(t, json.Unmarshal(gottenTimestamp, signedMeta))
+	require.True(t, signedMeta.Signed.Expires.After(time.Now().Add(2*time.Hour)))
 }
 
 func TestGetTimestampKey(t *testing.T) {
@@ -50,6 +72,49 @@ func TestGetTimestampKey(t *testing.T) {
 	require.NotNil(t, k2, "Key should not be nil")
 }
 
+// When several servers create the timestamp key for a GUN at the same time,
+// only one key is stored, and they all get that key back
+func TestGetTimestampKeyConcurrently(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+	store, err := storage.NewSQLStorage("sqlite3", filepath.Join(tempBaseDir, "test_db"))
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.CreateTables())
+
+	crypto := signed.NewEd25519()
+	results := make(chan data.PublicKey)
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			k, err := GetOrCreateTimestampKey("gun", store, crypto, data.ED25519Key)
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- k
+		}()
+	}
+
+	var keyIDs []string
+	for i := 0; i < 10; i++ {
+		select {
+		case k := <-results:
+			keyIDs = append(keyIDs, k.ID())
+		case err := <-errs:
+			require.NoError(t, err)
+		}
+	}
+
+	keys, err := store.GetKeys("gun", data.CanonicalTimestampRole)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	for _, keyID := range keyIDs {
+		require.Equal(t, keys[0].ID(), keyID)
+	}
+}
+
 // If there is no previous timestamp or the previous timestamp is corrupt, then
 // even if everything else is in place, getting the timestamp fails
 func TestGetTimestampNoPreviousTimestamp(t *testing.T) {
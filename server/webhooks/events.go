@@ -0,0 +1,138 @@
+package webhooks
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/docker/go/canonical/json"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+)
+
+// The actions an event may describe
+const (
+	ActionPublish = "publish"
+	ActionDelete  = "delete"
+)
+
+// Event is the JSON body POSTed to webhook endpoints
+type Event struct {
+	Action    string        `json:"action"`
+	GUN       string        `json:"gun"`
+	Timestamp time.Time     `json:"timestamp"`
+	Roles     []RoleVersion `json:"roles,omitempty"`
+	// Added contains the targets that were added, or whose content changed
+	Added   []TargetPath `json:"added,omitempty"`
+	Removed []TargetPath `json:"removed,omitempty"`
+}
+
+// RoleVersion is the new version of a role that was published
+type RoleVersion struct {
+	Role    string `json:"role"`
+	Version int    `json:"version"`
+}
+
+// TargetPath is the path of a target in a targets role
+type TargetPath struct {
+	Role string `json:"role"`
+	Path string `json:"path"`
+}
+
+// NewDeleteEvent returns the event for the deletion of all of a GUN's data
+func NewDeleteEvent(gun string) Event {
+	return Event{Action: ActionDelete, GUN: gun, Timestamp: time.Now().UTC()}
+}
+
+// NewPublishEvent returns the event for a set of updates to a GUN, comparing
+// the targets in any updated targets roles to the targets they had previously,
+// as returned by PreviousTargets
+func NewPublishEvent(gun string, updates []storage.MetaUpdate, previous map[string]data.Files) (Event, error) {
+	event := Event{Action: ActionPublish, GUN: gun, Timestamp: time.Now().UTC()}
+	for _, update := range updates {
+		event.Roles = append(event.Roles, RoleVersion{Role: update.Role, Version: update.Version})
+		if !isTargetsRole(update.Role) {
+			continue
+		}
+		current, err := parseTargets(update.Data)
+		if err != nil {
+			return Event{}, err
+		}
+		added, removed := diffTargets(previous[update.Role], current)
+		for _, path := range added {
+			event.Added = append(event.Added, TargetPath{Role: update.Role, Path: path})
+		}
+		for _, path := range removed {
+			event.Removed = append(event.Removed, TargetPath{Role: update.Role, Path: path})
+		}
+	}
+	return event, nil
+}
+
+// PreviousTargets returns the current targets of every targets role that is
+// about to be updated, so they can be compared to the new targets once the
+// update succeeds
+func PreviousTargets(store storage.MetaStore, gun string, updates []storage.MetaUpdate) (map[string]data.Files, error) {
+	previous := make(map[string]data.Files)
+	for _, update := range updates {
+		if !isTargetsRole(update.Role) {
+			continue
+		}
+		_, currentJSON, err := store.GetCurrent(gun, update.Role)
+		if _, ok := err.(storage.ErrNotFound); ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files, err := parseTargets(currentJSON)
+		if err != nil {
+			return nil, err
+		}
+		previous[update.Role] = files
+	}
+	return previous, nil
+}
+
+func isTargetsRole(role string) bool {
+	return role == data.CanonicalTargetsRole || data.IsDelegation(role)
+}
+
+func parseTargets(targetsJSON []byte) (data.Files, error) {
+	targets := &data.SignedTargets{}
+	if err := json.Unmarshal(targetsJSON, targets); err != nil {
+		return nil, err
+	}
+	return targets.Signed.Targets, nil
+}
+
+// diffTargets returns the sorted paths of the targets that are new or changed,
+// and that were removed
+func diffTargets(previous, current data.Files) (added, removed []string) {
+	for path, meta := range current {
+		if old, ok := previous[path]; !ok || !sameFile(old, meta) {
+			added = append(added, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func sameFile(a, b data.FileMeta) bool {
+	if a.Length != b.Length || len(a.Hashes) != len(b.Hashes) {
+		return false
+	}
+	for algorithm, hash := range a.Hashes {
+		if !bytes.Equal(hash, b.Hashes[algorithm]) {
+			return false
+		}
+	}
+	return true
+}
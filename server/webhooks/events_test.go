@@ -0,0 +1,86 @@
+package webhooks
+
+import (
+	"testing"
+
+	"github.com/docker/go/canonical/json"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docker/notary/server/storage"
+	"github.com/docker/notary/tuf/data"
+)
+
+func targetsJSON(t *testing.T, version int, files data.Files) []byte {
+	targets := data.SignedTargets{Signed: data.Targets{
+		SignedCommon: data.SignedCommon{Type: data.TUFTypes[data.CanonicalTargetsRole], Version: version},
+		Targets:      files,
+	}}
+	b, err := json.Marshal(targets)
+	require.NoError(t, err)
+	return b
+}
+
+func fileMeta(content string) data.FileMeta {
+	return data.FileMeta{Length: int64(len(content)), Hashes: data.Hashes{"sha256": []byte(content)}}
+}
+
+// The added, changed, and removed targets of every targets role are included
+// in a publish event, along with the new version of every role
+func TestNewPublishEvent(t *testing.T) {
+	previous := map[string]data.Files{
+		data.CanonicalTargetsRole: {
+			"unchanged": fileMeta("1"),
+			"changed":   fileMeta("2"),
+			"removed":   fileMeta("3"),
+		},
+	}
+	updates := []storage.MetaUpdate{
+		{Role: data.CanonicalTargetsRole, Version: 2, Data: targetsJSON(t, 2, data.Files{
+			"unchanged": fileMeta("1"),
+			"changed":   fileMeta("22"),
+			"added":     fileMeta("4"),
+		})},
+		{Role: "targets/new", Version: 1, Data: targetsJSON(t, 1, data.Files{"delegated": fileMeta("5")})},
+		{Role: data.CanonicalSnapshotRole, Version: 3, Data: []byte("not parsed")},
+	}
+
+	event, err := NewPublishEvent("gun", updates, previous)
+	require.NoError(t, err)
+	require.Equal(t, ActionPublish, event.Action)
+	require.Equal(t, "gun", event.GUN)
+	require.False(t, event.Timestamp.IsZero())
+	require.Equal(t, []RoleVersion{
+		{Role: data.CanonicalTargetsRole, Version: 2},
+		{Role: "targets/new", Version: 1},
+		{Role: data.CanonicalSnapshotRole, Version: 3},
+	}, event.Roles)
+	require.Equal(t, []TargetPath{
+		{Role: data.CanonicalTargetsRole, Path: "added"},
+		{Role: data.CanonicalTargetsRole, Path: "changed"},
+		{Role: "targets/new", Path: "delegated"},
+	}, event.Added)
+	require.Equal(t, []TargetPath{{Role: data.CanonicalTargetsRole, Path: "removed"}}, event.Removed)
+
+	_, err = NewPublishEvent("gun", []storage.MetaUpdate{
+		{Role: data.CanonicalTargetsRole, Version: 1, Data: []byte("invalid")},
+	}, nil)
+	require.Error(t, err)
+}
+
+// The previous targets are only read for the targets roles being updated, and
+// roles that don't exist yet are skipped
+func TestPreviousTargets(t *testing.T) {
+	store := storage.NewMemStorage()
+	files := data.Files{"existing": fileMeta("1")}
+	require.NoError(t, store.UpdateCurrent("gun", storage.MetaUpdate{
+		Role: data.CanonicalTargetsRole, Version: 1, Data: targetsJSON(t, 1, files)}))
+
+	previous, err := PreviousTargets(store, "gun", []storage.MetaUpdate{
+		{Role: data.CanonicalTargetsRole, Version: 2},
+		{Role: "targets/new", Version: 1},
+		{Role: data.CanonicalSnapshotRole, Version: 2},
+	})
+	require.NoError(t, err)
+	require.Len(t, previous, 1)
+	require.Equal(t, files, previous[data.CanonicalTargetsRole])
+}
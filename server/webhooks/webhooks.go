@@ -0,0 +1,158 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// SignatureHeader is the header containing the HMAC-SHA256 of the body of
+	// each webhook request, keyed with the endpoint's secret
+	SignatureHeader = "X-Notary-Signature"
+	// EventHeader is the header containing the action of the event being
+	// delivered
+	EventHeader = "X-Notary-Event"
+
+	signaturePrefix = "sha256="
+
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultTimeout     = 10 * time.Second
+)
+
+// Endpoint is a URL that events are POSTed to, and the secret shared with it
+// which is used to sign those events
+type Endpoint struct {
+	URL    string
+	Secret string
+}
+
+// Config configures a Dispatcher.  Zero values are replaced by defaults.
+type Config struct {
+	Endpoints []Endpoint
+	// MaxAttempts is the number of times delivery of an event to an
+	// endpoint is attempted before it is given up on
+	MaxAttempts int
+	// Backoff is how long to wait before the first retry - it doubles after
+	// every failed attempt
+	Backoff time.Duration
+	// Timeout is the timeout for a single delivery attempt
+	Timeout time.Duration
+}
+
+// Dispatcher delivers events to every configured endpoint in the background,
+// retrying failed deliveries with exponential backoff
+type Dispatcher struct {
+	endpoints   []Endpoint
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+	wg          sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher for the given configuration
+func NewDispatcher(config Config) *Dispatcher {
+	d := &Dispatcher{
+		endpoints:   config.Endpoints,
+		maxAttempts: config.MaxAttempts,
+		backoff:     config.Backoff,
+		client:      &http.Client{Timeout: config.Timeout},
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = defaultMaxAttempts
+	}
+	if d.backoff <= 0 {
+		d.backoff = defaultBackoff
+	}
+	if d.client.Timeout <= 0 {
+		d.client.Timeout = defaultTimeout
+	}
+	return d
+}
+
+// Notify delivers the event to every endpoint asynchronously
+func (d *Dispatcher) Notify(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("unable to serialize %s event for %s: %v", event.Action, event.GUN, err)
+		return
+	}
+	for _, endpoint := range d.endpoints {
+		d.wg.Add(1)
+		go func(endpoint Endpoint) {
+			defer d.wg.Done()
+			d.deliver(endpoint, event, body)
+		}(endpoint)
+	}
+}
+
+// Wait blocks until every event passed to Notify so far has either been
+// delivered or given up on
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(endpoint Endpoint, event Event, body []byte) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		err := d.post(endpoint, event, body)
+		if err == nil {
+			return
+		}
+		if attempt >= d.maxAttempts {
+			logrus.Errorf("giving up delivering %s event for %s to %s after %d attempts: %v",
+				event.Action, event.GUN, endpoint.URL, attempt, err)
+			return
+		}
+		logrus.Warnf("failed to deliver %s event for %s to %s, retrying in %s: %v",
+			event.Action, event.GUN, endpoint.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (d *Dispatcher) post(endpoint Endpoint, event Event, body []byte) error {
+	req, err := http.NewRequest("POST", endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Action)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the value of the signature header for a webhook request body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the value of the signature header of a webhook request against
+// its body, and can be used by receivers to authenticate events
+func Verify(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is a webhook endpoint that records the events it receives, and
+// fails the first `failures` requests
+type receiver struct {
+	secret   string
+	failures int
+
+	lock     sync.Mutex
+	attempts int
+	events   []Event
+	valid    []bool
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.lock.Lock()
+	defer rcv.lock.Unlock()
+	rcv.attempts++
+	if rcv.attempts <= rcv.failures {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rcv.events = append(rcv.events, event)
+	rcv.valid = append(rcv.valid,
+		Verify(rcv.secret, body, r.Header.Get(SignatureHeader)) && r.Header.Get(EventHeader) == event.Action)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"action": "publish"}`)
+	signature := Sign("secret", body)
+	require.True(t, Verify("secret", body, signature))
+	require.False(t, Verify("other secret", body, signature))
+	require.False(t, Verify("secret", []byte(`{"action": "delete"}`), signature))
+	require.False(t, Verify("secret", body, signature[len(signaturePrefix):]))
+}
+
+// Events are delivered to every endpoint, signed with that endpoint's secret
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	receivers := []*receiver{{secret: "secret1"}, {secret: "secret2"}}
+	conf := Config{}
+	for _, rcv := range receivers {
+		server := httptest.NewServer(rcv)
+		defer server.Close()
+		conf.Endpoints = append(conf.Endpoints, Endpoint{URL: server.URL, Secret: rcv.secret})
+	}
+	d := NewDispatcher(conf)
+
+	d.Notify(NewDeleteEvent("gun"))
+	d.Wait()
+
+	for _, rcv := range receivers {
+		require.Len(t, rcv.events, 1)
+		require.Equal(t, ActionDelete, rcv.events[0].Action)
+		require.Equal(t, "gun", rcv.events[0].GUN)
+		require.Equal(t, []bool{true}, rcv.valid)
+	}
+}
+
+// Failed deliveries are retried until they succeed
+func TestDispatcherRetries(t *testing.T) {
+	rcv := &receiver{secret: "secret", failures: 2}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d := NewDispatcher(Config{
+		Endpoints:   []Endpoint{{URL: server.URL, Secret: rcv.secret}},
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	d.Notify(NewDeleteEvent("gun"))
+	d.Wait()
+
+	require.Equal(t, 3, rcv.attempts)
+	require.Len(t, rcv.events, 1)
+}
+
+// Delivery is given up on after the maximum number of attempts
+func TestDispatcherGivesUp(t *testing.T) {
+	rcv := &receiver{secret: "secret", failures: 10}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d := NewDispatcher(Config{
+		Endpoints:   []Endpoint{{URL: server.URL, Secret: rcv.secret}},
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+	})
+	d.Notify(NewDeleteEvent("gun"))
+	d.Wait()
+
+	require.Equal(t, 3, rcv.attempts)
+	require.Empty(t, rcv.events)
+}
+
+func TestNewDispatcherDefaults(t *testing.T) {
+	d := NewDispatcher(Config{})
+	require.Equal(t, defaultMaxAttempts, d.maxAttempts)
+	require.Equal(t, defaultBackoff, d.backoff)
+	require.Equal(t, defaultTimeout, d.client.Timeout)
+}
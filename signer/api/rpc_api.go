@@ -117,10 +117,32 @@ func (s *KeyManagementServer) CheckHealth(ctx context.Context, v *pb.Void) (*pb.
 
 //Sign signs a message and returns the signature using a private key associate with the KeyID from the SignatureRequest
 func (s *SignerServer) Sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.Signature, error) {
-	tufKey, service, err := FindKeyByID(s.CryptoServices, sr.KeyID)
+	return s.sign(ctx, sr)
+}
+
+//SignMany signs each of a batch of requests, reporting an error in place of
+//the signature for each request that could not be signed
+func (s *SignerServer) SignMany(ctx context.Context, srs *pb.SignatureRequests) (*pb.Signatures, error) {
+	results := make([]*pb.SignatureResult, 0, len(srs.Requests))
+	for _, sr := range srs.Requests {
+		signature, err := s.sign(ctx, sr)
+		if err != nil {
+			results = append(results, &pb.SignatureResult{Error: grpc.ErrorDesc(err)})
+			continue
+		}
+		results = append(results, &pb.SignatureResult{Signature: signature})
+	}
+	return &pb.Signatures{Results: results}, nil
+}
 
+func (s *SignerServer) sign(ctx context.Context, sr *pb.SignatureRequest) (*pb.Signature, error) {
 	logger := ctxu.GetLogger(ctx)
 
+	if sr.KeyID == nil {
+		logger.Error("Sign: no KeyID provided")
+		return nil, grpc.Errorf(codes.InvalidArgument, "no KeyID provided")
+	}
+	tufKey, service, err := FindKeyByID(s.CryptoServices, sr.KeyID)
 	if err != nil {
 		logger.Errorf("Sign: key %s not found", sr.KeyID.ID)
 		return nil, grpc.Errorf(codes.NotFound, "key %s not found", sr.KeyID.ID)
@@ -153,6 +153,49 @@ func TestSignReturnsNotFoundOnNonexistKeys(t *testing.T) {
 	require.Nil(t, ret)
 }
 
+func TestSignManySignsEachRequest(t *testing.T) {
+	var requests []*pb.SignatureRequest
+	var keyIDs []*pb.KeyID
+	for _, algorithm := range []string{data.ED25519Key, data.ECDSAKey} {
+		publicKey, err := kmClient.CreateKey(context.Background(), &pb.Algorithm{Algorithm: algorithm})
+		require.Nil(t, err)
+		keyIDs = append(keyIDs, publicKey.KeyInfo.KeyID)
+		requests = append(requests, &pb.SignatureRequest{Content: []byte(algorithm), KeyID: publicKey.KeyInfo.KeyID})
+	}
+
+	signatures, err := sClient.SignMany(context.Background(), &pb.SignatureRequests{Requests: requests})
+	require.Nil(t, err)
+	require.Len(t, signatures.Results, len(requests))
+	for i, result := range signatures.Results {
+		require.Empty(t, result.Error)
+		require.NotEmpty(t, result.Signature.Content)
+		require.Equal(t, keyIDs[i], result.Signature.KeyInfo.KeyID)
+	}
+}
+
+// A request that can't be signed doesn't stop the rest of the batch from being
+// signed
+func TestSignManyReportsErrorsPerRequest(t *testing.T) {
+	publicKey, err := kmClient.CreateKey(context.Background(), &pb.Algorithm{Algorithm: data.ED25519Key})
+	require.Nil(t, err)
+	fakeID := "c62e6d68851cef1f7e55a9d56e3b0c05f3359f16838cad43600f0554e7d3b54d"
+	requests := []*pb.SignatureRequest{
+		{Content: []byte{0}, KeyID: &pb.KeyID{ID: fakeID}},
+		{Content: []byte{0}, KeyID: publicKey.KeyInfo.KeyID},
+		{Content: []byte{0}},
+	}
+
+	signatures, err := sClient.SignMany(context.Background(), &pb.SignatureRequests{Requests: requests})
+	require.Nil(t, err)
+	require.Len(t, signatures.Results, len(requests))
+	require.Nil(t, signatures.Results[0].Signature)
+	require.Contains(t, signatures.Results[0].Error, "not found")
+	require.Empty(t, signatures.Results[1].Error)
+	require.NotEmpty(t, signatures.Results[1].Signature.Content)
+	require.Nil(t, signatures.Results[2].Signature)
+	require.NotEmpty(t, signatures.Results[2].Error)
+}
+
 func TestHealthChecksForServices(t *testing.T) {
 	sHealthStatus, err := sClient.CheckHealth(context.Background(), void)
 	require.Nil(t, err)
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	pb "github.com/docker/notary/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// breakerThreshold is the number of consecutive times a signer can be
+	// unreachable before requests stop being sent to it
+	breakerThreshold = 3
+	// breakerCooldown is how long requests stop being sent to a signer for,
+	// once it has been unreachable too many times or has failed a health
+	// check, before it is tried again
+	breakerCooldown = 30 * time.Second
+)
+
+// endpoint is one of the signers a failoverClient can send requests to, and
+// the state of its circuit breaker
+type endpoint struct {
+	address    string
+	kmClient   pb.KeyManagementClient
+	sClient    pb.SignerClient
+	clientConn checkableConnectionState
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// available returns whether requests should be sent to the signer: its
+// circuit breaker must be closed, and it must not be known to be disconnected
+func (e *endpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Before(e.openUntil) {
+		return false
+	}
+	if e.clientConn != nil {
+		state := e.clientConn.State()
+		return state == grpc.Idle || state == grpc.Ready
+	}
+	return true
+}
+
+// succeeded closes the circuit breaker
+func (e *endpoint) succeeded() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = 0
+	e.openUntil = time.Time{}
+}
+
+// failed opens the circuit breaker if the signer has now been unreachable
+// too many times in a row
+func (e *endpoint) failed(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures >= breakerThreshold {
+		e.openUntil = now.Add(breakerCooldown)
+	}
+}
+
+// trip opens the circuit breaker straight away
+func (e *endpoint) trip(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures = breakerThreshold
+	e.openUntil = now.Add(breakerCooldown)
+}
+
+// failoverClient implements the KeyManagement and Signer clients by sending
+// each request to one of several signers, which are expected to share the
+// same key storage.  If a signer can't be reached, the request is retried
+// with the next one.  Requests which change the keys are only retried if the
+// signer was unavailable, rather than if the request timed out, so that a key
+// is not created twice.
+type failoverClient struct {
+	endpoints []*endpoint
+	next      uint32
+}
+
+var (
+	_ pb.KeyManagementClient = &failoverClient{}
+	_ pb.SignerClient        = &failoverClient{}
+)
+
+// order returns the endpoints in the order a request should try them: the
+// available endpoints first, starting with a different one each time to spread
+// the load between them, then the unavailable ones as a last resort
+func (f *failoverClient) order() []*endpoint {
+	now := time.Now()
+	start := int(atomic.AddUint32(&f.next, 1))
+	available := make([]*endpoint, 0, len(f.endpoints))
+	var unavailable []*endpoint
+	for i := range f.endpoints {
+		e := f.endpoints[(start+i)%len(f.endpoints)]
+		if e.available(now) {
+			available = append(available, e)
+		} else {
+			unavailable = append(unavailable, e)
+		}
+	}
+	return append(available, unavailable...)
+}
+
+// call calls fn with each endpoint in turn, until one of them succeeds or
+// fails for a reason other than the signer being unreachable.  A request that
+// timed out may still have been carried out by the signer, so it is only
+// retried if it is idempotent, and no request is retried once ctx is done.
+func (f *failoverClient) call(ctx context.Context, idempotent bool, fn func(e *endpoint) error) error {
+	var err error
+	for _, e := range f.order() {
+		err = fn(e)
+		if !unreachable(err) {
+			e.succeeded()
+			return err
+		}
+		logrus.Warnf("notary signer %s is unreachable: %v", e.address, err)
+		e.failed(time.Now())
+		if ctx.Err() != nil || (!idempotent && grpc.Code(err) != codes.Unavailable) {
+			return err
+		}
+	}
+	return err
+}
+
+// unreachable returns whether an error means that a signer could not be
+// reached, in which case another signer may succeed
+func unreachable(err error) bool {
+	switch grpc.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// checkHealth checks the health of every signer, opening the circuit breakers
+// of the unhealthy ones and closing those of the healthy ones.  It only
+// returns an error if every signer is unhealthy.
+func (f *failoverClient) checkHealth(timeout time.Duration) error {
+	var errs []string
+	for _, e := range f.endpoints {
+		if err := checkHealth(e.kmClient, e.clientConn, timeout); err != nil {
+			e.trip(time.Now())
+			errs = append(errs, fmt.Sprintf("%s: %v", e.address, err))
+			continue
+		}
+		e.succeeded()
+	}
+	if len(errs) == len(f.endpoints) {
+		return fmt.Errorf("No trust server is healthy: %s", strings.Join(errs, "; "))
+	}
+	for _, err := range errs {
+		logrus.Error("Trust server is not healthy: ", err)
+	}
+	return nil
+}
+
+// CreateKey creates a key on one of the signers
+func (f *failoverClient) CreateKey(ctx context.Context, in *pb.Algorithm, opts ...grpc.CallOption) (*pb.PublicKey, error) {
+	var out *pb.PublicKey
+	err := f.call(ctx, false, func(e *endpoint) (err error) {
+		out, err = e.kmClient.CreateKey(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
+
+// DeleteKey deletes a key using one of the signers
+func (f *failoverClient) DeleteKey(ctx context.Context, in *pb.KeyID, opts ...grpc.CallOption) (*pb.Void, error) {
+	var out *pb.Void
+	err := f.call(ctx, false, func(e *endpoint) (err error) {
+		out, err = e.kmClient.DeleteKey(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
+
+// GetKeyInfo gets a public key from one of the signers
+func (f *failoverClient) GetKeyInfo(ctx context.Context, in *pb.KeyID, opts ...grpc.CallOption) (*pb.PublicKey, error) {
+	var out *pb.PublicKey
+	err := f.call(ctx, true, func(e *endpoint) (err error) {
+		out, err = e.kmClient.GetKeyInfo(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
+
+// Sign signs with one of the signers
+func (f *failoverClient) Sign(ctx context.Context, in *pb.SignatureRequest, opts ...grpc.CallOption) (*pb.Signature, error) {
+	var out *pb.Signature
+	err := f.call(ctx, true, func(e *endpoint) (err error) {
+		out, err = e.sClient.Sign(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
+
+// SignMany signs a batch of requests with one of the signers
+func (f *failoverClient) SignMany(ctx context.Context, in *pb.SignatureRequests, opts ...grpc.CallOption) (*pb.Signatures, error) {
+	var out *pb.Signatures
+	err := f.call(ctx, true, func(e *endpoint) (err error) {
+		out, err = e.sClient.SignMany(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
+
+// CheckHealth returns the health of one of the signers
+func (f *failoverClient) CheckHealth(ctx context.Context, in *pb.Void, opts ...grpc.CallOption) (*pb.HealthStatus, error) {
+	var out *pb.HealthStatus
+	err := f.call(ctx, true, func(e *endpoint) (err error) {
+		out, err = e.kmClient.CheckHealth(ctx, in, opts...)
+		return err
+	})
+	return out, err
+}
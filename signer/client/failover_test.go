@@ -0,0 +1,184 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/docker/notary/proto"
+	"github.com/docker/notary/trustmanager"
+	"github.com/docker/notary/tuf/data"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// A signer that can't be reached, which counts how many requests were sent to
+// it.  If timeout is set, requests time out rather than being unavailable.
+type UnreachableClient struct {
+	calls   int
+	timeout bool
+}
+
+func (c *UnreachableClient) unreachable() error {
+	c.calls++
+	if c.timeout {
+		return grpc.Errorf(codes.DeadlineExceeded, "timed out")
+	}
+	return grpc.Errorf(codes.Unavailable, "unreachable")
+}
+
+func (c *UnreachableClient) CreateKey(context.Context, *pb.Algorithm, ...grpc.CallOption) (*pb.PublicKey, error) {
+	return nil, c.unreachable()
+}
+
+func (c *UnreachableClient) DeleteKey(context.Context, *pb.KeyID, ...grpc.CallOption) (*pb.Void, error) {
+	return nil, c.unreachable()
+}
+
+func (c *UnreachableClient) GetKeyInfo(context.Context, *pb.KeyID, ...grpc.CallOption) (*pb.PublicKey, error) {
+	return nil, c.unreachable()
+}
+
+func (c *UnreachableClient) Sign(context.Context, *pb.SignatureRequest, ...grpc.CallOption) (*pb.Signature, error) {
+	return nil, c.unreachable()
+}
+
+func (c *UnreachableClient) SignMany(context.Context, *pb.SignatureRequests, ...grpc.CallOption) (*pb.Signatures, error) {
+	return nil, c.unreachable()
+}
+
+func (c *UnreachableClient) CheckHealth(context.Context, *pb.Void, ...grpc.CallOption) (*pb.HealthStatus, error) {
+	return nil, c.unreachable()
+}
+
+// A reachable signer, which counts how many key lookups were sent to it
+type CountingClient struct {
+	*StubClientFromServers
+	keyInfoCalls int
+}
+
+func (c *CountingClient) GetKeyInfo(ctx context.Context, keyID *pb.KeyID,
+	opts ...grpc.CallOption) (*pb.PublicKey, error) {
+	c.keyInfoCalls++
+	return c.StubClientFromServers.GetKeyInfo(ctx, keyID, opts...)
+}
+
+// returns a NotarySigner that fails over between the given signer clients
+func setUpFailoverSigner(clients ...interface {
+	pb.KeyManagementClient
+	pb.SignerClient
+}) (NotarySigner, []*endpoint) {
+	failover := &failoverClient{}
+	for _, client := range clients {
+		failover.endpoints = append(failover.endpoints, &endpoint{
+			address:    "signer",
+			kmClient:   client,
+			sClient:    client,
+			clientConn: StubGRPCConnection{},
+		})
+	}
+	return NotarySigner{kmClient: failover, sClient: failover, failover: failover}, failover.endpoints
+}
+
+// returns a signer client backed by a signer server with a memory key store
+func reachableClient(t *testing.T) *StubClientFromServers {
+	return setUpSigner(t, trustmanager.NewKeyMemoryStore(ret)).sClient.(*StubClientFromServers)
+}
+
+// Requests sent to a signer that can't be reached are retried with the next
+// one, and once it has been unreachable too many times requests stop being
+// sent to it
+func TestFailoverSkipsUnreachableSigner(t *testing.T) {
+	down := &UnreachableClient{}
+	signer, endpoints := setUpFailoverSigner(down, reachableClient(t))
+
+	for i := 0; i < 2*breakerThreshold; i++ {
+		pubKey, err := signer.Create(data.CanonicalTimestampRole, "gun", data.ECDSAKey)
+		require.NoError(t, err)
+
+		privKey, _, err := signer.GetPrivateKey(pubKey.ID())
+		require.NoError(t, err)
+		require.NotNil(t, privKey)
+		_, err = privKey.Sign(nil, []byte("message"), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, breakerThreshold, down.calls)
+	require.False(t, endpoints[0].available(time.Now()))
+	require.True(t, endpoints[0].available(time.Now().Add(breakerCooldown)))
+	require.True(t, endpoints[1].available(time.Now()))
+}
+
+// If no signer can be reached, the request fails after trying each of them
+func TestFailoverAllSignersUnreachable(t *testing.T) {
+	down1, down2 := &UnreachableClient{}, &UnreachableClient{}
+	signer, _ := setUpFailoverSigner(down1, down2)
+
+	_, err := signer.Create(data.CanonicalTimestampRole, "gun", data.ECDSAKey)
+	require.Error(t, err)
+	require.Equal(t, codes.Unavailable, grpc.Code(err))
+	require.Equal(t, 1, down1.calls)
+	require.Equal(t, 1, down2.calls)
+}
+
+// Errors other than a signer being unreachable are not retried
+func TestFailoverDoesNotRetryOtherErrors(t *testing.T) {
+	client := reachableClient(t)
+	first, second := &CountingClient{StubClientFromServers: client}, &CountingClient{StubClientFromServers: client}
+	signer, _ := setUpFailoverSigner(first, second)
+
+	require.Nil(t, signer.GetKey("bogus key ID"))
+	require.Equal(t, 1, first.keyInfoCalls+second.keyInfoCalls)
+}
+
+// A request that times out is only retried with the next signer if it is
+// idempotent, since the signer may have carried it out anyway
+func TestFailoverTimeoutOnlyRetriesIdempotentRequests(t *testing.T) {
+	slow := &UnreachableClient{timeout: true}
+	signer, _ := setUpFailoverSigner(slow, reachableClient(t))
+
+	// the signers are tried in turn, so one of the keys is created by the slow signer
+	var created []data.PublicKey
+	for i := 0; i < 2; i++ {
+		pubKey, err := signer.Create(data.CanonicalTimestampRole, "gun", data.ECDSAKey)
+		if err != nil {
+			require.Equal(t, codes.DeadlineExceeded, grpc.Code(err))
+			continue
+		}
+		created = append(created, pubKey)
+	}
+	require.Len(t, created, 1)
+	require.Equal(t, 1, slow.calls)
+
+	for i := 0; i < 2; i++ {
+		require.NotNil(t, signer.GetKey(created[0].ID()))
+	}
+	require.Equal(t, 2, slow.calls)
+}
+
+// No request is retried once its context is done
+func TestFailoverDoesNotRetryDoneContext(t *testing.T) {
+	slow1, slow2 := &UnreachableClient{timeout: true}, &UnreachableClient{timeout: true}
+	_, endpoints := setUpFailoverSigner(slow1, slow2)
+	failover := &failoverClient{endpoints: endpoints}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := failover.GetKeyInfo(ctx, &pb.KeyID{ID: "key ID"})
+	require.Equal(t, codes.DeadlineExceeded, grpc.Code(err))
+	require.Equal(t, 1, slow1.calls+slow2.calls)
+}
+
+// The health check only fails if every signer is unhealthy, and stops requests
+// being sent to the unhealthy ones until they pass a health check again
+func TestFailoverCheckHealth(t *testing.T) {
+	signer, endpoints := setUpFailoverSigner(&UnreachableClient{}, reachableClient(t))
+
+	endpoints[1].trip(time.Now())
+	require.NoError(t, signer.CheckHealth(time.Second))
+	require.False(t, endpoints[0].available(time.Now()))
+	require.True(t, endpoints[1].available(time.Now()))
+
+	signer, _ = setUpFailoverSigner(&UnreachableClient{}, &UnreachableClient{})
+	require.Error(t, signer.CheckHealth(time.Second))
+}
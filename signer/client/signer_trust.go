@@ -15,6 +15,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	pb "github.com/docker/notary/proto"
 	"github.com/docker/notary/tuf/data"
+	"github.com/docker/notary/tuf/signed"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -105,19 +106,17 @@ type NotarySigner struct {
 	kmClient   pb.KeyManagementClient
 	sClient    pb.SignerClient
 	clientConn checkableConnectionState
+	// failover is set, and is both the kmClient and the sClient, if there
+	// are several signers to send requests to
+	failover *failoverClient
 }
 
 // NewNotarySigner is a convenience method that returns NotarySigner
 func NewNotarySigner(hostname string, port string, tlsConfig *tls.Config) *NotarySigner {
-	var opts []grpc.DialOption
-	netAddr := net.JoinHostPort(hostname, port)
-	creds := credentials.NewTLS(tlsConfig)
-	opts = append(opts, grpc.WithTransportCredentials(creds))
-	conn, err := grpc.Dial(netAddr, opts...)
+	return newNotarySigner(dial(net.JoinHostPort(hostname, port), tlsConfig))
+}
 
-	if err != nil {
-		logrus.Fatal("fail to dial: ", err)
-	}
+func newNotarySigner(conn *grpc.ClientConn) *NotarySigner {
 	kmClient := pb.NewKeyManagementClient(conn)
 	sClient := pb.NewSignerClient(conn)
 	return &NotarySigner{
@@ -127,6 +126,45 @@ func NewNotarySigner(hostname string, port string, tlsConfig *tls.Config) *Notar
 	}
 }
 
+// NewFailoverNotarySigner returns a NotarySigner that sends each request to
+// one of several signers, given as "host:port" addresses, which share the same
+// key storage.  Requests are spread across the signers that are reachable and
+// passed their last health check, and are retried with another signer if the
+// one they were sent to can't be reached.
+func NewFailoverNotarySigner(addresses []string, tlsConfig *tls.Config) *NotarySigner {
+	if len(addresses) == 1 {
+		return newNotarySigner(dial(addresses[0], tlsConfig))
+	}
+
+	failover := &failoverClient{}
+	for _, address := range addresses {
+		conn := dial(address, tlsConfig)
+		failover.endpoints = append(failover.endpoints, &endpoint{
+			address:    address,
+			kmClient:   pb.NewKeyManagementClient(conn),
+			sClient:    pb.NewSignerClient(conn),
+			clientConn: conn,
+		})
+	}
+	return &NotarySigner{
+		kmClient: failover,
+		sClient:  failover,
+		failover: failover,
+	}
+}
+
+func dial(address string, tlsConfig *tls.Config) *grpc.ClientConn {
+	var opts []grpc.DialOption
+	creds := credentials.NewTLS(tlsConfig)
+	opts = append(opts, grpc.WithTransportCredentials(creds))
+	conn, err := grpc.Dial(address, opts...)
+
+	if err != nil {
+		logrus.Fatal("fail to dial: ", err)
+	}
+	return conn
+}
+
 // Create creates a remote key and returns the PublicKey associated with the remote private key
 func (trust *NotarySigner) Create(role, gun, algorithm string) (data.PublicKey, error) {
 	publicKey, err := trust.kmClient.CreateKey(context.Background(), &pb.Algorithm{Algorithm: algorithm})
@@ -166,6 +204,63 @@ func (trust *NotarySigner) GetPrivateKey(keyid string) (data.PrivateKey, string,
 	return NewRemotePrivateKey(pubKey, trust.sClient), "", nil
 }
 
+// SignMany asks the signer for a signature for each of the requests in a
+// single round-trip, returning a signature or an error for each request.  If
+// there is only one request, or the signer is too old to sign in batches, each
+// request is signed in turn.  Since signed.Sign makes one request per key, only
+// roles with several keys save round-trips; the server signs the timestamp
+// and snapshot of each GUN with one key apiece, so these are still signed one
+// request at a time.
+func (trust *NotarySigner) SignMany(requests []signed.SignatureRequest) ([][]byte, []error) {
+	pbRequests := make([]*pb.SignatureRequest, 0, len(requests))
+	for _, request := range requests {
+		pbRequests = append(pbRequests, &pb.SignatureRequest{
+			KeyID:   &pb.KeyID{ID: request.KeyID},
+			Content: request.Content,
+		})
+	}
+	if len(pbRequests) > 1 {
+		signatures, err := trust.sClient.SignMany(context.Background(), &pb.SignatureRequests{Requests: pbRequests})
+		if grpc.Code(err) != codes.Unimplemented {
+			return batchResults(signatures, err, len(requests))
+		}
+	}
+
+	sigs := make([][]byte, len(requests))
+	errs := make([]error, len(requests))
+	for i, sr := range pbRequests {
+		var sig *pb.Signature
+		if sig, errs[i] = trust.sClient.Sign(context.Background(), sr); errs[i] == nil {
+			sigs[i] = sig.Content
+		}
+	}
+	return sigs, errs
+}
+
+// batchResults splits the response to a batch of n signature requests into a
+// signature or an error for each request
+func batchResults(signatures *pb.Signatures, err error, n int) ([][]byte, []error) {
+	sigs := make([][]byte, n)
+	errs := make([]error, n)
+	if err == nil && len(signatures.Results) != n {
+		err = fmt.Errorf("expected %d signatures from the signer, got %d",
+			n, len(signatures.Results))
+	}
+	for i := 0; i < n; i++ {
+		switch {
+		case err != nil:
+			errs[i] = err
+		case signatures.Results[i].Error != "":
+			errs[i] = errors.New(signatures.Results[i].Error)
+		case signatures.Results[i].Signature == nil:
+			errs[i] = errors.New("signer returned no signature")
+		default:
+			sigs[i] = signatures.Results[i].Signature.Content
+		}
+	}
+	return sigs, errs
+}
+
 // ListKeys not supported for NotarySigner
 func (trust *NotarySigner) ListKeys(role string) []string {
 	return []string{}
@@ -177,17 +272,26 @@ func (trust *NotarySigner) ListAllKeys() map[string]string {
 }
 
 // CheckHealth checks the health of one of the clients, since both clients run
-// from the same GRPC server.
+// from the same GRPC server.  If there are several signers, it checks the
+// health of each of them, and only fails if none of them are healthy.
 func (trust *NotarySigner) CheckHealth(timeout time.Duration) error {
+	if trust.failover != nil {
+		return trust.failover.checkHealth(timeout)
+	}
+	return checkHealth(trust.kmClient, trust.clientConn, timeout)
+}
+
+func checkHealth(kmClient pb.KeyManagementClient, clientConn checkableConnectionState,
+	timeout time.Duration) error {
 
 	// Do not bother starting checking at all if the connection is broken.
-	if trust.clientConn.State() != grpc.Idle &&
-		trust.clientConn.State() != grpc.Ready {
+	if clientConn.State() != grpc.Idle &&
+		clientConn.State() != grpc.Ready {
 		return fmt.Errorf("Not currently connected to trust server.")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	status, err := trust.kmClient.CheckHealth(ctx, &pb.Void{})
+	status, err := kmClient.CheckHealth(ctx, &pb.Void{})
 	defer cancel()
 	if err == nil && len(status.Status) > 0 {
 		var stats string
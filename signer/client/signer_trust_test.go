@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 
+	"github.com/docker/go/canonical/json"
 	"github.com/docker/notary/cryptoservice"
 	"github.com/docker/notary/passphrase"
 	pb "github.com/docker/notary/proto"
@@ -54,12 +55,12 @@ func stubHealthFunction(t *testing.T, status map[string]string, err error) rpcHe
 
 func makeSigner(kmFunc rpcHealthCheck, conn StubGRPCConnection) NotarySigner {
 	return NotarySigner{
-		StubKeyManagementClient{
+		kmClient: StubKeyManagementClient{
 			pb.NewKeyManagementClient(nil),
 			kmFunc,
 		},
-		pb.NewSignerClient(nil),
-		conn,
+		sClient:    pb.NewSignerClient(nil),
+		clientConn: conn,
 	}
 }
 
@@ -175,6 +176,11 @@ func (c *StubClientFromServers) Sign(ctx context.Context,
 	return c.SignerServer.Sign(ctx, sr)
 }
 
+func (c *StubClientFromServers) SignMany(ctx context.Context,
+	srs *pb.SignatureRequests, _ ...grpc.CallOption) (*pb.Signatures, error) {
+	return c.SignerServer.SignMany(ctx, srs)
+}
+
 func (c *StubClientFromServers) CheckHealth(ctx context.Context, v *pb.Void,
 	_ ...grpc.CallOption) (*pb.HealthStatus, error) {
 	return c.KeyManagementServer.CheckHealth(ctx, v)
@@ -199,3 +205,68 @@ func setUpSigner(t *testing.T, store trustmanager.KeyStore) NotarySigner {
 
 	return NotarySigner{kmClient: &client, sClient: &client}
 }
+
+// A signer client that doesn't implement SignMany, like an older signer
+type StubClientWithoutSignMany struct {
+	*StubClientFromServers
+}
+
+func (c StubClientWithoutSignMany) SignMany(ctx context.Context,
+	srs *pb.SignatureRequests, _ ...grpc.CallOption) (*pb.Signatures, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "unknown method SignMany")
+}
+
+func TestSignMany(t *testing.T) {
+	signer := setUpSigner(t, trustmanager.NewKeyMemoryStore(ret))
+	var keys []data.PublicKey
+	for _, algorithm := range []string{data.ECDSAKey, data.ED25519Key} {
+		key, err := signer.Create(data.CanonicalTimestampRole, "gun", algorithm)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	msg := []byte("message!")
+	requests := []signed.SignatureRequest{
+		{KeyID: keys[0].ID(), Content: msg},
+		{KeyID: "bogus key ID", Content: msg},
+		{KeyID: keys[1].ID(), Content: []byte("another message!")},
+	}
+
+	// older signers are asked for each signature in turn
+	withoutSignMany := signer
+	withoutSignMany.sClient = StubClientWithoutSignMany{signer.sClient.(*StubClientFromServers)}
+
+	for _, s := range []NotarySigner{signer, withoutSignMany} {
+		sigs, errs := s.SignMany(requests)
+		require.Len(t, sigs, 3)
+		require.Len(t, errs, 3)
+
+		require.NoError(t, errs[0])
+		require.NoError(t, signed.Verifiers[data.ECDSASignature].Verify(keys[0], sigs[0], msg))
+		require.Error(t, errs[1])
+		require.Nil(t, sigs[1])
+		require.NoError(t, errs[2])
+		require.NoError(t, signed.Verifiers[data.EDDSASignature].Verify(keys[1], sigs[2], requests[2].Content))
+	}
+}
+
+// Signing a role with several remote keys asks the signer for all the
+// signatures at once
+func TestSignWithManyRemoteKeys(t *testing.T) {
+	signer := setUpSigner(t, trustmanager.NewKeyMemoryStore(ret))
+	var keys []data.PublicKey
+	for i := 0; i < 2; i++ {
+		key, err := signer.Create(data.CanonicalTimestampRole, "gun", data.ECDSAKey)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	raw := json.RawMessage("{}")
+	s := &data.Signed{Signed: &raw}
+	require.NoError(t, signed.Sign(&signer, s, keys...))
+	require.Len(t, s.Signatures, 2)
+	require.NoError(t, signed.VerifySignatures(s, data.BaseRole{
+		Name:      data.CanonicalTimestampRole,
+		Keys:      map[string]data.PublicKey{keys[0].ID(): keys[0], keys[1].ID(): keys[1]},
+		Threshold: 2,
+	}))
+}
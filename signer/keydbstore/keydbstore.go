@@ -53,6 +53,11 @@ func NewKeyDBStore(passphraseRetriever passphrase.Retriever, defaultPassAlias st
 	if err != nil {
 		return nil, err
 	}
+	if dbDialect == "sqlite3" {
+		// SQLite only allows one writer at a time, so share a single
+		// connection rather than failing when the database is locked
+		db.DB().SetMaxOpenConns(1)
+	}
 
 	return &KeyDBStore{
 		db:               db,
@@ -61,6 +66,16 @@ func NewKeyDBStore(passphraseRetriever passphrase.Retriever, defaultPassAlias st
 		cachedKeys:       cachedKeys}, nil
 }
 
+// CreateTable creates the table for the private keys if it doesn't already
+// exist.  This is intended for databases like SQLite that are not set up with
+// the migrations.
+func (s *KeyDBStore) CreateTable() error {
+	if s.db.HasTable(&GormPrivateKey{}) {
+		return nil
+	}
+	return s.db.CreateTable(&GormPrivateKey{}).Error
+}
+
 // Name returns a user friendly name for the storage location
 func (s *KeyDBStore) Name() string {
 	return "database"
@@ -182,6 +197,78 @@ func (s *KeyDBStore) RotateKeyPassphrase(keyID, newPassphraseAlias string) error
 		return trustmanager.ErrKeyNotFound{KeyID: keyID}
 	}
 
+	if err := s.reencrypt(&dbPrivateKey, newPassphraseAlias); err != nil {
+		return err
+	}
+	s.db.Save(dbPrivateKey)
+
+	return nil
+}
+
+// RotatePassphraseAlias re-encrypts every private key encrypted with the
+// passphrase of one alias with the passphrase of another.  The keys are
+// re-encrypted batchSize at a time, each batch in its own transaction, and
+// progress, if not nil, is called with the number of keys re-encrypted so far
+// and the total after each batch.  Since only the keys still using the old
+// alias are re-encrypted, an interrupted rotation can be resumed by running it
+// again.  It returns the number of keys that were re-encrypted.
+func (s *KeyDBStore) RotatePassphraseAlias(fromAlias, toAlias string, batchSize int,
+	progress func(rotated, total int)) (int, error) {
+
+	if fromAlias == toAlias {
+		return 0, fmt.Errorf("cannot rotate passphrase alias %s to itself", fromAlias)
+	}
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batch size must be positive")
+	}
+
+	var total int
+	if err := s.db.Model(&GormPrivateKey{}).Where(
+		&GormPrivateKey{PassphraseAlias: fromAlias}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for {
+		var batch []GormPrivateKey
+		if err := s.db.Where(&GormPrivateKey{PassphraseAlias: fromAlias}).Order("id").Limit(
+			batchSize).Find(&batch).Error; err != nil {
+			return rotated, err
+		}
+		if len(batch) == 0 {
+			return rotated, nil
+		}
+
+		for i := range batch {
+			if err := s.reencrypt(&batch[i], toAlias); err != nil {
+				return rotated, fmt.Errorf("failed to re-encrypt key %s: %v", batch[i].KeyID, err)
+			}
+		}
+
+		tx := s.db.Begin()
+		if tx.Error != nil {
+			return rotated, tx.Error
+		}
+		for _, key := range batch {
+			if err := tx.Save(&key).Error; err != nil {
+				tx.Rollback()
+				return rotated, err
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return rotated, err
+		}
+
+		rotated += len(batch)
+		if progress != nil {
+			progress(rotated, total)
+		}
+	}
+}
+
+// reencrypt decrypts a private key with the passphrase of its alias, and
+// encrypts it again with the passphrase of the new alias
+func (s *KeyDBStore) reencrypt(dbPrivateKey *GormPrivateKey, newPassphraseAlias string) error {
 	// Get the current passphrase to use for this key
 	passphrase, _, err := s.retriever(dbPrivateKey.KeyID, dbPrivateKey.PassphraseAlias, false, 1)
 	if err != nil {
@@ -209,8 +296,8 @@ func (s *KeyDBStore) RotateKeyPassphrase(keyID, newPassphraseAlias string) error
 	// Update the database object
 	dbPrivateKey.Private = newEncryptedKey
 	dbPrivateKey.PassphraseAlias = newPassphraseAlias
-	s.db.Save(dbPrivateKey)
-
+	dbPrivateKey.EncryptionAlg = EncryptionAlg
+	dbPrivateKey.KeywrapAlg = KeywrapAlg
 	return nil
 }
 
@@ -221,6 +308,9 @@ func (s *KeyDBStore) ExportKey(keyID string) ([]byte, error) {
 
 // HealthCheck verifies that DB exists and is query-able
 func (s *KeyDBStore) HealthCheck() error {
+	if err := s.db.DB().Ping(); err != nil {
+		return err
+	}
 	dbPrivateKey := GormPrivateKey{}
 	tableOk := s.db.HasTable(&dbPrivateKey)
 	switch {
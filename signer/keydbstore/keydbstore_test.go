@@ -10,6 +10,7 @@ import (
 
 	"github.com/docker/notary/trustmanager"
 	"github.com/docker/notary/tuf/data"
+	jose "github.com/dvsekhvalnov/jose2go"
 	"github.com/jinzhu/gorm"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
@@ -186,6 +187,117 @@ func TestKeyRotation(t *testing.T) {
 	require.Error(t, err, "there should be no password for alias_3")
 }
 
+// adds keys to a new KeyDBStore using the default alias, and returns their IDs
+func addKeysWithAlias(t *testing.T, dbFilename, alias string, count int) []string {
+	dbStore, err := NewKeyDBStore(anotherRetriever, alias, "sqlite3", dbFilename)
+	require.NoError(t, err)
+	defer dbStore.db.Close()
+
+	var keyIDs []string
+	for i := 0; i < count; i++ {
+		testKey, err := trustmanager.GenerateECDSAKey(rand.Reader)
+		require.NoError(t, err)
+		require.NoError(t, dbStore.AddKey(trustmanager.KeyInfo{Role: data.CanonicalTimestampRole}, testKey))
+		keyIDs = append(keyIDs, testKey.ID())
+	}
+	return keyIDs
+}
+
+// asserts that the keys are encrypted with the passphrase of the alias
+func requireKeysUseAlias(t *testing.T, dbStore *KeyDBStore, alias string, keyIDs []string) {
+	for _, keyID := range keyIDs {
+		dbPrivateKey := GormPrivateKey{}
+		require.False(t, dbStore.db.Where(&GormPrivateKey{KeyID: keyID}).First(&dbPrivateKey).RecordNotFound())
+		require.Equal(t, alias, dbPrivateKey.PassphraseAlias)
+
+		passphrase, _, err := anotherRetriever(keyID, alias, false, 1)
+		require.NoError(t, err)
+		_, _, err = jose.Decode(dbPrivateKey.Private, passphrase)
+		require.NoError(t, err)
+	}
+}
+
+// Rotating a passphrase alias re-encrypts every key using the old alias with
+// the new one, in batches, and leaves keys using other aliases alone
+func TestRotatePassphraseAlias(t *testing.T) {
+	tmpFilename := initializeDB(t)
+	defer os.Remove(tmpFilename)
+
+	rotating := addKeysWithAlias(t, tmpFilename, "alias_1", 5)
+	others := addKeysWithAlias(t, tmpFilename, "alias_2", 2)
+
+	dbStore, err := NewKeyDBStore(anotherRetriever, "alias_2", "sqlite3", tmpFilename)
+	require.NoError(t, err)
+
+	var progress [][2]int
+	rotated, err := dbStore.RotatePassphraseAlias("alias_1", "alias_2", 2, func(rotated, total int) {
+		progress = append(progress, [2]int{rotated, total})
+	})
+	require.NoError(t, err)
+	require.Equal(t, 5, rotated)
+	require.Equal(t, [][2]int{{2, 5}, {4, 5}, {5, 5}}, progress)
+
+	requireKeysUseAlias(t, dbStore, "alias_2", append(rotating, others...))
+	for _, keyID := range rotating {
+		privKey, _, err := dbStore.GetKey(keyID)
+		require.NoError(t, err)
+		require.Equal(t, keyID, privKey.ID())
+	}
+
+	// there is nothing left to do
+	rotated, err = dbStore.RotatePassphraseAlias("alias_1", "alias_2", 2, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, rotated)
+}
+
+// If a rotation fails part way through, the batches that were re-encrypted stay
+// re-encrypted, and running it again finishes the rotation
+func TestRotatePassphraseAliasResumes(t *testing.T) {
+	tmpFilename := initializeDB(t)
+	defer os.Remove(tmpFilename)
+
+	keyIDs := addKeysWithAlias(t, tmpFilename, "alias_1", 4)
+
+	// the new passphrase becomes unavailable after the first batch
+	calls := 0
+	flakyRetriever := func(keyName, alias string, createNew bool, attempts int) (string, bool, error) {
+		if alias == "alias_2" {
+			calls++
+			if calls > 2 {
+				return "", false, errors.New("passphrase unavailable")
+			}
+		}
+		return anotherRetriever(keyName, alias, createNew, attempts)
+	}
+	dbStore, err := NewKeyDBStore(flakyRetriever, "alias_2", "sqlite3", tmpFilename)
+	require.NoError(t, err)
+
+	rotated, err := dbStore.RotatePassphraseAlias("alias_1", "alias_2", 2, nil)
+	require.Error(t, err)
+	require.Equal(t, 2, rotated)
+	requireKeysUseAlias(t, dbStore, "alias_2", keyIDs[:2])
+	requireKeysUseAlias(t, dbStore, "alias_1", keyIDs[2:])
+
+	dbStore.retriever = anotherRetriever
+	rotated, err = dbStore.RotatePassphraseAlias("alias_1", "alias_2", 2, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, rotated)
+	requireKeysUseAlias(t, dbStore, "alias_2", keyIDs)
+}
+
+func TestRotatePassphraseAliasInvalid(t *testing.T) {
+	tmpFilename := initializeDB(t)
+	defer os.Remove(tmpFilename)
+
+	dbStore, err := NewKeyDBStore(anotherRetriever, "alias_1", "sqlite3", tmpFilename)
+	require.NoError(t, err)
+
+	_, err = dbStore.RotatePassphraseAlias("alias_1", "alias_1", 2, nil)
+	require.Error(t, err)
+	_, err = dbStore.RotatePassphraseAlias("alias_1", "alias_2", 0, nil)
+	require.Error(t, err)
+}
+
 func TestDBHealthCheck(t *testing.T) {
 	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
 	defer os.RemoveAll(tempBaseDir)
@@ -214,3 +326,36 @@ func TestDBHealthCheck(t *testing.T) {
 	err = dbStore.HealthCheck()
 	require.Error(t, err, "Cannot access table:")
 }
+
+// CreateTable creates the private key table if it doesn't exist, and leaves
+// any existing keys alone if it does
+func TestCreateTable(t *testing.T) {
+	tempBaseDir, err := ioutil.TempDir("/tmp", "notary-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempBaseDir)
+
+	dbStore, err := NewKeyDBStore(retriever, "alias_1",
+		"sqlite3", filepath.Join(tempBaseDir, "test_db"))
+	require.NoError(t, err)
+
+	require.Error(t, dbStore.HealthCheck())
+	require.NoError(t, dbStore.CreateTable())
+	require.NoError(t, dbStore.HealthCheck())
+
+	gormKey := GormPrivateKey{
+		KeyID:           "keyID",
+		EncryptionAlg:   EncryptionAlg,
+		KeywrapAlg:      KeywrapAlg,
+		PassphraseAlias: "alias_1",
+		Algorithm:       data.ECDSAKey,
+		Public:          "public",
+		Private:         "private",
+	}
+	require.NoError(t, dbStore.db.Create(&gormKey).Error)
+
+	require.NoError(t, dbStore.CreateTable())
+
+	var count int
+	require.NoError(t, dbStore.db.Model(&GormPrivateKey{}).Count(&count).Error)
+	require.Equal(t, 1, count)
+}
@@ -19,6 +19,9 @@ type Client struct {
 	local  *tuf.Repo
 	remote store.RemoteStore
 	cache  store.MetadataStore
+
+	skipInvalid map[string]bool
+	invalid     map[string]*data.SignedTargets
 }
 
 // NewClient initialized a Client with the given repo, remote source of content, and cache
@@ -30,6 +33,25 @@ func NewClient(local *tuf.Repo, remote store.RemoteStore, cache store.MetadataSt
 	}
 }
 
+// SkipInvalid makes Update leave out the metadata for any of the given
+// delegation roles that fails verification, because it does not have enough
+// valid signatures or has expired, rather than failing the whole update.  The
+// metadata that was left out is returned by Invalid.
+func (c *Client) SkipInvalid(roles ...string) {
+	if c.skipInvalid == nil {
+		c.skipInvalid = make(map[string]bool)
+	}
+	for _, role := range roles {
+		c.skipInvalid[role] = true
+	}
+}
+
+// Invalid returns the metadata for the roles passed to SkipInvalid that was
+// left out by Update because it failed verification
+func (c *Client) Invalid() map[string]*data.SignedTargets {
+	return c.invalid
+}
+
 // Update performs an update to the TUF repo as defined by the TUF spec
 func (c *Client) Update() error {
 	// 1. Get timestamp
@@ -182,6 +204,11 @@ func (c *Client) downloadRoot() error {
 	var s *data.Signed
 	var raw []byte
 	if download {
+		// the new root may be several rotations on from the one we trust, so
+		// step through each root in between first
+		if err := c.walkRootChain(); err != nil {
+			return err
+		}
 		// use consistent download if we have the checksum.
 		raw, s, err = c.downloadSigned(role, size, expectedHashes)
 		if err != nil {
@@ -204,6 +231,63 @@ func (c *Client) downloadRoot() error {
 	return nil
 }
 
+// walkRootChain downloads each version of the root after the one currently
+// trusted in turn, and trusts it if it is signed by the root before it as well
+// as by itself, until it reaches a version the remote doesn't have.  The
+// latest root is still verified against the last root trusted here.  This
+// lets a client which has missed several root rotations verify the latest root.
+// Intermediate roots may have expired, since only the latest root is used.
+func (c *Client) walkRootChain() error {
+	if c.local.Root == nil {
+		return nil
+	}
+	for {
+		version := c.local.Root.Signed.Version + 1
+		raw, err := c.remote.GetMeta(fmt.Sprintf("%s.%d", data.CanonicalRootRole, version), -1)
+		if err != nil {
+			// either there are no more roots, or the remote doesn't serve
+			// roots by version, so the latest root must be signed by the
+			// root we trust
+			logrus.Debugf("stopped walking the root chain at version %d: %s", version, err)
+			return nil
+		}
+		s := &data.Signed{}
+		if err := json.Unmarshal(raw, s); err != nil {
+			return err
+		}
+		prevRootRole, err := c.local.GetBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return err
+		}
+		if err := signed.VerifySignatures(s, prevRootRole); err != nil {
+			logrus.Debugf("root version %d did not verify with the keys of version %d", version, version-1)
+			return err
+		}
+		root, err := data.RootFromSigned(s)
+		if err != nil {
+			return err
+		}
+		if root.Signed.Version != version {
+			// the remote doesn't really serve roots by version
+			logrus.Debugf("stopped walking the root chain: got root version %d when requesting version %d",
+				root.Signed.Version, version)
+			return nil
+		}
+		rootRole, err := root.BuildBaseRole(data.CanonicalRootRole)
+		if err != nil {
+			return err
+		}
+		if err := signed.VerifySignatures(s, rootRole); err != nil {
+			logrus.Debugf("root version %d did not verify with its own keys", version)
+			return err
+		}
+		logrus.Debugf("trusting root version %d", version)
+		if err := c.local.SetRoot(root); err != nil {
+			return err
+		}
+	}
+}
+
 func (c Client) verifyRoot(role string, s *data.Signed, minVersion int) error {
 	// this will confirm that the root has been signed by the old root role
 	// with the root keys we bootstrapped with.
@@ -281,8 +365,9 @@ func (c *Client) downloadTimestamp() error {
 		}
 	}
 	// unlike root, targets and snapshot, always try and download timestamps
-	// from remote, only using the cache one if we couldn't reach remote.
-	raw, s, err := c.downloadSigned(role, notary.MaxTimestampSize, nil)
+	// from remote, only using the cache one if we couldn't reach remote or
+	// the remote tells us it hasn't changed.
+	raw, s, err := c.downloadSignedIfModified(role, notary.MaxTimestampSize, cachedTS)
 	if err == nil {
 		ts, err = c.verifyTimestamp(s, version)
 		if err == nil {
@@ -422,6 +507,18 @@ func (c *Client) downloadTargets(role string) error {
 				// that's ok, continue
 				continue
 			}
+			if s != nil && c.skippable(role, err) {
+				logrus.Warnf("Skipping invalid %s metadata: %s", role, err)
+				t, err := data.TargetsFromSigned(s, role)
+				if err != nil {
+					return err
+				}
+				if c.invalid == nil {
+					c.invalid = make(map[string]*data.SignedTargets)
+				}
+				c.invalid[role] = t
+				continue
+			}
 			logrus.Error("Error getting targets file:", err)
 			return err
 		}
@@ -446,6 +543,19 @@ func (c *Client) downloadTargets(role string) error {
 	return nil
 }
 
+// skippable returns whether a role's metadata, which failed verification
+// with the given error, should be left out rather than failing the update
+func (c *Client) skippable(role string, err error) bool {
+	if !c.skipInvalid[role] || !data.IsDelegation(role) {
+		return false
+	}
+	switch err.(type) {
+	case signed.ErrRoleThreshold, signed.ErrExpired:
+		return true
+	}
+	return false
+}
+
 func (c *Client) downloadSigned(role string, size int64, expectedHashes data.Hashes) ([]byte, *data.Signed, error) {
 	rolePath := utils.ConsistentName(role, expectedHashes["sha256"])
 	raw, err := c.remote.GetMeta(rolePath, size)
@@ -467,6 +577,31 @@ func (c *Client) downloadSigned(role string, size int64, expectedHashes data.Has
 	return raw, s, nil
 }
 
+// downloadSignedIfModified is like downloadSigned, but if the remote supports
+// conditional requests and the remote metadata is the same as the cached copy,
+// the cached copy is returned rather than being downloaded again.  Either way,
+// the returned metadata still has to be verified.
+func (c *Client) downloadSignedIfModified(role string, size int64, cached []byte) ([]byte, *data.Signed, error) {
+	conditional, ok := c.remote.(store.ConditionalStore)
+	if !ok || cached == nil {
+		return c.downloadSigned(role, size, nil)
+	}
+	raw, err := conditional.GetMetaIfModified(role, size, cached)
+	if _, ok := err.(store.ErrNotModified); ok {
+		logrus.Debugf("remote %s has not been modified, using cached copy", role)
+		raw = cached
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	s := &data.Signed{}
+	err = json.Unmarshal(raw, s)
+	if err != nil {
+		return nil, nil, err
+	}
+	return raw, s, nil
+}
+
 func (c Client) getTargetsFile(role string, snapshotMeta data.Files, consistent bool) (*data.Signed, error) {
 	// require role exists in snapshots
 	roleMeta, ok := snapshotMeta[role]
@@ -532,7 +667,8 @@ func (c Client) getTargetsFile(role string, snapshotMeta data.Files, consistent
 		}
 	}
 	if err = signed.Verify(s, targetOrDelgRole, version); err != nil {
-		return nil, err
+		// the metadata is returned so that it can be re-signed if it is invalid
+		return s, err
 	}
 	logrus.Debugf("successfully verified %s", role)
 	if download {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/notary"
 	tuf "github.com/docker/notary/tuf"
 	"github.com/docker/notary/tuf/testutils"
 	"github.com/stretchr/testify/require"
@@ -467,6 +468,56 @@ func TestDownloadTargetsDeepHappy(t *testing.T) {
 	}
 }
 
+// Delegation metadata that fails verification, because the key that signed it
+// has been removed from the delegation, fails the download unless the role is
+// one whose invalid metadata should be skipped
+func TestDownloadTargetsSkipInvalid(t *testing.T) {
+	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	localStorage := store.NewMemoryStore(nil)
+	remoteStorage := store.NewMemoryStore(nil)
+	client := NewClient(repo, remoteStorage, localStorage)
+
+	oldKey, err := cs.Create("targets/a", "docker.com/notary", data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateDelegationKeys("targets/a", []data.PublicKey{oldKey}, []string{}, 1))
+	require.NoError(t, repo.UpdateDelegationPaths("targets/a", []string{""}, []string{}, false))
+	_, err = repo.AddTargets("targets/a", data.Files{"file": data.FileMeta{Length: 1, Hashes: data.Hashes{"sha256": []byte{1}}}})
+	require.NoError(t, err)
+
+	signedDelegation, err := repo.SignTargets("targets/a", data.DefaultExpires("targets"))
+	require.NoError(t, err)
+	delegationJSON, err := json.Marshal(signedDelegation)
+	require.NoError(t, err)
+	require.NoError(t, remoteStorage.SetMeta("targets/a", delegationJSON))
+
+	// replace the key that signed the delegation
+	newKey, err := cs.Create("targets/a", "docker.com/notary", data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateDelegationKeys("targets/a", []data.PublicKey{newKey}, []string{oldKey.ID()}, 1))
+	signedOrig, err := repo.SignTargets("targets", data.DefaultExpires("targets"))
+	require.NoError(t, err)
+	orig, err := json.Marshal(signedOrig)
+	require.NoError(t, err)
+	require.NoError(t, remoteStorage.SetMeta("targets", orig))
+	repo.SignSnapshot(data.DefaultExpires("snapshot"))
+
+	delete(repo.Targets, "targets")
+	delete(repo.Targets, "targets/a")
+	require.IsType(t, signed.ErrRoleThreshold{}, client.downloadTargets("targets"))
+	require.Len(t, client.Invalid(), 0)
+
+	client.SkipInvalid("targets/a")
+	require.NoError(t, client.downloadTargets("targets"))
+	_, ok := repo.Targets["targets/a"]
+	require.False(t, ok)
+	require.Len(t, client.Invalid(), 1)
+	invalid, ok := client.Invalid()["targets/a"]
+	require.True(t, ok)
+	_, ok = invalid.Signed.Targets["file"]
+	require.True(t, ok)
+}
+
 func TestDownloadTargetChecksumMismatch(t *testing.T) {
 	repo, _, err := testutils.EmptyRepo("docker.com/notary")
 	require.NoError(t, err)
@@ -594,6 +645,101 @@ func TestUpdateDownloadRootHappy(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// rotateRootTwice rotates the root key of the repo twice, uploading each new
+// root to the remote by version, so that the latest root is not signed by the
+// original root key.  It returns the original root.
+func rotateRootTwice(t *testing.T, repo *tuf.Repo, cs signed.CryptoService, remote store.MetadataStore) *data.SignedRoot {
+	signedOrig, err := repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole))
+	require.NoError(t, err)
+	orig, err := data.RootFromSigned(signedOrig)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		newKey, err := cs.Create(data.CanonicalRootRole, "docker.com/notary", data.ECDSAKey)
+		require.NoError(t, err)
+		require.NoError(t, repo.ReplaceBaseKeys(data.CanonicalRootRole, newKey))
+		signedRoot, err := repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole))
+		require.NoError(t, err)
+		rootJSON, err := json.Marshal(signedRoot)
+		require.NoError(t, err)
+		require.NoError(t, remote.SetMeta(fmt.Sprintf("root.%d", repo.Root.Signed.Version), rootJSON))
+		require.NoError(t, remote.SetMeta(data.CanonicalRootRole, rootJSON))
+		// the next root only needs to be signed by this one
+		require.NoError(t, repo.SetRoot(repo.Root))
+	}
+
+	// sign snapshot to make root meta in snapshot get updated
+	_, err = repo.SignSnapshot(data.DefaultExpires(data.CanonicalSnapshotRole))
+	require.NoError(t, err)
+	return orig
+}
+
+// A client which trusts a root from several rotations ago verifies each root
+// version in turn to get to the latest root
+func TestUpdateDownloadRootWalksRootChain(t *testing.T) {
+	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	remoteStorage := store.NewMemoryStore(nil)
+	orig := rotateRootTwice(t, repo, cs, remoteStorage)
+
+	local := tuf.NewRepo(nil)
+	require.NoError(t, local.SetRoot(orig))
+	local.SetSnapshot(repo.Snapshot)
+
+	client := NewClient(local, remoteStorage, store.NewMemoryStore(nil))
+	require.NoError(t, client.downloadRoot())
+	require.Equal(t, repo.Root.Signed.Version, local.Root.Signed.Version)
+	require.Equal(t, repo.Root.Signed.Roles[data.CanonicalRootRole].KeyIDs,
+		local.Root.Signed.Roles[data.CanonicalRootRole].KeyIDs)
+}
+
+// Without the intermediate roots, the latest root can't be verified by a client
+// which trusts a root from several rotations ago
+func TestUpdateDownloadRootMissingRootChain(t *testing.T) {
+	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	remoteStorage := store.NewMemoryStore(nil)
+	orig := rotateRootTwice(t, repo, cs, remoteStorage)
+	require.NoError(t, remoteStorage.RemoveMeta(fmt.Sprintf("root.%d", repo.Root.Signed.Version-1)))
+
+	local := tuf.NewRepo(nil)
+	require.NoError(t, local.SetRoot(orig))
+	local.SetSnapshot(repo.Snapshot)
+
+	client := NewClient(local, remoteStorage, store.NewMemoryStore(nil))
+	require.Error(t, client.downloadRoot())
+	require.Equal(t, orig.Signed.Version, local.Root.Signed.Version)
+}
+
+// A root served for a version must be signed by the root before it, and is only
+// trusted if it has that version
+func TestUpdateDownloadRootChainInvalid(t *testing.T) {
+	repo, cs, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+	remoteStorage := store.NewMemoryStore(nil)
+	orig := rotateRootTwice(t, repo, cs, remoteStorage)
+
+	next := fmt.Sprintf("root.%d", orig.Signed.Version+1)
+	latest, err := remoteStorage.GetMeta(data.CanonicalRootRole, -1)
+	require.NoError(t, err)
+
+	// the latest root is not signed by the original root key
+	require.NoError(t, remoteStorage.SetMeta(next, latest))
+	local := tuf.NewRepo(nil)
+	require.NoError(t, local.SetRoot(orig))
+	local.SetSnapshot(repo.Snapshot)
+	client := NewClient(local, remoteStorage, store.NewMemoryStore(nil))
+	require.IsType(t, signed.ErrRoleThreshold{}, client.walkRootChain())
+
+	// the original root is correctly signed, but has the wrong version, so
+	// it's not trusted in place of itself
+	origJSON, err := json.Marshal(orig)
+	require.NoError(t, err)
+	require.NoError(t, remoteStorage.SetMeta(next, origJSON))
+	require.NoError(t, client.walkRootChain())
+	require.Equal(t, orig.Signed.Version, local.Root.Signed.Version)
+}
+
 func TestUpdateDownloadRootBadChecksum(t *testing.T) {
 	remoteStore := testutils.NewCorruptingMemoryStore(nil)
 
@@ -894,6 +1040,69 @@ func TestDownloadTimestampLocalTimestampInvalidRemoteTimestamp(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// conditionalStore is a remote store that supports conditional requests, and
+// counts how many times metadata was actually sent
+type conditionalStore struct {
+	store.RemoteStore
+	sent int
+}
+
+func (c *conditionalStore) GetMetaIfModified(name string, size int64, cached []byte) ([]byte, error) {
+	meta, err := c.GetMeta(name, size)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(meta, cached) {
+		return nil, store.ErrNotModified{Resource: name}
+	}
+	c.sent++
+	return meta, nil
+}
+
+func mustMarshalTimestamp(t *testing.T, repo *tuf.Repo) []byte {
+	s, err := repo.Timestamp.ToSigned()
+	require.NoError(t, err)
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	return b
+}
+
+// If the remote timestamp is the same as the cached one, the cached copy is used
+// without being downloaded again, and if it is different it is downloaded
+func TestDownloadTimestampNotModified(t *testing.T) {
+	repo, _, err := testutils.EmptyRepo("docker.com/notary")
+	require.NoError(t, err)
+
+	tsSigned, err := repo.SignTimestamp(data.DefaultExpires("timestamp"))
+	require.NoError(t, err)
+	ts, err := json.Marshal(tsSigned)
+	require.NoError(t, err)
+	localStorage := store.NewMemoryStore(map[string][]byte{data.CanonicalTimestampRole: ts})
+	remoteStorage := &conditionalStore{
+		RemoteStore: store.NewMemoryStore(map[string][]byte{data.CanonicalTimestampRole: ts}),
+	}
+
+	client := NewClient(repo, remoteStorage, localStorage)
+	require.NoError(t, client.downloadTimestamp())
+	require.Equal(t, 0, remoteStorage.sent)
+	require.Equal(t, ts, mustMarshalTimestamp(t, repo))
+
+	// publish a new timestamp
+	repo.Timestamp.Signed.Version++
+	tsSigned, err = repo.SignTimestamp(data.DefaultExpires("timestamp"))
+	require.NoError(t, err)
+	newTS, err := json.Marshal(tsSigned)
+	require.NoError(t, err)
+	require.NoError(t, remoteStorage.SetMeta(data.CanonicalTimestampRole, newTS))
+
+	require.NoError(t, client.downloadTimestamp())
+	require.Equal(t, 1, remoteStorage.sent)
+	require.Equal(t, newTS, mustMarshalTimestamp(t, repo))
+	cached, err := localStorage.GetMeta(data.CanonicalTimestampRole, notary.MaxTimestampSize)
+	require.NoError(t, err)
+	require.Equal(t, newTS, cached)
+}
+
 // GetSupportedHashes is a helper function that returns
 // the checksums of all the supported hash algorithms
 // of the given payload.
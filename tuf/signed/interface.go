@@ -38,6 +38,26 @@ type CryptoService interface {
 	KeyService
 }
 
+// SignatureRequest asks a BatchSigner to sign Content with the key with the
+// (canonical) ID KeyID
+type SignatureRequest struct {
+	KeyID   string
+	Content []byte
+}
+
+// BatchSigner may be implemented by a CryptoService that can produce several
+// signatures at once more cheaply than one at a time, for instance in a single
+// round-trip to a remote signing service.  Sign batches the signatures for
+// one piece of metadata, one per key, so batching only helps roles with more
+// than one key.
+type BatchSigner interface {
+	// SignMany produces a signature for each of the requests, which need not
+	// be for the same key or content.  It returns a signature and an error
+	// for each request, in the same order as the requests, so that one
+	// request failing does not affect the rest.
+	SignMany(requests []SignatureRequest) ([][]byte, []error)
+}
+
 // Verifier defines an interface for verfying signatures. An implementer
 // of this interface should verify signatures for one and only one
 // signing scheme.
@@ -32,6 +32,7 @@ func Sign(service CryptoService, s *data.Signed, keys ...data.PublicKey) error {
 	ids := make([]string, 0, len(keys))
 
 	privKeys := make(map[string]data.PrivateKey)
+	canonicalIDs := make(map[string]string)
 
 	// Get all the private key objects related to the public keys
 	for _, key := range keys {
@@ -46,6 +47,7 @@ func Sign(service CryptoService, s *data.Signed, keys ...data.PublicKey) error {
 			continue
 		}
 		privKeys[key.ID()] = k
+		canonicalIDs[key.ID()] = canonicalID
 	}
 
 	// Check to ensure we have at least one signing key
@@ -54,16 +56,11 @@ func Sign(service CryptoService, s *data.Signed, keys ...data.PublicKey) error {
 	}
 
 	// Do signing and generate list of signatures
-	for keyID, pk := range privKeys {
-		sig, err := pk.Sign(rand.Reader, *s.Signed, nil)
-		if err != nil {
-			logrus.Debugf("Failed to sign with key: %s. Reason: %v", keyID, err)
-			continue
-		}
+	for keyID, sig := range signWithKeys(service, privKeys, canonicalIDs, *s.Signed) {
 		signingKeyIDs[keyID] = struct{}{}
 		signatures = append(signatures, data.Signature{
 			KeyID:     keyID,
-			Method:    pk.SignatureAlgorithm(),
+			Method:    privKeys[keyID].SignatureAlgorithm(),
 			Signature: sig[:],
 		})
 	}
@@ -101,3 +98,40 @@ func Sign(service CryptoService, s *data.Signed, keys ...data.PublicKey) error {
 	s.Signatures = signatures
 	return nil
 }
+
+// signWithKeys signs msg with each of the private keys, which are indexed by
+// TUF key ID, and returns the signatures that could be produced by TUF key ID.
+// If the service is a BatchSigner, the signatures are all requested at once,
+// which only saves anything when there is more than one key.
+func signWithKeys(service CryptoService, privKeys map[string]data.PrivateKey,
+	canonicalIDs map[string]string, msg []byte) map[string][]byte {
+
+	sigs := make(map[string][]byte, len(privKeys))
+	if batchSigner, ok := service.(BatchSigner); ok {
+		keyIDs := make([]string, 0, len(privKeys))
+		requests := make([]SignatureRequest, 0, len(privKeys))
+		for keyID := range privKeys {
+			keyIDs = append(keyIDs, keyID)
+			requests = append(requests, SignatureRequest{KeyID: canonicalIDs[keyID], Content: msg})
+		}
+		batchSigs, errs := batchSigner.SignMany(requests)
+		for i, keyID := range keyIDs {
+			if errs[i] != nil {
+				logrus.Debugf("Failed to sign with key: %s. Reason: %v", keyID, errs[i])
+				continue
+			}
+			sigs[keyID] = batchSigs[i]
+		}
+		return sigs
+	}
+
+	for keyID, pk := range privKeys {
+		sig, err := pk.Sign(rand.Reader, msg, nil)
+		if err != nil {
+			logrus.Debugf("Failed to sign with key: %s. Reason: %v", keyID, err)
+			continue
+		}
+		sigs[keyID] = sig
+	}
+	return sigs
+}
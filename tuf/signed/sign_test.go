@@ -178,6 +178,73 @@ func TestMultiSign(t *testing.T) {
 	require.Equal(t, 2, count)
 }
 
+// A CryptoService which signs in batches, failing to sign with any of the
+// keys in refuse
+type BatchCryptoService struct {
+	*Ed25519
+	batches [][]SignatureRequest
+	refuse  map[string]bool
+}
+
+func (bcs *BatchCryptoService) SignMany(requests []SignatureRequest) ([][]byte, []error) {
+	bcs.batches = append(bcs.batches, requests)
+	sigs := make([][]byte, len(requests))
+	errs := make([]error, len(requests))
+	for i, request := range requests {
+		if bcs.refuse[request.KeyID] {
+			errs[i] = trustmanager.ErrKeyNotFound{KeyID: request.KeyID}
+			continue
+		}
+		privKey, _, err := bcs.GetPrivateKey(request.KeyID)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		sigs[i], errs[i] = privKey.Sign(rand.Reader, request.Content, nil)
+	}
+	return sigs, errs
+}
+
+// Signing with several keys using a BatchSigner requests all the signatures at
+// once, and keeps every signature that could be produced
+func TestSignBatch(t *testing.T) {
+	cs := &BatchCryptoService{Ed25519: NewEd25519(), refuse: make(map[string]bool)}
+	var keys []data.PublicKey
+	for i := 0; i < 3; i++ {
+		key, err := cs.Create(data.CanonicalRootRole, "", data.ED25519Key)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	cs.refuse[keys[2].ID()] = true
+
+	raw := json.RawMessage("{}")
+	testData := data.Signed{Signed: &raw}
+	require.NoError(t, Sign(cs, &testData, keys...))
+	require.Len(t, cs.batches, 1)
+	require.Len(t, cs.batches[0], 3)
+	for _, request := range cs.batches[0] {
+		require.Equal(t, []byte(raw), request.Content)
+	}
+
+	require.Len(t, testData.Signatures, 2)
+	for _, sig := range testData.Signatures {
+		require.NotEqual(t, keys[2].ID(), sig.KeyID)
+		require.Equal(t, data.EDDSASignature, sig.Method)
+		for _, key := range keys[:2] {
+			if key.ID() == sig.KeyID {
+				require.NoError(t, VerifySignature(raw, sig, key))
+			}
+		}
+	}
+
+	// a single key is requested in a batch of its own
+	testData = data.Signed{Signed: &raw}
+	require.NoError(t, Sign(cs, &testData, keys[0]))
+	require.Len(t, cs.batches, 2)
+	require.Equal(t, []SignatureRequest{{KeyID: keys[0].ID(), Content: raw}}, cs.batches[1])
+	require.Len(t, testData.Signatures, 1)
+}
+
 func TestSignReturnsNoSigs(t *testing.T) {
 	failingCryptoService := &FailingCryptoService{}
 	testData := data.Signed{
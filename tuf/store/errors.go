@@ -11,3 +11,22 @@ type ErrMetaNotFound struct {
 func (err ErrMetaNotFound) Error() string {
 	return fmt.Sprintf("%s trust data unavailable.  Has a notary repository been initialized?", err.Resource)
 }
+
+// ErrNotModified indicates that the remote copy of a particular piece of
+// metadata is the same as the copy the caller already has
+type ErrNotModified struct {
+	Resource string
+}
+
+func (err ErrNotModified) Error() string {
+	return fmt.Sprintf("%s trust data has not been modified.", err.Resource)
+}
+
+// ErrOldVersion indicates that the remote server rejected an update because
+// it already has a newer version of the metadata, most likely because someone
+// else published to the repository in the meantime
+type ErrOldVersion struct{}
+
+func (err ErrOldVersion) Error() string {
+	return "trust server already has a newer version of the trust data."
+}
@@ -12,6 +12,8 @@ package store
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -100,6 +102,10 @@ func NewHTTPStore(baseURL, metaPrefix, metaExtension, keyExtension string, round
 	}, nil
 }
 
+// oldVersionErrorCode is the error code the notary server responds with when
+// it already has a newer version of the metadata being updated
+const oldVersionErrorCode = "VERSION"
+
 func tryUnmarshalError(resp *http.Response, defaultError error) error {
 	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -107,7 +113,8 @@ func tryUnmarshalError(resp *http.Response, defaultError error) error {
 	}
 	var parsedErrors struct {
 		Errors []struct {
-			Detail validation.SerializableError `json:"detail"`
+			Code   string          `json:"code"`
+			Detail json.RawMessage `json:"detail"`
 		} `json:"errors"`
 	}
 	if err := json.Unmarshal(bodyBytes, &parsedErrors); err != nil {
@@ -116,7 +123,14 @@ func tryUnmarshalError(resp *http.Response, defaultError error) error {
 	if len(parsedErrors.Errors) != 1 {
 		return defaultError
 	}
-	err = parsedErrors.Errors[0].Detail.Error
+	if parsedErrors.Errors[0].Code == oldVersionErrorCode {
+		return ErrOldVersion{}
+	}
+	var detail validation.SerializableError
+	if err := json.Unmarshal(parsedErrors.Errors[0].Detail, &detail); err != nil {
+		return defaultError
+	}
+	err = detail.Error
 	if err == nil {
 		return defaultError
 	}
@@ -141,6 +155,19 @@ func translateStatusToError(resp *http.Response, resource string) error {
 // not an exact length.
 // If size is -1, this corresponds to "infinite," but we cut off at 100MB
 func (s HTTPStore) GetMeta(name string, size int64) ([]byte, error) {
+	return s.getMeta(name, size, "")
+}
+
+// GetMetaIfModified downloads the named meta file like GetMeta, but sends the
+// checksum of the cached copy as the entity tag the server should compare the
+// current metadata with.  If the server responds that it has not been
+// modified, ErrNotModified is returned and the cached copy should be used.
+func (s HTTPStore) GetMetaIfModified(name string, size int64, cached []byte) ([]byte, error) {
+	checksum := sha256.Sum256(cached)
+	return s.getMeta(name, size, hex.EncodeToString(checksum[:]))
+}
+
+func (s HTTPStore) getMeta(name string, size int64, etag string) ([]byte, error) {
 	url, err := s.buildMetaURL(name)
 	if err != nil {
 		return nil, err
@@ -149,11 +176,18 @@ func (s HTTPStore) GetMeta(name string, size int64) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", fmt.Sprintf("%q", etag))
+	}
 	resp, err := s.roundTrip.RoundTrip(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		logrus.Debugf("%s has not been modified since it was cached", name)
+		return nil, ErrNotModified{Resource: name}
+	}
 	if err := translateStatusToError(resp, name); err != nil {
 		logrus.Debugf("received HTTP status %d when requesting %s.", resp.StatusCode, name)
 		return nil, err
@@ -242,9 +276,23 @@ func (s HTTPStore) SetMultiMeta(metas map[string][]byte) error {
 	return translateStatusToError(resp, "POST metadata endpoint")
 }
 
-// RemoveAll in the interface is not supported, admins should use the DeleteHandler endpoint directly to delete remote data for a GUN
+// RemoveAll deletes all the remote data for the GUN.  The server requires
+// push and pull access to the GUN to do so.
 func (s HTTPStore) RemoveAll() error {
-	return errors.New("remove all functionality not supported for HTTPStore")
+	url, err := s.buildMetaURL("")
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", url.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.roundTrip.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return translateStatusToError(resp, "DELETE metadata endpoint")
 }
 
 func (s HTTPStore) buildMetaURL(name string) (*url.URL, error) {
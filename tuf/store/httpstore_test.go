@@ -2,6 +2,7 @@ package store
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -127,6 +128,40 @@ func TestHTTPStoreGetAllMeta(t *testing.T) {
 	}
 }
 
+// GetMetaIfModified sends the checksum of the cached copy as the entity tag,
+// and returns ErrNotModified if the server responds with a 304
+func TestHTTPStoreGetMetaIfModified(t *testing.T) {
+	checksum := sha256.Sum256([]byte(testRoot))
+	etag := fmt.Sprintf("%q", hex.EncodeToString(checksum[:]))
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(testRoot))
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	store, err := NewHTTPStore(server.URL, "metadata", "json", "key", &http.Transport{})
+	require.NoError(t, err)
+	conditional, ok := store.(ConditionalStore)
+	require.True(t, ok)
+
+	_, err = conditional.GetMetaIfModified("root", 4801, []byte(testRoot))
+	require.Error(t, err)
+	require.IsType(t, ErrNotModified{}, err)
+
+	j, err := conditional.GetMetaIfModified("root", 4801, []byte("stale"))
+	require.NoError(t, err)
+	require.Equal(t, testRoot, string(j))
+
+	// GetMeta is never conditional
+	j, err = store.GetMeta("root", 4801)
+	require.NoError(t, err)
+	require.Equal(t, testRoot, string(j))
+}
+
 func TestSetMultiMeta(t *testing.T) {
 	metas := map[string][]byte{
 		"root":    []byte("root data"),
@@ -269,6 +304,17 @@ func TestTranslateErrorsParse400Errors(t *testing.T) {
 	require.Equal(t, origErr, finalError)
 }
 
+// If it's a 400 because the server already has a newer version of the
+// metadata, translateStatusToError returns an ErrOldVersion
+func TestTranslateErrorsOldVersion(t *testing.T) {
+	errorResp := http.Response{
+		StatusCode: http.StatusBadRequest,
+		Body: ioutil.NopCloser(bytes.NewBuffer([]byte(
+			`{"errors": [{"code": "VERSION", "message": "newer", "detail": {}}]}`))),
+	}
+	require.Equal(t, ErrOldVersion{}, translateStatusToError(&errorResp, ""))
+}
+
 // If it's a 400, translateStatusToError attempts to parse the body into
 // an error.  If parsing fails, an InvalidOperation is returned instead.
 func TestTranslateErrorsWhenCannotParse400(t *testing.T) {
@@ -291,20 +337,35 @@ func TestTranslateErrorsWhenCannotParse400(t *testing.T) {
 	}
 }
 
+// RemoveAll sends a DELETE to the metadata endpoint for the GUN
 func TestHTTPStoreRemoveAll(t *testing.T) {
-	// Set up a simple handler and server for our store
+	var method, path string
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(testRoot))
+		method, path = r.Method, r.URL.Path
 	}
 	server := httptest.NewServer(http.HandlerFunc(handler))
 	defer server.Close()
-	store, err := NewHTTPStore(server.URL, "metadata", "json", "key", http.DefaultTransport)
+	store, err := NewHTTPStore(server.URL+"/v2/gun/_trust/tuf/", "", "json", "key", http.DefaultTransport)
+	require.NoError(t, err)
+
+	require.NoError(t, store.RemoveAll())
+	require.Equal(t, "DELETE", method)
+	require.Equal(t, "/v2/gun/_trust/tuf/", path)
+}
+
+// Failures to delete the remote data are returned
+func TestHTTPStoreRemoveAllFails(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	store, err := NewHTTPStore(server.URL+"/v2/gun/_trust/tuf/", "", "json", "key", http.DefaultTransport)
 	require.NoError(t, err)
 
-	// currently unsupported since there is no use case
-	// check for the error
 	err = store.RemoveAll()
 	require.Error(t, err)
+	require.IsType(t, ErrServerUnavailable{}, err)
 }
 
 func TestHTTPOffline(t *testing.T) {
@@ -26,3 +26,11 @@ type RemoteStore interface {
 	MetadataStore
 	PublicKeyStore
 }
+
+// ConditionalStore is implemented by remote stores that can avoid sending
+// metadata the caller already has a copy of
+type ConditionalStore interface {
+	// GetMetaIfModified is like GetMeta, but returns ErrNotModified if the
+	// metadata is the same as the cached copy
+	GetMetaIfModified(name string, size int64, cached []byte) ([]byte, error)
+}
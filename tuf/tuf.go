@@ -62,6 +62,16 @@ type Repo struct {
 	Snapshot      *data.SignedSnapshot
 	Timestamp     *data.SignedTimestamp
 	cryptoService signed.CryptoService
+
+	// originalRootRole is the root role as it was when the root was loaded
+	// or initialized, before any key changes were applied.  A rotated root
+	// must also be signed by these keys so that clients that trust the old
+	// root can verify the new one.
+	originalRootRole data.BaseRole
+
+	// unsignedChanges allows changes to roles for which there are no signing
+	// keys available, because the metadata will be signed elsewhere
+	unsignedChanges bool
 }
 
 // NewRepo initializes a Repo instance with a CryptoService.
@@ -75,6 +85,13 @@ func NewRepo(cryptoService signed.CryptoService) *Repo {
 	return repo
 }
 
+// AllowUnsignedChanges lets targets and delegations be changed even when there
+// are no signing keys for the role available locally, for metadata which will
+// be exported and signed elsewhere.
+func (tr *Repo) AllowUnsignedChanges() {
+	tr.unsignedChanges = true
+}
+
 // AddBaseKeys is used to add keys to the role in root.json
 func (tr *Repo) AddBaseKeys(role string, keys ...data.PublicKey) error {
 	if tr.Root == nil {
@@ -84,8 +101,11 @@ func (tr *Repo) AddBaseKeys(role string, keys ...data.PublicKey) error {
 	for _, k := range keys {
 		// Store only the public portion
 		tr.Root.Signed.Keys[k.ID()] = k
-		tr.Root.Signed.Roles[role].KeyIDs = append(tr.Root.Signed.Roles[role].KeyIDs, k.ID())
 		ids = append(ids, k.ID())
+		if utils.StrSliceContains(tr.Root.Signed.Roles[role].KeyIDs, k.ID()) {
+			continue
+		}
+		tr.Root.Signed.Roles[role].KeyIDs = append(tr.Root.Signed.Roles[role].KeyIDs, k.ID())
 	}
 	tr.Root.Dirty = true
 
@@ -118,7 +138,37 @@ func (tr *Repo) ReplaceBaseKeys(role string, keys ...data.PublicKey) error {
 	if err != nil {
 		return err
 	}
-	return tr.AddBaseKeys(role, keys...)
+	if err = tr.AddBaseKeys(role, keys...); err != nil {
+		return err
+	}
+	// the role can no longer require more signatures than it has keys
+	if rootRole := tr.Root.Signed.Roles[role]; len(keys) > 0 && rootRole.Threshold > len(rootRole.KeyIDs) {
+		rootRole.Threshold = len(rootRole.KeyIDs)
+	}
+	return nil
+}
+
+// SetBaseThreshold sets the number of signatures required for the given role
+// in root.json.  The threshold cannot be greater than the number of keys in
+// the role.
+func (tr *Repo) SetBaseThreshold(role string, threshold int) error {
+	if tr.Root == nil {
+		return ErrNotLoaded{Role: data.CanonicalRootRole}
+	}
+	rootRole, ok := tr.Root.Signed.Roles[role]
+	if !ok {
+		return data.ErrInvalidRole{Role: role, Reason: "invalid base role name"}
+	}
+	if threshold < notary.MinThreshold || threshold > len(rootRole.KeyIDs) {
+		return data.ErrInvalidRole{
+			Role: role,
+			Reason: fmt.Sprintf("threshold %d must be at least %d and at most the number of keys (%d)",
+				threshold, notary.MinThreshold, len(rootRole.KeyIDs)),
+		}
+	}
+	rootRole.Threshold = threshold
+	tr.Root.Dirty = true
+	return nil
 }
 
 // RemoveBaseKeys is used to remove keys from the roles in root.json
@@ -128,13 +178,13 @@ func (tr *Repo) RemoveBaseKeys(role string, keyIDs ...string) error {
 	}
 	var keep []string
 	toDelete := make(map[string]struct{})
-	// remove keys from specified role
 	for _, k := range keyIDs {
 		toDelete[k] = struct{}{}
-		for _, rk := range tr.Root.Signed.Roles[role].KeyIDs {
-			if k != rk {
-				keep = append(keep, rk)
-			}
+	}
+	// remove keys from specified role
+	for _, rk := range tr.Root.Signed.Roles[role].KeyIDs {
+		if _, ok := toDelete[rk]; !ok {
+			keep = append(keep, rk)
 		}
 	}
 	tr.Root.Signed.Roles[role].KeyIDs = keep
@@ -289,12 +339,18 @@ func delegationUpdateVisitor(roleName string, addKeys data.KeyList, removeKeys,
 				}
 				delgRole.AddPaths(addPaths)
 				delgRole.RemoveKeys(removeKeys)
+				if newThreshold > 0 {
+					delgRole.Threshold = newThreshold
+				}
 				break
 			}
 		}
 		// We didn't find the role earlier, so create it only if we have keys to add
 		if delgRole == nil {
 			if len(addKeys) > 0 {
+				if newThreshold <= 0 {
+					newThreshold = notary.MinThreshold
+				}
 				delgRole, err = data.NewRole(roleName, newThreshold, addKeys.IDs(), addPaths)
 				if err != nil {
 					return err
@@ -336,6 +392,9 @@ func delegationUpdateVisitor(roleName string, addKeys data.KeyList, removeKeys,
 // a new delegation or updating an existing one. If keys are
 // provided, the IDs will be added to the role (if they do not exist
 // there already), and the keys will be added to the targets file.
+// If newThreshold is greater than zero it becomes the role's threshold,
+// otherwise an existing role keeps its threshold and a new role is
+// created with the minimum threshold.
 func (tr *Repo) UpdateDelegationKeys(roleName string, addKeys data.KeyList, removeKeys []string, newThreshold int) error {
 	if !data.IsDelegation(roleName) {
 		return data.ErrInvalidRole{Role: roleName, Reason: "not a valid delegated role"}
@@ -388,7 +447,7 @@ func (tr *Repo) UpdateDelegationPaths(roleName string, addPaths, removePaths []s
 	// Walk to the parent of this delegation, since that is where its role metadata exists
 	// We do not have to verify that the walker reached its desired role in this scenario
 	// since we've already done another walk to the parent role in VerifyCanSign
-	err := tr.WalkTargets("", parent, delegationUpdateVisitor(roleName, data.KeyList{}, []string{}, addPaths, removePaths, clearPaths, notary.MinThreshold))
+	err := tr.WalkTargets("", parent, delegationUpdateVisitor(roleName, data.KeyList{}, []string{}, addPaths, removePaths, clearPaths, 0))
 	if err != nil {
 		return err
 	}
@@ -459,6 +518,7 @@ func (tr *Repo) InitRoot(root, timestamp, snapshot, targets data.BaseRole, consi
 		return err
 	}
 	tr.Root = r
+	tr.originalRootRole = root
 	return nil
 }
 
@@ -518,7 +578,11 @@ func (tr *Repo) InitTimestamp() error {
 // SetRoot sets the Repo.Root field to the SignedRoot object.
 func (tr *Repo) SetRoot(s *data.SignedRoot) error {
 	tr.Root = s
-	return nil
+	var err error
+	// record the root role before any mutations are made to tr.Root, so
+	// that a root rotation can be signed by the old keys too
+	tr.originalRootRole, err = tr.Root.BuildBaseRole(data.CanonicalRootRole)
+	return err
 }
 
 // SetTimestamp parses the Signed object into a SignedTimestamp object
@@ -593,6 +657,9 @@ func (tr *Repo) VerifyCanSign(roleName string) error {
 	if err != nil {
 		return data.ErrInvalidRole{Role: roleName, Reason: "does not exist"}
 	}
+	if tr.unsignedChanges {
+		return nil
+	}
 
 	for keyID, k := range role.Keys {
 		check := []string{keyID}
@@ -799,7 +866,9 @@ func (tr *Repo) SignRoot(expires time.Time) (*data.Signed, error) {
 	if err != nil {
 		return nil, err
 	}
-	signed, err = tr.sign(signed, root)
+	// if the root keys have been rotated, also sign with the previous root
+	// keys so that the new root chains to the old one
+	signed, err = tr.sign(signed, root, tr.originalRootRole)
 	if err != nil {
 		return nil, err
 	}
@@ -918,8 +987,18 @@ func (tr *Repo) SignTimestamp(expires time.Time) (*data.Signed, error) {
 	return signed, nil
 }
 
-func (tr Repo) sign(signedData *data.Signed, role data.BaseRole) (*data.Signed, error) {
-	if err := signed.Sign(tr.cryptoService, signedData, role.ListKeys()...); err != nil {
+func (tr Repo) sign(signedData *data.Signed, roles ...data.BaseRole) (*data.Signed, error) {
+	keys := make(map[string]data.PublicKey)
+	for _, role := range roles {
+		for keyID, key := range role.Keys {
+			keys[keyID] = key
+		}
+	}
+	keyList := make(data.KeyList, 0, len(keys))
+	for _, key := range keys {
+		keyList = append(keyList, key)
+	}
+	if err := signed.Sign(tr.cryptoService, signedData, keyList...); err != nil {
 		return nil, err
 	}
 	return signedData, nil
@@ -292,6 +292,50 @@ func TestUpdateDelegationsAddKeyToRole(t *testing.T) {
 	require.True(t, r.Dirty)
 }
 
+// A delegation's threshold is only changed when a new threshold is given, and
+// can never be more than the number of keys in the delegation
+func TestUpdateDelegationsThreshold(t *testing.T) {
+	ed25519 := signed.NewEd25519()
+	repo := initRepo(t, ed25519)
+
+	testKey, err := ed25519.Create("targets/test", testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	testKey2, err := ed25519.Create("targets/test", testGUN, data.ED25519Key)
+	require.NoError(t, err)
+
+	getThreshold := func() int {
+		delgRole, err := repo.GetDelegationRole("targets/test")
+		require.NoError(t, err)
+		return delgRole.Threshold
+	}
+
+	// a new delegation defaults to the minimum threshold
+	require.NoError(t, repo.UpdateDelegationKeys("targets/test", []data.PublicKey{testKey}, []string{}, 0))
+	require.Equal(t, 1, getThreshold())
+
+	require.NoError(t, repo.UpdateDelegationKeys("targets/test", []data.PublicKey{testKey2}, []string{}, 2))
+	require.Equal(t, 2, getThreshold())
+
+	// updating the paths, or the keys without a threshold, keeps the threshold
+	require.NoError(t, repo.UpdateDelegationPaths("targets/test", []string{"test"}, []string{}, false))
+	require.Equal(t, 2, getThreshold())
+	require.NoError(t, repo.UpdateDelegationKeys("targets/test", []data.PublicKey{testKey2}, []string{}, 0))
+	require.Equal(t, 2, getThreshold())
+
+	// the threshold can't be more than the number of keys
+	err = repo.UpdateDelegationKeys("targets/test", []data.PublicKey{}, []string{}, 3)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	err = repo.UpdateDelegationKeys("targets/test", []data.PublicKey{}, []string{testKey2.ID()}, 0)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+	require.Equal(t, 2, getThreshold())
+
+	// removing a key and lowering the threshold at the same time is fine
+	require.NoError(t, repo.UpdateDelegationKeys("targets/test", []data.PublicKey{}, []string{testKey2.ID()}, 1))
+	require.Equal(t, 1, getThreshold())
+}
+
 func TestDeleteDelegations(t *testing.T) {
 	ed25519 := signed.NewEd25519()
 	repo := initRepo(t, ed25519)
@@ -678,6 +722,38 @@ func TestAddTargetsNoSigningKeys(t *testing.T) {
 	require.IsType(t, signed.ErrNoKeys{}, err)
 }
 
+// Adding targets to a role for which there are no signing keys succeeds once
+// unsigned changes have been allowed, but the role still can't be signed.
+func TestAddTargetsAllowUnsignedChanges(t *testing.T) {
+	hash := sha256.Sum256([]byte{})
+	f := data.FileMeta{
+		Length: 1,
+		Hashes: map[string][]byte{
+			"sha256": hash[:],
+		},
+	}
+
+	ed25519 := signed.NewEd25519()
+	repo := initRepo(t, ed25519)
+	repo.cryptoService = signed.NewEd25519()
+
+	_, err := repo.AddTargets(data.CanonicalTargetsRole, data.Files{"f": f})
+	require.IsType(t, signed.ErrNoKeys{}, err)
+
+	repo.AllowUnsignedChanges()
+	_, err = repo.AddTargets(data.CanonicalTargetsRole, data.Files{"f": f})
+	require.NoError(t, err)
+	_, ok := repo.Targets[data.CanonicalTargetsRole].Signed.Targets["f"]
+	require.True(t, ok)
+
+	// roles which don't exist are still rejected
+	_, err = repo.AddTargets("targets/test", data.Files{"f": f})
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	_, err = repo.SignTargets(data.CanonicalTargetsRole, data.DefaultExpires(data.CanonicalTargetsRole))
+	require.Error(t, err)
+}
+
 // Removing targets from a role that exists, has targets, and is signable
 // should succeed, even if we also want to remove targets that don't exist.
 func TestRemoveExistingAndNonexistingTargets(t *testing.T) {
@@ -790,6 +866,98 @@ func TestAddBaseKeysToRoot(t *testing.T) {
 	}
 }
 
+// removing multiple keys from a role removes all of them, and keeps the rest
+func TestRemoveBaseKeysFromRoot(t *testing.T) {
+	ed25519 := signed.NewEd25519()
+	repo := initRepo(t, ed25519)
+
+	origKeyIDs := repo.Root.Signed.Roles[data.CanonicalTargetsRole].KeyIDs
+	key1, err := ed25519.Create(data.CanonicalTargetsRole, testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	key2, err := ed25519.Create(data.CanonicalTargetsRole, testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddBaseKeys(data.CanonicalTargetsRole, key1, key2))
+	require.Len(t, repo.Root.Signed.Roles[data.CanonicalTargetsRole].KeyIDs, 3)
+
+	require.NoError(t, repo.RemoveBaseKeys(data.CanonicalTargetsRole, key1.ID(), key2.ID()))
+	require.Equal(t, origKeyIDs, repo.Root.Signed.Roles[data.CanonicalTargetsRole].KeyIDs)
+	for _, k := range []data.PublicKey{key1, key2} {
+		_, ok := repo.Root.Signed.Keys[k.ID()]
+		require.False(t, ok)
+	}
+}
+
+// the threshold for a base role must be between one and the number of keys in
+// the role, and adding a key the role already has does not count it twice
+func TestSetBaseThreshold(t *testing.T) {
+	ed25519 := signed.NewEd25519()
+	repo := initRepo(t, ed25519)
+	repo.Root.Dirty = false
+
+	key, err := ed25519.Create(data.CanonicalTargetsRole, testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.AddBaseKeys(data.CanonicalTargetsRole, key, key))
+	require.Len(t, repo.Root.Signed.Roles[data.CanonicalTargetsRole].KeyIDs, 2)
+	repo.Root.Dirty = false
+
+	for _, threshold := range []int{0, 3} {
+		err := repo.SetBaseThreshold(data.CanonicalTargetsRole, threshold)
+		require.Error(t, err)
+		require.IsType(t, data.ErrInvalidRole{}, err)
+		require.False(t, repo.Root.Dirty)
+	}
+
+	err = repo.SetBaseThreshold("targets/a", 1)
+	require.Error(t, err)
+	require.IsType(t, data.ErrInvalidRole{}, err)
+
+	require.NoError(t, repo.SetBaseThreshold(data.CanonicalTargetsRole, 2))
+	require.Equal(t, 2, repo.Root.Signed.Roles[data.CanonicalTargetsRole].Threshold)
+	require.True(t, repo.Root.Dirty)
+
+	// replacing the keys with fewer keys lowers the threshold accordingly
+	require.NoError(t, repo.ReplaceBaseKeys(data.CanonicalTargetsRole, key))
+	require.Equal(t, 1, repo.Root.Signed.Roles[data.CanonicalTargetsRole].Threshold)
+
+	// the threshold can't be set without a root
+	repo.Root = nil
+	err = repo.SetBaseThreshold(data.CanonicalTargetsRole, 1)
+	require.Error(t, err)
+	require.IsType(t, ErrNotLoaded{}, err)
+}
+
+// replacing the root keys results in a root signed by both the old and the
+// new root keys
+func TestSignRootAfterRootKeyRotation(t *testing.T) {
+	ed25519 := signed.NewEd25519()
+	repo := initRepo(t, ed25519)
+
+	oldRootRole, err := repo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+
+	newKey, err := ed25519.Create(data.CanonicalRootRole, testGUN, data.ED25519Key)
+	require.NoError(t, err)
+	require.NoError(t, repo.ReplaceBaseKeys(data.CanonicalRootRole, newKey))
+
+	newRootRole, err := repo.GetBaseRole(data.CanonicalRootRole)
+	require.NoError(t, err)
+	require.Equal(t, []string{newKey.ID()}, newRootRole.ListKeyIDs())
+
+	signedRoot, err := repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole))
+	require.NoError(t, err)
+	require.Len(t, signedRoot.Signatures, 2)
+	require.NoError(t, signed.VerifySignatures(signedRoot, oldRootRole))
+	require.NoError(t, signed.VerifySignatures(signedRoot, newRootRole))
+
+	// once the rotated root has been loaded, subsequent signing only uses the
+	// new root key
+	require.NoError(t, repo.SetRoot(repo.Root))
+	signedRoot, err = repo.SignRoot(data.DefaultExpires(data.CanonicalRootRole))
+	require.NoError(t, err)
+	require.Len(t, signedRoot.Signatures, 1)
+	require.Equal(t, newKey.ID(), signedRoot.Signatures[0].KeyID)
+}
+
 func TestGetAllRoles(t *testing.T) {
 	ed25519 := signed.NewEd25519()
 	repo := initRepo(t, ed25519)
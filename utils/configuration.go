@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
@@ -112,6 +113,10 @@ func ParseStorage(configuration *viper.Viper, allowedBackends []string) (*Storag
 		return nil, fmt.Errorf(
 			"must provide a non-empty database source for %s", store.Backend)
 	}
+	if store.Backend == SqliteBackend {
+		// the source is the path to the database file
+		store.Source = GetPathRelativeToConfig(configuration, "storage.db_url")
+	}
 	return &store, nil
 }
 
@@ -173,3 +178,14 @@ func ParseViper(v *viper.Viper, configFile string) error {
 	}
 	return nil
 }
+
+// GlobToRegexp converts a glob from the configuration, such as a GUN glob, in
+// which "*" matches any sequence of characters (including "/"), to an anchored
+// regular expression
+func GlobToRegexp(glob string) *regexp.Regexp {
+	parts := strings.Split(glob, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
@@ -220,6 +220,21 @@ func TestParseStorageDBStore(t *testing.T) {
 	require.Equal(t, expected, *store)
 }
 
+// A SQLite database file is relative to the config file
+func TestParseStorageSqliteRelativeToConfigFile(t *testing.T) {
+	config := configure(`{
+		"storage": {
+			"backend": "sqlite3",
+			"db_url": "data/notary.db"
+		}
+	}`)
+	config.SetConfigFile("/etc/notary/config.json")
+
+	store, err := ParseStorage(config, []string{SqliteBackend})
+	require.NoError(t, err)
+	require.Equal(t, Storage{Backend: SqliteBackend, Source: "/etc/notary/data/notary.db"}, *store)
+}
+
 func TestParseStorageWithEnvironmentVariables(t *testing.T) {
 	config := configure(`{
 		"storage": {
@@ -388,3 +403,11 @@ func TestParseViperWithValidFile(t *testing.T) {
 
 	require.Equal(t, "debug", v.GetString("logging.level"))
 }
+
+func TestGlobToRegexp(t *testing.T) {
+	require.True(t, GlobToRegexp("docker.io/*").MatchString("docker.io/library/alpine"))
+	require.False(t, GlobToRegexp("docker.io/*").MatchString("dockerXio/library"))
+	require.True(t, GlobToRegexp("*/alpine").MatchString("docker.io/library/alpine"))
+	require.True(t, GlobToRegexp("exact").MatchString("exact"))
+	require.False(t, GlobToRegexp("exact").MatchString("exactly"))
+}
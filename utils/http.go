@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -156,9 +157,13 @@ type cacheControlResponseWriter struct {
 }
 
 // WriteHeader stores the header before writing it, so we can tell if it's been set
-// to a non-200 status code
+// to a non-200 status code.  A 304 has no body to write, so the cache headers are
+// set here so that caches can refresh their copy.
 func (c *cacheControlResponseWriter) WriteHeader(statusCode int) {
 	c.statusCode = statusCode
+	if statusCode == http.StatusNotModified {
+		c.setCacheHeaders()
+	}
 	c.ResponseWriter.WriteHeader(statusCode)
 }
 
@@ -166,14 +171,18 @@ func (c *cacheControlResponseWriter) WriteHeader(statusCode int) {
 // code has either not been set or set to 200
 func (c *cacheControlResponseWriter) Write(data []byte) (int, error) {
 	if c.statusCode == http.StatusOK || c.statusCode == 0 {
-		headers := c.ResponseWriter.Header()
-		if headers.Get("Cache-Control") == "" {
-			c.config.SetHeaders(headers)
-		}
+		c.setCacheHeaders()
 	}
 	return c.ResponseWriter.Write(data)
 }
 
+func (c *cacheControlResponseWriter) setCacheHeaders() {
+	headers := c.ResponseWriter.Header()
+	if headers.Get("Cache-Control") == "" {
+		c.config.SetHeaders(headers)
+	}
+}
+
 type cacheControlHandler struct {
 	http.Handler
 	config CacheControlConfig
@@ -197,3 +206,35 @@ func WrapWithCacheHandler(ccc CacheControlConfig, handler http.Handler) http.Han
 func SetLastModifiedHeader(headers http.Header, lmt time.Time) {
 	headers.Set("Last-Modified", lmt.Format(time.RFC1123))
 }
+
+// SetETagHeader sets the ETag header to the given entity tag, quoting it as
+// required by RFC 7232
+func SetETagHeader(headers http.Header, etag string) {
+	headers.Set("ETag", fmt.Sprintf("%q", etag))
+}
+
+// NotModified returns whether a conditional GET request can be answered with a
+// 304 because the client already has the entity with the given (unquoted) tag,
+// which was last modified at the given time.  As per RFC 7232, If-Modified-Since
+// is ignored if the request has an If-None-Match header.
+func NotModified(r *http.Request, etag string, lastModified *time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || strings.Trim(tag, `"`) == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && lastModified != nil {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			// clients may echo back the Last-Modified header set by SetLastModifiedHeader
+			since, err = time.Parse(time.RFC1123, ims)
+		}
+		// HTTP dates only have a granularity of seconds
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
@@ -252,3 +252,43 @@ func TestWrapWithCacheHeaderNoCacheControlCacheControlHeader(t *testing.T) {
 	nowToNearestSecond := now.Add(time.Duration(-1 * now.Nanosecond()))
 	require.True(t, lastModified.Equal(nowToNearestSecond))
 }
+
+// If the wrapped handler responds with a 304, the cache headers are set even
+// though nothing is written
+func TestWrapWithCacheHeaderNotModifiedResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+
+	req := &http.Request{URL: &url.URL{Path: "/"}, Body: ioutil.NopCloser(bytes.NewBuffer(nil))}
+	rw := httptest.NewRecorder()
+	WrapWithCacheHandler(NewCacheControlConfig(10, true), mux).ServeHTTP(rw, req)
+
+	require.Equal(t, http.StatusNotModified, rw.Code)
+	require.Equal(t, "public, max-age=10, s-maxage=10, must-revalidate", rw.HeaderMap.Get("Cache-Control"))
+	require.NotEqual(t, "", rw.HeaderMap.Get("Last-Modified"))
+}
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Now()
+	request := func(header http.Header) *http.Request {
+		return &http.Request{Header: header}
+	}
+
+	require.False(t, NotModified(request(nil), "abc", &lastModified))
+	require.True(t, NotModified(request(http.Header{"If-None-Match": {`"abc"`}}), "abc", &lastModified))
+	require.True(t, NotModified(request(http.Header{"If-None-Match": {`"x", W/"abc"`}}), "abc", nil))
+	require.True(t, NotModified(request(http.Header{"If-None-Match": {"*"}}), "abc", nil))
+	require.False(t, NotModified(request(http.Header{"If-None-Match": {`"abcd"`}}), "abc", nil))
+
+	since := lastModified.UTC().Format(http.TimeFormat)
+	require.True(t, NotModified(request(http.Header{"If-Modified-Since": {since}}), "abc", &lastModified))
+	// there is nothing to compare to if the last modified time is not known
+	require.False(t, NotModified(request(http.Header{"If-Modified-Since": {since}}), "abc", nil))
+	earlier := lastModified.Add(-time.Minute).UTC().Format(http.TimeFormat)
+	require.False(t, NotModified(request(http.Header{"If-Modified-Since": {earlier}}), "abc", &lastModified))
+	// If-None-Match takes precedence
+	require.False(t, NotModified(
+		request(http.Header{"If-None-Match": {`"x"`}, "If-Modified-Since": {since}}), "abc", &lastModified))
+}